@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"strings"
+	"text/tabwriter"
+)
+
+// adminCommand executes the "admin create|reset-password|list" command, used to create the first
+// administrator and to recover the access of an administrator
+func (c *commands) adminCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("admin: use admin create|reset-password|list")
+	}
+	fs := flag.NewFlagSet("admin "+args[0], flag.ContinueOnError)
+	fs.SetOutput(c.out)
+	switch args[0] {
+	case "create":
+		name := fs.String("name", c.admin.Name, "the name of the administrator")
+		email := fs.String("email", c.admin.Email, "the email of the administrator")
+		roles := fs.String("roles", strings.Join(c.admin.Roles, ","), "the comma-separated roles of the administrator besides administrator")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return c.adminCreate(ctx, *name, *email, splitRoles(*roles))
+	case "reset-password":
+		email := fs.String("email", "", "the email of the administrator")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return c.adminResetPassword(ctx, *email)
+	case "list":
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return c.adminList(ctx)
+	}
+	return fmt.Errorf("admin: unknown command %q - use create, reset-password or list", args[0])
+}
+
+// splitRoles returns the roles of a comma-separated list
+func splitRoles(s string) []string {
+	roles := []string{}
+	for _, r := range strings.Split(s, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+// adminCreate inserts an active user with the administrator roles and the password read from the input
+func (c *commands) adminCreate(ctx context.Context, name, email string, others []string) error {
+	if name == "" || email == "" {
+		return errors.New("admin create: the name and the email are required")
+	}
+	roles, err := adminRoles(ctx, c.store.Users, others)
+	if err != nil {
+		return fmt.Errorf("admin create: %v", err)
+	}
+	// the password is not asked for an email that is taken
+	_, err = c.store.Users.GetByEmail(ctx, email)
+	if err == nil {
+		return fmt.Errorf("admin create: a user with the email %s exists - use admin reset-password", email)
+	}
+	if !errors.Is(err, models.ErrNoRecord) {
+		return fmt.Errorf("admin create: %v", err)
+	}
+	password, err := c.newPassword(name, email)
+	if err != nil {
+		return fmt.Errorf("admin create: %v", err)
+	}
+	err = c.store.Users.Insert(ctx, name, email, password, roles)
+	if errors.Is(err, models.ErrDuplicateEmail) {
+		return fmt.Errorf("admin create: a user with the email %s exists - use admin reset-password", email)
+	}
+	if err != nil {
+		return fmt.Errorf("admin create: %v", err)
+	}
+	c.infoLog.Printf("admin: created the administrator %s\n", email)
+	return nil
+}
+
+// adminResetPassword replaces the password of the administrator with the one read from the input
+func (c *commands) adminResetPassword(ctx context.Context, email string) error {
+	if email == "" {
+		return errors.New("admin reset-password: the email is required")
+	}
+	u, err := c.store.Users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return fmt.Errorf("admin reset-password: no user with the email %s", email)
+		}
+		return fmt.Errorf("admin reset-password: %v", err)
+	}
+	if !isAdmin(u) {
+		return fmt.Errorf("admin reset-password: the user %s is not an administrator", email)
+	}
+	password, err := c.newPassword(u.Name, u.Email)
+	if err != nil {
+		return fmt.Errorf("admin reset-password: %v", err)
+	}
+	if err = c.store.Users.ResetPassword(ctx, u.ID, password); err != nil {
+		return fmt.Errorf("admin reset-password: %v", err)
+	}
+	c.infoLog.Printf("admin: reset the password of %s\n", email)
+	return nil
+}
+
+// adminList writes the users with the AministratorRole
+func (c *commands) adminList(ctx context.Context) error {
+	users, err := c.store.Users.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("admin list: %v", err)
+	}
+	w := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tEMAIL\tACTIVE\tCREATED")
+	for _, u := range users {
+		if isAdmin(u) {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%v\t%s\n", u.ID, u.Name, u.Email, u.Active, u.Created.Format("2006-01-02 15:04:05"))
+		}
+	}
+	return w.Flush()
+}
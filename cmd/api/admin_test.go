@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+// newTestCommands returns the commands on the in-memory storage, the passwords are read from the lines of input
+func newTestCommands(t *testing.T, input string) (*commands, *bytes.Buffer) {
+	t.Helper()
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	hasher, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discard := log.New(ioutil.Discard, "", 0)
+	store, err := openStorage(discard, discard, &configType{DBDriver: DriverMemory}, hasher, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := models.NewPasswordPolicy(models.PasswordPolicyData{MinLength: 10, DisallowPersonal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	return &commands{
+		in:      strings.NewReader(input),
+		out:     &out,
+		infoLog: discard,
+		store:   store,
+		admin:   adminData{Name: "Administrator", Roles: []string{"user"}},
+		policy:  policy,
+	}, &out
+}
+
+func TestAdminCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		input     string
+		wantErr   string
+		wantRoles []string
+	}{
+		{"Valid", []string{"-name", "Carol", "-email", "carol@example.com"}, "Pa$$word-1234\nPa$$word-1234\n",
+			"", []string{models.AministratorRole, "user"}},
+		{"Without the last newline", []string{"-name", "Carol", "-email", "carol@example.com"}, "Pa$$word-1234\nPa$$word-1234",
+			"", []string{models.AministratorRole, "user"}},
+		{"Roles", []string{"-name", "Carol", "-email", "carol@example.com", "-roles", ""}, "Pa$$word-1234\nPa$$word-1234\n",
+			"", []string{models.AministratorRole}},
+		{"Unknown role", []string{"-name", "Carol", "-email", "carol@example.com", "-roles", "user,unknown"}, "Pa$$word-1234\nPa$$word-1234\n",
+			`role "unknown" not found`, nil},
+		{"No email", []string{"-name", "Carol"}, "", "the name and the email are required", nil},
+		{"Duplicate email", []string{"-name", "Alice", "-email", "alice@example.com"}, "Pa$$word-1234\nPa$$word-1234\n",
+			"a user with the email alice@example.com exists", nil},
+		{"Password of the policy", []string{"-name", "Carol", "-email", "carol@example.com"}, "Carol-1234\nCarol-1234\n",
+			"invalid password", nil},
+		{"Passwords not matching", []string{"-name", "Carol", "-email", "carol@example.com"}, "Pa$$word-1234\nPa$$word-5678\n",
+			"the passwords do not match", nil},
+		{"No confirmation", []string{"-name", "Carol", "-email", "carol@example.com"}, "Pa$$word-1234\n", "EOF", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, out := newTestCommands(t, tt.input)
+			ctx := context.Background()
+			if err := c.store.Users.Insert(ctx, "Alice", "alice@example.com", "Pa$$word-1234", nil); err != nil {
+				t.Fatal(err)
+			}
+
+			err := c.run(ctx, append([]string{"admin", "create"}, tt.args...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("want the error %q; got %v", tt.wantErr, err)
+				}
+				if _, err := c.store.Users.GetByEmail(ctx, "carol@example.com"); err == nil {
+					t.Error("want no administrator created")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the prompts are written without the password
+			if got := out.String(); got != "Password: Confirm password: " {
+				t.Errorf("want the prompts only; got %q", got)
+			}
+			id, err := c.store.Users.Authenticate(ctx, "carol@example.com", "Pa$$word-1234")
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := c.store.Users.Get(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Name != "Carol" || !u.Active {
+				t.Errorf("want the active user Carol; got %q active %v", u.Name, u.Active)
+			}
+			if strings.Join(u.Roles, ",") != strings.Join(tt.wantRoles, ",") {
+				t.Errorf("want the roles %v; got %v", tt.wantRoles, u.Roles)
+			}
+		})
+	}
+}
+
+func TestAdminResetPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		input    string
+		wantErr  string
+		password string
+	}{
+		{"Valid", "admin@example.com", "N3w-Pa$$word\nN3w-Pa$$word\n", "", "N3w-Pa$$word"},
+		{"No email", "", "", "the email is required", "Pa$$word-1234"},
+		{"Unknown email", "unknown@example.com", "", "no user with the email unknown@example.com", "Pa$$word-1234"},
+		{"Not an administrator", "alice@example.com", "N3w-Pa$$word\nN3w-Pa$$word\n", "is not an administrator", "Pa$$word-1234"},
+		{"Password of the policy", "admin@example.com", "short\nshort\n", "invalid password", "Pa$$word-1234"},
+		{"Passwords not matching", "admin@example.com", "N3w-Pa$$word\nOther-Pa$$word\n", "the passwords do not match", "Pa$$word-1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, out := newTestCommands(t, tt.input)
+			ctx := context.Background()
+			roles, err := adminRoles(ctx, c.store.Users, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = c.store.Users.Insert(ctx, "Administrator", "admin@example.com", "Pa$$word-1234", roles); err != nil {
+				t.Fatal(err)
+			}
+			if err = c.store.Users.Insert(ctx, "Alice", "alice@example.com", "Pa$$word-1234", nil); err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.run(ctx, []string{"admin", "reset-password", "-email", tt.email})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("want the error %q; got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(out.String(), tt.password) {
+				t.Errorf("want the password not written; got %q", out.String())
+			}
+			if _, err = c.store.Users.Authenticate(ctx, "admin@example.com", tt.password); err != nil {
+				t.Errorf("want the login with %q; got %v", tt.password, err)
+			}
+		})
+	}
+}
+
+func TestAdminList(t *testing.T) {
+	c, out := newTestCommands(t, "")
+	ctx := context.Background()
+	roles, err := adminRoles(ctx, c.store.Users, []string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.store.Users.Insert(ctx, "Administrator", "admin@example.com", "Pa$$word-1234", roles); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.store.Users.Insert(ctx, "Alice", "alice@example.com", "Pa$$word-1234", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.run(ctx, []string{"admin", "list"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "admin@example.com") {
+		t.Errorf("want the header and the administrator; got %q", out.String())
+	}
+}
+
+func TestAdminCommandErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"Unknown command", []string{"unknown"}, `unknown command "unknown"`},
+		{"No admin command", []string{"admin"}, "use admin create|reset-password|list"},
+		{"Unknown admin command", []string{"admin", "delete"}, `unknown command "delete"`},
+		{"Unknown flag", []string{"admin", "create", "-unknown"}, "flag provided but not defined"},
+		{"Migrate without migrations", []string{"migrate", "up"}, "has no migrations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCommands(t, "")
+			err := c.run(context.Background(), tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("want the error %q; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSeedAdminRoles(t *testing.T) {
+	c, out := newTestCommands(t, "")
+	ctx := context.Background()
+	admin := adminData{Name: "Administrator", Email: "admin@example.com", Roles: []string{"unknown"}}
+
+	// the roles of the configuration must exist
+	err := seedAdmin(ctx, c.infoLog, out, c.store.Users, admin)
+	if err == nil || !strings.Contains(err.Error(), `role "unknown" not found`) {
+		t.Errorf("want the error of the unknown role; got %v", err)
+	}
+	admin.Roles = []string{"user"}
+	if err = seedAdmin(ctx, c.infoLog, out, c.store.Users, admin); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "one-time password of the administrator admin@example.com") {
+		t.Errorf("want the one-time password written; got %q", out.String())
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"golang.org/x/term"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// commands holds the dependencies of the commands given on the command line after the flags
+type commands struct {
+	in      io.Reader // the passwords are read without echo when it is a terminal
+	reader  *bufio.Reader
+	out     io.Writer
+	infoLog *log.Logger
+	store   *storage
+	admin   adminData
+	policy  *models.PasswordPolicy
+}
+
+// run executes the command of the arguments, "migrate up|down|status" or "admin create|reset-password|list"
+func (c *commands) run(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return c.migrate(ctx, args[1:])
+	case "admin":
+		return c.adminCommand(ctx, args[1:])
+	}
+	return fmt.Errorf("run: unknown command %q - use migrate or admin", args[0])
+}
+
+// readPassword prompts for a password on the output, the password is not echoed on a terminal.
+// The password is read from a line of the input when it is not a terminal, as on a script.
+func (c *commands) readPassword(prompt string) (string, error) {
+	fmt.Fprint(c.out, prompt)
+	if f, ok := c.in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		b, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(c.out)
+		return string(b), err
+	}
+	if c.reader == nil {
+		c.reader = bufio.NewReader(c.in)
+	}
+	line, err := c.reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// newPassword prompts for a new password of the user and its confirmation,
+// the password must follow the password policy
+func (c *commands) newPassword(name, email string) (string, error) {
+	password, err := c.readPassword("Password: ")
+	if err != nil {
+		return "", err
+	}
+	msgs, err := c.policy.Check(password, name, email)
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) > 0 {
+		return "", fmt.Errorf("invalid password: %s", strings.Join(msgs, ", "))
+	}
+	confirm, err := c.readPassword("Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm != password {
+		return "", errors.New("the passwords do not match")
+	}
+	return password, nil
+}
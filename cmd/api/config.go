@@ -2,9 +2,13 @@ package main
 
 import (
 	"github.com/spf13/viper"
+	"github.com/vgraveto/snippets/pkg/mailer"
 	"github.com/vgraveto/snippets/pkg/models"
 	"github.com/vgraveto/snippets/pkg/models/dbmysql"
+	"github.com/vgraveto/snippets/pkg/models/dbpostgres"
+	"github.com/vgraveto/snippets/pkg/models/dbsqlite"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -21,8 +25,48 @@ type configType struct {
 	deadlineWaitForClose time.Duration // number of seconds
 	sessionLifetime      time.Duration // number of hours
 
-	// Database connection data
-	DB dbmysql.DBdata
+	// Database connection data of the DBDriver, DB is used by the DriverMySQL
+	DBDriver  string
+	DBMigrate bool          // apply the pending migrations and create the Admin on startup
+	DBHealth  time.Duration // number of seconds between the health checks of the database
+	DB        dbmysql.DBdata
+	Postgres  dbpostgres.DBdata
+	SQLite    dbsqlite.DBdata
+
+	// Initial administrator account
+	Admin adminData
+
+	// Mail delivery data
+	Mail                mailer.MailData
+	ResetPasswordURL    string
+	ResetTokenValidTime time.Duration // number of minutes
+
+	// Public registration data
+	Registration models.RegistrationData
+
+	// OpenID Connect login data
+	OIDCEnabled bool
+	OIDC        models.OIDCData
+
+	// Two-factor authentication data
+	MFAIssuer string
+
+	// Failed logins throttling data
+	Throttle       models.ThrottleData
+	ThrottleStore  string
+	TrustedProxies []string
+
+	// Password policy data
+	Password models.PasswordPolicyData
+
+	// Password hashing data
+	Hasher models.HasherData
+
+	// Snippets cache data
+	CacheEnabled bool
+	CacheSize    int           // number of cached keys
+	CacheTTL     time.Duration // number of seconds
+	MaxAge       time.Duration // number of seconds
 }
 
 func readConfig(errorLog *log.Logger, path, filename string) (globalData configType) {
@@ -40,19 +84,97 @@ func readConfig(errorLog *log.Logger, path, filename string) (globalData configT
 	if !viper.IsSet("token.issuerName") {
 		log.Fatalf("Key/Value not set in file %s - token.issuerName", filename)
 	}
-	if !viper.IsSet("token.validTime") {
-		log.Fatalf("Key/Value not set in file %s - token.validTime", filename)
+	// token.validTime (hours) is the former key of the access token valid time, it is still
+	// read when token.accessValidTime is not set and then it is the refresh token default
+	if !viper.IsSet("token.accessValidTime") && !viper.IsSet("token.validTime") {
+		log.Fatalf("Key/Value not set in file %s - token.accessValidTime", filename)
+	}
+	if !viper.IsSet("token.refreshValidTime") && !viper.IsSet("token.validTime") {
+		log.Fatalf("Key/Value not set in file %s - token.refreshValidTime", filename)
+	}
+	if viper.IsSet("token.validTime") {
+		log.Printf("Key token.validTime in file %s is deprecated - use token.accessValidTime and token.refreshValidTime", filename)
+	}
+	viper.SetDefault("dbase.driver", DriverMySQL)
+	viper.SetDefault("dbase.path", "snippets.db")
+	viper.SetDefault("dbase.sslMode", "verify-full")
+	viper.SetDefault("dbase.tlsMode", "verify-full")
+	viper.SetDefault("dbase.queryTimeout", 5)
+	viper.SetDefault("dbase.migrate", false)
+	viper.SetDefault("dbase.maxOpenConns", 25)
+	viper.SetDefault("dbase.maxIdleConns", 25)
+	viper.SetDefault("dbase.connMaxIdleTime", 300)
+	viper.SetDefault("dbase.retryAttempts", 3)
+	viper.SetDefault("dbase.retryBackoff", 100)
+	viper.SetDefault("dbase.healthInterval", 30)
+	viper.SetDefault("dbase.maxReplicaLag", 5)
+	viper.SetDefault("dbase.replicaCheckInterval", 5)
+	viper.SetDefault("admin.name", "Administrator")
+	viper.SetDefault("admin.roles", []string{"user"})
+	viper.SetDefault("token.algorithm", models.TokenAlgHS256)
+	if viper.GetString("token.algorithm") == models.TokenAlgHS256 {
+		if !viper.IsSet("token.signingKey") {
+			log.Fatalf("Key/Value not set in file %s - token.signingKey", filename)
+		}
+	} else if !viper.IsSet("token.privateKey") {
+		log.Fatalf("Key/Value not set in file %s - token.privateKey", filename)
 	}
-	if !viper.IsSet("token.signingKey") {
-		log.Fatalf("Key/Value not set in file %s - token.signingKey", filename)
+	viper.SetDefault("mail.resetTokenValidTime", 60)
+	viper.SetDefault("registration.mode", models.RegistrationClosed)
+	viper.SetDefault("registration.defaultRole", "user")
+	viper.SetDefault("registration.verifyTokenValidTime", 1440)
+	viper.SetDefault("oidc.groupsClaim", "groups")
+	viper.SetDefault("mfa.issuer", "Snippets")
+	viper.SetDefault("throttle.store", "database")
+	viper.SetDefault("throttle.freeAttempts", 3)
+	viper.SetDefault("throttle.lockoutAttempts", 10)
+	viper.SetDefault("throttle.ipFreeAttempts", 20)
+	viper.SetDefault("throttle.ipLockoutAttempts", 100)
+	viper.SetDefault("throttle.baseDelay", 1)
+	viper.SetDefault("throttle.maxDelay", 300)
+	viper.SetDefault("throttle.lockoutTime", 15)
+	viper.SetDefault("throttle.resetAfter", 60)
+	viper.SetDefault("password.minLength", 10)
+	viper.SetDefault("password.maxLength", 64)
+	viper.SetDefault("password.minClasses", 3)
+	viper.SetDefault("password.disallowPersonal", true)
+	viper.SetDefault("cache.enabled", true)
+	viper.SetDefault("cache.size", 1000)
+	viper.SetDefault("cache.ttl", 60)
+	viper.SetDefault("cache.maxAge", 60)
+	hd := models.DefaultHasherData()
+	viper.SetDefault("password.hashAlgorithm", hd.Algorithm)
+	viper.SetDefault("password.bcryptCost", hd.BcryptCost)
+	viper.SetDefault("password.argon2Time", hd.Argon2Time)
+	viper.SetDefault("password.argon2Memory", hd.Argon2Memory)
+	viper.SetDefault("password.argon2Threads", hd.Argon2Threads)
+	if viper.GetBool("oidc.enabled") {
+		if !viper.IsSet("oidc.issuer") {
+			log.Fatalf("Key/Value not set in file %s - oidc.issuer", filename)
+		}
+		if !viper.IsSet("oidc.clientId") {
+			log.Fatalf("Key/Value not set in file %s - oidc.clientId", filename)
+		}
 	}
 	// TODO implement all required checks for config file
 
 	globalData.HttpPort = viper.GetString("global.httpPort")
 
 	globalData.TD.TokenIssuerName = viper.GetString("token.issuerName")
-	globalData.TD.TokenValidTime = time.Duration(viper.GetInt("token.validTime")) * time.Hour
+	globalData.TD.TokenAudience = viper.GetString("token.audience")
+	validTime := time.Duration(viper.GetInt("token.validTime")) * time.Hour
+	globalData.TD.TokenValidTime = validTime
+	if viper.IsSet("token.accessValidTime") {
+		globalData.TD.TokenValidTime = time.Duration(viper.GetInt("token.accessValidTime")) * time.Minute
+	}
+	globalData.TD.TokenRefreshValidTime = validTime
+	if viper.IsSet("token.refreshValidTime") {
+		globalData.TD.TokenRefreshValidTime = time.Duration(viper.GetInt("token.refreshValidTime")) * time.Hour
+	}
+	globalData.TD.TokenAlgorithm = viper.GetString("token.algorithm")
 	globalData.TD.TokenSigningKey = viper.GetString("token.signingKey")
+	globalData.TD.TokenPrivateKey = viper.GetString("token.privateKey")
+	globalData.TD.TokenVerificationKeys = viper.GetStringSlice("token.verificationKeys")
 
 	globalData.HttpIdleTimeout = time.Duration(viper.GetInt("api.httoIdleTimeout")) * time.Second
 	globalData.HttpReadTimeout = time.Duration(viper.GetInt("api.httpReadTimeout")) * time.Second
@@ -60,15 +182,141 @@ func readConfig(errorLog *log.Logger, path, filename string) (globalData configT
 	globalData.deadlineWaitForClose = time.Duration(viper.GetInt("api.deadlineWaitForClose")) * time.Second
 	globalData.sessionLifetime = time.Duration(viper.GetInt("api.sessionLifetime")) * time.Hour
 
+	globalData.DBDriver = viper.GetString("dbase.driver")
+	switch globalData.DBDriver {
+	case DriverMySQL, DriverPostgres, DriverSQLite, DriverMemory:
+	default:
+		log.Fatalf("Invalid value in file %s - dbase.driver: %q", filename, globalData.DBDriver)
+	}
+	globalData.DBMigrate = viper.GetBool("dbase.migrate")
+	globalData.DBHealth = time.Duration(viper.GetInt("dbase.healthInterval")) * time.Second
+	if globalData.DBHealth <= 0 {
+		log.Fatalf("Invalid value in file %s - dbase.healthInterval: %v", filename, globalData.DBHealth)
+	}
+	globalData.Postgres.Server = viper.GetString("dbase.server")
+	globalData.Postgres.Dbase = viper.GetString("dbase.database")
+	globalData.Postgres.Username = viper.GetString("dbase.username")
+	globalData.Postgres.Password = viper.GetString("dbase.password")
+	globalData.Postgres.SSLMode = viper.GetString("dbase.sslMode")
+	globalData.Postgres.ServerCA = viper.GetString("dbase.serverCA")
+	globalData.Postgres.DbConnMaxLifetime = time.Duration(viper.GetInt("dbase.dbConnMaxLifetime")) * time.Second
+	globalData.Postgres.QueryTimeout = time.Duration(viper.GetInt("dbase.queryTimeout")) * time.Second
+	globalData.Postgres.MaxOpenConns = viper.GetInt("dbase.maxOpenConns")
+	globalData.Postgres.MaxIdleConns = viper.GetInt("dbase.maxIdleConns")
+	globalData.Postgres.ConnMaxIdleTime = time.Duration(viper.GetInt("dbase.connMaxIdleTime")) * time.Second
+	globalData.SQLite.Path = viper.GetString("dbase.path")
+	globalData.SQLite.QueryTimeout = time.Duration(viper.GetInt("dbase.queryTimeout")) * time.Second
 	globalData.DB.Protocol = viper.GetString("dbase.protocol")
 	globalData.DB.Server = viper.GetString("dbase.server")
 	globalData.DB.Dbase = viper.GetString("dbase.database")
 	globalData.DB.Username = viper.GetString("dbase.username")
 	globalData.DB.Password = viper.GetString("dbase.password")
+	globalData.DB.TLSMode = viper.GetString("dbase.tlsMode")
+	globalData.DB.ServerName = viper.GetString("dbase.serverName")
 	globalData.DB.ServerCA = viper.GetString("dbase.serverCA")
 	globalData.DB.ClientCert = viper.GetString("dbase.clientCert")
 	globalData.DB.ClientKey = viper.GetString("dbase.clientKey")
 	globalData.DB.DbConnMaxLifetime = time.Duration(viper.GetInt("dbase.dbConnMaxLifetime")) * time.Second
+	globalData.DB.QueryTimeout = time.Duration(viper.GetInt("dbase.queryTimeout")) * time.Second
+	globalData.DB.MaxOpenConns = viper.GetInt("dbase.maxOpenConns")
+	globalData.DB.MaxIdleConns = viper.GetInt("dbase.maxIdleConns")
+	globalData.DB.ConnMaxIdleTime = time.Duration(viper.GetInt("dbase.connMaxIdleTime")) * time.Second
+	globalData.DB.RetryAttempts = viper.GetInt("dbase.retryAttempts")
+	globalData.DB.RetryBackoff = time.Duration(viper.GetInt("dbase.retryBackoff")) * time.Millisecond
+	globalData.DB.Replicas = viper.GetStringSlice("dbase.replicas")
+	globalData.DB.MaxReplicaLag = time.Duration(viper.GetInt("dbase.maxReplicaLag")) * time.Second
+	globalData.DB.ReplicaCheck = time.Duration(viper.GetInt("dbase.replicaCheckInterval")) * time.Second
+
+	globalData.Admin.Name = viper.GetString("admin.name")
+	globalData.Admin.Email = viper.GetString("admin.email")
+	globalData.Admin.Password = viper.GetString("admin.password")
+	globalData.Admin.Roles = viper.GetStringSlice("admin.roles")
+
+	globalData.Mail.Driver = viper.GetString("mail.driver")
+	globalData.Mail.Host = viper.GetString("mail.host")
+	globalData.Mail.Port = viper.GetInt("mail.port")
+	globalData.Mail.Username = viper.GetString("mail.username")
+	globalData.Mail.Password = viper.GetString("mail.password")
+	globalData.Mail.From = viper.GetString("mail.from")
+	globalData.Mail.File = viper.GetString("mail.file")
+	globalData.ResetPasswordURL = viper.GetString("mail.resetPasswordURL")
+	globalData.ResetTokenValidTime = time.Duration(viper.GetInt("mail.resetTokenValidTime")) * time.Minute
+
+	globalData.Registration.Mode = viper.GetString("registration.mode")
+	switch globalData.Registration.Mode {
+	case models.RegistrationClosed, models.RegistrationVerify, models.RegistrationApproval:
+	default:
+		log.Fatalf("Invalid value in file %s - registration.mode: %q", filename, globalData.Registration.Mode)
+	}
+	if globalData.Registration.Mode == models.RegistrationVerify && !viper.IsSet("registration.verifyEmailURL") {
+		log.Fatalf("Key/Value not set in file %s - registration.verifyEmailURL", filename)
+	}
+	globalData.Registration.AllowedDomains = viper.GetStringSlice("registration.allowedDomains")
+	globalData.Registration.DefaultRole = viper.GetString("registration.defaultRole")
+	globalData.Registration.VerifyEmailURL = viper.GetString("registration.verifyEmailURL")
+	globalData.Registration.VerifyTokenValidTime = time.Duration(viper.GetInt("registration.verifyTokenValidTime")) * time.Minute
+
+	globalData.OIDCEnabled = viper.GetBool("oidc.enabled")
+	globalData.OIDC.Issuer = viper.GetString("oidc.issuer")
+	globalData.OIDC.ClientID = viper.GetString("oidc.clientId")
+	globalData.OIDC.GroupsClaim = viper.GetString("oidc.groupsClaim")
+	globalData.OIDC.AutoProvision = viper.GetBool("oidc.autoProvision")
+	// groupRoles is a list of "group=role" as the keys of toml tables are lower cased
+	globalData.OIDC.GroupRoles = map[string]string{}
+	for _, gr := range viper.GetStringSlice("oidc.groupRoles") {
+		i := strings.LastIndex(gr, "=")
+		if i <= 0 || i == len(gr)-1 {
+			log.Fatalf("Invalid value in file %s - oidc.groupRoles: %q", filename, gr)
+		}
+		globalData.OIDC.GroupRoles[gr[:i]] = gr[i+1:]
+	}
+
+	globalData.MFAIssuer = viper.GetString("mfa.issuer")
+
+	globalData.ThrottleStore = viper.GetString("throttle.store")
+	switch globalData.ThrottleStore {
+	case "database", "mysql", "memory":
+	default:
+		log.Fatalf("Invalid value in file %s - throttle.store: %q", filename, globalData.ThrottleStore)
+	}
+	globalData.Throttle.FreeAttempts = viper.GetInt("throttle.freeAttempts")
+	globalData.Throttle.LockoutAttempts = viper.GetInt("throttle.lockoutAttempts")
+	globalData.Throttle.IPFreeAttempts = viper.GetInt("throttle.ipFreeAttempts")
+	globalData.Throttle.IPLockoutAttempts = viper.GetInt("throttle.ipLockoutAttempts")
+	globalData.Throttle.BaseDelay = time.Duration(viper.GetInt("throttle.baseDelay")) * time.Second
+	globalData.Throttle.MaxDelay = time.Duration(viper.GetInt("throttle.maxDelay")) * time.Second
+	globalData.Throttle.LockoutTime = time.Duration(viper.GetInt("throttle.lockoutTime")) * time.Minute
+	globalData.Throttle.ResetAfter = time.Duration(viper.GetInt("throttle.resetAfter")) * time.Minute
+	globalData.TrustedProxies = viper.GetStringSlice("throttle.trustedProxies")
+
+	globalData.Password.MinLength = viper.GetInt("password.minLength")
+	globalData.Password.MaxLength = viper.GetInt("password.maxLength")
+	globalData.Password.MinClasses = viper.GetInt("password.minClasses")
+	globalData.Password.DisallowPersonal = viper.GetBool("password.disallowPersonal")
+	globalData.Password.BreachedFile = viper.GetString("password.breachedFile")
+
+	globalData.Hasher = hd
+	globalData.Hasher.Algorithm = viper.GetString("password.hashAlgorithm")
+	switch globalData.Hasher.Algorithm {
+	case models.HashBcrypt, models.HashArgon2id:
+	default:
+		log.Fatalf("Invalid value in file %s - password.hashAlgorithm: %q", filename, globalData.Hasher.Algorithm)
+	}
+	globalData.Hasher.BcryptCost = viper.GetInt("password.bcryptCost")
+	globalData.Hasher.Argon2Time = viper.GetUint32("password.argon2Time")
+	globalData.Hasher.Argon2Memory = viper.GetUint32("password.argon2Memory")
+	globalData.Hasher.Argon2Threads = uint8(viper.GetUint("password.argon2Threads"))
+
+	globalData.CacheEnabled = viper.GetBool("cache.enabled")
+	globalData.CacheSize = viper.GetInt("cache.size")
+	globalData.CacheTTL = time.Duration(viper.GetInt("cache.ttl")) * time.Second
+	if globalData.CacheEnabled && (globalData.CacheSize <= 0 || globalData.CacheTTL <= 0) {
+		log.Fatalf("Invalid value in file %s - cache.size and cache.ttl must be positive", filename)
+	}
+	globalData.MaxAge = time.Duration(viper.GetInt("cache.maxAge")) * time.Second
+	if globalData.MaxAge < 0 {
+		log.Fatalf("Invalid value in file %s - cache.maxAge: %v", filename, globalData.MaxAge)
+	}
 
 	/*	// Push Token values to services.token
 		services.IssuerName = GlobalData.tokenIssuerName
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"github.com/vgraveto/snippets/pkg/models/dbmemory"
+	"github.com/vgraveto/snippets/pkg/models/dbmysql"
+	"github.com/vgraveto/snippets/pkg/models/dbpostgres"
+	"github.com/vgraveto/snippets/pkg/models/dbsqlite"
+	"github.com/vgraveto/snippets/pkg/models/migrate"
+	"log"
+)
+
+const (
+	// DriverMySQL keeps the data on a MySQL server reached over TLS
+	DriverMySQL = "mysql"
+	// DriverPostgres keeps the data on a PostgreSQL server
+	DriverPostgres = "postgres"
+	// DriverSQLite keeps the data on a local SQLite file, used for development and tests
+	DriverSQLite = "sqlite"
+	// DriverMemory keeps the data in memory until the API stops, used for demos and integration tests
+	DriverMemory = "memory"
+)
+
+// storage holds the models of the database selected by the dbase.driver setting or the -storage flag
+type storage struct {
+	Snippets      models.Snippets
+	Users         models.Users
+	UserTokens    models.UserTokens
+	RefreshTokens models.RefreshTokens
+	Denylist      models.TokenDenylist
+	Sessions      models.Sessions
+	Audit         models.AuditLog
+	APIKeys       models.APIKeys
+	MFA           models.MFA
+	LoginAttempts models.LoginAttempts
+
+	// Migrator applies the migrations of the schema, nil when the storage has no schema
+	Migrator *migrate.Migrator
+	// Pool is the connection pool checked by the health checks, nil when the storage has none
+	Pool *sql.DB
+
+	// Close closes the connection pool of the models
+	Close func() error
+}
+
+// openStorage dials the database of the configured driver and returns its models
+func openStorage(infoLog, errorLog *log.Logger, globalData *configType, hasher models.PasswordHasher, certsPath, keysPath string) (*storage, error) {
+	switch globalData.DBDriver {
+	case DriverPostgres:
+		db, err := dbpostgres.DialDB(infoLog, globalData.Postgres, certsPath)
+		if err != nil {
+			return nil, err
+		}
+		migrator, err := dbpostgres.NewMigrator(db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &storage{
+			Snippets:      dbpostgres.NewSnippetModel(db),
+			Users:         dbpostgres.NewUserModel(db, hasher, errorLog),
+			UserTokens:    dbpostgres.NewUserTokenModel(db),
+			RefreshTokens: dbpostgres.NewRefreshTokenModel(db),
+			Denylist:      dbpostgres.NewTokenDenylistModel(db),
+			Sessions:      dbpostgres.NewSessionModel(db),
+			Audit:         dbpostgres.NewAuditModel(db),
+			APIKeys:       dbpostgres.NewAPIKeyModel(db),
+			MFA:           dbpostgres.NewMFAModel(db),
+			LoginAttempts: dbpostgres.NewLoginAttemptModel(db),
+			Migrator:      migrator,
+			Pool:          db.DB,
+			Close:         func() error { return dbpostgres.CloseDB(infoLog, db) },
+		}, nil
+	case DriverSQLite:
+		db, err := dbsqlite.DialDB(infoLog, globalData.SQLite)
+		if err != nil {
+			return nil, err
+		}
+		migrator, err := dbsqlite.NewMigrator(db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &storage{
+			Snippets:      dbsqlite.NewSnippetModel(db),
+			Users:         dbsqlite.NewUserModel(db, hasher, errorLog),
+			UserTokens:    dbsqlite.NewUserTokenModel(db),
+			RefreshTokens: dbsqlite.NewRefreshTokenModel(db),
+			Denylist:      dbsqlite.NewTokenDenylistModel(db),
+			Sessions:      dbsqlite.NewSessionModel(db),
+			Audit:         dbsqlite.NewAuditModel(db),
+			APIKeys:       dbsqlite.NewAPIKeyModel(db),
+			MFA:           dbsqlite.NewMFAModel(db),
+			LoginAttempts: dbsqlite.NewLoginAttemptModel(db),
+			Migrator:      migrator,
+			Pool:          db.DB,
+			Close:         func() error { return dbsqlite.CloseDB(infoLog, db) },
+		}, nil
+	case DriverMemory:
+		infoLog.Println("openStorage: the data is kept in memory and lost when the API stops")
+		db := dbmemory.New()
+		return &storage{
+			Snippets:      dbmemory.NewSnippetModel(db),
+			Users:         dbmemory.NewUserModel(db, hasher, errorLog),
+			UserTokens:    dbmemory.NewUserTokenModel(db),
+			RefreshTokens: dbmemory.NewRefreshTokenModel(db),
+			Denylist:      dbmemory.NewTokenDenylistModel(db),
+			Sessions:      dbmemory.NewSessionModel(db),
+			Audit:         dbmemory.NewAuditModel(db),
+			APIKeys:       dbmemory.NewAPIKeyModel(db),
+			MFA:           dbmemory.NewMFAModel(db),
+			LoginAttempts: models.NewMemoryLoginAttempts(),
+			Close:         func() error { return nil },
+		}, nil
+	case DriverMySQL:
+	default:
+		return nil, fmt.Errorf("openStorage: unknown storage driver %q", globalData.DBDriver)
+	}
+
+	db, err := dbmysql.DialDB(infoLog, globalData.DB, certsPath, keysPath)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := dbmysql.NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &storage{
+		Snippets:      dbmysql.NewSnippetModel(db),
+		Users:         dbmysql.NewUserModel(db, hasher, errorLog),
+		UserTokens:    dbmysql.NewUserTokenModel(db),
+		RefreshTokens: dbmysql.NewRefreshTokenModel(db),
+		Denylist:      dbmysql.NewTokenDenylistModel(db),
+		Sessions:      dbmysql.NewSessionModel(db),
+		Audit:         dbmysql.NewAuditModel(db),
+		APIKeys:       dbmysql.NewAPIKeyModel(db),
+		MFA:           dbmysql.NewMFAModel(db),
+		LoginAttempts: dbmysql.NewLoginAttemptModel(db),
+		Migrator:      migrator,
+		Pool:          db.DB,
+		Close:         func() error { return dbmysql.CloseDB(infoLog, db) },
+	}, nil
+}
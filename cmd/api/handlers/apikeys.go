@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KeyCreateAPIKey is a key used for CreateAPIKey object in the context
+type KeyCreateAPIKey struct{}
+
+// KeyAPIKey is a key used for the APIKey object of the authenticated request in the context
+type KeyAPIKey struct{}
+
+// authenticateAPIKey authenticates the request with the API key of an user,
+// the key is granted the roles of the user included on its scopes
+func (app *Application) authenticateAPIKey(rw http.ResponseWriter, r *http.Request, key string, next http.Handler) {
+	k, err := app.APIKeys.Authenticate(r.Context(), key)
+	if err != nil {
+		app.ErrorLog.Printf("authenticateAPIKey: %v\n", err)
+		if errors.Is(err, models.ErrInvalidAPIKey) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.ToJSON(&models.GenericMessage{Message: "invalid API key"}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to verify API key"}, rw)
+		return
+	}
+
+	u, err := app.Users.Get(r.Context(), k.UserID)
+	if err != nil {
+		app.ErrorLog.Printf("authenticateAPIKey: get user %d: %v\n", k.UserID, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+	if !u.Active {
+		app.ErrorLog.Printf("authenticateAPIKey: user %d is not active\n", u.ID)
+		rw.WriteHeader(http.StatusUnauthorized)
+		models.ToJSON(&models.GenericMessage{Message: "invalid API key"}, rw)
+		return
+	}
+
+	tUser := &models.TokenUser{
+		ID:    u.ID,
+		Name:  u.Name,
+		Roles: models.ScopeRoles(k.Scopes, u.Roles),
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("authenticateAPIKey: key %d of user %d - roles %v\n", k.ID, u.ID, tUser.Roles)
+	}
+	context.Set(r, KeyTokenUser{}, tUser)
+	context.Set(r, KeyAPIKey{}, k)
+	next.ServeHTTP(rw, r)
+}
+
+// requireJWT rejects the requests authenticated with an API key,
+// used by the operations that can only be done by users logged in with their password
+func (app *Application) requireJWT(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if k, ok := context.Get(r, KeyAPIKey{}).(*models.APIKey); ok {
+			app.ErrorLog.Printf("requireJWT: API key %d not allowed: %s %s\n", k.ID, r.Method, r.URL.RequestURI())
+			rw.WriteHeader(http.StatusForbidden)
+			models.ToJSON(&models.GenericMessage{Message: "Operation not allowed with an API key"}, rw)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// swagger:route GET /users/{id}/api-keys apikeys listAPIKeys
+// Return the API keys of user {id} that are not revoked
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: apiKeysResponse
+//  401: messageResponse
+//  403: messageResponse
+//	500: messageResponse
+
+// listAPIKeys handles GET requests and returns the API keys of the user
+func (app *Application) listAPIKeys(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("listAPIKeys: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	keys, err := app.APIKeys.GetAll(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("listAPIKeys: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Unable to get API keys list"}, rw)
+		return
+	}
+
+	err = models.ToJSON(keys, rw)
+	if err != nil {
+		// we should never be here but log the error just incase
+		app.ErrorLog.Printf("listAPIKeys: Unable to serializing API keys  %v\n", err)
+	}
+}
+
+// swagger:route POST /users/{id}/api-keys apikeys createAPIKey
+// Create an API key for user {id}
+//
+// The scopes must be roles of the user or self, that grants the operations on the own user,
+// the plain-text key is only returned on this response
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: newAPIKeyResponse
+//  400: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// createAPIKey handles POST requests to create an API key for the user
+func (app *Application) createAPIKey(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the key data from the context
+	ck, ok := context.Get(r, KeyCreateAPIKey{}).(*models.CreateAPIKey)
+	if !ok {
+		app.ErrorLog.Printf("createAPIKey: No API key data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with API key data"}, rw)
+		return
+	}
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("createAPIKey: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	// the key can only be granted roles of its user
+	u, err := app.Users.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("createAPIKey: user %d:  %v\n", id, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusNotFound)
+			models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("User %d not found", id)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+	for _, s := range ck.Scopes {
+		if s != models.SelfRole && len(models.ScopeRoles([]string{s}, u.Roles)) == 0 {
+			app.ErrorLog.Printf("createAPIKey: user %d: scope %q not granted\n", id, s)
+			rw.WriteHeader(http.StatusBadRequest)
+			models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("Scope %q is not a role of the user", s)}, rw)
+			return
+		}
+	}
+
+	var expires *time.Time
+	if ck.ExpiresInDays > 0 {
+		e := time.Now().AddDate(0, 0, ck.ExpiresInDays)
+		expires = &e
+	}
+	k, key, err := app.APIKeys.Insert(r.Context(), id, ck.Name, ck.Scopes, expires)
+	if err != nil {
+		app.ErrorLog.Printf("createAPIKey: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to create API key"}, rw)
+		return
+	}
+
+	if app.DebugOn {
+		app.InfoLog.Printf("createAPIKey: key %d created for user %d\n", k.ID, id)
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditAPIKeyCreate, TargetType: models.AuditTargetAPIKey, TargetID: k.ID,
+		Details: fmt.Sprintf("key %q of user %d with scopes %v", ck.Name, id, ck.Scopes)})
+	models.ToJSON(&models.NewAPIKeyMessage{APIKey: *k, Key: key}, rw)
+}
+
+// swagger:route DELETE /users/{id}/api-keys/{keyId} apikeys revokeAPIKey
+// Revoke the API key {keyId} of user {id}
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: messageResponse
+//  400: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//  404: messageResponse
+//	500: messageResponse
+
+// revokeAPIKey handles DELETE requests to revoke an API key of the user
+func (app *Application) revokeAPIKey(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// get IDs from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("revokeAPIKey: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+	keyID, err := strconv.Atoi(mux.Vars(r)["keyId"])
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("revokeAPIKey: key of user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	err = app.APIKeys.Revoke(r.Context(), id, keyID)
+	if err != nil {
+		app.ErrorLog.Printf("revokeAPIKey: key %d of user %d:  %v\n", keyID, id, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusNotFound)
+			models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("API key %d not found", keyID)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to revoke API key"}, rw)
+		return
+	}
+
+	if app.DebugOn {
+		app.InfoLog.Printf("revokeAPIKey: key %d of user %d revoked\n", keyID, id)
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditAPIKeyRevoke, TargetType: models.AuditTargetAPIKey, TargetID: keyID,
+		Details: fmt.Sprintf("key of user %d", id)})
+	models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("API key %d revoked", keyID)}, rw)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScopeRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		roles  []string
+		want   []string
+	}{
+		{"Role of the user", []string{"user"}, []string{"user", "administrator"}, []string{"user"}},
+		{"All the roles", []string{"administrator", "user"}, []string{"user", "administrator"}, []string{"administrator", "user"}},
+		{"Role not of the user", []string{"administrator"}, []string{"user"}, []string{}},
+		{"Self is not a role", []string{models.SelfRole}, []string{"user"}, []string{}},
+		{"No roles", []string{"user"}, nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := models.ScopeRoles(tt.scopes, tt.roles); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	app := newTestApplication(t)
+	aliceID := insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	adminID := insertUser(t, app, "admin@example.com", "Pa$$word1234", "administrator")
+	ts := newTestServer(t, app.Routes())
+	ctx := context.Background()
+
+	// newKey inserts a key of the user and returns its plain-text value
+	newKey := func(userID int, scopes []string, expires *time.Time) string {
+		t.Helper()
+		_, key, err := app.APIKeys.Insert(ctx, userID, "test", scopes, expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	alice := newKey(aliceID, []string{"user"}, &future)
+	aliceSelf := newKey(aliceID, []string{"user", models.SelfRole}, nil)
+	expired := newKey(aliceID, []string{"user", models.SelfRole}, &past)
+	admin := newKey(adminID, []string{"administrator"}, nil)
+	adminSelf := newKey(adminID, []string{models.SelfRole}, nil)
+	revoked, key, err := app.APIKeys.Insert(ctx, aliceID, "revoked", []string{"user", models.SelfRole}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = app.APIKeys.Revoke(ctx, aliceID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	users := fmt.Sprintf("/users/%d", aliceID)
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		key      string
+		body     interface{}
+		wantCode int
+	}{
+		{"Unknown key", http.MethodGet, users, models.APIKeyPrefix + "unknown", nil, http.StatusUnauthorized},
+		{"Revoked key", http.MethodGet, users, key, nil, http.StatusUnauthorized},
+		{"Expired key", http.MethodGet, users, expired, nil, http.StatusUnauthorized},
+		{"Role of the scopes", http.MethodPost, "/snippets", alice,
+			&models.SnippetCreate{Title: "Key", Content: "Created with a key", Expires: "7"}, http.StatusOK},
+		{"Role not of the scopes", http.MethodPost, "/snippets", adminSelf,
+			&models.SnippetCreate{Title: "Key", Content: "Created with a key", Expires: "7"}, http.StatusForbidden},
+		{"Administrator scope", http.MethodGet, "/users", admin, nil, http.StatusOK},
+		{"Administrator without the scope", http.MethodGet, "/users", adminSelf, nil, http.StatusForbidden},
+		{"Self without the scope", http.MethodGet, users, alice, nil, http.StatusForbidden},
+		{"Self scope", http.MethodGet, users, aliceSelf, nil, http.StatusOK},
+		{"Self scope of another user", http.MethodGet, users, adminSelf, nil, http.StatusForbidden},
+		{"Change password", http.MethodPut, users + "/change-password", aliceSelf,
+			&models.ChangeUserPassword{OldPassword: "Pa$$word1234", NewPassword: "N3w-Pa$$word-5678"}, http.StatusForbidden},
+		{"Change password of another user", http.MethodPut, users + "/change-password", admin,
+			&models.ChangeUserPassword{OldPassword: "Pa$$word1234", NewPassword: "N3w-Pa$$word-5678"}, http.StatusForbidden},
+		{"List API keys", http.MethodGet, users + "/api-keys", aliceSelf, nil, http.StatusForbidden},
+		{"Create API key", http.MethodPost, users + "/api-keys", aliceSelf,
+			&models.CreateAPIKey{Name: "other", Scopes: []string{"user"}}, http.StatusForbidden},
+		{"Enroll MFA", http.MethodPost, users + "/mfa", aliceSelf, nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.authJSON(t, tt.method, tt.path, tt.key, tt.body)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d %s", tt.wantCode, code, body)
+			}
+		})
+	}
+
+	// the key is accepted on its own header too
+	code, _, body := ts.get(t, users, map[string]string{"X-API-Key": aliceSelf})
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d %s", http.StatusOK, code, body)
+	}
+	code, _, _ = ts.get(t, users, map[string]string{"X-API-Key": key})
+	if code != http.StatusUnauthorized {
+		t.Errorf("want %d for the revoked key; got %d", http.StatusUnauthorized, code)
+	}
+	if _, err = app.Users.Authenticate(ctx, "alice@example.com", "Pa$$word1234"); err != nil {
+		t.Errorf("want the password unchanged by the keys; got %v", err)
+	}
+}
+
+func TestCreateAPIKeyScopes(t *testing.T) {
+	app := newTestApplication(t)
+	aliceID := insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	ts := newTestServer(t, app.Routes())
+	alice := ts.login(t, "alice@example.com", "Pa$$word1234")
+	path := fmt.Sprintf("/users/%d/api-keys", aliceID)
+
+	tests := []struct {
+		name     string
+		scopes   []string
+		wantCode int
+	}{
+		{"Role of the user", []string{"user"}, http.StatusOK},
+		{"Self", []string{models.SelfRole}, http.StatusOK},
+		{"Role and self", []string{"user", models.SelfRole}, http.StatusOK},
+		{"Role not of the user", []string{"administrator"}, http.StatusBadRequest},
+		{"Unknown role", []string{"user", "unknown"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.authJSON(t, http.MethodPost, path, alice.Token, &models.CreateAPIKey{Name: tt.name, Scopes: tt.scopes})
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d %s", tt.wantCode, code, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"github.com/gorilla/context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// KeyRequestID is a key used for the ID of the request in the context
+type KeyRequestID struct{}
+
+// audit appends the event to the audit log with the IP address and the ID of the request,
+// the actor is the user of the JWT or API key of the request when not given.
+// The failures are logged as the operation was already done.
+func (app *Application) audit(r *http.Request, e *models.AuditEvent) {
+	if e.ActorID == 0 {
+		if u, ok := context.Get(r, KeyTokenUser{}).(*models.TokenUser); ok {
+			e.ActorID = u.ID
+		}
+	}
+	e.IP = models.ClientIP(r, app.TrustedProxies)
+	e.RequestID, _ = context.Get(r, KeyRequestID{}).(string)
+	err := app.Audit.Insert(r.Context(), e)
+	if err != nil {
+		app.ErrorLog.Printf("audit: %s %s %d: %v\n", e.Action, e.TargetType, e.TargetID, err)
+	}
+}
+
+// swagger:route GET /audit audit listAudit
+// Return a page of the audit log, the most recent events first
+//
+// The query parameters action, actor, targetType and target filter the events, from and to
+// are dates in RFC 3339 or YYYY-MM-DD, page starts at 1 and perPage is at most 200
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: auditResponse
+//  400: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//	500: messageResponse
+
+// listAudit handles GET requests and returns a page of the audit log
+func (app *Application) listAudit(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	f, err := models.ParseAuditFilter(r.URL.Query())
+	if err != nil {
+		app.ErrorLog.Printf("listAudit: %v\n", err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: err.Error()}, rw)
+		return
+	}
+
+	page, err := app.Audit.GetAll(r.Context(), f)
+	if err != nil {
+		app.ErrorLog.Printf("listAudit: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Unable to get audit log"}, rw)
+		return
+	}
+
+	err = models.ToJSON(page, rw)
+	if err != nil {
+		// we should never be here but log the error just incase
+		app.ErrorLog.Printf("listAudit: Unable to serializing audit log  %v\n", err)
+	}
+}
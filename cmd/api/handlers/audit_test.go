@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// auditEvents returns the events of the action recorded on the audit log
+func auditEvents(t *testing.T, app *Application, action string) []*models.AuditEvent {
+	t.Helper()
+	page, err := app.Audit.GetAll(context.Background(), &models.AuditFilter{Action: action, Page: 1, PerPage: models.AuditMaxPerPage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page.Events
+}
+
+// sessionID returns the session of the JWT
+func sessionID(t *testing.T, token string) int {
+	t.Helper()
+	claims, err := models.GetClaimsFromToken(&token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims.SessionID
+}
+
+func TestAuditSecurityEvents(t *testing.T) {
+	app := newTestApplication(t)
+	aliceID := insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	adminID := insertUser(t, app, "admin@example.com", "Pa$$word1234", "administrator")
+	ts := newTestServer(t, app.Routes())
+
+	// the sessions are opened before the two-factor authentication is enabled
+	alice, other := ts.login(t, "alice@example.com", "Pa$$word1234"), ts.login(t, "alice@example.com", "Pa$$word1234")
+	admin := ts.login(t, "admin@example.com", "Pa$$word1234")
+	users := fmt.Sprintf("/users/%d", aliceID)
+
+	// do sends the request and fails when the status is not 200
+	do := func(method, path, credential string, v interface{}) []byte {
+		t.Helper()
+		code, _, body := ts.authJSON(t, method, path, credential, v)
+		if code != http.StatusOK {
+			t.Fatalf("%s %s: want %d; got %d %s", method, path, http.StatusOK, code, body)
+		}
+		return body
+	}
+
+	var key models.NewAPIKeyMessage
+	body := do(http.MethodPost, users+"/api-keys", alice.Token, &models.CreateAPIKey{Name: "ci", Scopes: []string{"user"}})
+	if err := json.Unmarshal(body, &key); err != nil {
+		t.Fatal(err)
+	}
+	do(http.MethodDelete, fmt.Sprintf("%s/api-keys/%d", users, key.ID), alice.Token, nil)
+
+	var enrollment models.MFAEnrollment
+	if err := json.Unmarshal(do(http.MethodPost, users+"/mfa", alice.Token, nil), &enrollment); err != nil {
+		t.Fatal(err)
+	}
+	code, err := models.TOTPCode(enrollment.Secret, models.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	do(http.MethodPut, users+"/mfa", alice.Token, &models.MFACode{Code: code})
+	// the administrator turns off the second factor of the user without a code
+	do(http.MethodDelete, users+"/mfa", admin.Token, &models.MFACode{})
+
+	roleTypes, err := app.Users.GetRoleTypes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roleID int
+	for _, rt := range roleTypes {
+		if rt.Role == "user" {
+			roleID = rt.ID
+		}
+	}
+	do(http.MethodPut, fmt.Sprintf("/users/role-types/%d/mfa", roleID), admin.Token, &models.RoleMFA{MFARequired: true})
+
+	otherSession := sessionID(t, other.Token)
+	do(http.MethodDelete, fmt.Sprintf("%s/sessions/%d", users, otherSession), alice.Token, nil)
+	do(http.MethodPost, "/users/logout", alice.Token, &models.LogoutUser{RefreshToken: alice.RefreshToken})
+
+	tests := []struct {
+		action     string
+		actorID    int
+		targetType string
+		targetID   int
+		details    string
+	}{
+		{models.AuditAPIKeyCreate, aliceID, models.AuditTargetAPIKey, key.ID, `"ci"`},
+		{models.AuditAPIKeyRevoke, aliceID, models.AuditTargetAPIKey, key.ID, ""},
+		{models.AuditMFAEnable, aliceID, models.AuditTargetUser, aliceID, ""},
+		{models.AuditMFADisable, adminID, models.AuditTargetUser, aliceID, "administrator"},
+		{models.AuditRoleMFA, adminID, models.AuditTargetRole, roleID, "true"},
+		{models.AuditSessionRevoke, aliceID, models.AuditTargetSession, otherSession, ""},
+		{models.AuditLogout, aliceID, models.AuditTargetSession, sessionID(t, alice.Token), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			events := auditEvents(t, app, tt.action)
+			if len(events) != 1 {
+				t.Fatalf("want 1 event; got %d", len(events))
+			}
+			e := events[0]
+			if e.ActorID != tt.actorID || e.TargetType != tt.targetType || e.TargetID != tt.targetID {
+				t.Errorf("want the actor %d and the target %s %d; got %d and %s %d",
+					tt.actorID, tt.targetType, tt.targetID, e.ActorID, e.TargetType, e.TargetID)
+			}
+			if !strings.Contains(e.Details, tt.details) {
+				t.Errorf("want the details with %q; got %q", tt.details, e.Details)
+			}
+			if e.RequestID == "" {
+				t.Error("want the ID of the request; got none")
+			}
+		})
+	}
+}
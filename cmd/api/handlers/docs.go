@@ -16,9 +16,14 @@
 //  SecurityDefinitions:
 //  snippetskey:
 //    type: apiKey
-//    description: JSON Web Token (JWT) - token
+//    description: JSON Web Token (JWT) - token, or a personal API key starting with snk_
 //    name: Authentication
 //    in: header
+//  apikey:
+//    type: apiKey
+//    description: Personal API key - same permissions of snippetskey restricted to the key scopes
+//    name: X-API-Key
+//    in: header
 //
 // swagger:meta
 package handlers
@@ -92,6 +97,11 @@ type userResponseWrapper struct {
 type noContentResponseWrapper struct {
 }
 
+// The resource did not change since the validators of the request, no content is returned
+// swagger:response notModifiedResponse
+type notModifiedResponseWrapper struct {
+}
+
 // Data structure representing the user token record
 // swagger:response userTokenResponse
 type userTokenResponseWrapper struct {
@@ -100,6 +110,94 @@ type userTokenResponseWrapper struct {
 	Body models.TokenMessage
 }
 
+// The public keys that verify the JWT
+// swagger:response jwksResponse
+type jwksResponseWrapper struct {
+	// The JSON Web Key Set
+	// in: body
+	Body models.JWKSet
+}
+
+// A list of API keys
+// swagger:response apiKeysResponse
+type apiKeysResponseWrapper struct {
+	// The API keys of the user
+	// in: body
+	Body []models.APIKey
+}
+
+// A page of the audit log
+// swagger:response auditResponse
+type auditResponseWrapper struct {
+	// The events of the page and the number of events matching the filter
+	// in: body
+	Body models.AuditPage
+}
+
+// A list of sessions
+// swagger:response sessionsResponse
+type sessionsResponseWrapper struct {
+	// The active sessions of the user
+	// in: body
+	Body []models.Session
+}
+
+// Data structure representing a new API key
+// swagger:response newAPIKeyResponse
+type newAPIKeyResponseWrapper struct {
+	// The API key with its plain-text value
+	// in: body
+	Body models.NewAPIKeyMessage
+}
+
+// Challenge to complete the login with two-factor authentication
+// swagger:response mfaChallengeResponse
+type mfaChallengeResponseWrapper struct {
+	// The challenge of the login
+	// in: body
+	Body models.MFAChallenge
+}
+
+// Two-factor authentication status of an user
+// swagger:response mfaStatusResponse
+type mfaStatusResponseWrapper struct {
+	// The two-factor authentication status
+	// in: body
+	Body models.MFAStatus
+}
+
+// Data structure representing a new enrollment
+// swagger:response mfaEnrollmentResponse
+type mfaEnrollmentResponseWrapper struct {
+	// The secret to add to the authenticator app
+	// in: body
+	Body models.MFAEnrollment
+}
+
+// The recovery codes of the two-factor authentication
+// swagger:response mfaRecoveryCodesResponse
+type mfaRecoveryCodesResponseWrapper struct {
+	// The recovery codes, each one can be used once
+	// in: body
+	Body models.MFARecoveryCodes
+}
+
+// The status of the database on its last health check
+// swagger:response healthResponse
+type healthResponseWrapper struct {
+	// The result of the last check
+	// in: body
+	Body models.Health
+}
+
+// The statistics of the connection pool of the database
+// swagger:response poolStatsResponse
+type poolStatsResponseWrapper struct {
+	// The statistics of the pool
+	// in: body
+	Body models.PoolStats
+}
+
 // A list of role types
 // swagger:response rolesResponse
 type rolesResponseWrapper struct {
@@ -116,6 +214,30 @@ type loginUserParamsWrapper struct {
 	Body models.LoginUser
 }
 
+// swagger:parameters loginOIDC
+type loginOIDCParamsWrapper struct {
+	// Data structure with the ID token of the OpenID Connect provider
+	// in: body
+	// required: true
+	Body models.LoginOIDC
+}
+
+// swagger:parameters verifyMFA
+type verifyMFAParamsWrapper struct {
+	// Data structure with the challenge of the login and the code
+	// in: body
+	// required: true
+	Body models.VerifyMFA
+}
+
+// swagger:parameters enrollMFAChallenge
+type enrollMFAChallengeParamsWrapper struct {
+	// Data structure with the challenge of the login
+	// in: body
+	// required: true
+	Body models.MFAChallengeEnrollment
+}
+
 // swagger:parameters createUser
 type createUserParamsWrapper struct {
 	// Data structure to create an user.
@@ -124,6 +246,67 @@ type createUserParamsWrapper struct {
 	Body models.CreateUser
 }
 
+// swagger:parameters refreshToken
+type refreshTokenParamsWrapper struct {
+	// Data structure with the refresh token to exchange for a new JWT.
+	// in: body
+	// required: true
+	Body models.RefreshToken
+}
+
+// swagger:parameters logoutUser
+type logoutUserParamsWrapper struct {
+	// Data structure with the refresh token to revoke on logout.
+	// in: body
+	// required: true
+	Body models.LogoutUser
+}
+
+// swagger:parameters forgotPassword
+type forgotPasswordParamsWrapper struct {
+	// Data structure to request a password reset email.
+	// in: body
+	// required: true
+	Body models.ForgotPassword
+}
+
+// swagger:parameters resetUserPassword
+type resetUserPasswordParamsWrapper struct {
+	// Data structure to reset the password with the token received by email.
+	// in: body
+	// required: true
+	Body models.ResetUserPassword
+}
+
+// swagger:parameters registerUser
+type registerUserParamsWrapper struct {
+	// Data structure to register a new user.
+	// in: body
+	// required: true
+	Body models.RegisterUser
+}
+
+// swagger:parameters verifyEmail
+type verifyEmailParamsWrapper struct {
+	// Data structure to verify the email of a registered user.
+	// in: body
+	// required: true
+	Body models.VerifyEmail
+}
+
+// swagger:parameters approveUser
+type approveUserParamsWrapper struct {
+	// The ID of the user to which the operation relates
+	// in: path
+	// required: true
+	ID int `json:"id"`
+
+	// Data structure with the decision on the registration
+	// in: body
+	// required: true
+	Body models.UserApproval
+}
+
 // swagger:parameters changeUserPassword
 type changeUserPasswordParamsWrapper struct {
 	// The ID of the user to which the operation relates
@@ -137,7 +320,107 @@ type changeUserPasswordParamsWrapper struct {
 	Body models.ChangeUserPassword
 }
 
-// swagger:parameters listSingleUser listSingleSnippet
+// swagger:parameters createAPIKey
+type createAPIKeyParamsWrapper struct {
+	// The ID of the user to which the operation relates
+	// in: path
+	// required: true
+	ID int `json:"id"`
+
+	// Data structure to create a new API key
+	// in: body
+	// required: true
+	Body models.CreateAPIKey
+}
+
+// swagger:parameters revokeAPIKey
+type revokeAPIKeyParamsWrapper struct {
+	// The ID of the user to which the operation relates
+	// in: path
+	// required: true
+	ID int `json:"id"`
+
+	// The ID of the API key to revoke
+	// in: path
+	// required: true
+	KeyID int `json:"keyId"`
+}
+
+// swagger:parameters listAudit
+type listAuditParamsWrapper struct {
+	// The action of the events, like login, login.failed or user.create
+	// in: query
+	Action string `json:"action"`
+
+	// The ID of the user that did the actions
+	// in: query
+	ActorID int `json:"actor"`
+
+	// The type of the targets: user, role, snippet, apikey or session
+	// in: query
+	TargetType string `json:"targetType"`
+
+	// The ID of the target
+	// in: query
+	TargetID int `json:"target"`
+
+	// The first date of the events, in RFC 3339 or YYYY-MM-DD
+	// in: query
+	From string `json:"from"`
+
+	// The last date of the events, in RFC 3339 or YYYY-MM-DD
+	// in: query
+	To string `json:"to"`
+
+	// The page number, starting at 1
+	// in: query
+	Page int `json:"page"`
+
+	// The number of events of a page, at most 200
+	// in: query
+	PerPage int `json:"perPage"`
+}
+
+// swagger:parameters revokeSession
+type revokeSessionParamsWrapper struct {
+	// The ID of the user to which the operation relates
+	// in: path
+	// required: true
+	ID int `json:"id"`
+
+	// The ID of the session to sign out
+	// in: path
+	// required: true
+	SessionID int `json:"sid"`
+}
+
+// swagger:parameters confirmMFA disableMFA
+type mfaCodeParamsWrapper struct {
+	// The ID of the user to which the operation relates
+	// in: path
+	// required: true
+	ID int `json:"id"`
+
+	// Data structure with the code of the authenticator app
+	// in: body
+	// required: true
+	Body models.MFACode
+}
+
+// swagger:parameters setRoleMFA
+type setRoleMFAParamsWrapper struct {
+	// The ID of the role type to which the operation relates
+	// in: path
+	// required: true
+	ID int `json:"id"`
+
+	// Data structure with the two-factor authentication requirement of the role
+	// in: body
+	// required: true
+	Body models.RoleMFA
+}
+
+// swagger:parameters listSingleUser listSingleSnippet listAPIKeys getMFA enrollMFA unlockUser listSessions
 type idParamsWrapper struct {
 	// The ID for which the operation relates
 	// in: path
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"time"
+)
+
+// swagger:route GET /health global health
+// Return the status of the database on its last health check
+//
+// The status is "down" with the status code 503 when the database did not answer the last check
+//
+// responses:
+//	200: healthResponse
+//	503: healthResponse
+
+// health handles GET requests and returns the result of the last health check of the database
+func (app *Application) health(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// the storages without a connection pool are always up
+	h := models.Health{Status: models.HealthUp, Checked: time.Now().UTC()}
+	if app.DBHealth != nil {
+		h = app.DBHealth.Health()
+	}
+	if h.Status != models.HealthUp {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	models.ToJSON(&h, rw)
+}
+
+// swagger:route GET /health/pool global poolStats
+// Return the statistics of the connection pool of the database
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: poolStatsResponse
+//  401: messageResponse
+//  403: messageResponse
+//	404: messageResponse
+
+// poolStats handles GET requests and returns the statistics of the connection pool
+func (app *Application) poolStats(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	if app.DBHealth == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		models.ToJSON(&models.GenericMessage{Message: "the storage has no connection pool"}, rw)
+		return
+	}
+	models.ToJSON(app.DBHealth.Stats(), rw)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// the Cache-Control of the responses that depend on the user or change on each request,
+// the middleware sets it and the handlers of the public resources replace it
+const noStore = "no-store"
+
+// publicMaxAge returns the Cache-Control of a public response that can be used for up to the
+// maxAge and never after the expires
+func publicMaxAge(maxAge time.Duration, expires time.Time) string {
+	if until := time.Until(expires); until < maxAge {
+		maxAge = until
+	}
+	if maxAge < time.Second {
+		return "public, no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+}
+
+// writeCached writes v as the JSON body of the response with its ETag validator and the
+// Last-Modified one when not zero, a 304 Not Modified without body is written instead
+// when the conditional headers of the request match the validators
+func writeCached(rw http.ResponseWriter, r *http.Request, v interface{}, lastModified time.Time, cacheControl string) error {
+	var body bytes.Buffer
+	if err := models.ToJSON(v, &body); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+	h := rw.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", cacheControl)
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, lastModified) {
+		h.Del("Content-Type")
+		rw.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	_, err := rw.Write(body.Bytes())
+	return err
+}
+
+// notModified returns true when the client has the current representation, the If-None-Match
+// header takes precedence over the If-Modified-Since one as on RFC 7232
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == "*" || t == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.Truncate(time.Second).After(since)
+}
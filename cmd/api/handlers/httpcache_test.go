@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// insertSnippet inserts a snippet that expires after the days and returns its path
+func insertSnippet(t *testing.T, app *Application, title, days string) string {
+	id, err := app.Snippets.Insert(context.Background(), title, "O snail\nClimb Mount Fuji,\nBut slowly, slowly!", days)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("/snippets/%d", id)
+}
+
+func TestGetSnippetConditional(t *testing.T) {
+	app := newTestApplication(t)
+	path := insertSnippet(t, app, "O snail", "7")
+	ts := newTestServer(t, app.Routes())
+
+	code, h, body := ts.get(t, path, nil)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d %s", http.StatusOK, code, body)
+	}
+	etag, lastModified := h.Get("ETag"), h.Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("want the ETag and Last-Modified validators; got %q and %q", etag, lastModified)
+	}
+	if cc := h.Get("Cache-Control"); cc != "public, max-age=60" {
+		t.Errorf("want the maxAge of the API; got %q", cc)
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := modified.Add(-time.Hour).Format(http.TimeFormat)
+
+	// If-None-Match takes precedence over If-Modified-Since
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		wantCode int
+	}{
+		{"Matching ETag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"Weak ETag", map[string]string{"If-None-Match": "W/" + etag}, http.StatusNotModified},
+		{"One of the ETags", map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified},
+		{"Any ETag", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"Other ETag", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"Other ETag and not modified", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+		{"Not modified since", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"Modified since", map[string]string{"If-Modified-Since": before}, http.StatusOK},
+		{"Invalid date", map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, h, body := ts.get(t, path, tt.headers)
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if h.Get("ETag") != etag {
+				t.Errorf("want the ETag %s; got %q", etag, h.Get("ETag"))
+			}
+			if code == http.StatusNotModified && len(body) != 0 {
+				t.Errorf("want no body; got %s", body)
+			}
+			if code == http.StatusOK && len(body) == 0 {
+				t.Error("want the snippet; got no body")
+			}
+		})
+	}
+}
+
+func TestGetSnippetMaxAge(t *testing.T) {
+	app := newTestApplication(t)
+	app.SnippetMaxAge = 48 * time.Hour
+	path := insertSnippet(t, app, "O snail", "1")
+	ts := newTestServer(t, app.Routes())
+
+	// the snippet is never used after it expires
+	_, h, _ := ts.get(t, path, nil)
+	cc := h.Get("Cache-Control")
+	if !strings.HasPrefix(cc, "public, max-age=") {
+		t.Fatalf("want a public max-age; got %q", cc)
+	}
+	maxAge, err := strconv.Atoi(strings.TrimPrefix(cc, "public, max-age="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day := int((24 * time.Hour).Seconds()); maxAge > day || maxAge < day-60 {
+		t.Errorf("want the max-age capped at the expiration in one day; got %d", maxAge)
+	}
+}
+
+func TestListSnippetsConditional(t *testing.T) {
+	app := newTestApplication(t)
+	insertSnippet(t, app, "O snail", "7")
+	ts := newTestServer(t, app.Routes())
+
+	code, h, _ := ts.get(t, "/snippets", nil)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	etag := h.Get("ETag")
+	if cc := h.Get("Cache-Control"); cc != "public, no-cache" {
+		t.Errorf("want the list revalidated on each use; got %q", cc)
+	}
+	if h.Get("Last-Modified") != "" {
+		t.Errorf("want no Last-Modified; got %q", h.Get("Last-Modified"))
+	}
+	if code, _, _ := ts.get(t, "/snippets", map[string]string{"If-None-Match": etag}); code != http.StatusNotModified {
+		t.Errorf("want %d; got %d", http.StatusNotModified, code)
+	}
+
+	// a new snippet changes the list and its ETag
+	insertSnippet(t, app, "Over the wintry forest", "7")
+	code, h, _ = ts.get(t, "/snippets", map[string]string{"If-None-Match": etag})
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if h.Get("ETag") == etag {
+		t.Errorf("want a new ETag; got %q", etag)
+	}
+}
+
+func TestNoStore(t *testing.T) {
+	app := newTestApplication(t)
+	insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	ts := newTestServer(t, app.Routes())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"Missing snippet", http.MethodGet, "/snippets/99", ""},
+		{"Login", http.MethodPost, "/users/login", `{"email":"alice@example.com","password":"Pa$$word1234"}`},
+		{"Users", http.MethodGet, "/users", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, h, _ := ts.do(t, tt.method, tt.path, tt.body, map[string]string{"Content-Type": "application/json"})
+			if cc := h.Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("want no-store; got %q", cc)
+			}
+			if h.Get("ETag") != "" {
+				t.Errorf("want no ETag; got %q", h.Get("ETag"))
+			}
+		})
+	}
+}
@@ -0,0 +1,666 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"time"
+)
+
+// KeyVerifyMFA is a key used for VerifyMFA object in the context
+type KeyVerifyMFA struct{}
+
+// KeyMFAChallengeEnrollment is a key used for MFAChallengeEnrollment object in the context
+type KeyMFAChallengeEnrollment struct{}
+
+// KeyMFACode is a key used for MFACode object in the context
+type KeyMFACode struct{}
+
+// KeyRoleMFA is a key used for RoleMFA object in the context
+type KeyRoleMFA struct{}
+
+// mfaRequired returns true when one of the roles requires two-factor authentication
+func (app *Application) mfaRequired(r *http.Request, roles []string) (bool, error) {
+	if len(roles) == 0 {
+		return false, nil
+	}
+	roleTypes, err := app.Users.GetRoleTypes(r.Context())
+	if err != nil {
+		return false, err
+	}
+	for _, rt := range roleTypes {
+		if !rt.MFARequired {
+			continue
+		}
+		for _, role := range roles {
+			if role == rt.Role {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// mfaStatus returns the two-factor authentication of the user, not enabled when the user never enrolled
+func (app *Application) mfaStatus(r *http.Request, u *models.User) (*models.MFAStatus, error) {
+	status, err := app.MFA.Get(r.Context(), u.ID)
+	if errors.Is(err, models.ErrNoRecord) {
+		status, err = &models.MFAStatus{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.Required, err = app.mfaRequired(r, u.Roles)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// mfaChallenge returns the challenge the user must complete to login, nil when the
+// user has no two-factor authentication enabled or required
+func (app *Application) mfaChallenge(r *http.Request, u *models.User) (*models.MFAChallenge, error) {
+	status, err := app.mfaStatus(r, u)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Enabled && !status.Required {
+		return nil, nil
+	}
+	challenge, err := app.MFA.NewChallenge(r.Context(), u.ID, models.MFAChallengeValidTime)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFAChallenge{Challenge: challenge, EnrollmentRequired: !status.Enabled}, nil
+}
+
+// checkMFACode verifies the TOTP code, or a recovery code when allowed, of the user and marks it as used
+func (app *Application) checkMFACode(r *http.Request, userID int, status *models.MFAStatus, code string, allowRecovery bool) error {
+	if step, ok := models.ValidateTOTP(status.Secret, code, time.Now()); ok {
+		// a code is only accepted once
+		ok, err := app.MFA.UseStep(r.Context(), userID, step)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: code already used", models.ErrInvalidMFACode)
+		}
+		return nil
+	}
+	if allowRecovery {
+		ok, err := app.MFA.UseRecoveryCode(r.Context(), userID, code)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if app.DebugOn {
+				app.InfoLog.Printf("checkMFACode: recovery code used by user %d\n", userID)
+			}
+			return nil
+		}
+	}
+	return models.ErrInvalidMFACode
+}
+
+// isTokenUser returns true when the user of the JWT has the id
+func (app *Application) isTokenUser(r *http.Request, id int) bool {
+	tUser, ok := context.Get(r, KeyTokenUser{}).(*models.TokenUser)
+	return ok && tUser.ID == id
+}
+
+// enrollment creates a new secret for the user
+func (app *Application) enrollment(r *http.Request, u *models.User) (*models.MFAEnrollment, error) {
+	secret, err := models.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = app.MFA.Enroll(r.Context(), u.ID, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFAEnrollment{
+		Secret: secret,
+		URI:    models.TOTPURI(app.MFAIssuer, u.Email, secret),
+	}, nil
+}
+
+// enable confirms the enrollment of the user and returns the new recovery codes
+func (app *Application) enable(r *http.Request, userID int) ([]string, error) {
+	codes, err := models.NewRecoveryCodes(models.RecoveryCodesCount)
+	if err != nil {
+		return nil, err
+	}
+	err = app.MFA.Enable(r.Context(), userID, codes)
+	if err != nil {
+		return nil, err
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditMFAEnable, ActorID: userID, TargetType: models.AuditTargetUser,
+		TargetID: userID})
+	return codes, nil
+}
+
+// swagger:route POST /users/login/mfa mfa verifyMFA
+// Complete the login of an user with two-factor authentication and return the JWT
+//
+// The code is a TOTP code or a recovery code. When the login required an enrollment
+// the TOTP code confirms it and the recovery codes are returned with the tokens.
+//
+// responses:
+//	200: userTokenResponse
+//  401: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// verifyMFA handles POST requests to complete the login with the code of the challenge
+func (app *Application) verifyMFA(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the challenge and code from the context
+	vm, ok := context.Get(r, KeyVerifyMFA{}).(*models.VerifyMFA)
+	if !ok {
+		app.ErrorLog.Printf("verifyMFA: No challenge data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with challenge data"}, rw)
+		return
+	}
+
+	id, err := app.MFA.CheckChallenge(r.Context(), vm.Challenge, models.MFAChallengeMaxAttempts)
+	if err != nil {
+		app.ErrorLog.Printf("verifyMFA: %v\n", err)
+		if errors.Is(err, models.ErrInvalidToken) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.ToJSON(&models.GenericMessage{Message: "invalid or expired challenge"}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to verify challenge"}, rw)
+		return
+	}
+
+	status, err := app.MFA.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("verifyMFA: user %d: %v\n", id, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.ToJSON(&models.GenericMessage{Message: "two-factor authentication enrollment not started"}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get two-factor authentication"}, rw)
+		return
+	}
+
+	// the recovery codes only exist after the enrollment
+	err = app.checkMFACode(r, id, status, vm.Code, status.Enabled)
+	if err != nil {
+		app.ErrorLog.Printf("verifyMFA: user %d: %v\n", id, err)
+		if errors.Is(err, models.ErrInvalidMFACode) {
+			app.audit(r, &models.AuditEvent{Action: models.AuditLoginFailed, TargetType: models.AuditTargetUser,
+				TargetID: id, Details: "invalid two-factor code"})
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.ToJSON(&models.GenericMessage{Message: "invalid code"}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to verify code"}, rw)
+		return
+	}
+
+	var recoveryCodes []string
+	if !status.Enabled {
+		recoveryCodes, err = app.enable(r, id)
+		if err != nil {
+			app.ErrorLog.Printf("verifyMFA: user %d: %v\n", id, err)
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.ToJSON(&models.GenericMessage{Message: "unable to enable two-factor authentication"}, rw)
+			return
+		}
+	}
+
+	err = app.MFA.DeleteChallenge(r.Context(), vm.Challenge)
+	if err != nil {
+		// the challenge expires anyway
+		app.ErrorLog.Printf("verifyMFA: user %d: %v\n", id, err)
+	}
+
+	u, err := app.Users.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("verifyMFA: get user %d: %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+	tokenmsg, err := app.newTokenMessage(r, u)
+	if err != nil {
+		app.ErrorLog.Printf("verifyMFA: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to create JWT"}, rw)
+		return
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditLogin, ActorID: u.ID, TargetType: models.AuditTargetUser,
+		TargetID: u.ID, Details: "two-factor login"})
+	tokenmsg.RecoveryCodes = recoveryCodes
+	models.ToJSON(tokenmsg, rw)
+}
+
+// swagger:route POST /users/login/mfa/enroll mfa enrollMFAChallenge
+// Start the enrollment required to complete the login
+//
+// Used when the login returned a challenge with enrollmentRequired,
+// the enrollment is confirmed by the login with a TOTP code of the returned secret.
+//
+// responses:
+//	200: mfaEnrollmentResponse
+//  400: messageResponse
+//  401: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// enrollMFAChallenge handles POST requests to enroll the user of the challenge
+func (app *Application) enrollMFAChallenge(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the challenge from the context
+	ce, ok := context.Get(r, KeyMFAChallengeEnrollment{}).(*models.MFAChallengeEnrollment)
+	if !ok {
+		app.ErrorLog.Printf("enrollMFAChallenge: No challenge data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with challenge data"}, rw)
+		return
+	}
+
+	id, err := app.MFA.CheckChallenge(r.Context(), ce.Challenge, models.MFAChallengeMaxAttempts)
+	if err != nil {
+		app.ErrorLog.Printf("enrollMFAChallenge: %v\n", err)
+		if errors.Is(err, models.ErrInvalidToken) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.ToJSON(&models.GenericMessage{Message: "invalid or expired challenge"}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to verify challenge"}, rw)
+		return
+	}
+
+	app.enrollUser(rw, r, id, "enrollMFAChallenge")
+}
+
+// enrollUser writes the response with a new enrollment of the user with the id
+func (app *Application) enrollUser(rw http.ResponseWriter, r *http.Request, id int, caller string) {
+	u, err := app.Users.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("%s: get user %d: %v\n", caller, id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+	status, err := app.mfaStatus(r, u)
+	if err != nil {
+		app.ErrorLog.Printf("%s: user %d: %v\n", caller, id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get two-factor authentication"}, rw)
+		return
+	}
+	if status.Enabled {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: "two-factor authentication already enabled"}, rw)
+		return
+	}
+
+	enrollment, err := app.enrollment(r, u)
+	if err != nil {
+		app.ErrorLog.Printf("%s: user %d: %v\n", caller, id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to enroll"}, rw)
+		return
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("%s: user %d enrolled\n", caller, id)
+	}
+	models.ToJSON(enrollment, rw)
+}
+
+// swagger:route GET /users/{id}/mfa mfa getMFA
+// Return the two-factor authentication status of user {id}
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: mfaStatusResponse
+//  401: messageResponse
+//  403: messageResponse
+//	500: messageResponse
+
+// getMFA handles GET requests and returns the two-factor authentication status of the user
+func (app *Application) getMFA(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("getMFA: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	u, err := app.Users.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("getMFA: user %d:  %v\n", id, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusNotFound)
+			models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("User %d not found", id)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+	status, err := app.mfaStatus(r, u)
+	if err != nil {
+		app.ErrorLog.Printf("getMFA: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get two-factor authentication"}, rw)
+		return
+	}
+	models.ToJSON(status, rw)
+}
+
+// swagger:route POST /users/{id}/mfa mfa enrollMFA
+// Start the two-factor authentication enrollment of user {id}
+//
+// The enrollment is confirmed with a TOTP code of the returned secret.
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: mfaEnrollmentResponse
+//  400: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//	500: messageResponse
+
+// enrollMFA handles POST requests to start the enrollment of the user
+func (app *Application) enrollMFA(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("enrollMFA: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	// only the user enrolls the own authenticator app
+	if !app.isTokenUser(r, id) {
+		app.ErrorLog.Printf("enrollMFA: user %d: %s\n", id, http.StatusText(http.StatusForbidden))
+		rw.WriteHeader(http.StatusForbidden)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusForbidden)}, rw)
+		return
+	}
+
+	app.enrollUser(rw, r, id, "enrollMFA")
+}
+
+// swagger:route PUT /users/{id}/mfa mfa confirmMFA
+// Confirm the two-factor authentication enrollment of user {id} and return the recovery codes
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: mfaRecoveryCodesResponse
+//  400: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// confirmMFA handles PUT requests to enable the two-factor authentication of the user
+func (app *Application) confirmMFA(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the code from the context
+	mc, ok := context.Get(r, KeyMFACode{}).(*models.MFACode)
+	if !ok {
+		app.ErrorLog.Printf("confirmMFA: No code data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with code data"}, rw)
+		return
+	}
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("confirmMFA: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	// only the user enrolls the own authenticator app
+	if !app.isTokenUser(r, id) {
+		app.ErrorLog.Printf("confirmMFA: user %d: %s\n", id, http.StatusText(http.StatusForbidden))
+		rw.WriteHeader(http.StatusForbidden)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusForbidden)}, rw)
+		return
+	}
+
+	status, err := app.MFA.Get(r.Context(), id)
+	if err == nil && status.Enabled {
+		err = fmt.Errorf("%w: already enabled", models.ErrInvalidMFACode)
+	}
+	if err == nil {
+		err = app.checkMFACode(r, id, status, mc.Code, false)
+	}
+	if err != nil {
+		app.ErrorLog.Printf("confirmMFA: user %d:  %v\n", id, err)
+		if errors.Is(err, models.ErrNoRecord) || errors.Is(err, models.ErrInvalidMFACode) {
+			rw.WriteHeader(http.StatusBadRequest)
+			models.ToJSON(&models.GenericMessage{Message: "invalid code or enrollment not started"}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to verify code"}, rw)
+		return
+	}
+
+	codes, err := app.enable(r, id)
+	if err != nil {
+		app.ErrorLog.Printf("confirmMFA: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to enable two-factor authentication"}, rw)
+		return
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("confirmMFA: two-factor authentication enabled for user %d\n", id)
+	}
+	models.ToJSON(&models.MFARecoveryCodes{RecoveryCodes: codes}, rw)
+}
+
+// swagger:route DELETE /users/{id}/mfa mfa disableMFA
+// Disable the two-factor authentication of user {id}
+//
+// The users must send a TOTP or recovery code and can not disable it when required by their roles,
+// the administrators disable it for other users without a code.
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: messageResponse
+//  400: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//  404: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// disableMFA handles DELETE requests to disable the two-factor authentication of the user
+func (app *Application) disableMFA(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the code and the token user from the context
+	mc, ok := context.Get(r, KeyMFACode{}).(*models.MFACode)
+	if !ok {
+		app.ErrorLog.Printf("disableMFA: No code data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with code data"}, rw)
+		return
+	}
+	tUser, ok := context.Get(r, KeyTokenUser{}).(*models.TokenUser)
+	if !ok {
+		app.ErrorLog.Printf("disableMFA: No user data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with user data"}, rw)
+		return
+	}
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("disableMFA: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	u, err := app.Users.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("disableMFA: user %d:  %v\n", id, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusNotFound)
+			models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("User %d not found", id)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+	status, err := app.mfaStatus(r, u)
+	if err != nil {
+		app.ErrorLog.Printf("disableMFA: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get two-factor authentication"}, rw)
+		return
+	}
+	if !status.Enabled {
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: "two-factor authentication not enabled"}, rw)
+		return
+	}
+
+	// the users confirm with a code, the administrators reset the other users
+	if tUser.ID == id {
+		if status.Required {
+			rw.WriteHeader(http.StatusBadRequest)
+			models.ToJSON(&models.GenericMessage{Message: "two-factor authentication is required by your roles"}, rw)
+			return
+		}
+		err = app.checkMFACode(r, id, status, mc.Code, true)
+		if err != nil {
+			app.ErrorLog.Printf("disableMFA: user %d:  %v\n", id, err)
+			if errors.Is(err, models.ErrInvalidMFACode) {
+				rw.WriteHeader(http.StatusBadRequest)
+				models.ToJSON(&models.GenericMessage{Message: "invalid code"}, rw)
+				return
+			}
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.ToJSON(&models.GenericMessage{Message: "unable to verify code"}, rw)
+			return
+		}
+	}
+
+	err = app.MFA.Disable(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("disableMFA: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to disable two-factor authentication"}, rw)
+		return
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("disableMFA: two-factor authentication of user %d disabled by user %d\n", id, tUser.ID)
+	}
+	e := &models.AuditEvent{Action: models.AuditMFADisable, TargetType: models.AuditTargetUser, TargetID: id,
+		Details: "confirmed with a code"}
+	if tUser.ID != id {
+		e.Details = "reset by an administrator without a code"
+	}
+	app.audit(r, e)
+	models.ToJSON(&models.GenericMessage{Message: "two-factor authentication disabled"}, rw)
+}
+
+// swagger:route PUT /users/role-types/{id}/mfa mfa setRoleMFA
+// Define if the users of role type {id} must use two-factor authentication
+//
+// The users without two-factor authentication enabled must enroll on their next login.
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: messageResponse
+//  400: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//  404: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// setRoleMFA handles PUT requests to require two-factor authentication to the users of a role
+func (app *Application) setRoleMFA(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the role data from the context
+	rm, ok := context.Get(r, KeyRoleMFA{}).(*models.RoleMFA)
+	if !ok {
+		app.ErrorLog.Printf("setRoleMFA: No role data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with role data"}, rw)
+		return
+	}
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("setRoleMFA: role %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	err = app.Users.SetRoleMFARequired(r.Context(), id, rm.MFARequired)
+	if err != nil {
+		app.ErrorLog.Printf("setRoleMFA: role %d:  %v\n", id, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusNotFound)
+			models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("Role type %d not found", id)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to update role type"}, rw)
+		return
+	}
+
+	if app.DebugOn {
+		app.InfoLog.Printf("setRoleMFA: role %d requires two-factor authentication: %v\n", id, rm.MFARequired)
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditRoleMFA, TargetType: models.AuditTargetRole, TargetID: id,
+		Details: fmt.Sprintf("two-factor authentication required: %v", rm.MFARequired)})
+	if rm.MFARequired {
+		models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("Role type %d requires two-factor authentication", id)}, rw)
+	} else {
+		models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("Role type %d does not require two-factor authentication", id)}, rw)
+	}
+}
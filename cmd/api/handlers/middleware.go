@@ -1,23 +1,49 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/gorilla/context"
 	"github.com/vgraveto/snippets/pkg/models"
 	"net/http"
+	"reflect"
+	"regexp"
 )
 
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-XSS-Protection", "1; mode=block")
 		w.Header().Set("X-Frame-Options", "deny")
+		// the responses are private unless the handler makes them public
+		w.Header().Set("Cache-Control", noStore)
 		next.ServeHTTP(w, r)
 	})
 }
 
 func (app *Application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.InfoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		app.InfoLog.Printf("%s - %s %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI(),
+			context.Get(r, KeyRequestID{}))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validRequestID matches the request IDs accepted from the X-Request-ID header
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID sets the ID of the request in the context and on the X-Request-ID header of the response,
+// the ID sent by the client or a proxy on the X-Request-ID header is kept when valid
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		context.Set(r, KeyRequestID{}, id)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -42,8 +68,8 @@ func (app *Application) recoverPanic(next http.Handler) http.Handler {
 }
 
 // ValidateJSONBody provides JSON body read and validation middleware for handlers
-// reads the JSON body to a given data object and validates its content
-// adds that data to the request context when valid and calls the next handler
+// reads the JSON body to a new object of the type of the given data object and validates its content
+// adds that object to the request context when valid and calls the next handler
 // otherwise aborts the request returning the response message with information
 func (app *Application) ValidateJSONBody(dataObj, contextKey interface{}) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -51,7 +77,7 @@ func (app *Application) ValidateJSONBody(dataObj, contextKey interface{}) func(n
 			rw.Header().Add("Content-Type", "application/json")
 
 			// verify that parameters are not nil that represents a misuse use of this middleware
-			if dataObj == nil || contextKey == nil {
+			if dataObj == nil || contextKey == nil || reflect.TypeOf(dataObj).Kind() != reflect.Ptr {
 				app.ErrorLog.Printf("ValidateJSONBody: Invalid parameters: dataObj - %#v contextKey - %#v\n", dataObj, contextKey)
 
 				rw.WriteHeader(http.StatusInternalServerError)
@@ -62,7 +88,9 @@ func (app *Application) ValidateJSONBody(dataObj, contextKey interface{}) func(n
 				app.InfoLog.Printf("ValidateJSONBody: dataObj - %#v contextKey - %#v\n", dataObj, contextKey)
 			}
 
-			err := models.FromJSON(dataObj, r.Body)
+			// the requests are served concurrently, each one reads its own object
+			obj := reflect.New(reflect.TypeOf(dataObj).Elem()).Interface()
+			err := models.FromJSON(obj, r.Body)
 			if err != nil {
 				app.ErrorLog.Printf("ValidateJSONBody: Deserializing error: %v\n", err)
 
@@ -72,7 +100,7 @@ func (app *Application) ValidateJSONBody(dataObj, contextKey interface{}) func(n
 			}
 
 			// validate the product
-			errs := app.Val.Validate(dataObj)
+			errs := app.Val.Validate(obj)
 			if len(errs) != 0 {
 				app.ErrorLog.Printf("ValidateJSONBody: Validating: %v\n", errs)
 
@@ -83,7 +111,7 @@ func (app *Application) ValidateJSONBody(dataObj, contextKey interface{}) func(n
 			}
 
 			// add the LoginUser to the context
-			context.Set(r, contextKey, dataObj)
+			context.Set(r, contextKey, obj)
 
 			// Call the next handler, which can be another middleware in the chain, or the final handler.
 			next.ServeHTTP(rw, r)
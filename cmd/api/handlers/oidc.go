@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gorilla/context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"time"
+)
+
+// KeyLoginOIDC is a key used for LoginOIDC object in the context
+type KeyLoginOIDC struct{}
+
+// swagger:route POST /users/login/oidc users loginOIDC
+// Return a JWT for the user of the OpenID Connect ID token
+//
+// The nonce of the authorization request of the ID token is required and it is accepted once.
+// The user is linked to the subject of the ID token, on the first login the link is made
+// to the user with the verified email of the token or to a new user, with the registration
+// default role, when auto provisioning is enabled. The ID tokens without a true email_verified
+// claim are never linked by their email.
+// The roles mapped from the groups of the user are granted on every login. The users with
+// two-factor authentication enabled, or required by one of their roles, receive a challenge
+// completed as on the password login.
+//
+// responses:
+//	200: userTokenResponse
+//	202: mfaChallengeResponse
+//  401: messageResponse
+//  404: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// loginOIDC handles POST requests to exchange an ID token for a JWT and a refresh token
+func (app *Application) loginOIDC(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	if app.OIDC == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		models.ToJSON(&models.GenericMessage{Message: "OIDC login is not enabled"}, rw)
+		return
+	}
+
+	// fetch the ID token from the context
+	lo, ok := context.Get(r, KeyLoginOIDC{}).(*models.LoginOIDC)
+	if !ok {
+		app.ErrorLog.Printf("loginOIDC: No ID token in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with ID token data"}, rw)
+		return
+	}
+
+	id, err := app.OIDC.VerifyIDToken(lo.IDToken, lo.Nonce)
+	if err == nil {
+		err = app.useNonce(r, lo.Nonce, id.Expires)
+	}
+	if err != nil {
+		app.ErrorLog.Printf("loginOIDC: %v\n", err)
+		app.audit(r, &models.AuditEvent{Action: models.AuditLoginFailed, TargetType: models.AuditTargetUser,
+			Details: "invalid OIDC ID token"})
+		rw.WriteHeader(http.StatusUnauthorized)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusUnauthorized)}, rw)
+		return
+	}
+
+	u, err := app.oidcUser(r, id)
+	if err != nil {
+		app.ErrorLog.Printf("loginOIDC: subject %q: %v\n", id.Subject, err)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.audit(r, &models.AuditEvent{Action: models.AuditLoginFailed, TargetType: models.AuditTargetUser,
+				Details: fmt.Sprintf("OIDC login of subject %q: %v", id.Subject, err)})
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusUnauthorized)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+
+	// the two-factor authentication completes the login, as on the password login
+	challenge, err := app.mfaChallenge(r, u)
+	if err != nil {
+		app.ErrorLog.Printf("loginOIDC: two-factor authentication: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to create two-factor authentication challenge"}, rw)
+		return
+	}
+	if challenge != nil {
+		if app.DebugOn {
+			app.InfoLog.Printf("loginOIDC: two-factor authentication challenge for user %d\n", u.ID)
+		}
+		rw.WriteHeader(http.StatusAccepted)
+		models.ToJSON(challenge, rw)
+		return
+	}
+
+	tokenmsg, err := app.newTokenMessage(r, u)
+	if err != nil {
+		app.ErrorLog.Printf("loginOIDC: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to create JWT"}, rw)
+		return
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditLogin, ActorID: u.ID, TargetType: models.AuditTargetUser,
+		TargetID: u.ID, Details: "OIDC login"})
+	models.ToJSON(tokenmsg, rw)
+}
+
+// useNonce records the nonce of an ID token as used until the token expires, on the denylist of
+// the tokens, an error is returned when it was already used so that the ID tokens are not replayed
+func (app *Application) useNonce(r *http.Request, nonce string, expires time.Time) error {
+	sum := sha256.Sum256([]byte(nonce))
+	key := hex.EncodeToString(sum[:])
+	used, err := app.Denylist.Contains(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	if used {
+		return errors.New("useNonce: the nonce of the ID token was already used")
+	}
+	return app.Denylist.Add(r.Context(), key, expires)
+}
+
+// oidcUser returns the active local user of the identity with the roles mapped from its groups,
+// ErrInvalidCredentials is returned when the identity can not be mapped to an active user
+func (app *Application) oidcUser(r *http.Request, id *models.OIDCIdentity) (*models.User, error) {
+	issuer := app.OIDCData.Issuer
+	u, err := app.Users.GetByIdentity(r.Context(), issuer, id.Subject)
+	if err != nil {
+		if !errors.Is(err, models.ErrNoRecord) {
+			return nil, err
+		}
+		// first login of the subject
+		if id.Email == "" || !id.EmailVerified {
+			return nil, fmt.Errorf("%w: no verified email", models.ErrInvalidCredentials)
+		}
+		u, err = app.Users.GetByEmail(r.Context(), id.Email)
+		if errors.Is(err, models.ErrNoRecord) {
+			if !app.OIDCData.AutoProvision {
+				return nil, fmt.Errorf("%w: user %q not registered", models.ErrInvalidCredentials, id.Email)
+			}
+			name := id.Name
+			if name == "" {
+				name = id.Email
+			}
+			var roles []int
+			roles, err = app.defaultRoles(r)
+			if err != nil {
+				return nil, err
+			}
+			var uid int
+			uid, err = app.Users.Provision(r.Context(), name, id.Email, roles)
+			if err == nil {
+				app.audit(r, &models.AuditEvent{Action: models.AuditUserCreate, ActorID: uid,
+					TargetType: models.AuditTargetUser, TargetID: uid,
+					Details: fmt.Sprintf("provisioned from OIDC subject %q", id.Subject)})
+				u, err = app.Users.Get(r.Context(), uid)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = app.Users.LinkIdentity(r.Context(), u.ID, issuer, id.Subject)
+		if err != nil {
+			return nil, err
+		}
+		if app.DebugOn {
+			app.InfoLog.Printf("oidcUser: subject %q linked to user %d\n", id.Subject, u.ID)
+		}
+	}
+	if !u.Active {
+		return nil, fmt.Errorf("%w: user %d is not active", models.ErrInvalidCredentials, u.ID)
+	}
+
+	roles, err := app.groupRoles(r, id.Groups)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) > 0 {
+		err = app.Users.AddRoles(r.Context(), u.ID, roles)
+		if err != nil {
+			return nil, err
+		}
+		before := u.Roles
+		u, err = app.Users.Get(r.Context(), u.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(u.Roles) != len(before) {
+			app.audit(r, &models.AuditEvent{Action: models.AuditUserRoles, ActorID: u.ID,
+				TargetType: models.AuditTargetUser, TargetID: u.ID,
+				Details: fmt.Sprintf("roles granted by OIDC groups: %v -> %v", before, u.Roles)})
+		}
+	}
+	return u, nil
+}
+
+// groupRoles returns the ID of the roles mapped from the groups of the user
+func (app *Application) groupRoles(r *http.Request, groups []string) ([]int, error) {
+	if len(groups) == 0 || len(app.OIDCData.GroupRoles) == 0 {
+		return nil, nil
+	}
+	roleTypes, err := app.Users.GetRoleTypes(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	roles := []int{}
+	for _, g := range groups {
+		role, ok := app.OIDCData.GroupRoles[g]
+		if !ok {
+			continue
+		}
+		found := false
+		for _, rt := range roleTypes {
+			if rt.Role == role {
+				roles = append(roles, rt.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("groupRoles: role %q of group %q not found", role, g)
+		}
+	}
+	return roles, nil
+}
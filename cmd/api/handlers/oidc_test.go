@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"github.com/vgraveto/snippets/pkg/models/mock"
+	"net/http"
+	"testing"
+)
+
+// newTestOIDC enables the login with the ID tokens of a local provider of the user
+func newTestOIDC(t *testing.T, app *Application, user mock.OIDCUser) *mock.OIDCServer {
+	idp, err := mock.NewOIDCServer("snippets-web", user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(idp.Close)
+	app.OIDCData = models.OIDCData{Issuer: idp.URL, ClientID: "snippets-web"}
+	app.OIDC = models.NewOIDCProvider(&app.OIDCData)
+	return idp
+}
+
+func TestLoginOIDC(t *testing.T) {
+	app := newTestApplication(t)
+	insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	idp := newTestOIDC(t, app, mock.OIDCUser{Subject: "alice-sub", Email: "alice@example.com", Name: "Alice"})
+	ts := newTestServer(t, app.Routes())
+
+	idToken, err := idp.IDToken("nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, body := ts.postJSON(t, "/users/login/oidc", &models.LoginOIDC{IDToken: idToken, Nonce: "nonce-1"})
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d %s", http.StatusOK, code, body)
+	}
+
+	// the ID token is not accepted again with its nonce, nor with another one or without any
+	tests := []struct {
+		name     string
+		nonce    string
+		wantCode int
+	}{
+		{"Replayed", "nonce-1", http.StatusUnauthorized},
+		{"Other nonce", "nonce-2", http.StatusUnauthorized},
+		{"No nonce", "", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.postJSON(t, "/users/login/oidc", &models.LoginOIDC{IDToken: idToken, Nonce: tt.nonce})
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestLoginOIDCMFARequired(t *testing.T) {
+	app := newTestApplication(t)
+	insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	idp := newTestOIDC(t, app, mock.OIDCUser{Subject: "alice-sub", Email: "alice@example.com", Name: "Alice"})
+	ts := newTestServer(t, app.Routes())
+
+	roleTypes, err := app.Users.GetRoleTypes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rt := range roleTypes {
+		if rt.Role == "user" {
+			if err = app.Users.SetRoleMFARequired(context.Background(), rt.ID, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// the user of a role that requires two-factor authentication receives a challenge, not the tokens
+	idToken, err := idp.IDToken("nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, body := ts.postJSON(t, "/users/login/oidc", &models.LoginOIDC{IDToken: idToken, Nonce: "nonce-1"})
+	if code != http.StatusAccepted {
+		t.Fatalf("want %d; got %d %s", http.StatusAccepted, code, body)
+	}
+	challenge := &models.MFAChallenge{}
+	if err = models.FromJSON(challenge, bytes.NewReader(body)); err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Challenge == "" || !challenge.EnrollmentRequired {
+		t.Errorf("want a challenge with the enrollment required; got %+v", challenge)
+	}
+	if bytes.Contains(body, []byte(`"token"`)) {
+		t.Errorf("want no token on the challenge; got %s", body)
+	}
+}
+
+func TestLoginOIDCUnverifiedEmail(t *testing.T) {
+	app := newTestApplication(t)
+	insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	idp := newTestOIDC(t, app, mock.OIDCUser{Subject: "mallory-sub", Email: "alice@example.com", UnverifiedEmail: true})
+	ts := newTestServer(t, app.Routes())
+
+	// the ID token without the email_verified claim is not linked to the user of the email
+	idToken, err := idp.IDToken("nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, _ := ts.postJSON(t, "/users/login/oidc", &models.LoginOIDC{IDToken: idToken, Nonce: "nonce-1"})
+	if code != http.StatusUnauthorized {
+		t.Errorf("want %d; got %d", http.StatusUnauthorized, code)
+	}
+}
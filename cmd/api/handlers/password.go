@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// checkPassword replies with the rules of the password policy the password of the field does not follow,
+// prefixed by the field name, and returns false when the password is not valid
+func (app *Application) checkPassword(rw http.ResponseWriter, fn, field, password, name, email string) bool {
+	msgs, err := app.PasswordPolicy.Check(password, name, email)
+	if err != nil {
+		app.ErrorLog.Printf("%s: %v\n", fn, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to check password"}, rw)
+		return false
+	}
+	if len(msgs) == 0 {
+		return true
+	}
+	for i, msg := range msgs {
+		msgs[i] = field + ": " + msg
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("%s: password rejected: %v\n", fn, msgs)
+	}
+	rw.WriteHeader(http.StatusUnprocessableEntity)
+	models.ToJSON(&models.ValidationMessagesError{Messages: msgs}, rw)
+	return false
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// KeyRegisterUser is a key used for RegisterUser object in the context
+type KeyRegisterUser struct{}
+
+// KeyVerifyEmail is a key used for VerifyEmail object in the context
+type KeyVerifyEmail struct{}
+
+// KeyUserApproval is a key used for UserApproval object in the context
+type KeyUserApproval struct{}
+
+// swagger:route POST /users/register registration registerUser
+// Register a new inactive user when the public registration is enabled
+//
+// Depending on the registration mode the user is activated after the verification of the email
+// or after the approval of an administrator. The same response is returned when the email is
+// already registered, the users that did not verify their email yet receive the link again
+//
+// responses:
+//	200: messageResponse
+//  400: messageResponse
+//  403: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// registerUser handles POST requests for the public registration of new users
+func (app *Application) registerUser(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	if app.Registration.Mode != models.RegistrationVerify && app.Registration.Mode != models.RegistrationApproval {
+		app.ErrorLog.Printf("registerUser: %v\n", models.ErrRegistrationClosed)
+		rw.WriteHeader(http.StatusForbidden)
+		models.ToJSON(&models.GenericMessage{Message: models.ErrRegistrationClosed.Error()}, rw)
+		return
+	}
+
+	// fetch the register user from the context
+	user, ok := context.Get(r, KeyRegisterUser{}).(*models.RegisterUser)
+	if !ok {
+		app.ErrorLog.Printf("registerUser: No user data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with user data"}, rw)
+		return
+	}
+
+	if !models.EmailDomainAllowed(user.Email, app.Registration.AllowedDomains) {
+		app.ErrorLog.Printf("registerUser: %q: %v\n", user.Email, models.ErrEmailDomainNotAllowed)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: models.ErrEmailDomainNotAllowed.Error()}, rw)
+		return
+	}
+
+	if !app.checkPassword(rw, "registerUser", "password", user.Password, user.Name, user.Email) {
+		return
+	}
+
+	// registered users get the configured default role
+	roles, err := app.defaultRoles(r)
+	if err != nil {
+		app.ErrorLog.Printf("registerUser: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Unable to get role types list"}, rw)
+		return
+	}
+
+	// the reply never discloses if the email belongs to an user
+	approval := app.Registration.Mode == models.RegistrationApproval
+	msg := &models.GenericMessage{Message: "Registration done, please follow the link sent to your email to activate the account"}
+	if approval {
+		msg = &models.GenericMessage{Message: "Registration done, the account will be activated after the approval of an administrator"}
+	}
+
+	id, err := app.Users.Register(r.Context(), user.Name, user.Email, user.Password, roles, approval)
+	if errors.Is(err, models.ErrDuplicateEmail) {
+		app.InfoLog.Printf("registerUser: email %q is already registered\n", user.Email)
+		if !approval {
+			app.resendVerification(r, user.Email)
+		}
+		models.ToJSON(msg, rw)
+		return
+	}
+	if err != nil {
+		app.ErrorLog.Printf("registerUser: %v\n", err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditUserCreate, TargetType: models.AuditTargetUser, TargetID: id,
+		Details: fmt.Sprintf("registration of %q", user.Email)})
+
+	if approval {
+		if app.DebugOn {
+			app.InfoLog.Printf("registerUser: user %d waiting for approval\n", id)
+		}
+		models.ToJSON(msg, rw)
+		return
+	}
+
+	// a failure is only logged, registering again sends the link while the user is not verified
+	err = app.sendVerification(r, id, user.Name, user.Email)
+	if err != nil {
+		app.ErrorLog.Printf("registerUser: %v\n", err)
+	} else if app.DebugOn {
+		app.InfoLog.Printf("registerUser: verification token sent to user %d\n", id)
+	}
+	models.ToJSON(msg, rw)
+}
+
+// resendVerification sends a new verification link to the inactive user of the email when the
+// last one was not used, so that the rejected users are never activated
+func (app *Application) resendVerification(r *http.Request, email string) {
+	u, err := app.Users.GetByEmail(r.Context(), email)
+	if err != nil {
+		app.ErrorLog.Printf("resendVerification: %v\n", err)
+		return
+	}
+	if u.Active {
+		return
+	}
+	unused, err := app.UserTokens.HasUnused(r.Context(), u.ID, models.TokenPurposeVerifyEmail)
+	if err != nil {
+		app.ErrorLog.Printf("resendVerification: %v\n", err)
+		return
+	}
+	if !unused {
+		return
+	}
+	err = app.sendVerification(r, u.ID, u.Name, u.Email)
+	if err != nil {
+		app.ErrorLog.Printf("resendVerification: %v\n", err)
+		return
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("resendVerification: verification token sent again to user %d\n", u.ID)
+	}
+}
+
+// sendVerification emails a new verification link to the user, the previous ones are no longer valid
+func (app *Application) sendVerification(r *http.Request, id int, name, email string) error {
+	token, err := app.UserTokens.New(r.Context(), id, models.TokenPurposeVerifyEmail, app.Registration.VerifyTokenValidTime)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Hello %s,\r\n\r\n"+
+		"Thank you for registering. Use the link below to verify your email and activate your account:\r\n\r\n"+
+		"%s?token=%s\r\n\r\n"+
+		"The link is valid for %v. If you did not register, please ignore this email.\r\n",
+		name, app.Registration.VerifyEmailURL, token, app.Registration.VerifyTokenValidTime)
+	return app.Mailer.Send(email, "Snippets email verification", body)
+}
+
+// defaultRoles returns the ID of the role assigned to the registered users
+func (app *Application) defaultRoles(r *http.Request) ([]int, error) {
+	if app.Registration.DefaultRole == "" {
+		return nil, nil
+	}
+	roleTypes, err := app.Users.GetRoleTypes(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	for _, rt := range roleTypes {
+		if rt.Role == app.Registration.DefaultRole {
+			return []int{rt.ID}, nil
+		}
+	}
+	return nil, fmt.Errorf("defaultRoles: role %q not found", app.Registration.DefaultRole)
+}
+
+// swagger:route POST /users/verify-email registration verifyEmail
+// Activate a registered user with the verification token received by email
+//
+// responses:
+//	200: messageResponse
+//  400: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// verifyEmail handles POST requests to activate the owner of a verification token
+func (app *Application) verifyEmail(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the verification data from the context
+	ve, ok := context.Get(r, KeyVerifyEmail{}).(*models.VerifyEmail)
+	if !ok {
+		app.ErrorLog.Printf("verifyEmail: No user data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with user data"}, rw)
+		return
+	}
+
+	id, err := app.UserTokens.Consume(r.Context(), ve.Token, models.TokenPurposeVerifyEmail)
+	if err != nil {
+		app.ErrorLog.Printf("verifyEmail: %v\n", err)
+		if errors.Is(err, models.ErrInvalidToken) {
+			rw.WriteHeader(http.StatusBadRequest)
+			models.ToJSON(&models.GenericMessage{Message: err.Error()}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to validate verification token"}, rw)
+		return
+	}
+
+	err = app.Users.Activate(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("verifyEmail: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to activate user"}, rw)
+		return
+	}
+
+	if app.DebugOn {
+		app.InfoLog.Printf("verifyEmail: user %d activated\n", id)
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditUserUpdate, ActorID: id, TargetType: models.AuditTargetUser,
+		TargetID: id, Details: "email verified, user activated"})
+	models.ToJSON(&models.GenericMessage{Message: "Email verified, the account is now active"}, rw)
+}
+
+// swagger:route GET /users/pending registration listPendingUsers
+// Return the list of registered users waiting for the approval of an administrator
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: usersResponse
+//  401: messageResponse
+//  403: messageResponse
+//	500: messageResponse
+
+// listPendingUsers handles GET requests and returns the users waiting for approval
+func (app *Application) listPendingUsers(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	users, err := app.Users.GetPending(r.Context())
+	if err != nil {
+		app.ErrorLog.Printf("listPendingUsers: Unable to get users  %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Unable to get pending users list"}, rw)
+		return
+	}
+
+	err = models.ToJSON(users, rw)
+	if err != nil {
+		// we should never be here but log the error just incase
+		app.ErrorLog.Printf("listPendingUsers: Unable to serializing users  %v\n", err)
+	}
+}
+
+// swagger:route PUT /users/{id}/approval registration approveUser
+// Approve or reject the pending registration of user {id}
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: messageResponse
+//  400: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//  404: messageResponse
+//	500: messageResponse
+
+// approveUser handles PUT requests with the administrator decision on a pending registration
+func (app *Application) approveUser(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the approval from the context
+	approval, ok := context.Get(r, KeyUserApproval{}).(*models.UserApproval)
+	if !ok {
+		app.ErrorLog.Printf("approveUser: No approval data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with approval data"}, rw)
+		return
+	}
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("approveUser: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	err = app.Users.Approve(r.Context(), id, approval.Approved)
+	if err != nil {
+		app.ErrorLog.Printf("approveUser: user %d:  %v\n", id, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusNotFound)
+			models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("No pending registration for user %d", id)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("Unable to update user %d", id)}, rw)
+		return
+	}
+
+	msg := fmt.Sprintf("Registration of user %d rejected", id)
+	if approval.Approved {
+		msg = fmt.Sprintf("Registration of user %d approved", id)
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("approveUser: %s\n", msg)
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditUserUpdate, TargetType: models.AuditTargetUser, TargetID: id,
+		Details: msg})
+	models.ToJSON(&models.GenericMessage{Message: msg}, rw)
+}
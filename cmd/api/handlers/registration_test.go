@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newRegistrationApplication returns an application with the registration of the mode
+func newRegistrationApplication(t *testing.T, mode string) *Application {
+	app := newTestApplication(t)
+	app.Registration = models.RegistrationData{
+		Mode:                 mode,
+		DefaultRole:          "user",
+		VerifyEmailURL:       "https://snippets.example.com/user/verify-email",
+		VerifyTokenValidTime: time.Hour,
+	}
+	return app
+}
+
+func TestRegisterUserVerify(t *testing.T) {
+	app := newRegistrationApplication(t, models.RegistrationVerify)
+	mailer := app.Mailer.(*testMailer)
+	ts := newTestServer(t, app.Routes())
+	bob := &models.RegisterUser{Name: "Bob", Email: "bob@example.com", Password: "Pa$$word1234"}
+
+	code, _, want := ts.postJSON(t, "/users/register", bob)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d %s", http.StatusOK, code, want)
+	}
+	first := mailedToken(t, app, bob.Email)
+
+	// the user that did not verify the email gets the same response and a new link
+	code, _, body := ts.postJSON(t, "/users/register", bob)
+	if code != http.StatusOK || !bytes.Equal(body, want) {
+		t.Errorf("want %d %s; got %d %s", http.StatusOK, want, code, body)
+	}
+	if n := len(mailer.sent(bob.Email)); n != 2 {
+		t.Fatalf("want the link sent again; got %d messages", n)
+	}
+	second := mailedToken(t, app, bob.Email)
+	code, _, _ = ts.postJSON(t, "/users/verify-email", &models.VerifyEmail{Token: first})
+	if code != http.StatusBadRequest {
+		t.Errorf("want %d for the previous link; got %d", http.StatusBadRequest, code)
+	}
+	code, _, body = ts.postJSON(t, "/users/verify-email", &models.VerifyEmail{Token: second})
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d %s", http.StatusOK, code, body)
+	}
+
+	// the active user gets the same response and no message
+	code, _, body = ts.postJSON(t, "/users/register", bob)
+	if code != http.StatusOK || !bytes.Equal(body, want) {
+		t.Errorf("want %d %s; got %d %s", http.StatusOK, want, code, body)
+	}
+	if n := len(mailer.sent(bob.Email)); n != 2 {
+		t.Errorf("want no message to the active user; got %d messages", n)
+	}
+}
+
+func TestRegisterUserMailFailure(t *testing.T) {
+	app := newRegistrationApplication(t, models.RegistrationVerify)
+	mailer := app.Mailer.(*testMailer)
+	ts := newTestServer(t, app.Routes())
+	bob := &models.RegisterUser{Name: "Bob", Email: "bob@example.com", Password: "Pa$$word1234"}
+
+	mailer.err = errors.New("connection refused")
+	code, _, want := ts.postJSON(t, "/users/register", bob)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d %s", http.StatusOK, code, want)
+	}
+
+	// registering again sends the link that could not be sent
+	mailer.err = nil
+	code, _, body := ts.postJSON(t, "/users/register", bob)
+	if code != http.StatusOK || !bytes.Equal(body, want) {
+		t.Errorf("want %d %s; got %d %s", http.StatusOK, want, code, body)
+	}
+	code, _, body = ts.postJSON(t, "/users/verify-email", &models.VerifyEmail{Token: mailedToken(t, app, bob.Email)})
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d %s", http.StatusOK, code, body)
+	}
+}
+
+func TestRegisterUserRejected(t *testing.T) {
+	app := newRegistrationApplication(t, models.RegistrationApproval)
+	mailer := app.Mailer.(*testMailer)
+	ts := newTestServer(t, app.Routes())
+	bob := &models.RegisterUser{Name: "Bob", Email: "bob@example.com", Password: "Pa$$word1234"}
+
+	code, _, want := ts.postJSON(t, "/users/register", bob)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d %s", http.StatusOK, code, want)
+	}
+	code, _, body := ts.postJSON(t, "/users/register", bob)
+	if code != http.StatusOK || !bytes.Equal(body, want) {
+		t.Errorf("want %d %s; got %d %s", http.StatusOK, want, code, body)
+	}
+
+	// the rejected user never receives a verification link, even after a change of the mode
+	u, err := app.Users.GetByEmail(context.Background(), bob.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = app.Users.Approve(context.Background(), u.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	app.Registration.Mode = models.RegistrationVerify
+	code, _, body = ts.postJSON(t, "/users/register", bob)
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d %s", http.StatusOK, code, body)
+	}
+	if n := len(mailer.sent(bob.Email)); n != 0 {
+		t.Errorf("want no message to the rejected user; got %d messages", n)
+	}
+}
@@ -11,12 +11,17 @@ func (app *Application) Routes() http.Handler {
 
 	mux := mux.NewRouter()
 	// Wrap the existing chain with the logRequest middleware.
-	mux.Use(app.recoverPanic, app.logRequest, secureHeaders)
+	mux.Use(app.recoverPanic, requestID, app.logRequest, secureHeaders)
 
 	// GET handlers for API
 	getR := mux.Methods(http.MethodGet).Subrouter()
 	getR.HandleFunc("/", home)
 	getR.HandleFunc("/ping", ping)
+	getR.HandleFunc("/health", app.health)
+	getR.Handle("/health/pool", AddMiddleware(http.HandlerFunc(app.poolStats),
+		app.authorize("administrator"),
+		app.authenticate))
+	getR.HandleFunc("/.well-known/jwks.json", app.listKeys)
 	getR.HandleFunc("/snippets", app.listAllSnippets)
 	getR.HandleFunc("/snippets/{id:[1-9][0-9]*}", app.getSimpleSnippet)
 	getR.Handle("/users", AddMiddleware(http.HandlerFunc(app.listAllUsers),
@@ -25,6 +30,23 @@ func (app *Application) Routes() http.Handler {
 	getR.Handle("/users/{id:[1-9][0-9]*}", AddMiddleware(http.HandlerFunc(app.getSimpleUser),
 		app.authorize("self"),
 		app.authenticate))
+	getR.Handle("/users/pending", AddMiddleware(http.HandlerFunc(app.listPendingUsers),
+		app.authorize("administrator"),
+		app.authenticate))
+	getR.Handle("/users/{id:[1-9][0-9]*}/api-keys", AddMiddleware(http.HandlerFunc(app.listAPIKeys),
+		app.authorize("self"),
+		app.requireJWT,
+		app.authenticate))
+	getR.Handle("/users/{id:[1-9][0-9]*}/mfa", AddMiddleware(http.HandlerFunc(app.getMFA),
+		app.authorize("self"),
+		app.authenticate))
+	getR.Handle("/users/{id:[1-9][0-9]*}/sessions", AddMiddleware(http.HandlerFunc(app.listSessions),
+		app.authorize("self"),
+		app.requireJWT,
+		app.authenticate))
+	getR.Handle("/audit", AddMiddleware(http.HandlerFunc(app.listAudit),
+		app.authorize("administrator"),
+		app.authenticate))
 	getR.Handle("/users/role-types", AddMiddleware(http.HandlerFunc(app.listAllRoleTypes),
 		app.authorize("administrator"),
 		app.authenticate))
@@ -37,6 +59,35 @@ func (app *Application) Routes() http.Handler {
 		app.authenticate))
 	postR.Handle("/users/login", AddMiddleware(http.HandlerFunc(app.loginUser),
 		app.ValidateJSONBody(&models.LoginUser{}, KeyLoginUser{})))
+	postR.Handle("/users/login/oidc", AddMiddleware(http.HandlerFunc(app.loginOIDC),
+		app.ValidateJSONBody(&models.LoginOIDC{}, KeyLoginOIDC{})))
+	postR.Handle("/users/login/mfa", AddMiddleware(http.HandlerFunc(app.verifyMFA),
+		app.ValidateJSONBody(&models.VerifyMFA{}, KeyVerifyMFA{})))
+	postR.Handle("/users/login/mfa/enroll", AddMiddleware(http.HandlerFunc(app.enrollMFAChallenge),
+		app.ValidateJSONBody(&models.MFAChallengeEnrollment{}, KeyMFAChallengeEnrollment{})))
+	postR.Handle("/users/token/refresh", AddMiddleware(http.HandlerFunc(app.refreshToken),
+		app.ValidateJSONBody(&models.RefreshToken{}, KeyRefreshToken{})))
+	postR.Handle("/users/logout", AddMiddleware(http.HandlerFunc(app.logoutUser),
+		app.ValidateJSONBody(&models.LogoutUser{}, KeyLogoutUser{}),
+		app.requireJWT,
+		app.authenticate))
+	postR.Handle("/users/{id:[1-9][0-9]*}/api-keys", AddMiddleware(http.HandlerFunc(app.createAPIKey),
+		app.ValidateJSONBody(&models.CreateAPIKey{}, KeyCreateAPIKey{}),
+		app.authorize("self"),
+		app.requireJWT,
+		app.authenticate))
+	postR.Handle("/users/{id:[1-9][0-9]*}/mfa", AddMiddleware(http.HandlerFunc(app.enrollMFA),
+		app.authorize("self"),
+		app.requireJWT,
+		app.authenticate))
+	postR.Handle("/users/forgot-password", AddMiddleware(http.HandlerFunc(app.forgotPassword),
+		app.ValidateJSONBody(&models.ForgotPassword{}, KeyForgotPassword{})))
+	postR.Handle("/users/reset-password", AddMiddleware(http.HandlerFunc(app.resetUserPassword),
+		app.ValidateJSONBody(&models.ResetUserPassword{}, KeyResetUserPassword{})))
+	postR.Handle("/users/register", AddMiddleware(http.HandlerFunc(app.registerUser),
+		app.ValidateJSONBody(&models.RegisterUser{}, KeyRegisterUser{})))
+	postR.Handle("/users/verify-email", AddMiddleware(http.HandlerFunc(app.verifyEmail),
+		app.ValidateJSONBody(&models.VerifyEmail{}, KeyVerifyEmail{})))
 	postR.Handle("/users", AddMiddleware(http.HandlerFunc(app.createUser),
 		app.ValidateJSONBody(&models.CreateUser{}, KeyCreateUser{}),
 		app.authorize("administrator"),
@@ -47,6 +98,41 @@ func (app *Application) Routes() http.Handler {
 	putR.Handle("/users/{id}/change-password", AddMiddleware(http.HandlerFunc(app.changeUserPassword),
 		app.ValidateJSONBody(&models.ChangeUserPassword{}, KeyChangeUserPassword{}),
 		app.authorize("self"),
+		app.requireJWT,
+		app.authenticate))
+	putR.Handle("/users/{id:[1-9][0-9]*}/approval", AddMiddleware(http.HandlerFunc(app.approveUser),
+		app.ValidateJSONBody(&models.UserApproval{}, KeyUserApproval{}),
+		app.authorize("administrator"),
+		app.authenticate))
+	putR.Handle("/users/{id:[1-9][0-9]*}/mfa", AddMiddleware(http.HandlerFunc(app.confirmMFA),
+		app.ValidateJSONBody(&models.MFACode{}, KeyMFACode{}),
+		app.authorize("self"),
+		app.requireJWT,
+		app.authenticate))
+	putR.Handle("/users/role-types/{id:[1-9][0-9]*}/mfa", AddMiddleware(http.HandlerFunc(app.setRoleMFA),
+		app.ValidateJSONBody(&models.RoleMFA{}, KeyRoleMFA{}),
+		app.authorize("administrator"),
+		app.requireJWT,
+		app.authenticate))
+
+	// DELETE handlers for API
+	deleteR := mux.Methods(http.MethodDelete).Subrouter()
+	deleteR.Handle("/users/{id:[1-9][0-9]*}/api-keys/{keyId:[1-9][0-9]*}", AddMiddleware(http.HandlerFunc(app.revokeAPIKey),
+		app.authorize("self"),
+		app.requireJWT,
+		app.authenticate))
+	deleteR.Handle("/users/{id:[1-9][0-9]*}/lockout", AddMiddleware(http.HandlerFunc(app.unlockUser),
+		app.authorize("administrator"),
+		app.requireJWT,
+		app.authenticate))
+	deleteR.Handle("/users/{id:[1-9][0-9]*}/mfa", AddMiddleware(http.HandlerFunc(app.disableMFA),
+		app.ValidateJSONBody(&models.MFACode{}, KeyMFACode{}),
+		app.authorize("self"),
+		app.requireJWT,
+		app.authenticate))
+	deleteR.Handle("/users/{id:[1-9][0-9]*}/sessions/{sid:[1-9][0-9]*}", AddMiddleware(http.HandlerFunc(app.revokeSession),
+		app.authorize("self"),
+		app.requireJWT,
 		app.authenticate))
 
 	// handler for documentation
@@ -5,6 +5,7 @@ import (
 	"github.com/gorilla/context"
 	"github.com/vgraveto/snippets/pkg/models"
 	"net/http"
+	"time"
 )
 
 // KeySnippet is a key used for the Snippet object in the context
@@ -13,15 +14,19 @@ type KeySnippetCreate struct{}
 // swagger:route GET /snippets snippets listSnippets
 // Return a list of snippets from the database
 //
+// The response has an ETag, a request with its value on the If-None-Match header returns a 304
+// when the list did not change. The list is public and must be revalidated on each use.
+//
 // responses:
 //	200: snippetsResponse
+//	304: notModifiedResponse
 //	500: messageResponse
 
 // listAllSnippets handles GET requests and returns all current snippets
 func (app *Application) listAllSnippets(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Add("Content-Type", "application/json")
 
-	sp, err := app.Snippets.Latest()
+	sp, err := app.Snippets.Latest(r.Context())
 	if err != nil {
 		app.ErrorLog.Printf("listAllSnippets: Unable to get snipplets  %v\n", err)
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -29,7 +34,8 @@ func (app *Application) listAllSnippets(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = models.ToJSON(sp, rw)
+	// a new snippet changes the list, the clients revalidate it with its ETag
+	err = writeCached(rw, r, sp, time.Time{}, "public, no-cache")
 	if err != nil {
 		// we should never be here but log the error just incase
 		app.ErrorLog.Printf("listAllSnippets: Unable to serializing snipplets  %v\n", err)
@@ -39,8 +45,13 @@ func (app *Application) listAllSnippets(rw http.ResponseWriter, r *http.Request)
 // swagger:route GET /snippets/{id} snippets listSingleSnippet
 // Return a single snippet from the database
 //
+// The response has an ETag and a Last-Modified validator for the conditional requests, that
+// return a 304 when the snippet did not change. The snippet is public and it can be used
+// without revalidation for the maxAge of the API, never after its expiration.
+//
 // responses:
 //	200: snippetResponse
+//	304: notModifiedResponse
 //	400: messageResponse
 //	404: messageResponse
 //  500: messageResponse
@@ -58,7 +69,7 @@ func (app *Application) getSimpleSnippet(rw http.ResponseWriter, r *http.Request
 		return
 	}
 
-	sp, err := app.Snippets.Get(id)
+	sp, err := app.Snippets.Get(r.Context(), id)
 	switch err {
 	case nil:
 		break
@@ -74,7 +85,8 @@ func (app *Application) getSimpleSnippet(rw http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = models.ToJSON(sp, rw)
+	// the snippets do not change after they are created
+	err = writeCached(rw, r, sp, sp.Created, publicMaxAge(app.SnippetMaxAge, sp.Expires))
 	if err != nil {
 		// we should never be here but log the error just incase
 		app.ErrorLog.Printf("getSimpleSnippet: Unable to serializing snipplet %d:  %v\n", id, err)
@@ -112,20 +124,23 @@ func (app *Application) createSnippet(rw http.ResponseWriter, r *http.Request) {
 		app.InfoLog.Printf("createSnippet: Inserting snippet: %#v\n", spc)
 	}
 
-	id, err := app.Snippets.Insert(spc.Title, spc.Content, spc.Expires)
+	id, err := app.Snippets.Insert(r.Context(), spc.Title, spc.Content, spc.Expires)
 	if err != nil {
 		app.ErrorLog.Printf("createSnippet: inserting: %v\n", err)
 		rw.WriteHeader(http.StatusInternalServerError)
 		models.ToJSON(&models.GenericMessage{"Problem inserting snippet data"}, rw)
 		return
 	}
-	sp, err := app.Snippets.Get(id)
+	// the new snippet is read from the primary database, the replicas may not have it yet
+	sp, err := app.Snippets.Get(models.WithPrimary(r.Context()), id)
 	if err != nil {
 		app.ErrorLog.Printf("createSnippet: geting: %v\n", err)
 		rw.WriteHeader(http.StatusInternalServerError)
 		models.ToJSON(&models.GenericMessage{"Problem geting snippet data"}, rw)
 		return
 	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditSnippetCreate, TargetType: models.AuditTargetSnippet,
+		TargetID: id, Details: fmt.Sprintf("snippet %q", sp.Title)})
 
 	models.ToJSON(sp, rw)
 }
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/vgraveto/snippets/pkg/models"
+	"github.com/vgraveto/snippets/pkg/models/dbmemory"
+	"github.com/vgraveto/snippets/pkg/models/mock"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestApplication returns an application with the models of the memory storage
+func newTestApplication(t *testing.T) *Application {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	hasher, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := models.NewPasswordPolicy(models.PasswordPolicyData{MinLength: 10, DisallowPersonal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := mock.TokenData
+	td.TokenValidTime = 15 * time.Minute
+	td.TokenRefreshValidTime = time.Hour
+
+	db := dbmemory.New()
+	return &Application{
+		ErrorLog:              log.New(ioutil.Discard, "", 0),
+		InfoLog:               log.New(ioutil.Discard, "", 0),
+		Snippets:              dbmemory.NewSnippetModel(db),
+		Users:                 dbmemory.NewUserModel(db, hasher, log.New(ioutil.Discard, "", 0)),
+		Tokens:                models.NewTokenModel(&td),
+		Val:                   models.NewValidation(),
+		UserTokens:            dbmemory.NewUserTokenModel(db),
+		RefreshTokens:         dbmemory.NewRefreshTokenModel(db),
+		RefreshTokenValidTime: td.TokenRefreshValidTime,
+		Denylist:              dbmemory.NewTokenDenylistModel(db),
+		Sessions:              dbmemory.NewSessionModel(db),
+		APIKeys:               dbmemory.NewAPIKeyModel(db),
+		MFA:                   dbmemory.NewMFAModel(db),
+		MFAIssuer:             "Snippets",
+		Audit:                 dbmemory.NewAuditModel(db),
+		Throttle: models.NewLoginThrottle(models.NewMemoryLoginAttempts(), models.ThrottleData{
+			FreeAttempts:      1,
+			LockoutAttempts:   3,
+			IPFreeAttempts:    10,
+			IPLockoutAttempts: 20,
+			BaseDelay:         time.Minute,
+			MaxDelay:          time.Hour,
+			LockoutTime:       time.Hour,
+			ResetAfter:        time.Hour,
+		}),
+		SnippetMaxAge:       time.Minute,
+		PasswordPolicy:      policy,
+		Mailer:              &testMailer{},
+		ResetPasswordURL:    "https://snippets.example.com/user/reset-password",
+		ResetTokenValidTime: time.Hour,
+	}
+}
+
+// testMailer keeps the bodies of the messages sent to each address, Send fails with err when it is set
+type testMailer struct {
+	mu     sync.Mutex
+	bodies map[string][]string
+	err    error
+}
+
+func (m *testMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if m.bodies == nil {
+		m.bodies = map[string][]string{}
+	}
+	m.bodies[to] = append(m.bodies[to], body)
+	return nil
+}
+
+// sent returns the bodies of the messages sent to the address
+func (m *testMailer) sent(to string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bodies[to]
+}
+
+// insertUser inserts an active user with the role and returns its ID
+func insertUser(t *testing.T, app *Application, email, password, role string) int {
+	ctx := context.Background()
+	roleTypes, err := app.Users.GetRoleTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roles []int
+	for _, rt := range roleTypes {
+		if rt.Role == role {
+			roles = append(roles, rt.ID)
+		}
+	}
+	if err = app.Users.Insert(ctx, "Alice", email, password, roles); err != nil {
+		t.Fatal(err)
+	}
+	u, err := app.Users.GetByEmail(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.ID
+}
+
+// testServer is a test server of the routes of the application
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return &testServer{ts}
+}
+
+// do sends the request with the headers and returns the status code, the headers and the body of the response
+func (ts *testServer) do(t *testing.T, method, path, body string, headers map[string]string) (int, http.Header, []byte) {
+	req, err := http.NewRequest(method, ts.URL+path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	b, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, b
+}
+
+// get sends a GET request with the headers
+func (ts *testServer) get(t *testing.T, path string, headers map[string]string) (int, http.Header, []byte) {
+	return ts.do(t, http.MethodGet, path, "", headers)
+}
+
+// postJSON sends a POST request with the JSON of v as its body
+func (ts *testServer) postJSON(t *testing.T, path string, v interface{}) (int, http.Header, []byte) {
+	var b bytes.Buffer
+	if err := models.ToJSON(v, &b); err != nil {
+		t.Fatal(err)
+	}
+	return ts.do(t, http.MethodPost, path, b.String(), map[string]string{"Content-Type": "application/json"})
+}
+
+// authJSON sends the request with the JWT or API key on the Authentication header and the JSON of v as its body,
+// the request has no body when v is nil
+func (ts *testServer) authJSON(t *testing.T, method, path, credential string, v interface{}) (int, http.Header, []byte) {
+	var b bytes.Buffer
+	if v != nil {
+		if err := models.ToJSON(v, &b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ts.do(t, method, path, b.String(), map[string]string{"Content-Type": "application/json", "Authentication": credential})
+}
+
+// login logs the user in with the password and returns the tokens
+func (ts *testServer) login(t *testing.T, email, password string) *models.TokenMessage {
+	t.Helper()
+	code, _, body := ts.postJSON(t, "/users/login", &models.LoginUser{Username: email, Password: password})
+	if code != http.StatusOK {
+		t.Fatalf("login %s: want %d; got %d %s", email, http.StatusOK, code, body)
+	}
+	var tm models.TokenMessage
+	if err := json.Unmarshal(body, &tm); err != nil {
+		t.Fatal(err)
+	}
+	return &tm
+}
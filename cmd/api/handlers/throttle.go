@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"strconv"
+)
+
+// loginKeys returns the throttling keys of the account and of the client address of the login
+func (app *Application) loginKeys(r *http.Request, email string) []string {
+	keys := []string{models.AccountKey(email)}
+	if ip := models.ClientIP(r, app.TrustedProxies); ip != "" {
+		keys = append(keys, models.IPKey(ip))
+	}
+	return keys
+}
+
+// setRetryAfter adds the Retry-After header when err is a *models.LoginLockedError and returns true
+func setRetryAfter(rw http.ResponseWriter, err error) bool {
+	var locked *models.LoginLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	rw.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+	return true
+}
+
+// swagger:route DELETE /users/{id}/lockout users unlockUser
+// Remove the lockout and the failed logins of user {id}
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//  404: messageResponse
+//	500: messageResponse
+
+// unlockUser handles DELETE requests to unlock the logins of the user
+func (app *Application) unlockUser(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("unlockUser: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	u, err := app.Users.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("unlockUser: user %d:  %v\n", id, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusNotFound)
+			models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("User %d not found", id)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+
+	err = app.Throttle.Unlock(r.Context(), models.AccountKey(u.Email))
+	if err != nil {
+		app.ErrorLog.Printf("unlockUser: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to unlock user"}, rw)
+		return
+	}
+
+	if app.DebugOn {
+		app.InfoLog.Printf("unlockUser: user %d unlocked\n", id)
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditUserUpdate, TargetType: models.AuditTargetUser, TargetID: id,
+		Details: "login lockout removed"})
+	models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("User %d unlocked", id)}, rw)
+}
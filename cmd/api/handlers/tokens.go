@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KeyRefreshToken is a key used for RefreshToken object in the context
+type KeyRefreshToken struct{}
+
+// KeyLogoutUser is a key used for LogoutUser object in the context
+type KeyLogoutUser struct{}
+
+// KeyTokenClaims is a key used for the claims of the authenticated JWT in the context
+type KeyTokenClaims struct{}
+
+// swagger:route POST /users/token/refresh users refreshToken
+// Exchange a refresh token for a new JWT and a new refresh token
+//
+// The refresh token is rotated on each use, the reuse of an already used refresh token
+// revokes all the refresh tokens issued since the login
+//
+// responses:
+//	200: userTokenResponse
+//  401: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// refreshToken handles POST requests to rotate the refresh token and return a new JWT
+func (app *Application) refreshToken(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the refresh token from the context
+	rt, ok := context.Get(r, KeyRefreshToken{}).(*models.RefreshToken)
+	if !ok {
+		app.ErrorLog.Printf("refreshToken: No token data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with token data"}, rw)
+		return
+	}
+
+	newRefreshToken, id, sessionID, err := app.RefreshTokens.Rotate(r.Context(), rt.RefreshToken, app.RefreshTokenValidTime)
+	if err != nil {
+		app.ErrorLog.Printf("refreshToken: %v\n", err)
+		if errors.Is(err, models.ErrInvalidToken) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusUnauthorized)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to refresh token"}, rw)
+		return
+	}
+
+	// get the current user data so that role changes are reflected on the new token
+	u, err := app.Users.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("refreshToken: get user %d: %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+	if !u.Active {
+		app.ErrorLog.Printf("refreshToken: user %d is not active\n", id)
+		app.RefreshTokens.Revoke(r.Context(), id, newRefreshToken)
+		rw.WriteHeader(http.StatusUnauthorized)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusUnauthorized)}, rw)
+		return
+	}
+
+	// the sessions started before the sessions were tracked have no ID
+	if sessionID != 0 {
+		err = app.Sessions.Touch(r.Context(), sessionID, models.ClientIP(r, app.TrustedProxies), app.RefreshTokenValidTime)
+		if err != nil {
+			app.ErrorLog.Printf("refreshToken: session %d: %v\n", sessionID, err)
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.ToJSON(&models.GenericMessage{Message: "unable to refresh token"}, rw)
+			return
+		}
+	}
+
+	token, err := app.Tokens.CreateSessionToken(u, sessionID)
+	if err != nil {
+		app.ErrorLog.Printf("refreshToken: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to create JWT"}, rw)
+		return
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("refreshToken: created JWT for user %d\n", id)
+	}
+
+	//  create message with user data and tokens to reply back
+	tokenmsg := models.TokenMessage{
+		Token:        token,
+		RefreshToken: newRefreshToken,
+		User: models.TokenUser{
+			ID:    u.ID,
+			Name:  u.Name,
+			Roles: u.Roles,
+		},
+	}
+	models.ToJSON(tokenmsg, rw)
+}
+
+// swagger:route POST /users/logout users logoutUser
+// Revoke the JWT used on the request and the given refresh token
+//
+// The session of the JWT is signed out, the refresh token must be of the user of the JWT
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// logoutUser handles POST requests to revoke the tokens of the user session
+func (app *Application) logoutUser(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the logout data and the token claims from the context
+	lu, ok := context.Get(r, KeyLogoutUser{}).(*models.LogoutUser)
+	if !ok {
+		app.ErrorLog.Printf("logoutUser: No logout data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with logout data"}, rw)
+		return
+	}
+	claims, ok := context.Get(r, KeyTokenClaims{}).(*models.MyCustomClaims)
+	if !ok {
+		app.ErrorLog.Printf("logoutUser: No token claims in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with token data"}, rw)
+		return
+	}
+
+	if lu.RefreshToken != "" {
+		// the refresh token of another user can not be revoked with this JWT
+		err := app.RefreshTokens.Revoke(r.Context(), claims.User.ID, lu.RefreshToken)
+		if err != nil {
+			app.ErrorLog.Printf("logoutUser: user %d: %v\n", claims.User.ID, err)
+			if errors.Is(err, models.ErrNoRecord) {
+				rw.WriteHeader(http.StatusForbidden)
+				models.ToJSON(&models.GenericMessage{Message: "Refresh token not of the user"}, rw)
+				return
+			}
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.ToJSON(&models.GenericMessage{Message: "unable to revoke refresh token"}, rw)
+			return
+		}
+	}
+
+	if claims.SessionID != 0 {
+		err := app.Sessions.Revoke(r.Context(), claims.User.ID, claims.SessionID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.ErrorLog.Printf("logoutUser: %v\n", err)
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.ToJSON(&models.GenericMessage{Message: "unable to revoke session"}, rw)
+			return
+		}
+	}
+
+	err := app.Denylist.Add(r.Context(), claims.Id, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		app.ErrorLog.Printf("logoutUser: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to revoke JWT"}, rw)
+		return
+	}
+
+	if app.DebugOn {
+		app.InfoLog.Printf("logoutUser: tokens revoked for user %d\n", claims.User.ID)
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditLogout, ActorID: claims.User.ID, TargetType: models.AuditTargetSession,
+		TargetID: claims.SessionID, Details: fmt.Sprintf("session of user %d", claims.User.ID)})
+	models.ToJSON(&models.GenericMessage{Message: "Logged out with success"}, rw)
+}
+
+// swagger:route GET /users/{id}/sessions sessions listSessions
+// Return the active sessions of user {id}
+//
+// The session of the JWT used on the request is flagged as current
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: sessionsResponse
+//  401: messageResponse
+//  403: messageResponse
+//	500: messageResponse
+
+// listSessions handles GET requests and returns the sessions of the user
+func (app *Application) listSessions(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("listSessions: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	sessions, err := app.Sessions.GetAll(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("listSessions: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Unable to get sessions list"}, rw)
+		return
+	}
+	if claims, ok := context.Get(r, KeyTokenClaims{}).(*models.MyCustomClaims); ok {
+		for _, s := range sessions {
+			s.Current = s.ID == claims.SessionID
+		}
+	}
+
+	err = models.ToJSON(sessions, rw)
+	if err != nil {
+		// we should never be here but log the error just incase
+		app.ErrorLog.Printf("listSessions: Unable to serializing sessions  %v\n", err)
+	}
+}
+
+// swagger:route DELETE /users/{id}/sessions/{sid} sessions revokeSession
+// Sign out the session {sid} of user {id}
+//
+// The refresh tokens of the session are revoked and its JWT are no longer accepted
+//
+//	Security:
+//  - snippetskey:
+//
+// responses:
+//	200: messageResponse
+//  400: messageResponse
+//  401: messageResponse
+//  403: messageResponse
+//  404: messageResponse
+//	500: messageResponse
+
+// revokeSession handles DELETE requests to sign out a session of the user
+func (app *Application) revokeSession(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// get IDs from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("revokeSession: user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+	sessionID, err := strconv.Atoi(mux.Vars(r)["sid"])
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("revokeSession: session of user %d:  %v\n", id, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(&models.GenericMessage{Message: http.StatusText(http.StatusBadRequest)}, rw)
+		return
+	}
+
+	err = app.Sessions.Revoke(r.Context(), id, sessionID)
+	if err != nil {
+		app.ErrorLog.Printf("revokeSession: session %d of user %d:  %v\n", sessionID, id, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusNotFound)
+			models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("Session %d not found", sessionID)}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to revoke session"}, rw)
+		return
+	}
+
+	if app.DebugOn {
+		app.InfoLog.Printf("revokeSession: session %d of user %d revoked\n", sessionID, id)
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditSessionRevoke, TargetType: models.AuditTargetSession, TargetID: sessionID,
+		Details: fmt.Sprintf("session of user %d", id)})
+	models.ToJSON(&models.GenericMessage{Message: fmt.Sprintf("Session %d signed out", sessionID)}, rw)
+}
+
+// swagger:route GET /.well-known/jwks.json tokens listKeys
+// Return the public keys that verify the JWT issued by the API
+//
+// The key used to verify a token is identified by its "kid" header, keys of previous
+// signing keys are kept during a key rotation. The set is empty with the HS256 algorithm
+// as the shared key is never published
+//
+// responses:
+//	200: jwksResponse
+
+// listKeys handles GET requests and returns the JSON Web Key Set of the API
+func (app *Application) listKeys(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "public, max-age=3600")
+
+	err := models.ToJSON(app.Tokens.JWKS(), rw)
+	if err != nil {
+		// we should never be here but log the error just incase
+		app.ErrorLog.Printf("listKeys: Unable to serializing keys  %v\n", err)
+	}
+}
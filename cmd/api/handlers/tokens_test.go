@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/vgraveto/snippets/pkg/models"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListKeys(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+
+	// the shared key of HS256 is never published
+	code, _, body := ts.get(t, "/.well-known/jwks.json", nil)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	var set models.JWKSet
+	if err := json.Unmarshal(body, &set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 0 {
+		t.Errorf("want no keys; got %d", len(set.Keys))
+	}
+
+	// the published key verifies the tokens of the API
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = ioutil.WriteFile(filepath.Join(dir, "token.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Tokens, err = models.NewTokenModelWithKeys(&models.TokenData{
+		TokenValidTime:  time.Minute,
+		TokenAlgorithm:  models.TokenAlgES256,
+		TokenPrivateKey: "token.pem",
+	}, dir+string(filepath.Separator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := app.Tokens.CreateToken(&models.User{ID: 1, Name: "Alice", Roles: []string{"user"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, h, body := ts.get(t, "/.well-known/jwks.json", nil)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if cc := h.Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Errorf("want the keys cached for an hour; got %q", cc)
+	}
+	set = models.JWKSet{}
+	if err = json.Unmarshal(body, &set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 {
+		t.Fatalf("want 1 key; got %d", len(set.Keys))
+	}
+	jwk := set.Keys[0]
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jwt.Parse(token, func(tk *jwt.Token) (interface{}, error) {
+		if tk.Header["kid"] != jwk.Kid || tk.Method.Alg() != jwk.Alg {
+			t.Errorf("want the kid %s and the alg %s; got %v and %v", jwk.Kid, jwk.Alg, tk.Header["kid"], tk.Method.Alg())
+		}
+		return pub, nil
+	})
+	if err != nil {
+		t.Errorf("want the token verified by the published key; got %v", err)
+	}
+}
+
+func TestLogoutUser(t *testing.T) {
+	app := newTestApplication(t)
+	aliceID := insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	insertUser(t, app, "bob@example.com", "Pa$$word1234", "user")
+	ts := newTestServer(t, app.Routes())
+	alice := ts.login(t, "alice@example.com", "Pa$$word1234")
+	bob := ts.login(t, "bob@example.com", "Pa$$word1234")
+
+	// refresh returns the status of the refresh of the token
+	refresh := func(token string) int {
+		t.Helper()
+		code, _, _ := ts.postJSON(t, "/users/token/refresh", &models.RefreshToken{RefreshToken: token})
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		refresh  string
+		wantCode int
+	}{
+		{"Refresh token of another user", bob.Token, alice.RefreshToken, http.StatusForbidden},
+		{"Unknown refresh token", bob.Token, "unknown-token", http.StatusForbidden},
+		{"Own refresh token", alice.Token, alice.RefreshToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.authJSON(t, http.MethodPost, "/users/logout", tt.token, &models.LogoutUser{RefreshToken: tt.refresh})
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d %s", tt.wantCode, code, body)
+			}
+		})
+	}
+
+	// the session of bob is kept and the one of alice is signed out
+	if code := refresh(bob.RefreshToken); code != http.StatusOK {
+		t.Errorf("want the refresh token of bob valid; got %d", code)
+	}
+	if code := refresh(alice.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("want the refresh token of alice revoked; got %d", code)
+	}
+	if code, _, _ := ts.get(t, fmt.Sprintf("/users/%d", aliceID), map[string]string{"Authentication": alice.Token}); code != http.StatusUnauthorized {
+		t.Errorf("want the JWT of alice revoked; got %d", code)
+	}
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"github.com/vgraveto/snippets/pkg/mailer"
 	"github.com/vgraveto/snippets/pkg/models"
 	"log"
+	"time"
 )
 
 type contextKey string
@@ -22,4 +24,48 @@ type Application struct {
 	Users    models.Users
 	Tokens   models.Tokens
 	Val      *models.Validation
+
+	// password recovery by email
+	UserTokens          models.UserTokens
+	Mailer              mailer.Mailer
+	ResetPasswordURL    string        // web page URL that receives the reset token, the reset is disabled when empty
+	ResetTokenValidTime time.Duration // valid time of the reset tokens sent by email
+
+	// public registration of users
+	Registration models.RegistrationData
+
+	// refresh and revocation of tokens
+	RefreshTokens         models.RefreshTokens
+	RefreshTokenValidTime time.Duration
+	Denylist              models.TokenDenylist
+
+	// logins of the users on their devices, signed out remotely by revoking them
+	Sessions models.Sessions
+
+	// personal API keys of the users
+	APIKeys models.APIKeys
+
+	// login with the ID tokens of an OpenID Connect provider, OIDC is nil when disabled
+	OIDC     *models.OIDCProvider
+	OIDCData models.OIDCData
+
+	// two-factor authentication of the users, MFAIssuer is shown by the authenticator apps
+	MFA       models.MFA
+	MFAIssuer string
+
+	// throttling of the failed logins, the X-Forwarded-For header is only used from the TrustedProxies
+	Throttle       *models.LoginThrottle
+	TrustedProxies []string
+
+	// rules of the new passwords of the users
+	PasswordPolicy *models.PasswordPolicy
+
+	// append-only log of the security relevant and content events
+	Audit models.AuditLog
+
+	// periodic checks and statistics of the connection pool, nil when the storage has none
+	DBHealth *models.DBHealth
+
+	// the time the clients may use a snippet without revalidating it, bounded by its expiration
+	SnippetMaxAge time.Duration
 }
@@ -21,6 +21,12 @@ type KeyCreateUser struct{}
 // KeyChangeUserPassword is a key used for ChangeUserPassword object in the context
 type KeyChangeUserPassword struct{}
 
+// KeyForgotPassword is a key used for ForgotPassword object in the context
+type KeyForgotPassword struct{}
+
+// KeyResetUserPassword is a key used for ResetUserPassword object in the context
+type KeyResetUserPassword struct{}
+
 // swagger:route GET /users users listUsers
 // Return a list of users from the database
 //
@@ -37,7 +43,7 @@ type KeyChangeUserPassword struct{}
 func (app *Application) listAllUsers(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Add("Content-Type", "application/json")
 
-	users, err := app.Users.GetAll()
+	users, err := app.Users.GetAll(r.Context())
 	if err != nil {
 		app.ErrorLog.Printf("listAllUsers: Unable to get users  %v\n", err)
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -81,7 +87,7 @@ func (app *Application) getSimpleUser(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	// get the user from the database
-	u, err := app.Users.Get(id)
+	u, err := app.Users.Get(r.Context(), id)
 	switch err {
 	case nil:
 		// OK just return the user JSON
@@ -108,10 +114,17 @@ func (app *Application) getSimpleUser(rw http.ResponseWriter, r *http.Request) {
 // swagger:route POST /users/login users loginUser
 // Validate user credentials and return JWT when valid
 //
+// The users with two-factor authentication enabled, or required by their roles,
+// receive a challenge to complete the login on /users/login/mfa.
+// The failed logins of the account and of the client address are delayed with an exponential
+// backoff and a temporary lockout, the Retry-After header has the seconds to wait.
+//
 // responses:
 //	200: userTokenResponse
+//	202: mfaChallengeResponse
 //  401: messageResponse
 //	422: validationResponse
+//	429: messageResponse
 //	500: messageResponse
 
 // loginUser handles POST requests to verify login and return JWT when user credentials are valid
@@ -127,17 +140,43 @@ func (app *Application) loginUser(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the attempt is reserved before the password is checked, the blocked logins and the
+	// parallel ones beyond the limits are rejected
+	keys := app.loginKeys(r, user.Username)
+	err := app.Throttle.Reserve(r.Context(), keys...)
+	if err != nil {
+		app.ErrorLog.Printf("loginUser: %v\n", err)
+		if setRetryAfter(rw, err) {
+			rw.WriteHeader(http.StatusTooManyRequests)
+			models.ToJSON(&models.GenericMessage{Message: "Too many failed logins, please retry later"}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to check failed logins"}, rw)
+		return
+	}
+
 	// Use credentials to obtain user ID
-	id, err := app.Users.Authenticate(user.Username, user.Password)
+	id, err := app.Users.Authenticate(r.Context(), user.Username, user.Password)
 	if err != nil {
 		app.ErrorLog.Printf("loginUser: %v\n", err)
+		app.audit(r, &models.AuditEvent{Action: models.AuditLoginFailed, TargetType: models.AuditTargetUser,
+			Details: fmt.Sprintf("password login of %q", user.Username)})
+		// the next login waits when the failures reach the limits
+		if ferr := app.Throttle.Fail(r.Context(), keys...); ferr != nil && !setRetryAfter(rw, ferr) {
+			app.ErrorLog.Printf("loginUser: %v\n", ferr)
+		}
 		rw.WriteHeader(http.StatusUnauthorized)
 		models.ToJSON(&models.GenericMessage{http.StatusText(http.StatusUnauthorized)}, rw)
 		return
 	}
+	err = app.Throttle.Succeed(r.Context(), keys...)
+	if err != nil {
+		app.ErrorLog.Printf("loginUser: %v\n", err)
+	}
 
 	// get user data from the database
-	u, err := app.Users.Get(id)
+	u, err := app.Users.Get(r.Context(), id)
 	if err != nil {
 		app.ErrorLog.Printf("loginUser: get user: %v\n", err)
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -145,50 +184,83 @@ func (app *Application) loginUser(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// create a JWT for the user
-	if app.DebugOn {
-		app.InfoLog.Printf("loginUser: creating JWT for %q\n", user.Username)
+	// the two-factor authentication completes the login
+	challenge, err := app.mfaChallenge(r, u)
+	if err != nil {
+		app.ErrorLog.Printf("loginUser: two-factor authentication: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to create two-factor authentication challenge"}, rw)
+		return
 	}
-	token, err := app.Tokens.CreateToken(u)
+	if challenge != nil {
+		if app.DebugOn {
+			app.InfoLog.Printf("loginUser: two-factor authentication challenge for user %d\n", u.ID)
+		}
+		rw.WriteHeader(http.StatusAccepted)
+		models.ToJSON(challenge, rw)
+		return
+	}
+
+	tokenmsg, err := app.newTokenMessage(r, u)
 	if err != nil {
 		app.ErrorLog.Printf("loginUser: %v\n", err)
 		rw.WriteHeader(http.StatusInternalServerError)
-		models.ToJSON(&models.GenericMessage{"unable to create JWT"}, rw)
+		models.ToJSON(&models.GenericMessage{Message: "unable to create JWT"}, rw)
 		return
 	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditLogin, ActorID: u.ID, TargetType: models.AuditTargetUser,
+		TargetID: u.ID, Details: "password login"})
+	models.ToJSON(tokenmsg, rw)
+}
+
+// newTokenMessage starts a session of the client of the request and creates the JWT
+// and the refresh token of the user logged in
+func (app *Application) newTokenMessage(r *http.Request, u *models.User) (*models.TokenMessage, error) {
+	sessionID, err := app.Sessions.New(r.Context(), u.ID, r.UserAgent(), models.ClientIP(r, app.TrustedProxies), app.RefreshTokenValidTime)
+	if err != nil {
+		return nil, fmt.Errorf("session: %v", err)
+	}
+
+	// create a JWT for the user
 	if app.DebugOn {
-		app.InfoLog.Printf("loginUser: created JWT\n")
+		app.InfoLog.Printf("newTokenMessage: creating JWT for user %d session %d\n", u.ID, sessionID)
+	}
+	token, err := app.Tokens.CreateSessionToken(u, sessionID)
+	if err != nil {
+		return nil, err
 	}
 
 	// verify the token - redundant check as the token was just created
 	err = app.Tokens.VerifyToken(&token)
 	if err != nil {
-		app.ErrorLog.Printf("loginUser: %v\n", err)
-		rw.WriteHeader(http.StatusInternalServerError)
-		models.ToJSON(&models.GenericMessage{"invalid JWT"}, rw)
-		return
+		return nil, err
 	}
 
 	// get user data from token - redundant as the data was already availabre befr creating the token
 	tUser, err := models.GetUserFromToken(&token)
 	if err != nil {
-		app.ErrorLog.Printf("loginUser: %v\n", err)
-		rw.WriteHeader(http.StatusInternalServerError)
-		models.ToJSON(&models.GenericMessage{"unable to get user from JWT"}, rw)
-		return
+		return nil, err
 	}
 
-	//  create message with user data and token to reply back
-	tokenmsg := models.TokenMessage{
-		Token: token,
-		User:  *tUser,
+	// create the refresh token used to obtain new access tokens
+	refreshToken, err := app.RefreshTokens.New(r.Context(), u.ID, sessionID, app.RefreshTokenValidTime)
+	if err != nil {
+		return nil, fmt.Errorf("refresh token: %v", err)
 	}
-	models.ToJSON(tokenmsg, rw)
+
+	//  create message with user data and token to reply back
+	return &models.TokenMessage{
+		Token:        token,
+		RefreshToken: refreshToken,
+		User:         *tUser,
+	}, nil
 }
 
 // swagger:route POST /users users createUser
 // Create and inserts a new user on the database
 //
+// The password must follow the password policy, the rules it does not follow are returned with 422
+//
 //	Security:
 //  - snippetskey:
 //
@@ -213,8 +285,12 @@ func (app *Application) createUser(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !app.checkPassword(rw, "createUser", "password", user.Password, user.Name, user.Email) {
+		return
+	}
+
 	// Insert user on the database
-	err := app.Users.Insert(user.Name, user.Email, user.Password, user.Roles)
+	err := app.Users.Insert(r.Context(), user.Name, user.Email, user.Password, user.Roles)
 	if err != nil {
 		app.ErrorLog.Printf("createUser: %v\n", err)
 		if errors.Is(err, models.ErrDuplicateEmail) {
@@ -230,6 +306,12 @@ func (app *Application) createUser(rw http.ResponseWriter, r *http.Request) {
 	if app.DebugOn {
 		app.InfoLog.Printf("createUser: created %q user\n", user.Name)
 	}
+	e := &models.AuditEvent{Action: models.AuditUserCreate, TargetType: models.AuditTargetUser,
+		Details: fmt.Sprintf("user %q with roles %v", user.Email, user.Roles)}
+	if u, err := app.Users.GetByEmail(r.Context(), user.Email); err == nil {
+		e.TargetID = u.ID
+	}
+	app.audit(r, e)
 
 	//  create message to reply back
 	msg := fmt.Sprintf("User %q created with success", user.Name)
@@ -239,6 +321,8 @@ func (app *Application) createUser(rw http.ResponseWriter, r *http.Request) {
 // swagger:route PUT /users/{id}/change-password users changeUserPassword
 // Change password for user {id} on the database
 //
+// The new password must follow the password policy, the rules it does not follow are returned with 422
+//
 //	Security:
 //  - snippetskey:
 //
@@ -247,6 +331,7 @@ func (app *Application) createUser(rw http.ResponseWriter, r *http.Request) {
 //  400: messageResponse
 //  401: messageResponse
 //  403: messageResponse
+//  404: messageResponse
 //	422: validationResponse
 //	500: messageResponse
 
@@ -282,12 +367,28 @@ func (app *Application) changeUserPassword(rw http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	u, err := app.Users.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("changeUserPassword: %v\n", err)
+		if errors.Is(err, models.ErrNoRecord) {
+			rw.WriteHeader(http.StatusNotFound)
+			models.ToJSON(&models.GenericMessage{Message: err.Error()}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+	if !app.checkPassword(rw, "changeUserPassword", "newPassword", user.NewPassword, u.Name, u.Email) {
+		return
+	}
+
 	// Change user's password on the database
 	if tuser.IsAdmin() && !(tuser.ID == id) {
 		// ignore old password if user is an administrator and not its own account
-		err = app.Users.ResetPassword(id, user.NewPassword)
+		err = app.Users.ResetPassword(r.Context(), id, user.NewPassword)
 	} else {
-		err = app.Users.ChangePassword(id, user.OldPassword, user.NewPassword)
+		err = app.Users.ChangePassword(r.Context(), id, user.OldPassword, user.NewPassword)
 	}
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
@@ -304,12 +405,169 @@ func (app *Application) changeUserPassword(rw http.ResponseWriter, r *http.Reque
 	if app.DebugOn {
 		app.InfoLog.Printf("changeUserPassword: password changed for user %d\n", id)
 	}
+	action := models.AuditPasswordChange
+	if tuser.IsAdmin() && !(tuser.ID == id) {
+		action = models.AuditPasswordReset
+	}
+	app.audit(r, &models.AuditEvent{Action: action, TargetType: models.AuditTargetUser, TargetID: id})
 
 	//  create message to reply back
 	msg := fmt.Sprintf("Password changed for user %d with success", id)
 	models.ToJSON(&models.GenericMessage{msg}, rw)
 }
 
+// swagger:route POST /users/forgot-password users forgotPassword
+// Send an email with a password reset link to the user
+//
+// The same response is returned whether the email belongs to an user or not.
+// The status code 501 is returned when the password reset page of the email is not configured.
+//
+// responses:
+//	200: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+//	501: messageResponse
+
+// forgotPassword handles POST requests to send a password reset token to the email of the user
+func (app *Application) forgotPassword(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// the link of the email needs the page that receives the reset token
+	if app.ResetPasswordURL == "" {
+		app.ErrorLog.Printf("forgotPassword: %v - set mail.resetPasswordURL\n", models.ErrPasswordResetDisabled)
+		rw.WriteHeader(http.StatusNotImplemented)
+		models.ToJSON(&models.GenericMessage{Message: "Password reset by email is not enabled"}, rw)
+		return
+	}
+
+	// fetch the forgot password data from the context
+	fp, ok := context.Get(r, KeyForgotPassword{}).(*models.ForgotPassword)
+	if !ok {
+		app.ErrorLog.Printf("forgotPassword: No user data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with user data"}, rw)
+		return
+	}
+
+	// the reply never discloses if the email belongs to an active user
+	msg := &models.GenericMessage{Message: "If the email belongs to an user a password reset link has been sent"}
+
+	u, err := app.Users.GetByEmail(r.Context(), fp.Email)
+	if err != nil {
+		if !errors.Is(err, models.ErrNoRecord) {
+			app.ErrorLog.Printf("forgotPassword: %v\n", err)
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+			return
+		}
+		app.InfoLog.Printf("forgotPassword: unknown email %q\n", fp.Email)
+		models.ToJSON(msg, rw)
+		return
+	}
+	if !u.Active {
+		app.InfoLog.Printf("forgotPassword: user %d is not active\n", u.ID)
+		models.ToJSON(msg, rw)
+		return
+	}
+
+	// the failures past this point are only logged, as they only happen for the emails of the users
+	token, err := app.UserTokens.New(r.Context(), u.ID, models.TokenPurposeResetPassword, app.ResetTokenValidTime)
+	if err != nil {
+		app.ErrorLog.Printf("forgotPassword: %v\n", err)
+		models.ToJSON(msg, rw)
+		return
+	}
+
+	body := fmt.Sprintf("Hello %s,\r\n\r\n"+
+		"A password reset was requested for your account. Use the link below to choose a new password:\r\n\r\n"+
+		"%s?token=%s\r\n\r\n"+
+		"The link is valid for %v and can only be used once. If you did not request it, please ignore this email.\r\n",
+		u.Name, app.ResetPasswordURL, token, app.ResetTokenValidTime)
+	err = app.Mailer.Send(u.Email, "Snippets password reset", body)
+	if err != nil {
+		app.ErrorLog.Printf("forgotPassword: %v\n", err)
+		models.ToJSON(msg, rw)
+		return
+	}
+
+	if app.DebugOn {
+		app.InfoLog.Printf("forgotPassword: reset token sent to user %d\n", u.ID)
+	}
+	models.ToJSON(msg, rw)
+}
+
+// swagger:route POST /users/reset-password users resetUserPassword
+// Change the password of an user with the reset token received by email
+//
+// The token is only used when the new password follows the password policy
+//
+// responses:
+//	200: messageResponse
+//  400: messageResponse
+//	422: validationResponse
+//	500: messageResponse
+
+// resetUserPassword handles POST requests to change the password of the owner of a reset token
+func (app *Application) resetUserPassword(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+
+	// fetch the reset password data from the context
+	rp, ok := context.Get(r, KeyResetUserPassword{}).(*models.ResetUserPassword)
+	if !ok {
+		app.ErrorLog.Printf("resetUserPassword: No user data in the context\n")
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "Problem with user data"}, rw)
+		return
+	}
+
+	// the password is checked before using the token so that it can be retried
+	id, err := app.UserTokens.Peek(r.Context(), rp.Token, models.TokenPurposeResetPassword)
+	if err != nil {
+		app.ErrorLog.Printf("resetUserPassword: %v\n", err)
+		if errors.Is(err, models.ErrInvalidToken) {
+			rw.WriteHeader(http.StatusBadRequest)
+			models.ToJSON(&models.GenericMessage{Message: err.Error()}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to validate reset token"}, rw)
+		return
+	}
+	u, err := app.Users.Get(r.Context(), id)
+	if err != nil {
+		app.ErrorLog.Printf("resetUserPassword: %v\n", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to get user"}, rw)
+		return
+	}
+	if !app.checkPassword(rw, "resetUserPassword", "newPassword", rp.NewPassword, u.Name, u.Email) {
+		return
+	}
+
+	// the token is only used when the password is changed
+	id, err = app.Users.ResetPasswordByToken(r.Context(), rp.Token, rp.NewPassword)
+	if err != nil {
+		app.ErrorLog.Printf("resetUserPassword: %v\n", err)
+		if errors.Is(err, models.ErrInvalidToken) {
+			rw.WriteHeader(http.StatusBadRequest)
+			models.ToJSON(&models.GenericMessage{Message: err.Error()}, rw)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		models.ToJSON(&models.GenericMessage{Message: "unable to change password"}, rw)
+		return
+	}
+
+	if app.DebugOn {
+		app.InfoLog.Printf("resetUserPassword: password reset for user %d\n", id)
+	}
+	app.audit(r, &models.AuditEvent{Action: models.AuditPasswordReset, ActorID: id, TargetType: models.AuditTargetUser,
+		TargetID: id, Details: "reset with email token"})
+
+	//  create message to reply back
+	models.ToJSON(&models.GenericMessage{Message: "Password changed with success"}, rw)
+}
+
 // Authenticate provides Authentication middleware for handlers
 func (app *Application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
@@ -325,6 +583,16 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 			tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 		}
 
+		// API keys are sent on the X-API-Key header or in place of the JWT
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" && models.IsAPIKey(tokenString) {
+			apiKey = tokenString
+		}
+		if apiKey != "" {
+			app.authenticateAPIKey(rw, r, apiKey, next)
+			return
+		}
+
 		// If the token is empty...
 		if tokenString == "" {
 			// If we get here, the required token is missing
@@ -335,7 +603,7 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 		}
 
 		// verify the token
-		err := app.Tokens.VerifyToken(&tokenString)
+		claims, err := app.Tokens.ParseToken(&tokenString)
 		if err != nil {
 			// If we get here, the required token is missing
 			app.ErrorLog.Printf("authenticate: Invalid JWT: %v\n", err)
@@ -344,24 +612,57 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		// get user data from token
-		tUser, err := models.GetUserFromToken(&tokenString)
+		// reject the tokens revoked on logout
+		revoked, err := app.Denylist.Contains(r.Context(), claims.Id)
 		if err != nil {
 			app.ErrorLog.Printf("authenticate: %v\n", err)
 			rw.WriteHeader(http.StatusInternalServerError)
-			models.ToJSON(&models.GenericMessage{"unable to get user from JWT"}, rw)
+			models.ToJSON(&models.GenericMessage{Message: "unable to verify JWT"}, rw)
+			return
+		}
+		if revoked {
+			app.ErrorLog.Printf("authenticate: %v: %s\n", models.ErrRevokedToken, claims.Id)
+			rw.WriteHeader(http.StatusUnauthorized)
+			models.ToJSON(&models.GenericMessage{Message: "revoked JWT"}, rw)
 			return
 		}
 
-		// Everything worked! Set the TokenUser in the context.
-		context.Set(r, KeyTokenUser{}, tUser)
+		// reject the tokens of the sessions signed out
+		if claims.SessionID != 0 {
+			active, err := app.Sessions.Active(r.Context(), claims.SessionID)
+			if err != nil {
+				app.ErrorLog.Printf("authenticate: %v\n", err)
+				rw.WriteHeader(http.StatusInternalServerError)
+				models.ToJSON(&models.GenericMessage{Message: "unable to verify JWT"}, rw)
+				return
+			}
+			if !active {
+				app.ErrorLog.Printf("authenticate: %v: session %d\n", models.ErrRevokedToken, claims.SessionID)
+				rw.WriteHeader(http.StatusUnauthorized)
+				models.ToJSON(&models.GenericMessage{Message: "revoked JWT"}, rw)
+				return
+			}
+		}
+
+		// get user data from token
+		if claims.User == nil {
+			app.ErrorLog.Printf("authenticate: no user in JWT claims\n")
+			rw.WriteHeader(http.StatusInternalServerError)
+			models.ToJSON(&models.GenericMessage{Message: "unable to get user from JWT"}, rw)
+			return
+		}
+
+		// Everything worked! Set the TokenUser and the claims in the context.
+		context.Set(r, KeyTokenUser{}, claims.User)
+		context.Set(r, KeyTokenClaims{}, claims)
 		next.ServeHTTP(rw, r)
 	})
 }
 
 // authorize provides authorization middleware for handlers
 // If the user has any of the required permissions or has AministrationRole than it is authorized
-// when SelfRole is required the check is made between URL ID request and user ID
+// when SelfRole is required the check is made between URL ID request and user ID,
+// an API key is only granted SelfRole when it is one of its scopes
 func (app *Application) authorize(permissions ...string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
@@ -382,7 +683,7 @@ func (app *Application) authorize(permissions ...string) func(next http.Handler)
 			// the user needs to have any the requested permissions to be authorized
 			isAuthorised := false
 			for _, permission := range permissions {
-				if permission == models.SelfRole {
+				if permission == models.SelfRole && app.selfAllowed(r) {
 					// authorize if token user is the same as id specified on URL and SerlRole permission exisys
 					id, err := getID(r)
 					if err != nil {
@@ -417,6 +718,24 @@ func (app *Application) authorize(permissions ...string) func(next http.Handler)
 	}
 }
 
+// selfAllowed returns true when the request can use the SelfRole permission,
+// the requests authenticated with an API key need the self scope
+func (app *Application) selfAllowed(r *http.Request) bool {
+	k, ok := context.Get(r, KeyAPIKey{}).(*models.APIKey)
+	if !ok {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if s == models.SelfRole {
+			return true
+		}
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("authorize: API key %d has no %s scope\n", k.ID, models.SelfRole)
+	}
+	return false
+}
+
 // swagger:route GET /users/role-types users listRoles
 // Return a list of valid user role types from the database
 //
@@ -433,7 +752,7 @@ func (app *Application) authorize(permissions ...string) func(next http.Handler)
 func (app *Application) listAllRoleTypes(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Add("Content-Type", "application/json")
 
-	roles, err := app.Users.GetRoleTypes()
+	roles, err := app.Users.GetRoleTypes(r.Context())
 	if err != nil {
 		app.ErrorLog.Printf("listAllRoleTypes: Unable to get role types  %v\n", err)
 		rw.WriteHeader(http.StatusInternalServerError)
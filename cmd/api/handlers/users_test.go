@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// mailedToken returns the token of the link of the last message sent to the address
+func mailedToken(t *testing.T, app *Application, to string) string {
+	t.Helper()
+	bodies := app.Mailer.(*testMailer).sent(to)
+	if len(bodies) == 0 {
+		t.Fatalf("want a message sent to %s; got none", to)
+	}
+	m := regexp.MustCompile(`\?token=(\S+)`).FindStringSubmatch(bodies[len(bodies)-1])
+	if m == nil {
+		t.Fatalf("want a link with a token; got %q", bodies[len(bodies)-1])
+	}
+	return m[1]
+}
+
+func TestForgotPassword(t *testing.T) {
+	app := newTestApplication(t)
+	insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	ts := newTestServer(t, app.Routes())
+
+	code, _, want := ts.postJSON(t, "/users/forgot-password", &models.ForgotPassword{Email: "alice@example.com"})
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d %s", http.StatusOK, code, want)
+	}
+	if len(app.Mailer.(*testMailer).sent("alice@example.com")) != 1 {
+		t.Error("want a reset link sent to the user")
+	}
+
+	// the unknown emails and the failures to send the message have the same response
+	tests := []struct {
+		name    string
+		email   string
+		mailErr error
+	}{
+		{"Unknown email", "bob@example.com", nil},
+		{"Mail failure", "alice@example.com", errors.New("connection refused")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.Mailer.(*testMailer).err = tt.mailErr
+			code, _, body := ts.postJSON(t, "/users/forgot-password", &models.ForgotPassword{Email: tt.email})
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if !bytes.Equal(body, want) {
+				t.Errorf("want %s; got %s", want, body)
+			}
+		})
+	}
+}
+
+func TestForgotPasswordDisabled(t *testing.T) {
+	app := newTestApplication(t)
+	app.ResetPasswordURL = ""
+	insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	ts := newTestServer(t, app.Routes())
+
+	code, _, body := ts.postJSON(t, "/users/forgot-password", &models.ForgotPassword{Email: "alice@example.com"})
+	if code != http.StatusNotImplemented {
+		t.Errorf("want %d; got %d %s", http.StatusNotImplemented, code, body)
+	}
+	if len(app.Mailer.(*testMailer).sent("alice@example.com")) != 0 {
+		t.Error("want no message sent without the reset page")
+	}
+}
+
+func TestResetUserPassword(t *testing.T) {
+	app := newTestApplication(t)
+	insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	ts := newTestServer(t, app.Routes())
+
+	code, _, body := ts.postJSON(t, "/users/forgot-password", &models.ForgotPassword{Email: "alice@example.com"})
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d %s", http.StatusOK, code, body)
+	}
+	token := mailedToken(t, app, "alice@example.com")
+
+	// the token is only used by a password that follows the policy, and only once
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		wantCode int
+	}{
+		{"Unknown token", "unknown-token", "New-pa55word", http.StatusBadRequest},
+		{"Short password", token, "Short1", http.StatusUnprocessableEntity},
+		{"Valid", token, "New-pa55word", http.StatusOK},
+		{"Used token", token, "Other-pa55word", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.postJSON(t, "/users/reset-password",
+				&models.ResetUserPassword{Token: tt.token, NewPassword: tt.password})
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d %s", tt.wantCode, code, body)
+			}
+		})
+	}
+
+	if _, err := app.Users.Authenticate(context.Background(), "alice@example.com", "New-pa55word"); err != nil {
+		t.Errorf("want login with the new password; got %v", err)
+	}
+}
+
+func TestLoginUserParallel(t *testing.T) {
+	app := newTestApplication(t)
+	insertUser(t, app, "alice@example.com", "Pa$$word1234", "user")
+	ts := newTestServer(t, app.Routes())
+
+	// the parallel guesses never exceed the lockout attempts
+	var wg sync.WaitGroup
+	codes := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, _, _ := ts.postJSON(t, "/users/login", &models.LoginUser{Username: "alice@example.com", Password: "wrong-password"})
+			codes <- code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	verified := 0
+	for code := range codes {
+		switch code {
+		case http.StatusUnauthorized:
+			verified++
+		case http.StatusTooManyRequests:
+		default:
+			t.Errorf("want %d or %d; got %d", http.StatusUnauthorized, http.StatusTooManyRequests, code)
+		}
+	}
+	if verified == 0 || verified > 3 {
+		t.Errorf("want up to the 3 lockout attempts verified; got %d", verified)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"flag"
 	"github.com/vgraveto/snippets/cmd/api/handlers"
+	"github.com/vgraveto/snippets/pkg/mailer"
 	"github.com/vgraveto/snippets/pkg/models"
-	"github.com/vgraveto/snippets/pkg/models/dbmysql"
+	"github.com/vgraveto/snippets/pkg/models/cache"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -21,11 +23,12 @@ const (
 
 // cmd line parameters
 var (
-	configPath = flag.String("config", "", "the path for the config file")
-	configFile = flag.String("configfile", "snippetsApi", "the filename of the config file - without required .toml extension")
-	certsPath  = flag.String("certs", "certs", "the path for certificates location")
-	keysPath   = flag.String("privatekeys", "certs", "the path for private keys location")
-	debugOn    = flag.Bool("debug", false, "Enable debug mode")
+	configPath    = flag.String("config", "", "the path for the config file")
+	configFile    = flag.String("configfile", "snippetsApi", "the filename of the config file - without required .toml extension")
+	certsPath     = flag.String("certs", "certs", "the path for certificates location")
+	keysPath      = flag.String("privatekeys", "certs", "the path for private keys location")
+	debugOn       = flag.Bool("debug", false, "Enable debug mode")
+	storageDriver = flag.String("storage", "", "the storage driver - mysql, postgres, sqlite or memory - overriding dbase.driver of the config file")
 )
 
 func main() {
@@ -56,39 +59,124 @@ func main() {
 
 	// read configuration file from disk
 	globalData := readConfig(errorLog, *configPath, *configFile)
+	if len(*storageDriver) > 0 {
+		globalData.DBDriver = *storageDriver
+	}
 
 	// setup signal catching
 	sigs := make(chan os.Signal, 1)
 	// Accept graceful shutdowns when quit via SIGINT (Ctrl+C), SIGKILL, SIGQUIT or SIGTERM
 	signal.Notify(sigs, os.Interrupt, os.Kill, syscall.SIGQUIT, syscall.SIGTERM)
 
+	tokens, err := models.NewTokenModelWithKeys(&globalData.TD, *keysPath+"/")
+	if err != nil {
+		errorLog.Fatalf("main: %v\n", err)
+	}
+
+	mail, err := mailer.New(infoLog, globalData.Mail)
+	if err != nil {
+		errorLog.Fatalf("main: %v\n", err)
+	}
+
+	passwordPolicy, err := models.NewPasswordPolicy(globalData.Password)
+	if err != nil {
+		errorLog.Fatalf("main: %v\n", err)
+	}
+	hasher, err := models.NewPasswordHasher(globalData.Hasher)
+	if err != nil {
+		errorLog.Fatalf("main: %v\n", err)
+	}
+
 	// To keep the main() function tidy I've put the code for creating a connection
-	// pool into the separate dialDB() function below.
-	db, err := dbmysql.DialDB(infoLog, globalData.DB, *certsPath+"/", *keysPath+"/")
+	// pool of the configured driver into the separate openStorage() function.
+	store, err := openStorage(infoLog, errorLog, &globalData, hasher, *certsPath+"/", *keysPath+"/")
 	if err != nil {
 		errorLog.Fatalf("main: %v\n", err)
 	}
-	// We also defer a call to CloseDB(), so that the connection pool is closed
+	// We also defer a call to Close(), so that the connection pool is closed
 	// before the main() function exits.
 	defer func() {
-		err = dbmysql.CloseDB(infoLog, db)
+		err = store.Close()
 		if err != nil {
 			errorLog.Printf("main: Closing database error: %v\n", err)
 			return
 		}
 	}()
 
+	// the command line arguments after the flags are a command, e.g. migrate up
+	if flag.NArg() > 0 {
+		cmds := &commands{
+			in:      os.Stdin,
+			out:     os.Stdout,
+			infoLog: infoLog,
+			store:   store,
+			admin:   globalData.Admin,
+			policy:  passwordPolicy,
+		}
+		err = cmds.run(context.Background(), flag.Args())
+		if err != nil {
+			errorLog.Fatalf("main: %v\n", err)
+		}
+		return
+	}
+	if globalData.DBMigrate {
+		// the one-time password of the administrator is never written to the log of the service
+		err = migrateUp(context.Background(), infoLog, nil, store, globalData.Admin)
+		if err != nil {
+			errorLog.Fatalf("main: %v\n", err)
+		}
+	} else {
+		logPending(context.Background(), errorLog, store)
+	}
+
 	// Initialize a new instance of application containing the dependencies.
 	app := &handlers.Application{
-		DebugOn:  *debugOn,
-		ErrorLog: errorLog,
-		InfoLog:  infoLog,
-		Snippets: dbmysql.NewSnippetModel(db),
-		Users:    dbmysql.NewUserModel(db),
-		Tokens:   models.NewTokenModel(&globalData.TD),
-		Val:      models.NewValidation(),
+		DebugOn:               *debugOn,
+		ErrorLog:              errorLog,
+		InfoLog:               infoLog,
+		Snippets:              store.Snippets,
+		Users:                 store.Users,
+		Tokens:                tokens,
+		Val:                   models.NewValidation(),
+		UserTokens:            store.UserTokens,
+		Mailer:                mail,
+		ResetPasswordURL:      globalData.ResetPasswordURL,
+		ResetTokenValidTime:   globalData.ResetTokenValidTime,
+		Registration:          globalData.Registration,
+		RefreshTokens:         store.RefreshTokens,
+		RefreshTokenValidTime: globalData.TD.TokenRefreshValidTime,
+		Denylist:              store.Denylist,
+		Sessions:              store.Sessions,
+		Audit:                 store.Audit,
+		APIKeys:               store.APIKeys,
+		OIDCData:              globalData.OIDC,
+		MFA:                   store.MFA,
+		MFAIssuer:             globalData.MFAIssuer,
+		TrustedProxies:        globalData.TrustedProxies,
+		PasswordPolicy:        passwordPolicy,
+		SnippetMaxAge:         globalData.MaxAge,
+	}
+	if globalData.CacheEnabled {
+		app.Snippets = cache.NewSnippets(store.Snippets, cache.NewLRU(globalData.CacheSize), globalData.CacheTTL, errorLog)
+	}
+	if store.Pool != nil {
+		app.DBHealth = models.NewDBHealth(store.Pool, globalData.DBHealth, globalData.DB.QueryTimeout, infoLog, errorLog)
+	}
+	// the failed logins are shared by all the instances of the API when kept on the database
+	attempts := store.LoginAttempts
+	if globalData.ThrottleStore == "memory" {
+		attempts = models.NewMemoryLoginAttempts()
+	}
+	app.Throttle = models.NewLoginThrottle(attempts, globalData.Throttle)
+	if globalData.OIDCEnabled {
+		app.OIDC = models.NewOIDCProvider(&globalData.OIDC)
+	}
+	if app.ResetPasswordURL == "" {
+		infoLog.Println("main: mail.resetPasswordURL not set - the password reset by email is disabled")
 	}
 
+	// the requests inherit this context, it is cancelled on shutdown to abort the pending operations
+	baseCtx, cancelBase := context.WithCancel(context.Background())
 	httpSrv := &http.Server{
 		Addr:         ":" + globalData.HttpPort,
 		ErrorLog:     errorLog,
@@ -96,6 +184,10 @@ func main() {
 		IdleTimeout:  globalData.HttpIdleTimeout,
 		ReadTimeout:  globalData.HttpReadTimeout,
 		WriteTimeout: globalData.HttpWriteTimeout,
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
+	}
+	if app.DBHealth != nil {
+		go app.DBHealth.Run(baseCtx)
 	}
 
 	// Initialize this REST API
@@ -117,11 +209,11 @@ func main() {
 	s := <-sigs
 
 	infoLog.Printf("main: received signal: %s", s)
-	AppCleanup(infoLog, httpSrv, globalData.deadlineWaitForClose)
+	AppCleanup(infoLog, httpSrv, cancelBase, globalData.deadlineWaitForClose)
 
 }
 
-func AppCleanup(infoLog *log.Logger, httpSrv *http.Server, wait time.Duration) {
+func AppCleanup(infoLog *log.Logger, httpSrv *http.Server, cancelBase context.CancelFunc, wait time.Duration) {
 	infoLog.Println("main: AppCleanup init")
 
 	// Create a deadline to wait for.
@@ -130,6 +222,8 @@ func AppCleanup(infoLog *log.Logger, httpSrv *http.Server, wait time.Duration) {
 	// Doesn't block if no connections, but will otherwise wait
 	// until the timeout deadline.
 	httpSrv.Shutdown(ctx)
+	// abort the requests still running after the deadline
+	cancelBase()
 
 	infoLog.Println("main: AppCleanup end")
 }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"io"
+	"log"
+	"text/tabwriter"
+)
+
+// adminData is the initial administrator account created when the database has none
+type adminData struct {
+	Name  string
+	Email string
+	// a one-time password is written to the output of the migrate up command when it is empty
+	Password string
+	// the roles of the administrator besides the AministratorRole
+	Roles []string
+}
+
+// migrate executes the "migrate up|down|status" command
+func (c *commands) migrate(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("migrate: use migrate up|down|status")
+	}
+	if c.store.Migrator == nil {
+		return errors.New("migrate: the storage driver has no migrations")
+	}
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, c.infoLog, c.out, c.store, c.admin)
+	case "down":
+		m, err := c.store.Migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		c.infoLog.Printf("migrate: reverted %d %s\n", m.Version, m.Name)
+		return nil
+	case "status":
+		status, err := c.store.Migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied != nil {
+				applied = s.Applied.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("migrate: unknown command %q - use up, down or status", args[0])
+}
+
+// migrateUp applies the pending migrations of the storage and creates the initial administrator,
+// its one-time password is written to out, nil on the startup of the API
+func migrateUp(ctx context.Context, infoLog *log.Logger, out io.Writer, store *storage, admin adminData) error {
+	if store.Migrator != nil {
+		done, err := store.Migrator.Up(ctx)
+		for _, m := range done {
+			infoLog.Printf("migrate: applied %d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return seedAdmin(ctx, infoLog, out, store.Users, admin)
+}
+
+// logPending logs the migrations not applied, used when they are not applied on startup
+func logPending(ctx context.Context, errorLog *log.Logger, store *storage) {
+	if store.Migrator == nil {
+		return
+	}
+	status, err := store.Migrator.Status(ctx)
+	if err != nil {
+		errorLog.Printf("migrate: %v\n", err)
+		return
+	}
+	pending := 0
+	for _, s := range status {
+		if s.Applied == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		errorLog.Printf("migrate: %d pending migrations - run the migrate up command or set dbase.migrate\n", pending)
+	}
+}
+
+// seedAdmin creates the administrator account when no user has the AministratorRole, nothing is
+// done when the admin email is not set. It fails when a user has the email, the role is never
+// granted to an account that may have been registered by anyone. Without the admin password a
+// one-time password is written to out, never to the log, and no account is created when out is nil.
+func seedAdmin(ctx context.Context, infoLog *log.Logger, out io.Writer, users models.Users, admin adminData) error {
+	if admin.Email == "" {
+		return nil
+	}
+	all, err := users.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("seedAdmin: %v", err)
+	}
+	for _, u := range all {
+		if isAdmin(u) {
+			return nil
+		}
+	}
+
+	roles, err := adminRoles(ctx, users, admin.Roles)
+	if err != nil {
+		return fmt.Errorf("seedAdmin: %v", err)
+	}
+
+	password := admin.Password
+	if password == "" {
+		if out == nil {
+			infoLog.Printf("seedAdmin: no administrator created - set admin.password or run the migrate up command\n")
+			return nil
+		}
+		password, err = models.NewRandomToken()
+		if err != nil {
+			return fmt.Errorf("seedAdmin: %v", err)
+		}
+	}
+	err = users.Insert(ctx, admin.Name, admin.Email, password, roles)
+	if errors.Is(err, models.ErrDuplicateEmail) {
+		return fmt.Errorf("seedAdmin: a user with the email %s exists and it is not an administrator"+
+			" - change admin.email or use admin create", admin.Email)
+	}
+	if err != nil {
+		return fmt.Errorf("seedAdmin: %v", err)
+	}
+	infoLog.Printf("seedAdmin: created the administrator %s\n", admin.Email)
+	if admin.Password == "" {
+		fmt.Fprintf(out, "The one-time password of the administrator %s is %s - change it on the first login\n", admin.Email, password)
+	}
+	return nil
+}
+
+// adminRoles returns the IDs of the AministratorRole and of the other roles, all of them must exist
+func adminRoles(ctx context.Context, users models.Users, others []string) ([]int, error) {
+	roleTypes, err := users.GetRoleTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	adminRole := roleID(roleTypes, models.AministratorRole)
+	if adminRole == 0 {
+		return nil, fmt.Errorf("role %q not found", models.AministratorRole)
+	}
+	roles := []int{adminRole}
+	for _, r := range others {
+		id := roleID(roleTypes, r)
+		if id == 0 {
+			return nil, fmt.Errorf("role %q not found", r)
+		}
+		if id != adminRole {
+			roles = append(roles, id)
+		}
+	}
+	return roles, nil
+}
+
+// roleID returns the ID of the role type, zero when it does not exist
+func roleID(roleTypes []*models.RoleType, role string) int {
+	for _, rt := range roleTypes {
+		if rt.Role == role {
+			return rt.ID
+		}
+	}
+	return 0
+}
+
+// isAdmin returns true when the user has the AministratorRole
+func isAdmin(u *models.User) bool {
+	for _, r := range u.Roles {
+		if r == models.AministratorRole {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/spf13/viper"
+	"github.com/vgraveto/snippets/pkg/models"
 	"github.com/vgraveto/snippets/pkg/models/dbapi"
 	"log"
 	"time"
@@ -19,6 +20,28 @@ type configType struct {
 
 	// Database connection data
 	DB dbapi.DBapi
+
+	// API JWT token verification data
+	Token models.VerifierData
+
+	// public registration pages enabled
+	RegistrationEnabled bool
+
+	// OpenID Connect login data
+	OIDCEnabled bool
+	OIDC        models.OIDCData
+
+	// Failed logins throttling data
+	Throttle       models.ThrottleData
+	TrustedProxies []string
+
+	// Password policy data
+	Password models.PasswordPolicyData
+
+	// Snippets cache data
+	CacheEnabled bool
+	CacheSize    int           // number of cached keys
+	CacheTTL     time.Duration // number of seconds
 }
 
 func readConfig(errorLog *log.Logger, path, filename string) (globalData configType) {
@@ -36,6 +59,35 @@ func readConfig(errorLog *log.Logger, path, filename string) (globalData configT
 	if !viper.IsSet("dbase.url") {
 		log.Fatalf("Key/Value not set in file %s - dbase.url", filename)
 	}
+	if !viper.IsSet("token.issuerName") {
+		log.Fatalf("Key/Value not set in file %s - token.issuerName", filename)
+	}
+	viper.SetDefault("dbase.timeout", 30)
+	viper.SetDefault("dbase.retries", 2)
+	viper.SetDefault("dbase.operationTimeout", 60)
+	viper.SetDefault("oidc.scopes", []string{"openid", "profile", "email"})
+	viper.SetDefault("throttle.freeAttempts", 3)
+	viper.SetDefault("throttle.lockoutAttempts", 10)
+	viper.SetDefault("throttle.ipFreeAttempts", 20)
+	viper.SetDefault("throttle.ipLockoutAttempts", 100)
+	viper.SetDefault("throttle.baseDelay", 1)
+	viper.SetDefault("throttle.maxDelay", 300)
+	viper.SetDefault("throttle.lockoutTime", 15)
+	viper.SetDefault("throttle.resetAfter", 60)
+	viper.SetDefault("password.minLength", 10)
+	viper.SetDefault("password.maxLength", 64)
+	viper.SetDefault("password.minClasses", 3)
+	viper.SetDefault("password.disallowPersonal", true)
+	viper.SetDefault("cache.enabled", true)
+	viper.SetDefault("cache.size", 1000)
+	viper.SetDefault("cache.ttl", 60)
+	if viper.GetBool("oidc.enabled") {
+		for _, key := range []string{"oidc.issuer", "oidc.clientId", "oidc.redirectUrl"} {
+			if !viper.IsSet(key) {
+				log.Fatalf("Key/Value not set in file %s - %s", filename, key)
+			}
+		}
+	}
 	// TODO implement all required checks for config file
 
 	globalData.HttpPort = viper.GetString("global.httpPort")
@@ -47,6 +99,45 @@ func readConfig(errorLog *log.Logger, path, filename string) (globalData configT
 	globalData.sessionLifetime = time.Duration(viper.GetInt("api.sessionLifetime")) * time.Hour
 
 	globalData.DB.URL = viper.GetString("dbase.url")
+	globalData.DB.Timeout = time.Duration(viper.GetInt("dbase.timeout")) * time.Second
+	globalData.DB.Retries = viper.GetInt("dbase.retries")
+	globalData.DB.OperationTimeout = time.Duration(viper.GetInt("dbase.operationTimeout")) * time.Second
+
+	globalData.Token.Issuer = viper.GetString("token.issuerName")
+	globalData.Token.Audience = viper.GetString("token.audience")
+	globalData.Token.SigningKey = viper.GetString("token.signingKey")
+
+	globalData.RegistrationEnabled = viper.GetBool("registration.enabled")
+
+	globalData.OIDCEnabled = viper.GetBool("oidc.enabled")
+	globalData.OIDC.Issuer = viper.GetString("oidc.issuer")
+	globalData.OIDC.ClientID = viper.GetString("oidc.clientId")
+	globalData.OIDC.ClientSecret = viper.GetString("oidc.clientSecret")
+	globalData.OIDC.RedirectURL = viper.GetString("oidc.redirectUrl")
+	globalData.OIDC.Scopes = viper.GetStringSlice("oidc.scopes")
+
+	globalData.Throttle.FreeAttempts = viper.GetInt("throttle.freeAttempts")
+	globalData.Throttle.LockoutAttempts = viper.GetInt("throttle.lockoutAttempts")
+	globalData.Throttle.IPFreeAttempts = viper.GetInt("throttle.ipFreeAttempts")
+	globalData.Throttle.IPLockoutAttempts = viper.GetInt("throttle.ipLockoutAttempts")
+	globalData.Throttle.BaseDelay = time.Duration(viper.GetInt("throttle.baseDelay")) * time.Second
+	globalData.Throttle.MaxDelay = time.Duration(viper.GetInt("throttle.maxDelay")) * time.Second
+	globalData.Throttle.LockoutTime = time.Duration(viper.GetInt("throttle.lockoutTime")) * time.Minute
+	globalData.Throttle.ResetAfter = time.Duration(viper.GetInt("throttle.resetAfter")) * time.Minute
+	globalData.TrustedProxies = viper.GetStringSlice("throttle.trustedProxies")
+
+	globalData.Password.MinLength = viper.GetInt("password.minLength")
+	globalData.Password.MaxLength = viper.GetInt("password.maxLength")
+	globalData.Password.MinClasses = viper.GetInt("password.minClasses")
+	globalData.Password.DisallowPersonal = viper.GetBool("password.disallowPersonal")
+	globalData.Password.BreachedFile = viper.GetString("password.breachedFile")
+
+	globalData.CacheEnabled = viper.GetBool("cache.enabled")
+	globalData.CacheSize = viper.GetInt("cache.size")
+	globalData.CacheTTL = time.Duration(viper.GetInt("cache.ttl")) * time.Second
+	if globalData.CacheEnabled && (globalData.CacheSize <= 0 || globalData.CacheTTL <= 0) {
+		log.Fatalf("Invalid value in file %s - cache.size and cache.ttl must be positive", filename)
+	}
 
 	return globalData
 }
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/vgraveto/snippets/pkg/forms"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"strconv"
+)
+
+// renderProfile renders the profile page of the logged in user with their API keys, two-factor
+// authentication and active sessions, td holds the values only available right after an operation, like the plain-text
+// key of a new API key or the recovery codes, that are never kept on the session
+func (app *Application) renderProfile(rw http.ResponseWriter, r *http.Request, tokenMsg *models.TokenMessage,
+	td *TemplateData) {
+	user, err := app.Users.Get(r.Context(), tokenMsg.Token, tokenMsg.User.ID)
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	keys, err := app.Users.GetAPIKeys(r.Context(), tokenMsg.Token, tokenMsg.User.ID)
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	status, err := app.Users.GetMFA(r.Context(), tokenMsg.Token, tokenMsg.User.ID)
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	sessions, err := app.Users.GetSessions(r.Context(), tokenMsg.Token, tokenMsg.User.ID)
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+
+	if td.Form == nil {
+		td.Form = forms.New(nil)
+	}
+	td.User = user
+	td.APIKeys = keys
+	td.MFA = status
+	td.Sessions = sessions
+	app.render(rw, r, "profile.page.tmpl", td)
+}
+
+func (app *Application) createAPIKey(rw http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("createAPIKey: no user available on session"))
+		return
+	}
+
+	// Validate the form contents using the form helper
+	form := forms.New(r.PostForm)
+	form.Required("name")
+	form.MaxLength("name", 255)
+	scopes := form.GetString("scopes")
+	if len(scopes) == 0 {
+		form.Errors.Add("scopes", "Select at least one role")
+	}
+	days := 0
+	if d := form.Get("expiresInDays"); d != "" {
+		days, err = strconv.Atoi(d)
+		if err != nil || days < 0 || days > 3650 {
+			form.Errors.Add("expiresInDays", "This field must be a number of days between 0 and 3650")
+		}
+	}
+	if !form.Valid() {
+		app.renderProfile(rw, r, &tokenMsg, &TemplateData{Form: form})
+		return
+	}
+
+	k, err := app.Users.CreateAPIKey(r.Context(), tokenMsg.Token, tokenMsg.User.ID, &models.CreateAPIKey{
+		Name:          form.Get("name"),
+		Scopes:        scopes,
+		ExpiresInDays: days,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrBadRequest) || errors.Is(err, models.ErrValidation) {
+			form.Errors.Add("generic", "Invalid API key data")
+			app.renderProfile(rw, r, &tokenMsg, &TemplateData{Form: form})
+		} else if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	// the plain-text key is rendered instead of redirecting so it is never kept on the session
+	app.renderProfile(rw, r, &tokenMsg, &TemplateData{NewAPIKey: k})
+}
+
+func (app *Application) revokeAPIKey(rw http.ResponseWriter, r *http.Request) {
+	// get key ID from the URL
+	keyID, err := strconv.Atoi(mux.Vars(r)["keyId"])
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("revokeAPIKey: key %d:  %v\n", keyID, err)
+		app.serverError(rw, err)
+		return
+	}
+
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("revokeAPIKey: no user available on session"))
+		return
+	}
+
+	err = app.Users.RevokeAPIKey(r.Context(), tokenMsg.Token, tokenMsg.User.ID, keyID)
+	if err != nil {
+		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.Session.Put(r, KeySessionFlash, fmt.Sprintf("API key #%d not found", keyID))
+			http.Redirect(rw, r, "/user/profile", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	app.Session.Put(r, KeySessionFlash, fmt.Sprintf("API key #%d revoked!", keyID))
+	http.Redirect(rw, r, "/user/profile", http.StatusSeeOther)
+}
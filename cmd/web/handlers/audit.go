@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/forms"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+func (app *Application) listAudit(rw http.ResponseWriter, r *http.Request) {
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("listAudit: no user available on session"))
+		return
+	}
+
+	// the filter fields are sent on the query of the GET requests
+	form := forms.New(r.URL.Query())
+	f, err := models.ParseAuditFilter(r.URL.Query())
+	if err != nil {
+		form.Errors.Add("generic", fmt.Sprintf("Invalid filter: %v", err))
+		app.render(rw, r, "audit.page.tmpl", &TemplateData{Form: form})
+		return
+	}
+
+	page, err := app.Audit.GetAll(r.Context(), tokenMsg.Token, f)
+	if err != nil {
+		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			if app.DebugOn {
+				app.ErrorLog.Printf("listAudit: %v\n", err)
+			}
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrBadRequest) {
+			form.Errors.Add("generic", "Invalid filter")
+			app.render(rw, r, "audit.page.tmpl", &TemplateData{Form: form})
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	// the links to the previous and next pages keep the filter
+	td := &TemplateData{Form: form, Audit: page}
+	if f.Page > 1 {
+		prev := *f
+		prev.Page--
+		td.AuditPrev = "/audit?" + prev.Query().Encode()
+	}
+	if f.Page*f.PerPage < page.Total {
+		next := *f
+		next.Page++
+		td.AuditNext = "/audit?" + next.Query().Encode()
+	}
+	app.render(rw, r, "audit.page.tmpl", td)
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"bytes"
+	"github.com/vgraveto/snippets/pkg/models"
+	"github.com/vgraveto/snippets/pkg/models/mock"
 	"net/http"
 	"net/url"
+	"sync"
 	"testing"
 )
 
@@ -100,6 +103,9 @@ func TestSignupUser(t *testing.T) {
 		{"Invalid email (missing @)", "Bob", "bobexample.com", "validPa$$word", csrfToken, http.StatusOK, []byte("This field is invalid")},
 		{"Invalid email (missing local part)", "Bob", "@example.com", "validPa$$word", csrfToken, http.StatusOK, []byte("This field is invalid")},
 		{"Short password", "Bob", "bob@example.com", "pa$$word", csrfToken, http.StatusOK, []byte("This field is too short (minimum is 10 characters)")},
+		{"Password with few classes", "Bob", "bob@example.com", "onlylowercase", csrfToken, http.StatusOK, []byte("Use at least 3 of lowercase letters, uppercase letters, digits and symbols")},
+		{"Password with name", "Bob", "bob@example.com", "Bobby$Secret1", csrfToken, http.StatusOK, []byte("The password cannot contain your name or email")},
+		{"Breached password", "Bob", "bob@example.com", "Password123!", csrfToken, http.StatusOK, []byte("This password is on a list of breached passwords")},
 		{"Duplicate email", "Bob", "dupe@example.com", "validPa$$word", csrfToken, http.StatusOK, []byte("Address is already in use")},
 		{"Invalid CSRF Token", "", "", "", "wrongToken", http.StatusBadRequest, nil},
 	}
@@ -162,3 +168,712 @@ func TestCreateSnippetForm(t *testing.T) {
 		}
 	})
 }
+
+func TestTokenRefresh(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	// Authenticate an user that receives an expired token...
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "expired@example.com")
+	form.Add("password", "")
+	form.Add("csrf_token", csrfToken)
+	ts.postForm(t, "/user/login", form)
+
+	// ...the token is refreshed and the user stays logged in.
+	code, _, body := ts.get(t, "/snippet/create")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	formTag := "<form action='/snippet/create' method='POST'>"
+	if !bytes.Contains(body, []byte(formTag)) {
+		t.Errorf("want body %s to contain %q", body, formTag)
+	}
+
+	// After the logout the user must authenticate again.
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ = ts.postForm(t, "/user/logout", form)
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	code, headers, _ := ts.get(t, "/snippet/create")
+	if code != http.StatusFound {
+		t.Errorf("want %d; got %d", http.StatusFound, code)
+	}
+	if headers.Get("Location") != "/user/login" {
+		t.Errorf("want %s; got %s", "/user/login", headers.Get("Location"))
+	}
+}
+
+func TestConcurrentTokenRefresh(t *testing.T) {
+	app := newTestApplication(t)
+	users := &mock.UserModel{}
+	app.Users = users
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "expired@example.com")
+	form.Add("password", "")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	// the concurrent requests of the session with the expired token, like the assets of a page,
+	// rotate the refresh token once and all of them stay logged in
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rs, err := ts.Client().Get(ts.URL + "/snippet/create")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rs.Body.Close()
+			codes[i] = rs.StatusCode
+		}(i)
+	}
+	wg.Wait()
+	for _, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+	}
+	if users.Refreshes != 1 {
+		t.Errorf("want one refresh of the token; got %d", users.Refreshes)
+	}
+}
+
+func TestTamperedToken(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	// Authenticate an user whose token payload was changed...
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "tampered@example.com")
+	form.Add("password", "")
+	form.Add("csrf_token", csrfToken)
+	ts.postForm(t, "/user/login", form)
+
+	// ...the token signature is rejected and the user is logged out.
+	_, _, body = ts.get(t, "/")
+	flash := "You&#39;ve been logged out - invalid authentication, please logon!"
+	if !bytes.Contains(body, []byte(flash)) {
+		t.Errorf("want body %s to contain %q", body, flash)
+	}
+	code, headers, _ := ts.get(t, "/snippet/create")
+	if code != http.StatusFound {
+		t.Errorf("want %d; got %d", http.StatusFound, code)
+	}
+	if headers.Get("Location") != "/user/login" {
+		t.Errorf("want %s; got %s", "/user/login", headers.Get("Location"))
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	code, _, body := ts.get(t, "/user/profile")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("snk_AbCdEfGh")) {
+		t.Errorf("want body %s to contain %q", body, "snk_AbCdEfGh")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		keyName  string
+		scopes   []string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid submission", "Deploy", []string{"user"}, http.StatusOK, []byte("snk_AbCdEfGhIjKlMnOpQrStUvWxYz")},
+		{"Empty name", "", []string{"user"}, http.StatusOK, []byte("This field cannot be blank")},
+		{"No scopes", "Deploy", nil, http.StatusOK, []byte("Select at least one role")},
+		{"Scope not granted", "Deploy", []string{"administrator"}, http.StatusOK, []byte("Invalid API key data")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.keyName)
+			for _, s := range tt.scopes {
+				form.Add("scopes", s)
+			}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/api-keys", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, headers, _ := ts.postForm(t, "/user/api-keys/1/revoke", form)
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if headers.Get("Location") != "/user/profile" {
+		t.Errorf("want %s; got %s", "/user/profile", headers.Get("Location"))
+	}
+}
+
+func TestSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	code, _, body := ts.get(t, "/user/profile")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range [][]byte{[]byte("curl/8.4.0"), []byte("This session"), []byte("/user/sessions/2/revoke")} {
+		if !bytes.Contains(body, want) {
+			t.Errorf("want body %s to contain %q", body, want)
+		}
+	}
+	if bytes.Contains(body, []byte("/user/sessions/1/revoke")) {
+		t.Errorf("want body %s not to contain %q", body, "/user/sessions/1/revoke")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantFlash string
+	}{
+		{"Valid session", "/user/sessions/2/revoke", http.StatusSeeOther, "Session #2 signed out!"},
+		{"Unknown session", "/user/sessions/9/revoke", http.StatusSeeOther, "Session #9 not found"},
+		{"Invalid ID", "/user/sessions/0/revoke", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantFlash == "" {
+				return
+			}
+			if headers.Get("Location") != "/user/profile" {
+				t.Errorf("want %s; got %s", "/user/profile", headers.Get("Location"))
+			}
+			_, _, body := ts.get(t, "/user/profile")
+			if !bytes.Contains(body, []byte(tt.wantFlash)) {
+				t.Errorf("want body %s to contain %q", body, tt.wantFlash)
+			}
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody []byte
+	}{
+		{"All events", "/audit", []byte("4f1c9a7e2b6d8c30")},
+		{"Filtered out", "/audit?action=login", []byte("There are no events matching the filter.")},
+		{"Invalid page", "/audit?page=0", []byte("Invalid filter")},
+		{"Invalid date", "/audit?from=yesterday", []byte("Invalid filter")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLoginMFA(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantForm []byte
+	}{
+		{"Enabled", "mfa@example.com", []byte("Enter the code of your authenticator app")},
+		{"Enrollment required", "enroll@example.com", []byte("data:image/png;base64,")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.Routes())
+			defer ts.Close()
+
+			_, _, body := ts.get(t, "/user/login")
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", "")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			code, headers, _ := ts.postForm(t, "/user/login", form)
+			if code != http.StatusSeeOther {
+				t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+			}
+			if headers.Get("Location") != "/user/login/mfa" {
+				t.Fatalf("want %s; got %s", "/user/login/mfa", headers.Get("Location"))
+			}
+
+			code, _, body = ts.get(t, "/user/login/mfa")
+			if code != http.StatusOK {
+				t.Fatalf("want %d; got %d", http.StatusOK, code)
+			}
+			if !bytes.Contains(body, tt.wantForm) {
+				t.Errorf("want body %s to contain %q", body, tt.wantForm)
+			}
+			csrfToken := extractCSRFToken(t, body)
+
+			form = url.Values{}
+			form.Add("code", "654321")
+			form.Add("secret", mock.MFASecret)
+			form.Add("uri", "otpauth://totp/Snippets:alice@example.com?secret="+mock.MFASecret)
+			form.Add("csrf_token", csrfToken)
+			_, _, body = ts.postForm(t, "/user/login/mfa", form)
+			if !bytes.Contains(body, []byte("The code is invalid or the login expired")) {
+				t.Errorf("want body %s to contain %q", body, "The code is invalid or the login expired")
+			}
+			if !bytes.Contains(body, tt.wantForm) {
+				t.Errorf("want body %s to contain %q", body, tt.wantForm)
+			}
+
+			form.Set("code", mock.MFACode)
+			code, headers, _ = ts.postForm(t, "/user/login/mfa", form)
+			if code != http.StatusSeeOther {
+				t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+			}
+			if headers.Get("Location") != "/snippets" {
+				t.Errorf("want %s; got %s", "/snippets", headers.Get("Location"))
+			}
+			code, _, _ = ts.get(t, "/user/profile")
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+		})
+	}
+}
+
+func TestProfileMFA(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	_, _, body = ts.get(t, "/user/profile")
+	csrfToken := extractCSRFToken(t, body)
+
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, _, body := ts.postForm(t, "/user/mfa", form)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range [][]byte{[]byte(mock.MFASecret), []byte("data:image/png;base64,")} {
+		if !bytes.Contains(body, want) {
+			t.Errorf("want body %s to contain %q", body, want)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantBody []byte
+	}{
+		{"Invalid code", "654321", []byte("The code is invalid")},
+		{"Valid code", mock.MFACode, []byte("abcde-fghij")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("code", tt.code)
+			form.Add("secret", mock.MFASecret)
+			form.Add("uri", "otpauth://totp/Snippets:alice@example.com?secret="+mock.MFASecret)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/mfa/confirm", form)
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name           string
+		email          string
+		wantCode       int
+		wantRetryAfter string
+		wantBody       []byte
+	}{
+		{"First failure", "bob@example.com", http.StatusOK, "", []byte("Email or Password is incorrect")},
+		{"Backoff", "bob@example.com", http.StatusOK, "60", []byte("Email or Password is incorrect")},
+		{"Blocked", "bob@example.com", http.StatusTooManyRequests, "60", []byte("Too many failed logins")},
+		{"Blocked by the API", "locked@example.com", http.StatusTooManyRequests, "90", []byte("Too many failed logins, please try again in 90 seconds")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", "wrongPassword")
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/user/login", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Retry-After") != tt.wantRetryAfter {
+				t.Errorf("want Retry-After %q; got %q", tt.wantRetryAfter, headers.Get("Retry-After"))
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	// the other accounts are not blocked
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "")
+	form.Add("csrf_token", csrfToken)
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	_, _, body = ts.get(t, "/user/1")
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ := ts.postForm(t, "/user/1/unlock", form)
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if headers.Get("Location") != "/user/1" {
+		t.Errorf("want %s; got %s", "/user/1", headers.Get("Location"))
+	}
+}
+
+func TestLoginOIDC(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	idp, err := mock.NewOIDCServer("snippets-web", mock.OIDCUser{
+		Subject: "alice-sub",
+		Email:   "alice@example.com",
+		Name:    "Alice",
+		Groups:  []string{"snippets-users"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idp.Close()
+	app.OIDC = models.NewOIDCProvider(&models.OIDCData{
+		Issuer:      idp.URL,
+		ClientID:    "snippets-web",
+		RedirectURL: ts.URL + "/user/login/oidc/callback",
+	})
+
+	// The login page offers the login with the provider...
+	_, _, body := ts.get(t, "/user/login")
+	link := "<a href='/user/login/oidc'>"
+	if !bytes.Contains(body, []byte(link)) {
+		t.Errorf("want body %s to contain %q", body, link)
+	}
+
+	// ...that redirects the user to the provider authorization endpoint...
+	code, headers, _ := ts.get(t, "/user/login/oidc")
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	rs, err := ts.Client().Get(headers.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if rs.StatusCode != http.StatusFound {
+		t.Fatalf("want %d; got %d", http.StatusFound, rs.StatusCode)
+	}
+	callback, err := url.Parse(rs.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ...and back to the callback with a state that is only valid once.
+	code, headers, _ = ts.get(t, callback.RequestURI())
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if headers.Get("Location") != "/snippets" {
+		t.Errorf("want %s; got %s", "/snippets", headers.Get("Location"))
+	}
+	code, _, _ = ts.get(t, "/snippet/create")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+
+	code, _, body = ts.get(t, callback.RequestURI())
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	message := "Login with the identity provider expired, please try again"
+	if !bytes.Contains(body, []byte(message)) {
+		t.Errorf("want body %s to contain %q", body, message)
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/forgot-password")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		userEmail    string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid submission", "alice@example.com", http.StatusSeeOther, "/user/login", nil},
+		{"Unknown email", "bob@example.com", http.StatusSeeOther, "/user/login", nil},
+		{"Reset disabled", "reset-disabled@example.com", http.StatusSeeOther, "/user/login", nil},
+		{"Empty email", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Invalid email", "bobexample.com", http.StatusOK, "", []byte("This field is invalid")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.userEmail)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/user/forgot-password", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRecoverPassword(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	t.Run("Missing token", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/user/reset-password")
+		if code != http.StatusBadRequest {
+			t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+		}
+	})
+
+	code, _, body := ts.get(t, "/user/reset-password?token=validToken")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	tokenField := "<input name='token' type='hidden' value='validToken'>"
+	if !bytes.Contains(body, []byte(tokenField)) {
+		t.Errorf("want body %s to contain %q", body, tokenField)
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		token        string
+		password     string
+		confirmation string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid submission", "validToken", "validPa$$word", "validPa$$word", http.StatusSeeOther, "/user/login", nil},
+		{"Invalid token", "wrongToken", "validPa$$word", "validPa$$word", http.StatusSeeOther, "/user/forgot-password", nil},
+		{"Short password", "validToken", "pa$$word", "pa$$word", http.StatusOK, "", []byte("This field is too short (minimum is 10 characters)")},
+		{"Password rejected by the API", "validToken", "Alice$Secret1", "Alice$Secret1", http.StatusOK, "", []byte("The password cannot contain your name or email")},
+		{"Passwords mismatch", "validToken", "validPa$$word", "otherPa$$word", http.StatusOK, "", []byte("Passwords do not match")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("token", tt.token)
+			form.Add("newPassword", tt.password)
+			form.Add("newPasswordConfirmation", tt.confirmation)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/user/reset-password", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRegisterUser(t *testing.T) {
+	t.Run("Registration disabled", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.Routes())
+		defer ts.Close()
+
+		code, _, _ := ts.get(t, "/user/register")
+		if code != http.StatusNotFound {
+			t.Errorf("want %d; got %d", http.StatusNotFound, code)
+		}
+	})
+
+	app := newTestApplication(t)
+	app.RegistrationEnabled = true
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/register")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		confirmation string
+		wantCode     int
+		wantBody     []byte
+	}{
+		{"Valid submission", "Bob", "bob@example.com", "validPa$$word", "validPa$$word", http.StatusSeeOther, nil},
+		{"Empty name", "", "bob@example.com", "validPa$$word", "validPa$$word", http.StatusOK, []byte("This field cannot be blank")},
+		{"Invalid email", "Bob", "bobexample.com", "validPa$$word", "validPa$$word", http.StatusOK, []byte("This field is invalid")},
+		{"Short password", "Bob", "bob@example.com", "pa$$word", "pa$$word", http.StatusOK, []byte("This field is too short (minimum is 10 characters)")},
+		{"Passwords mismatch", "Bob", "bob@example.com", "validPa$$word", "otherPa$$word", http.StatusOK, []byte("Passwords do not match")},
+		{"Duplicate email", "Bob", "dupe@example.com", "validPa$$word", "validPa$$word", http.StatusSeeOther, nil},
+		{"Domain not allowed", "Bob", "bob@blocked.com", "validPa$$word", "validPa$$word", http.StatusOK, []byte("Registration is not allowed for this email domain")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("passwordConfirmation", tt.confirmation)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/register", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.Routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Valid token", "/user/verify?token=validToken", http.StatusSeeOther, "/user/login"},
+		{"Invalid token", "/user/verify?token=wrongToken", http.StatusSeeOther, "/"},
+		{"Missing token", "/user/verify", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+		})
+	}
+}
@@ -57,6 +57,8 @@ func (app *Application) addDefaultData(td *TemplateData, r *http.Request) *Templ
 	td.Flash = app.Session.PopString(r, KeySessionFlash)
 	// Add the authentication status to the template data.
 	td.IsAuthenticated = app.isAuthenticated(r)
+	td.CanRegister = app.RegistrationEnabled
+	td.CanOIDC = app.OIDC != nil
 	if td.IsAuthenticated {
 		tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
 		if !ok {
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"github.com/vgraveto/snippets/pkg/forms"
+	"github.com/vgraveto/snippets/pkg/models"
+	"html/template"
+	"net/http"
+)
+
+// qrCode returns the PNG data URL of the QR code scanned by the authenticator apps
+func qrCode(uri string) (template.URL, error) {
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)), nil
+}
+
+// enrollmentData returns the template data with the enrollment and its QR code
+func enrollmentData(enrollment *models.MFAEnrollment, form *forms.Form) (*TemplateData, error) {
+	if enrollment == nil {
+		return &TemplateData{Form: form}, nil
+	}
+	qr, err := qrCode(enrollment.URI)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateData{Form: form, MFAEnrollment: enrollment, MFAQRCode: qr}, nil
+}
+
+// postedEnrollment returns the enrollment sent back by the confirmation forms, used to show
+// the same QR code again when the code is invalid, the secret itself is only verified by the API
+func postedEnrollment(form *forms.Form) *models.MFAEnrollment {
+	if form.Get("uri") == "" {
+		return nil
+	}
+	return &models.MFAEnrollment{Secret: form.Get("secret"), URI: form.Get("uri")}
+}
+
+// mfaLoginExpired restarts the login when the challenge is no longer valid
+func (app *Application) mfaLoginExpired(rw http.ResponseWriter, r *http.Request) {
+	app.Session.Remove(r, KeySessionMFAChallenge)
+	app.Session.Remove(r, KeySessionMFAEnroll)
+	app.Session.Put(r, KeySessionFlash, "The login expired, please login again")
+	http.Redirect(rw, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *Application) loginMFAForm(rw http.ResponseWriter, r *http.Request) {
+	challenge := app.Session.GetString(r, KeySessionMFAChallenge)
+	if challenge == "" {
+		http.Redirect(rw, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+	if !app.Session.GetBool(r, KeySessionMFAEnroll) {
+		app.render(rw, r, "mfa.page.tmpl", &TemplateData{Form: forms.New(nil)})
+		return
+	}
+
+	// the roles of the user require two-factor authentication, the login confirms the enrollment
+	enrollment, err := app.Users.EnrollMFAChallenge(r.Context(), challenge)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) || errors.Is(err, models.ErrBadRequest) {
+			app.mfaLoginExpired(rw, r)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+	td, err := enrollmentData(enrollment, forms.New(nil))
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	app.render(rw, r, "mfa.page.tmpl", td)
+}
+
+func (app *Application) loginMFA(rw http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+
+	challenge := app.Session.GetString(r, KeySessionMFAChallenge)
+	if challenge == "" {
+		http.Redirect(rw, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+	form.MaxLength("code", 45)
+	if form.Valid() {
+		var tm *models.TokenMessage
+		tm, err = app.clientUsers(r).VerifyMFA(r.Context(), challenge, form.Get("code"))
+		if err == nil {
+			app.Session.Remove(r, KeySessionMFAChallenge)
+			app.Session.Remove(r, KeySessionMFAEnroll)
+			if len(tm.RecoveryCodes) == 0 {
+				app.logIn(rw, r, tm)
+				return
+			}
+			// the login completed the enrollment, the recovery codes are shown once and never kept on the session
+			codes := tm.RecoveryCodes
+			tm.RecoveryCodes = nil
+			app.Session.Put(r, KeySessionTokenMessage, *tm)
+			app.Session.Put(r, KeySessionFlash, "Two-factor authentication enabled!")
+			app.render(rw, r, "mfa.page.tmpl", &TemplateData{RecoveryCodes: codes})
+			return
+		}
+		if !errors.Is(err, models.ErrInvalidMFACode) {
+			app.serverError(rw, err)
+			return
+		}
+		form.Errors.Add("code", "The code is invalid or the login expired")
+	}
+
+	td := &TemplateData{Form: form}
+	if app.Session.GetBool(r, KeySessionMFAEnroll) {
+		td, err = enrollmentData(postedEnrollment(form), form)
+		if err != nil {
+			app.serverError(rw, err)
+			return
+		}
+	}
+	app.render(rw, r, "mfa.page.tmpl", td)
+}
+
+func (app *Application) enableMFA(rw http.ResponseWriter, r *http.Request) {
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("enableMFA: no user available on session"))
+		return
+	}
+
+	enrollment, err := app.Users.EnrollMFA(r.Context(), tokenMsg.Token, tokenMsg.User.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrBadRequest) {
+			app.Session.Put(r, KeySessionFlash, "Two-factor authentication is already enabled")
+			http.Redirect(rw, r, "/user/profile", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	td, err := enrollmentData(enrollment, forms.New(nil))
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	app.renderProfile(rw, r, &tokenMsg, td)
+}
+
+func (app *Application) confirmMFA(rw http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("confirmMFA: no user available on session"))
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+	form.MaxLength("code", 45)
+	if form.Valid() {
+		var codes []string
+		codes, err = app.Users.ConfirmMFA(r.Context(), tokenMsg.Token, tokenMsg.User.ID, form.Get("code"))
+		if err == nil {
+			app.Session.Put(r, KeySessionFlash, "Two-factor authentication enabled!")
+			app.renderProfile(rw, r, &tokenMsg, &TemplateData{RecoveryCodes: codes})
+			return
+		}
+		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+			return
+		} else if !errors.Is(err, models.ErrInvalidMFACode) {
+			app.serverError(rw, err)
+			return
+		}
+		form.Errors.Add("code", "The code is invalid")
+	}
+
+	td, err := enrollmentData(postedEnrollment(form), form)
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	app.renderProfile(rw, r, &tokenMsg, td)
+}
+
+func (app *Application) disableMFA(rw http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("disableMFA: no user available on session"))
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("disableCode")
+	form.MaxLength("disableCode", 45)
+	if !form.Valid() {
+		app.renderProfile(rw, r, &tokenMsg, &TemplateData{Form: form})
+		return
+	}
+
+	err = app.Users.DisableMFA(r.Context(), tokenMsg.Token, tokenMsg.User.ID, form.Get("disableCode"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidMFACode) {
+			form.Errors.Add("disableCode", "The code is invalid or two-factor authentication is required by your roles")
+			app.renderProfile(rw, r, &tokenMsg, &TemplateData{Form: form})
+		} else if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	app.Session.Put(r, KeySessionFlash, "Two-factor authentication disabled!")
+	http.Redirect(rw, r, "/user/profile", http.StatusSeeOther)
+}
+
+func (app *Application) listRoles(rw http.ResponseWriter, r *http.Request) {
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("listRoles: no user available on session"))
+		return
+	}
+
+	roles, err := app.Users.GetRoleTypes(r.Context(), tokenMsg.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			if app.DebugOn {
+				app.ErrorLog.Printf("listRoles: %v\n", err)
+			}
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	app.render(rw, r, "roles.page.tmpl", &TemplateData{Roles: roles})
+}
+
+func (app *Application) setRoleMFA(rw http.ResponseWriter, r *http.Request) {
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("setRoleMFA: role %d:  %v\n", id, err)
+		app.serverError(rw, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	form.Required("mfaRequired")
+	form.PermittedValues("mfaRequired", "true", "false")
+	if !form.Valid() {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+	required := form.Get("mfaRequired") == "true"
+
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("setRoleMFA: no user available on session"))
+		return
+	}
+
+	err = app.Users.SetRoleMFA(r.Context(), tokenMsg.Token, id, required)
+	if err != nil {
+		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.Session.Put(r, KeySessionFlash, fmt.Sprintf("Role type #%d not found", id))
+			http.Redirect(rw, r, "/users/roles", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	if required {
+		app.Session.Put(r, KeySessionFlash, fmt.Sprintf("Role type #%d requires two-factor authentication!", id))
+	} else {
+		app.Session.Put(r, KeySessionFlash, fmt.Sprintf("Role type #%d does not require two-factor authentication!", id))
+	}
+	http.Redirect(rw, r, "/users/roles", http.StatusSeeOther)
+}
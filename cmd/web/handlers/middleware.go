@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/justinas/nosurf"
 	"github.com/vgraveto/snippets/pkg/models"
@@ -94,27 +95,37 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		// verify expiration date of token
-		tokenClaims, err := models.GetClaimsFromToken(&tokenMsg.Token)
-		if err != nil {
-			app.serverError(rw, err)
-			return
-		}
-		expiresAt := tokenClaims.StandardClaims.ExpiresAt
-		delta := time.Unix(time.Now().Unix(), 0).Sub(time.Unix(expiresAt, 0))
-		if delta > 0 {
+		// verify the signature and the claims of the token
+		tokenClaims, err := app.Tokens.Verify(tokenMsg.Token)
+		if err != nil && !errors.Is(err, models.ErrExpiredToken) {
+			app.ErrorLog.Printf("authenticate: user %d: %v\n", tokenMsg.User.ID, err)
 			app.Session.Remove(r, KeySessionTokenMessage)
-			app.Session.Put(r, KeySessionFlash, "You've been logged out - authentication expired, please logon!")
+			app.Session.Put(r, KeySessionFlash, "You've been logged out - invalid authentication, please logon!")
 			next.ServeHTTP(rw, r)
 			return
 		}
+		if err != nil || time.Until(time.Unix(tokenClaims.ExpiresAt, 0)) < tokenRefreshMargin {
+			// the token is expired or about to expire - get a new one with the refresh token
+			newTokenMsg, errRefresh := app.refreshToken(r, &tokenMsg)
+			if errRefresh != nil {
+				app.ErrorLog.Printf("authenticate: %v\n", errRefresh)
+			}
+			if newTokenMsg != nil {
+				app.Session.Put(r, KeySessionTokenMessage, *newTokenMsg)
+			} else if err != nil {
+				app.Session.Remove(r, KeySessionTokenMessage)
+				app.Session.Put(r, KeySessionFlash, "You've been logged out - authentication expired, please logon!")
+				next.ServeHTTP(rw, r)
+				return
+			}
+		}
 
 		/*
 			// Fetch the details of the current user from the api database. If no matching
 			// record is found, or the current user is has been deactivated, remove the
 			// (invalid) user value from their session and call the next
 			// handler in the chain as normal.
-			user, err := app.Users.Get(tokenMsg.Token, tokenMsg.User.ID)
+			user, err := app.Users.Get(r.Context(), tokenMsg.Token, tokenMsg.User.ID)
 			if  errors.Is(err, models.ErrNoRecord) ||
 				errors.Is(err, models.ErrUnauthorizedToken) ||
 				errors.Is(err, models.ErrForbiddenToken) ||
@@ -136,3 +147,26 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(rw, r.WithContext(ctx))
 	})
 }
+
+// refreshToken exchanges the refresh token of the token message for new tokens.
+// A nil token message is returned when no refresh token is available or the API rejects it.
+func (app *Application) refreshToken(r *http.Request, tokenMsg *models.TokenMessage) (*models.TokenMessage, error) {
+	if tokenMsg.RefreshToken == "" {
+		return nil, nil
+	}
+	// the concurrent requests of the session share the rotation of the refresh token
+	newTokenMsg, err := app.refreshes.do(r.Context(), tokenMsg.RefreshToken, func() (*models.TokenMessage, error) {
+		return app.clientUsers(r).RefreshToken(r.Context(), tokenMsg.RefreshToken)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("refreshToken: %v", err)
+	}
+	_, err = app.Tokens.Verify(newTokenMsg.Token)
+	if err != nil {
+		return nil, fmt.Errorf("refreshToken: %v", err)
+	}
+	if app.DebugOn {
+		app.InfoLog.Printf("refreshToken: token refreshed for user %d\n", newTokenMsg.User.ID)
+	}
+	return newTokenMsg, nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/forms"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// loginOIDC starts the authorization code flow redirecting the user to the OpenID Connect provider,
+// the state, nonce and PKCE code verifier of the request are kept on the session
+func (app *Application) loginOIDC(rw http.ResponseWriter, r *http.Request) {
+	if app.OIDC == nil {
+		app.notFound(rw)
+		return
+	}
+
+	state, err := models.NewRandomToken()
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	nonce, err := models.NewRandomToken()
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	verifier, err := models.NewPKCEVerifier()
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+
+	authURL, err := app.OIDC.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	app.Session.Put(r, KeySessionOIDCState, state)
+	app.Session.Put(r, KeySessionOIDCNonce, nonce)
+	app.Session.Put(r, KeySessionOIDCVerifier, verifier)
+	http.Redirect(rw, r, authURL, http.StatusSeeOther)
+}
+
+// loginOIDCCallback receives the authorization code from the OpenID Connect provider,
+// exchanges it for the ID token and logs in the user of the ID token on the API
+func (app *Application) loginOIDCCallback(rw http.ResponseWriter, r *http.Request) {
+	if app.OIDC == nil {
+		app.notFound(rw)
+		return
+	}
+
+	// the values of the request are only used once
+	state := app.Session.PopString(r, KeySessionOIDCState)
+	nonce := app.Session.PopString(r, KeySessionOIDCNonce)
+	verifier := app.Session.PopString(r, KeySessionOIDCVerifier)
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		app.ErrorLog.Printf("loginOIDCCallback: provider error %q: %s\n", e, q.Get("error_description"))
+		app.oidcLoginFailed(rw, r, "Login with the identity provider was not completed")
+		return
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(q.Get("state"))) != 1 {
+		app.ErrorLog.Printf("loginOIDCCallback: invalid state\n")
+		app.oidcLoginFailed(rw, r, "Login with the identity provider expired, please try again")
+		return
+	}
+
+	idToken, err := app.OIDC.Exchange(r.Context(), q.Get("code"), verifier)
+	if err != nil {
+		app.ErrorLog.Printf("loginOIDCCallback: %v\n", err)
+		app.oidcLoginFailed(rw, r, "Login with the identity provider failed")
+		return
+	}
+	_, err = app.OIDC.VerifyIDToken(idToken, nonce)
+	if err != nil {
+		app.ErrorLog.Printf("loginOIDCCallback: %v\n", err)
+		app.oidcLoginFailed(rw, r, "Login with the identity provider failed")
+		return
+	}
+
+	// the API verifies the nonce again and accepts it once
+	tm, err := app.clientUsers(r).AuthenticateOIDC(r.Context(), idToken, nonce)
+	if err != nil {
+		var challenge *models.MFAChallenge
+		if errors.As(err, &challenge) {
+			// the login is completed with the code of the two-factor authentication
+			app.Session.Put(r, KeySessionMFAChallenge, challenge.Challenge)
+			app.Session.Put(r, KeySessionMFAEnroll, challenge.EnrollmentRequired)
+			http.Redirect(rw, r, "/user/login/mfa", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrInvalidCredentials) {
+			app.oidcLoginFailed(rw, r, "Your account is not allowed to use this application")
+		} else {
+			app.serverError(rw, fmt.Errorf("loginOIDCCallback: %v", err))
+		}
+		return
+	}
+
+	app.logIn(rw, r, tm)
+}
+
+// oidcLoginFailed renders the login page with the error of the login with the identity provider
+func (app *Application) oidcLoginFailed(rw http.ResponseWriter, r *http.Request, message string) {
+	form := forms.New(nil)
+	form.Errors.Add("generic", message)
+	app.render(rw, r, "login.page.tmpl", &TemplateData{Form: form})
+}
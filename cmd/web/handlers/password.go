@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/vgraveto/snippets/pkg/forms"
+	"github.com/vgraveto/snippets/pkg/models"
+	"strings"
+)
+
+// checkPassword adds to the field of the form the rules of the password policy its password does not follow,
+// blank passwords are left to form.Required
+func (app *Application) checkPassword(form *forms.Form, field, name, email string) error {
+	password := form.Get(field)
+	if password == "" {
+		return nil
+	}
+	msgs, err := app.PasswordPolicy.Check(password, name, email)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		form.Errors.Add(field, msg)
+	}
+	return nil
+}
+
+// addValidationErrors adds to the form the messages of a validation error of the API, on the fields of their
+// "field: " prefix, or a generic error when the API did not describe the problems
+func addValidationErrors(form *forms.Form, err error, fields ...string) {
+	var ve *models.ValidationMessagesError
+	if !errors.As(err, &ve) {
+		form.Errors.Add("generic", "bad request invalid data provided")
+		return
+	}
+	for _, msg := range ve.Messages {
+		field := "generic"
+		for _, f := range fields {
+			if strings.HasPrefix(msg, f+": ") {
+				field, msg = f, strings.TrimPrefix(msg, f+": ")
+				break
+			}
+		}
+		form.Errors.Add(field, msg)
+	}
+}
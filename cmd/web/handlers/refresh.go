@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"sync"
+	"time"
+)
+
+// refreshGrace is the time the new tokens of a refresh are returned to the requests of the same
+// session that still carry the previous refresh token, like the assets of a page or another tab
+const refreshGrace = 30 * time.Second
+
+// refreshGroup serializes the refreshes of each refresh token. The API rotates a refresh token once
+// and revokes its family when it is used again, so the concurrent requests of a session share a
+// single refresh and its result instead of each one rotating the same token.
+type refreshGroup struct {
+	mu    sync.Mutex
+	calls map[string]*refreshCall // by the hash of the refresh token
+}
+
+// refreshCall is a refresh of a token, its result is kept until the expires after it is done
+type refreshCall struct {
+	done     chan struct{}
+	tokenMsg *models.TokenMessage
+	err      error
+	expires  time.Time
+}
+
+// do calls refresh once for the concurrent calls of the token and returns the new tokens to all of
+// them, and to the calls of the following refreshGrace. The failed refreshes are not kept.
+func (g *refreshGroup) do(ctx context.Context, token string, refresh func() (*models.TokenMessage, error)) (*models.TokenMessage, error) {
+	key := models.HashToken(token)
+	now := time.Now()
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*refreshCall{}
+	}
+	for k, c := range g.calls {
+		if !c.expires.IsZero() && now.After(c.expires) {
+			delete(g.calls, k)
+		}
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+		cp := *c.tokenMsg
+		return &cp, nil
+	}
+	c := &refreshCall{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.tokenMsg, c.err = refresh()
+	g.mu.Lock()
+	if c.err != nil {
+		delete(g.calls, key)
+	} else {
+		c.expires = time.Now().Add(refreshGrace)
+	}
+	g.mu.Unlock()
+	close(c.done)
+	return c.tokenMsg, c.err
+}
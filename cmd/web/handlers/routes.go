@@ -33,18 +33,53 @@ func (app *Application) Routes() http.Handler {
 		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createUser)).Methods("POST")
 	mux.Handle("/user/login", dynamicMiddleware.ThenFunc(app.loginUserForm)).Methods("GET")
 	mux.Handle("/user/login", dynamicMiddleware.ThenFunc(app.loginUser)).Methods("POST")
+	mux.Handle("/user/login/mfa", dynamicMiddleware.ThenFunc(app.loginMFAForm)).Methods("GET")
+	mux.Handle("/user/login/mfa", dynamicMiddleware.ThenFunc(app.loginMFA)).Methods("POST")
+	mux.Handle("/user/login/oidc", dynamicMiddleware.ThenFunc(app.loginOIDC)).Methods("GET")
+	mux.Handle("/user/login/oidc/callback", dynamicMiddleware.ThenFunc(app.loginOIDCCallback)).Methods("GET")
+	mux.Handle("/user/register", dynamicMiddleware.ThenFunc(app.registerUserForm)).Methods("GET")
+	mux.Handle("/user/register", dynamicMiddleware.ThenFunc(app.registerUser)).Methods("POST")
+	mux.Handle("/user/verify", dynamicMiddleware.ThenFunc(app.verifyEmail)).Methods("GET")
+	mux.Handle("/user/forgot-password", dynamicMiddleware.ThenFunc(app.forgotPasswordForm)).Methods("GET")
+	mux.Handle("/user/forgot-password", dynamicMiddleware.ThenFunc(app.forgotPassword)).Methods("POST")
+	mux.Handle("/user/reset-password", dynamicMiddleware.ThenFunc(app.recoverPasswordForm)).Methods("GET")
+	mux.Handle("/user/reset-password", dynamicMiddleware.ThenFunc(app.recoverPassword)).Methods("POST")
 	mux.Handle("/user/logout",
 		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.logoutUser)).Methods("POST")
 	mux.Handle("/users",
 		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.listUsers)).Methods("GET")
+	mux.Handle("/users/pending",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.listPendingUsers)).Methods("GET")
+	mux.Handle("/audit",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.listAudit)).Methods("GET")
+	mux.Handle("/users/roles",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.listRoles)).Methods("GET")
+	mux.Handle("/users/roles/{id:[1-9][0-9]*}/mfa",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.setRoleMFA)).Methods("POST")
+	mux.Handle("/user/{id:[1-9][0-9]*}/approval",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.approveUser)).Methods("POST")
 	mux.Handle("/user/{id:[1-9][0-9]*}",
 		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.userGet)).Methods("GET")
+	mux.Handle("/user/{id:[1-9][0-9]*}/unlock",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.unlockUser)).Methods("POST")
 	mux.Handle("/user/{id:[1-9][0-9]*}/reset-password",
 		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.resetPasswordForm)).Methods("GET")
 	mux.Handle("/user/{id:[1-9][0-9]*}/reset-password",
 		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.resetPassword)).Methods("POST")
 	mux.Handle("/user/profile",
 		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.userProfile)).Methods("GET")
+	mux.Handle("/user/api-keys",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createAPIKey)).Methods("POST")
+	mux.Handle("/user/api-keys/{keyId:[1-9][0-9]*}/revoke",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.revokeAPIKey)).Methods("POST")
+	mux.Handle("/user/sessions/{sid:[1-9][0-9]*}/revoke",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.revokeSession)).Methods("POST")
+	mux.Handle("/user/mfa",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.enableMFA)).Methods("POST")
+	mux.Handle("/user/mfa/confirm",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.confirmMFA)).Methods("POST")
+	mux.Handle("/user/mfa/disable",
+		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.disableMFA)).Methods("POST")
 	mux.Handle("/user/change-password",
 		dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.changePasswordForm)).Methods("GET")
 	mux.Handle("/user/change-password",
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"strconv"
+)
+
+// clientUsers returns the users of the API forwarding the address and the user agent of the
+// client of the request, so that the sessions started on the logins describe its device
+func (app *Application) clientUsers(r *http.Request) models.APIUsers {
+	return app.Users.WithClient(models.ClientIP(r, app.TrustedProxies), r.UserAgent())
+}
+
+func (app *Application) revokeSession(rw http.ResponseWriter, r *http.Request) {
+	// get session ID from the URL
+	sessionID, err := strconv.Atoi(mux.Vars(r)["sid"])
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("revokeSession: session %d:  %v\n", sessionID, err)
+		app.serverError(rw, err)
+		return
+	}
+
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("revokeSession: no user available on session"))
+		return
+	}
+
+	err = app.Users.RevokeSession(r.Context(), tokenMsg.Token, tokenMsg.User.ID, sessionID)
+	if err != nil {
+		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.Session.Put(r, KeySessionFlash, fmt.Sprintf("Session #%d not found", sessionID))
+			http.Redirect(rw, r, "/user/profile", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	app.Session.Put(r, KeySessionFlash, fmt.Sprintf("Session #%d signed out!", sessionID))
+	http.Redirect(rw, r, "/user/profile", http.StatusSeeOther)
+}
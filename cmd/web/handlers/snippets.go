@@ -10,7 +10,7 @@ import (
 
 func (app *Application) listSnippets(rw http.ResponseWriter, r *http.Request) {
 
-	s, err := app.Snippets.Latest()
+	s, err := app.Snippets.Latest(r.Context())
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(rw)
@@ -38,7 +38,7 @@ func (app *Application) showSnippet(rw http.ResponseWriter, r *http.Request) {
 	// Use the SnippetModel object's Get method to retrieve the data for a
 	// specific record based on its ID. If no matching record is found,
 	// return a 404 Not Found response.
-	s, err := app.Snippets.Get(idValue)
+	s, err := app.Snippets.Get(r.Context(), idValue)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(rw)
@@ -98,7 +98,7 @@ func (app *Application) createSnippet(rw http.ResponseWriter, r *http.Request) {
 	// Because the form data (with type url.Values) has been anonymously embedded
 	// in the form.Form struct, we can use the Get() method to retrieve
 	// the validated value for a particular form field.
-	id, err := app.Snippets.Insert(tokenMsg.Token, form.Get("title"), form.Get("content"), form.Get("expires"))
+	id, err := app.Snippets.Insert(r.Context(), tokenMsg.Token, form.Get("title"), form.Get("content"), form.Get("expires"))
 	if err != nil {
 		app.serverError(rw, err)
 		return
@@ -17,6 +17,8 @@ type TemplateData struct {
 	Form            *forms.Form
 	IsAuthenticated bool
 	IsAdmin         bool
+	CanRegister     bool
+	CanOIDC         bool
 	LoggedInName    string
 	ID              int
 	Snippet         *models.Snippet
@@ -24,6 +26,16 @@ type TemplateData struct {
 	User            *models.User
 	Users           []*models.User
 	Roles           []*models.RoleType
+	APIKeys         []*models.APIKey
+	NewAPIKey       *models.NewAPIKeyMessage
+	MFA             *models.MFAStatus
+	MFAEnrollment   *models.MFAEnrollment
+	MFAQRCode       template.URL
+	RecoveryCodes   []string
+	Sessions        []*models.Session
+	Audit           *models.AuditPage
+	AuditPrev       string
+	AuditNext       string
 }
 
 // Create a humanDate function which returns a nicely formatted string
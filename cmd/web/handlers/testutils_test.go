@@ -52,6 +52,17 @@ func newTestApplication(t *testing.T) *Application {
 	session.Lifetime = 12 * time.Hour
 	session.Secure = true
 
+	passwordPolicy, err := models.NewPasswordPolicy(models.PasswordPolicyData{
+		MinLength:        10,
+		MaxLength:        64,
+		MinClasses:       3,
+		DisallowPersonal: true,
+		BreachedFile:     "./../../../breachedPasswords.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// Initialize the dependencies, using the mocks for the loggers and // database models.
 	return &Application{
 		ErrorLog:      log.New(ioutil.Discard, "", 0),
@@ -60,6 +71,23 @@ func newTestApplication(t *testing.T) *Application {
 		Snippets:      &mock.SnippetModel{},
 		TemplateCache: templateCache,
 		Users:         &mock.UserModel{},
+		Audit:         &mock.AuditModel{},
+		Tokens: models.NewVerifierModel(&models.VerifierData{
+			Issuer:     mock.TokenData.TokenIssuerName,
+			Audience:   mock.TokenData.TokenAudience,
+			SigningKey: mock.TokenData.TokenSigningKey,
+		}),
+		Throttle: models.NewLoginThrottle(models.NewMemoryLoginAttempts(), models.ThrottleData{
+			FreeAttempts:      1,
+			LockoutAttempts:   3,
+			IPFreeAttempts:    10,
+			IPLockoutAttempts: 20,
+			BaseDelay:         time.Minute,
+			MaxDelay:          time.Hour,
+			LockoutTime:       time.Hour,
+			ResetAfter:        time.Hour,
+		}),
+		PasswordPolicy: passwordPolicy,
 	}
 }
 
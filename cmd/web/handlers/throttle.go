@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/forms"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"strconv"
+)
+
+// loginKeys returns the throttling keys of the account and of the client address of the login
+func (app *Application) loginKeys(r *http.Request, email string) []string {
+	keys := []string{models.AccountKey(email)}
+	if ip := models.ClientIP(r, app.TrustedProxies); ip != "" {
+		keys = append(keys, models.IPKey(ip))
+	}
+	return keys
+}
+
+// loginSucceeded forgets the failed logins of the account and gives back the attempt of the address
+func (app *Application) loginSucceeded(r *http.Request, keys []string) {
+	err := app.Throttle.Succeed(r.Context(), keys...)
+	if err != nil {
+		app.ErrorLog.Printf("loginSucceeded: %v\n", err)
+	}
+}
+
+// setRetryAfter adds the Retry-After header when err is a *models.LoginLockedError and returns true
+func setRetryAfter(rw http.ResponseWriter, err error) bool {
+	var locked *models.LoginLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	rw.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+	return true
+}
+
+// loginLocked renders the login page with the time to wait when err is a *models.LoginLockedError,
+// returned by the throttle of the web or by the API, and returns true
+func (app *Application) loginLocked(rw http.ResponseWriter, r *http.Request, form *forms.Form, err error) bool {
+	var locked *models.LoginLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	if app.DebugOn {
+		app.ErrorLog.Printf("loginLocked: %v\n", err)
+	}
+	setRetryAfter(rw, err)
+	form.Errors.Add("generic", fmt.Sprintf("Too many failed logins, please try again in %d seconds", locked.RetryAfterSeconds()))
+	rw.WriteHeader(http.StatusTooManyRequests)
+	app.render(rw, r, "login.page.tmpl", &TemplateData{Form: form})
+	return true
+}
+
+func (app *Application) unlockUser(rw http.ResponseWriter, r *http.Request) {
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("unlockUser: user %d:  %v\n", id, err)
+		app.serverError(rw, err)
+		return
+	}
+
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("unlockUser: no user available on session"))
+		return
+	}
+
+	err = app.Users.UnlockUser(r.Context(), tokenMsg.Token, id)
+	if err != nil {
+		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.Session.Put(r, KeySessionFlash, fmt.Sprintf("User #%d not found", id))
+			http.Redirect(rw, r, "/users", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	// the failed logins kept by the web are also removed
+	user, err := app.Users.Get(r.Context(), tokenMsg.Token, id)
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	err = app.Throttle.Unlock(r.Context(), models.AccountKey(user.Email))
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+
+	app.Session.Put(r, KeySessionFlash, fmt.Sprintf("User #%d unlocked!", id))
+	http.Redirect(rw, r, fmt.Sprintf("/user/%d", id), http.StatusSeeOther)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/vgraveto/snippets/pkg/models"
 	"html/template"
 	"log"
+	"time"
 )
 
 type contextKey string
@@ -17,8 +18,16 @@ const (
 	KeySessionTokenMessage = "authenticatedTokenMessage"
 	KeySessionFlash        = "flash"
 	KeySessionRedirectPath = "redirectPathAfterLogin"
+	KeySessionOIDCState    = "oidcState"
+	KeySessionOIDCNonce    = "oidcNonce"
+	KeySessionOIDCVerifier = "oidcCodeVerifier"
+	KeySessionMFAChallenge = "mfaChallenge"
+	KeySessionMFAEnroll    = "mfaEnrollmentRequired"
 )
 
+// tokenRefreshMargin the tokens that expire within this time are refreshed before being used on the API
+const tokenRefreshMargin = 30 * time.Second
+
 // Application Define a struct to hold the application-wide dependencies for the web application.
 // This will allow us to make the objects available to our handlers.
 type Application struct {
@@ -29,4 +38,22 @@ type Application struct {
 	TemplateCache map[string]*template.Template
 	Snippets      models.APISnippets
 	Users         models.APIUsers
+	Tokens        models.TokenVerifier
+	Audit         models.APIAudit
+
+	// RegistrationEnabled shows the public registration pages
+	RegistrationEnabled bool
+
+	// OIDC is the OpenID Connect provider of the users, nil when the login with it is disabled
+	OIDC *models.OIDCProvider
+
+	// throttling of the failed logins, the X-Forwarded-For header is only used from the TrustedProxies
+	Throttle       *models.LoginThrottle
+	TrustedProxies []string
+
+	// PasswordPolicy checks the new passwords before they are sent to the API
+	PasswordPolicy *models.PasswordPolicy
+
+	// the refreshes of the tokens in progress or done within the refreshGrace
+	refreshes refreshGroup
 }
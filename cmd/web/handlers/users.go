@@ -6,6 +6,7 @@ import (
 	"github.com/vgraveto/snippets/pkg/forms"
 	"github.com/vgraveto/snippets/pkg/models"
 	"net/http"
+	"net/url"
 )
 
 func (app *Application) createUserForm(rw http.ResponseWriter, r *http.Request) {
@@ -20,7 +21,7 @@ func (app *Application) createUserForm(rw http.ResponseWriter, r *http.Request)
 	}
 
 	// Get possible user role types from database
-	roles, err := app.Users.GetRoleTypes(tokenMsg.Token)
+	roles, err := app.Users.GetRoleTypes(r.Context(), tokenMsg.Token)
 	if err != nil {
 		app.serverError(rw, err)
 		return
@@ -46,7 +47,11 @@ func (app *Application) createUser(rw http.ResponseWriter, r *http.Request) {
 	form.MaxLength("name", 255)
 	form.MaxLength("email", 255)
 	form.MatchesPattern("email", forms.EmailRX)
-	form.MinLength("password", 10)
+	err = app.checkPassword(form, "password", form.Get("name"), form.Get("email"))
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
 
 	// If there are any errors, redisplay the signup form.
 	if !form.Valid() {
@@ -70,7 +75,7 @@ func (app *Application) createUser(rw http.ResponseWriter, r *http.Request) {
 
 	// Try to create a new user record in the database. If the email already exists
 	// add an error message to the form and re-display it.
-	err = app.Users.Insert(tokenMsg.Token,
+	err = app.Users.Insert(r.Context(), tokenMsg.Token,
 		form.Get("name"),
 		form.Get("email"),
 		form.Get("password"),
@@ -79,6 +84,9 @@ func (app *Application) createUser(rw http.ResponseWriter, r *http.Request) {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.Errors.Add("email", "Address is already in use")
 			app.render(rw, r, "signup.page.tmpl", &TemplateData{Form: form})
+		} else if errors.Is(err, models.ErrValidation) {
+			addValidationErrors(form, err, "password")
+			app.render(rw, r, "signup.page.tmpl", &TemplateData{Form: form})
 		} else if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
 			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
 			http.Redirect(rw, r, "/", http.StatusSeeOther)
@@ -113,28 +121,53 @@ func (app *Application) loginUser(rw http.ResponseWriter, r *http.Request) {
 	// message to the form failures map and re-display the login page.
 	form := forms.New(r.PostForm)
 
-	token, err := app.Users.Authenticate(form.Get("email"), form.Get("password"))
+	// the attempt is reserved before calling the API, the blocked logins and the parallel
+	// ones beyond the limits are rejected
+	keys := app.loginKeys(r, form.Get("email"))
+	err = app.Throttle.Reserve(r.Context(), keys...)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.Errors.Add("generic", "Email or Password is incorrect")
-			app.render(rw, r, "login.page.tmpl", &TemplateData{Form: form})
-		} else {
+		if !app.loginLocked(rw, r, form, err) {
 			app.serverError(rw, err)
 		}
 		return
 	}
 
-	// Add new tokenUser to the session, so that they are now 'logged in'.
-	tu, err := models.GetUserFromToken(&token)
+	tm, err := app.clientUsers(r).Authenticate(r.Context(), form.Get("email"), form.Get("password"))
 	if err != nil {
-		app.serverError(rw, err)
+		var challenge *models.MFAChallenge
+		if errors.As(err, &challenge) {
+			// the password is valid, the login is completed with the code of the two-factor authentication
+			app.loginSucceeded(r, keys)
+			app.Session.Put(r, KeySessionMFAChallenge, challenge.Challenge)
+			app.Session.Put(r, KeySessionMFAEnroll, challenge.EnrollmentRequired)
+			http.Redirect(rw, r, "/user/login/mfa", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrInvalidCredentials) {
+			// the next login waits when the failures reach the limits
+			if ferr := app.Throttle.Fail(r.Context(), keys...); ferr != nil && !setRetryAfter(rw, ferr) {
+				app.ErrorLog.Printf("loginUser: %v\n", ferr)
+			}
+			form.Errors.Add("generic", "Email or Password is incorrect")
+			app.render(rw, r, "login.page.tmpl", &TemplateData{Form: form})
+		} else {
+			// the password was not verified
+			if rerr := app.Throttle.Release(r.Context(), keys...); rerr != nil {
+				app.ErrorLog.Printf("loginUser: %v\n", rerr)
+			}
+			if !app.loginLocked(rw, r, form, err) {
+				app.serverError(rw, err)
+			}
+		}
 		return
 	}
-	tm := models.TokenMessage{
-		User:  *tu,
-		Token: token,
-	}
-	app.Session.Put(r, KeySessionTokenMessage, tm)
+
+	app.loginSucceeded(r, keys)
+	app.logIn(rw, r, tm)
+}
+
+// logIn adds the token message to the session and redirects the user to the page requested before the login
+func (app *Application) logIn(rw http.ResponseWriter, r *http.Request, tm *models.TokenMessage) {
+	// Add the token message to the session, so that they are now 'logged in'.
+	app.Session.Put(r, KeySessionTokenMessage, *tm)
 
 	app.Session.Put(r, KeySessionFlash, "You've been logged in successfully!")
 	path := app.Session.PopString(r, KeySessionRedirectPath)
@@ -148,6 +181,15 @@ func (app *Application) loginUser(rw http.ResponseWriter, r *http.Request) {
 }
 
 func (app *Application) logoutUser(rw http.ResponseWriter, r *http.Request) {
+	// Revoke the tokens on the API so that they can't be used anymore.
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if ok {
+		err := app.Users.Logout(r.Context(), tokenMsg.Token, tokenMsg.RefreshToken)
+		if err != nil {
+			app.ErrorLog.Printf("logoutUser: %v\n", err)
+		}
+	}
+
 	// Remove the authenticatedUser from the session data so that the user is 'logged out'.
 	app.Session.Remove(r, KeySessionTokenMessage)
 
@@ -165,7 +207,7 @@ func (app *Application) listUsers(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	u, err := app.Users.GetAll(tokenMsg.Token)
+	u, err := app.Users.GetAll(r.Context(), tokenMsg.Token)
 	if err != nil {
 		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
 			if app.DebugOn {
@@ -202,7 +244,7 @@ func (app *Application) userGet(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.Users.Get(tokenMsg.Token, id)
+	user, err := app.Users.Get(r.Context(), tokenMsg.Token, id)
 	if err != nil {
 		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
 			if app.DebugOn {
@@ -228,15 +270,7 @@ func (app *Application) userProfile(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.Users.Get(tokenMsg.Token, tokenMsg.User.ID)
-	if err != nil {
-		app.serverError(rw, err)
-		return
-	}
-
-	app.render(rw, r, "profile.page.tmpl",
-		&TemplateData{
-			User: user})
+	app.renderProfile(rw, r, &tokenMsg, &TemplateData{})
 }
 
 func (app *Application) changePasswordForm(rw http.ResponseWriter, r *http.Request) {
@@ -252,10 +286,20 @@ func (app *Application) changePassword(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Validate the form contents using the form helper
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("currentPassword: no user available on session"))
+		return
+	}
+
+	// Validate the form contents using the form helper, the email is only checked by the API
 	form := forms.New(r.PostForm)
 	form.Required("currentPassword", "newPassword", "newPasswordConfirmation")
-	form.MinLength("newPassword", 10)
+	err = app.checkPassword(form, "newPassword", tokenMsg.User.Name, "")
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
 	if form.Get("newPassword") != form.Get("newPasswordConfirmation") {
 		form.Errors.Add("newPasswordConfirmation", "Passwords do not match")
 	}
@@ -264,19 +308,13 @@ func (app *Application) changePassword(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
-	if !ok {
-		app.serverError(rw, fmt.Errorf("currentPassword: no user available on session"))
-		return
-	}
-
-	err = app.Users.ChangePassword(tokenMsg.Token, tokenMsg.User.ID, form.Get("currentPassword"), form.Get("newPassword"))
+	err = app.Users.ChangePassword(r.Context(), tokenMsg.Token, tokenMsg.User.ID, form.Get("currentPassword"), form.Get("newPassword"))
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.Errors.Add("currentPassword", "Current password is not valid")
 			app.render(rw, r, "password.page.tmpl", &TemplateData{Form: form})
 		} else if errors.Is(err, models.ErrValidation) {
-			form.Errors.Add("generic", "bad request invalid data provided")
+			addValidationErrors(form, err, "newPassword")
 			app.render(rw, r, "password.page.tmpl", &TemplateData{Form: form})
 		} else if err != nil {
 			app.serverError(rw, err)
@@ -323,7 +361,12 @@ func (app *Application) resetPassword(rw http.ResponseWriter, r *http.Request) {
 	// Validate the form contents using the form helper
 	form := forms.New(r.PostForm)
 	form.Required("newPassword", "newPasswordConfirmation")
-	form.MinLength("newPassword", 10)
+	// the name and email of the user are only checked by the API
+	err = app.checkPassword(form, "newPassword", "", "")
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
 	if form.Get("newPassword") != form.Get("newPasswordConfirmation") {
 		form.Errors.Add("newPasswordConfirmation", "Passwords do not match")
 	}
@@ -341,10 +384,16 @@ func (app *Application) resetPassword(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// "0123498765" dummy oldpassword just to satisfy min length required
-	err = app.Users.ChangePassword(tokenMsg.Token, id, "0123498765", form.Get("newPassword"))
+	// "0123498765" dummy oldpassword, ignored by the API when an administrator changes the password of other user
+	err = app.Users.ChangePassword(r.Context(), tokenMsg.Token, id, "0123498765", form.Get("newPassword"))
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) || errors.Is(err, models.ErrValidation) {
+		if errors.Is(err, models.ErrValidation) {
+			addValidationErrors(form, err, "newPassword")
+			app.render(rw, r, "resetPassword.page.tmpl",
+				&TemplateData{
+					Form: form,
+					ID:   id})
+		} else if errors.Is(err, models.ErrInvalidCredentials) {
 			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
 			http.Redirect(rw, r, "/", http.StatusSeeOther)
 		} else {
@@ -356,3 +405,259 @@ func (app *Application) resetPassword(rw http.ResponseWriter, r *http.Request) {
 	app.Session.Put(r, KeySessionFlash, fmt.Sprintf("Password of user #%d has been updated!", id))
 	http.Redirect(rw, r, fmt.Sprintf("/user/%d", id), http.StatusSeeOther)
 }
+
+func (app *Application) forgotPasswordForm(rw http.ResponseWriter, r *http.Request) {
+	app.render(rw, r, "forgotPassword.page.tmpl", &TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *Application) forgotPassword(rw http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+
+	// Validate the form contents using the form helper
+	form := forms.New(r.PostForm)
+	form.Required("email")
+	form.MaxLength("email", 255)
+	form.MatchesPattern("email", forms.EmailRX)
+	if !form.Valid() {
+		app.render(rw, r, "forgotPassword.page.tmpl", &TemplateData{Form: form})
+		return
+	}
+
+	err = app.Users.ForgotPassword(r.Context(), form.Get("email"))
+	if err != nil {
+		if errors.Is(err, models.ErrValidation) {
+			form.Errors.Add("email", "This field is invalid")
+			app.render(rw, r, "forgotPassword.page.tmpl", &TemplateData{Form: form})
+		} else if errors.Is(err, models.ErrPasswordResetDisabled) {
+			app.Session.Put(r, KeySessionFlash, "Password reset is not available, please contact an administrator")
+			http.Redirect(rw, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	app.Session.Put(r, KeySessionFlash, "If the email belongs to an user a password reset link has been sent!")
+	http.Redirect(rw, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *Application) recoverPasswordForm(rw http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+
+	app.render(rw, r, "recoverPassword.page.tmpl", &TemplateData{
+		Form: forms.New(url.Values{"token": []string{token}}),
+	})
+}
+
+func (app *Application) recoverPassword(rw http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+
+	// Validate the form contents using the form helper
+	form := forms.New(r.PostForm)
+	form.Required("token", "newPassword", "newPasswordConfirmation")
+	// the name and email of the owner of the token are only checked by the API
+	err = app.checkPassword(form, "newPassword", "", "")
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	if form.Get("newPassword") != form.Get("newPasswordConfirmation") {
+		form.Errors.Add("newPasswordConfirmation", "Passwords do not match")
+	}
+	if !form.Valid() {
+		app.render(rw, r, "recoverPassword.page.tmpl", &TemplateData{Form: form})
+		return
+	}
+
+	err = app.Users.ResetPasswordWithToken(r.Context(), form.Get("token"), form.Get("newPassword"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.Session.Put(r, KeySessionFlash, "The password reset link is invalid or has expired, please request a new one")
+			http.Redirect(rw, r, "/user/forgot-password", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrValidation) {
+			addValidationErrors(form, err, "newPassword")
+			app.render(rw, r, "recoverPassword.page.tmpl", &TemplateData{Form: form})
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	app.Session.Put(r, KeySessionFlash, "Your password has been updated, please log in!")
+	http.Redirect(rw, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *Application) registerUserForm(rw http.ResponseWriter, r *http.Request) {
+	if !app.RegistrationEnabled {
+		app.notFound(rw)
+		return
+	}
+
+	app.render(rw, r, "register.page.tmpl", &TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *Application) registerUser(rw http.ResponseWriter, r *http.Request) {
+	if !app.RegistrationEnabled {
+		app.notFound(rw)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+
+	// Validate the form contents using the form helper
+	form := forms.New(r.PostForm)
+	form.Required("name", "email", "password", "passwordConfirmation")
+	form.MaxLength("name", 255)
+	form.MaxLength("email", 255)
+	form.MatchesPattern("email", forms.EmailRX)
+	err = app.checkPassword(form, "password", form.Get("name"), form.Get("email"))
+	if err != nil {
+		app.serverError(rw, err)
+		return
+	}
+	if form.Get("password") != form.Get("passwordConfirmation") {
+		form.Errors.Add("passwordConfirmation", "Passwords do not match")
+	}
+	if !form.Valid() {
+		app.render(rw, r, "register.page.tmpl", &TemplateData{Form: form})
+		return
+	}
+
+	msg, err := app.Users.Register(r.Context(), form.Get("name"), form.Get("email"), form.Get("password"))
+	if err != nil {
+		if errors.Is(err, models.ErrEmailDomainNotAllowed) {
+			form.Errors.Add("email", "Registration is not allowed for this email domain")
+			app.render(rw, r, "register.page.tmpl", &TemplateData{Form: form})
+		} else if errors.Is(err, models.ErrValidation) {
+			addValidationErrors(form, err, "password")
+			app.render(rw, r, "register.page.tmpl", &TemplateData{Form: form})
+		} else if errors.Is(err, models.ErrRegistrationClosed) {
+			app.Session.Put(r, KeySessionFlash, "Registration is closed, please contact an administrator")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	app.Session.Put(r, KeySessionFlash, msg)
+	http.Redirect(rw, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *Application) verifyEmail(rw http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+
+	err := app.Users.VerifyEmail(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) || errors.Is(err, models.ErrValidation) {
+			app.Session.Put(r, KeySessionFlash, "The verification link is invalid or has expired")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	app.Session.Put(r, KeySessionFlash, "Your email has been verified, please log in!")
+	http.Redirect(rw, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *Application) listPendingUsers(rw http.ResponseWriter, r *http.Request) {
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("listPendingUsers: no user available on session"))
+		return
+	}
+
+	u, err := app.Users.GetPending(r.Context(), tokenMsg.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			if app.DebugOn {
+				app.ErrorLog.Printf("listPendingUsers: %v\n", err)
+			}
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	app.render(rw, r, "pending.page.tmpl", &TemplateData{Users: u})
+}
+
+func (app *Application) approveUser(rw http.ResponseWriter, r *http.Request) {
+	// get ID from the URL
+	id, err := getID(r)
+	if err != nil {
+		// should never happen as router blocks invalid URL request
+		app.ErrorLog.Printf("approveUser: user %d:  %v\n", id, err)
+		app.serverError(rw, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	form.Required("approved")
+	form.PermittedValues("approved", "true", "false")
+	if !form.Valid() {
+		app.clientError(rw, http.StatusBadRequest)
+		return
+	}
+	approved := form.Get("approved") == "true"
+
+	tokenMsg, ok := app.Session.Get(r, KeySessionTokenMessage).(models.TokenMessage)
+	if !ok {
+		app.serverError(rw, fmt.Errorf("approveUser: no user available on session"))
+		return
+	}
+
+	err = app.Users.Approve(r.Context(), tokenMsg.Token, id, approved)
+	if err != nil {
+		if errors.Is(err, models.ErrUnauthorizedToken) || errors.Is(err, models.ErrForbiddenToken) {
+			app.Session.Put(r, KeySessionFlash, "Operation not allowed by this user")
+			http.Redirect(rw, r, "/", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.Session.Put(r, KeySessionFlash, fmt.Sprintf("User #%d has no pending registration", id))
+			http.Redirect(rw, r, "/users/pending", http.StatusSeeOther)
+		} else {
+			app.serverError(rw, err)
+		}
+		return
+	}
+
+	if approved {
+		app.Session.Put(r, KeySessionFlash, fmt.Sprintf("Registration of user #%d approved!", id))
+	} else {
+		app.Session.Put(r, KeySessionFlash, fmt.Sprintf("Registration of user #%d rejected!", id))
+	}
+	http.Redirect(rw, r, "/users/pending", http.StatusSeeOther)
+}
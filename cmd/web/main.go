@@ -7,8 +7,10 @@ import (
 	"github.com/golangcollege/sessions"
 	"github.com/vgraveto/snippets/cmd/web/handlers"
 	"github.com/vgraveto/snippets/pkg/models"
+	"github.com/vgraveto/snippets/pkg/models/cache"
 	"github.com/vgraveto/snippets/pkg/models/dbapi"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -77,6 +79,9 @@ func main() {
 		}
 	}()
 
+	// tokens are verified with the shared key or with the keys published by the API
+	globalData.Token.FetchKeys = dbapi.NewKeyModel(db).Get
+
 	// Initialize a new template cache...
 	templateCache, err := handlers.NewTemplateCache("./ui/html/")
 	if err != nil {
@@ -94,11 +99,20 @@ func main() {
 	gob.Register(models.TokenUser{})    // used on session as field of models.TokenMessage
 	gob.Register(dbapi.UserModel{})     // needed because Users field is of this type on handlers.Application
 	gob.Register(dbapi.SnippetModel{})  // needed because Snippets field is of this type on handlers.Application
+	gob.Register(cache.APISnippets{})   // the type of the Snippets field when the cache is enabled
+	gob.Register(dbapi.AuditModel{})    // needed because Audit field is of this type on handlers.Application
 	// create new session
 	session := sessions.New([]byte(*secret))
 	session.Lifetime = globalData.sessionLifetime
 	session.Secure = true
-	session.SameSite = http.SameSiteStrictMode
+	// the lax mode sends the session cookie on the redirect back from the OpenID Connect provider,
+	// the cross-site POST requests are still rejected by noSurf
+	session.SameSite = http.SameSiteLaxMode
+
+	passwordPolicy, err := models.NewPasswordPolicy(globalData.Password)
+	if err != nil {
+		errorLog.Fatalf("main: %v\n", err)
+	}
 
 	// Initialize a new instance of application containing the dependencies.
 	app := &handlers.Application{
@@ -109,8 +123,27 @@ func main() {
 		TemplateCache: templateCache,
 		Snippets:      dbapi.NewSnippetModel(db),
 		Users:         dbapi.NewUserModel(db),
+		Tokens:        models.NewVerifierModel(&globalData.Token),
+		Audit:         dbapi.NewAuditModel(db),
+
+		RegistrationEnabled: globalData.RegistrationEnabled,
+
+		// the failed logins are also throttled by the API, these ones protect the API from the web clients
+		Throttle:       models.NewLoginThrottle(models.NewMemoryLoginAttempts(), globalData.Throttle),
+		TrustedProxies: globalData.TrustedProxies,
+
+		// the API enforces the same policy, it is checked here to show the messages on the fields of the forms
+		PasswordPolicy: passwordPolicy,
+	}
+	if globalData.CacheEnabled {
+		app.Snippets = cache.NewAPISnippets(app.Snippets, cache.NewLRU(globalData.CacheSize), globalData.CacheTTL, errorLog)
+	}
+	if globalData.OIDCEnabled {
+		app.OIDC = models.NewOIDCProvider(&globalData.OIDC)
 	}
 
+	// the requests inherit this context, it is cancelled on shutdown to abort the pending operations
+	baseCtx, cancelBase := context.WithCancel(context.Background())
 	httpSrv := &http.Server{
 		Addr:         ":" + globalData.HttpPort,
 		ErrorLog:     errorLog,
@@ -118,6 +151,7 @@ func main() {
 		IdleTimeout:  globalData.HttpIdleTimeout,
 		ReadTimeout:  globalData.HttpReadTimeout,
 		WriteTimeout: globalData.HttpWriteTimeout,
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
 	}
 
 	// Initialize this Web Frontend
@@ -139,11 +173,11 @@ func main() {
 	s := <-sigs
 
 	infoLog.Printf("main: received signal: %s", s)
-	AppCleanup(infoLog, httpSrv, globalData.deadlineWaitForClose)
+	AppCleanup(infoLog, httpSrv, cancelBase, globalData.deadlineWaitForClose)
 
 }
 
-func AppCleanup(infoLog *log.Logger, httpSrv *http.Server, wait time.Duration) {
+func AppCleanup(infoLog *log.Logger, httpSrv *http.Server, cancelBase context.CancelFunc, wait time.Duration) {
 	infoLog.Println("main: AppCleanup init")
 
 	// Create a deadline to wait for.
@@ -152,6 +186,8 @@ func AppCleanup(infoLog *log.Logger, httpSrv *http.Server, wait time.Duration) {
 	// Doesn't block if no connections, but will otherwise wait
 	// until the timeout deadline.
 	httpSrv.Shutdown(ctx)
+	// abort the requests still running after the deadline
+	cancelBase()
 
 	infoLog.Println("main: AppCleanup end")
 }
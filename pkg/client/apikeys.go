@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// GetAPIKeys returns the API keys of the user with the given id
+func (c *Client) GetAPIKeys(ctx context.Context, token string, id int) ([]*models.APIKey, error) {
+	keys := []*models.APIKey{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: fmt.Sprintf("/users/%d/api-keys", id), token: token}, &keys)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateAPIKey creates an API key for the user with the given id,
+// the returned message holds the only copy of the plain-text key
+func (c *Client) CreateAPIKey(ctx context.Context, token string, id int, ck *models.CreateAPIKey) (*models.NewAPIKeyMessage, error) {
+	k := &models.NewAPIKeyMessage{}
+	err := c.call(ctx, &request{
+		method: http.MethodPost,
+		path:   fmt.Sprintf("/users/%d/api-keys", id),
+		token:  token,
+		body:   ck,
+	}, k)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// RevokeAPIKey revokes the API key with keyID of the user with the given id
+func (c *Client) RevokeAPIKey(ctx context.Context, token string, id, keyID int) error {
+	return c.call(ctx, &request{
+		method: http.MethodDelete,
+		path:   fmt.Sprintf("/users/%d/api-keys/%d", id, keyID),
+		token:  token,
+	}, nil)
+}
@@ -0,0 +1,283 @@
+// Package client is the Go client of the Snippets API.
+//
+// Every endpoint of swagger.yaml has a typed method that receives a context.Context,
+// the requests are retried with an exponential backoff on network errors and on the
+// 429, 502, 503 and 504 responses when the method is idempotent, and the error responses
+// are returned as an *Error that wraps the matching models.Err* value, so that
+// errors.Is(err, models.ErrNoRecord) works as with the database models.
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the time limit of each attempt of a request when not configured
+	DefaultTimeout = 30 * time.Second
+	// DefaultRetryWaitMin is the wait before the first retry when not configured
+	DefaultRetryWaitMin = 100 * time.Millisecond
+	// DefaultRetryWaitMax is the maximum wait between two retries when not configured
+	DefaultRetryWaitMax = 2 * time.Second
+)
+
+// Config holds the configuration of a Client, only the URL is required
+type Config struct {
+	// the URL where the API is deployed, like http://localhost:9090
+	URL string
+	// the time limit of each attempt of a request, DefaultTimeout when zero
+	Timeout time.Duration
+	// the number of retries of the idempotent requests, zero disables the retries
+	MaxRetries int
+	// the wait before the first retry, doubled on each retry up to RetryWaitMax
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	// the transport of the requests, http.DefaultTransport when nil
+	Transport http.RoundTripper
+	// the User-Agent header of the requests, the Go default when empty
+	UserAgent string
+}
+
+// Client calls the endpoints of the API, it is safe for concurrent use
+type Client struct {
+	url          string
+	httpClient   *http.Client
+	maxRetries   int
+	retryWaitMin time.Duration
+	retryWaitMax time.Duration
+	userAgent    string
+	// the address of the client forwarded on the X-Forwarded-For header
+	forwardedFor string
+}
+
+// New creates a Client with the given configuration
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("client: New: invalid URL %q", cfg.URL)
+	}
+	if cfg.MaxRetries < 0 {
+		return nil, fmt.Errorf("client: New: invalid MaxRetries %d", cfg.MaxRetries)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.RetryWaitMin <= 0 {
+		cfg.RetryWaitMin = DefaultRetryWaitMin
+	}
+	if cfg.RetryWaitMax < cfg.RetryWaitMin {
+		cfg.RetryWaitMax = DefaultRetryWaitMax
+		if cfg.RetryWaitMax < cfg.RetryWaitMin {
+			cfg.RetryWaitMax = cfg.RetryWaitMin
+		}
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+	return &Client{
+		url:          strings.TrimSuffix(cfg.URL, "/"),
+		httpClient:   &http.Client{Transport: cfg.Transport, Timeout: cfg.Timeout},
+		maxRetries:   cfg.MaxRetries,
+		retryWaitMin: cfg.RetryWaitMin,
+		retryWaitMax: cfg.RetryWaitMax,
+		userAgent:    cfg.UserAgent,
+	}, nil
+}
+
+// URL returns the URL of the API
+func (c *Client) URL() string {
+	return c.url
+}
+
+// WithClient returns a copy of the client that forwards the IP address and the user agent
+// of the client of a proxy, like the web application, on the X-Forwarded-For and User-Agent headers.
+// The API only uses them when the proxy is one of its trusted proxies.
+func (c *Client) WithClient(ip, userAgent string) *Client {
+	cc := *c
+	cc.forwardedFor = ip
+	if userAgent != "" {
+		cc.userAgent = userAgent
+	}
+	return &cc
+}
+
+// request describes a call to an endpoint of the API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	// the JWT or API key of the Authentication header, not sent when empty
+	token string
+	// the value serialized to JSON as the body of the request, not sent when nil
+	body interface{}
+	// the errors returned for the status codes instead of the default ones
+	errs map[int]error
+	// the ETag of the If-None-Match header of a conditional request, not sent when empty
+	etag string
+}
+
+// response is a response of the API with its body already read
+type response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// call executes the request and deserializes the body of the 200 response into out, when not nil
+func (c *Client) call(ctx context.Context, r *request, out interface{}) error {
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newError(r, resp)
+	}
+	if out == nil {
+		return nil
+	}
+	err = models.FromJSON(out, bytes.NewReader(resp.Body))
+	if err != nil {
+		return fmt.Errorf("client: %s %s: Deserialization: %v", r.method, r.path, err)
+	}
+	return nil
+}
+
+// callIfNoneMatch executes the conditional request and deserializes the body of the 200 response
+// into out, it returns the ETag of the response or ErrNotModified on a 304 response
+func (c *Client) callIfNoneMatch(ctx context.Context, r *request, out interface{}) (string, error) {
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return r.etag, ErrNotModified
+	case http.StatusOK:
+	default:
+		return "", newError(r, resp)
+	}
+	err = models.FromJSON(out, bytes.NewReader(resp.Body))
+	if err != nil {
+		return "", fmt.Errorf("client: %s %s: Deserialization: %v", r.method, r.path, err)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// do executes the request, retrying it while retry allows, and returns the last response
+func (c *Client) do(ctx context.Context, r *request) (*response, error) {
+	var payload []byte
+	if r.body != nil {
+		var bd bytes.Buffer
+		err := models.ToJSON(r.body, &bd)
+		if err != nil {
+			return nil, fmt.Errorf("client: %s %s: Serialization: %v", r.method, r.path, err)
+		}
+		payload = bd.Bytes()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, r, payload)
+		if !c.retry(ctx, r, attempt, resp, err) {
+			if err != nil {
+				return nil, fmt.Errorf("client: %s %s: %w", r.method, r.path, err)
+			}
+			return resp, nil
+		}
+		timer := time.NewTimer(c.backoff(attempt, resp))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("client: %s %s: %w", r.method, r.path, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one attempt of the request
+func (c *Client) send(ctx context.Context, r *request, payload []byte) (*response, error) {
+	u := c.url + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	if r.token != "" {
+		req.Header.Set("Authentication", r.token)
+	}
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", c.forwardedFor)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// retry returns true when the failed attempt of an idempotent request can be repeated
+func (c *Client) retry(ctx context.Context, r *request, attempt int, resp *response, err error) bool {
+	if attempt >= c.maxRetries || ctx.Err() != nil {
+		return false
+	}
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+	default:
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the wait before the retry that follows the attempt, it doubles on each
+// retry with a random jitter and it honours the Retry-After header up to retryWaitMax
+func (c *Client) backoff(attempt int, resp *response) time.Duration {
+	wait := c.retryWaitMin << uint(attempt)
+	if wait <= 0 || wait > c.retryWaitMax {
+		wait = c.retryWaitMax
+	}
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			if after := time.Duration(seconds) * time.Second; after > wait {
+				wait = after
+			}
+		}
+	}
+	if wait > c.retryWaitMax {
+		wait = c.retryWaitMax
+	}
+	return wait
+}
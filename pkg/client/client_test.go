@@ -0,0 +1,311 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client of a test server that answers with the handler
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	c, err := New(Config{URL: ts.URL, MaxRetries: 2, RetryWaitMin: time.Millisecond, RetryWaitMax: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     int
+		body     string
+		wantErr  error
+		wantMsgs int
+	}{
+		{"Not found", http.StatusNotFound, `{"message":"snippet not found"}`, models.ErrNoRecord, 0},
+		{"Unauthorized", http.StatusUnauthorized, `{"message":"token expired"}`, models.ErrUnauthorizedToken, 0},
+		{"Forbidden", http.StatusForbidden, `{"message":"forbidden"}`, models.ErrForbiddenToken, 0},
+		{"Bad request", http.StatusBadRequest, `{"message":"Bad Request"}`, models.ErrBadRequest, 0},
+		{"Duplicate email", http.StatusBadRequest, `{"message":"models: duplicate email"}`, models.ErrDuplicateEmail, 0},
+		{"Validation", http.StatusUnprocessableEntity, `{"messages":["title is required","expires is invalid"]}`, models.ErrValidation, 2},
+		{"Login locked", http.StatusTooManyRequests, `{"message":"too many failed logins"}`, models.ErrLoginLocked, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("Retry-After", "0")
+				rw.WriteHeader(tt.code)
+				rw.Write([]byte(tt.body))
+			})
+
+			_, err := c.CreateSnippet(context.Background(), "token", &models.SnippetCreate{})
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("want *Error; got %v", err)
+			}
+			if apiErr.StatusCode != tt.code {
+				t.Errorf("want %d; got %d", tt.code, apiErr.StatusCode)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+			var ve *models.ValidationMessagesError
+			if errors.As(err, &ve) && len(ve.Messages) != tt.wantMsgs {
+				t.Errorf("want %d messages; got %d", tt.wantMsgs, len(ve.Messages))
+			}
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		code      int
+		wantCalls int32
+	}{
+		{"GET unavailable", http.MethodGet, http.StatusServiceUnavailable, 3},
+		{"GET not found", http.MethodGet, http.StatusNotFound, 1},
+		{"DELETE bad gateway", http.MethodDelete, http.StatusBadGateway, 3},
+		{"POST unavailable", http.MethodPost, http.StatusServiceUnavailable, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			c := newTestClient(t, func(rw http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				if r.Method != tt.method {
+					t.Errorf("want %s; got %s", tt.method, r.Method)
+				}
+				rw.WriteHeader(tt.code)
+			})
+
+			var err error
+			switch tt.method {
+			case http.MethodGet:
+				_, err = c.GetSnippet(context.Background(), 1)
+			case http.MethodDelete:
+				err = c.RevokeSession(context.Background(), "token", 1, 2)
+			case http.MethodPost:
+				err = c.ForgotPassword(context.Background(), "alice@example.com")
+			}
+			if err == nil {
+				t.Errorf("want error; got nil")
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("want %d calls; got %d", tt.wantCalls, calls)
+			}
+		})
+	}
+
+	t.Run("Recovered", func(t *testing.T) {
+		var calls int32
+		c := newTestClient(t, func(rw http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			rw.Write([]byte(`{"id":1,"title":"An old silent pond"}`))
+		})
+
+		s, err := c.GetSnippet(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Title != "An old silent pond" {
+			t.Errorf("want %q; got %q", "An old silent pond", s.Title)
+		}
+	})
+}
+
+func TestContext(t *testing.T) {
+	c := newTestClient(t, func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c.retryWaitMin, c.retryWaitMax = time.Minute, time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.LatestSnippets(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want %v; got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int
+		body    string
+		wantErr error
+	}{
+		{"Valid", http.StatusOK, `{"token":"jwt","refreshToken":"refresh"}`, nil},
+		{"Challenge", http.StatusAccepted, `{"challenge":"abc"}`, nil},
+		{"Invalid credentials", http.StatusUnauthorized, `{"message":"invalid credentials"}`, models.ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(rw http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Forwarded-For") != "192.0.2.1" {
+					t.Errorf("want %q; got %q", "192.0.2.1", r.Header.Get("X-Forwarded-For"))
+				}
+				rw.WriteHeader(tt.code)
+				rw.Write([]byte(tt.body))
+			}).WithClient("192.0.2.1", "curl/8.4.0")
+
+			tokenMsg, err := c.Login(context.Background(), "alice@example.com", "password")
+			switch {
+			case tt.code == http.StatusAccepted:
+				var challenge *models.MFAChallenge
+				if !errors.As(err, &challenge) || challenge.Challenge != "abc" {
+					t.Errorf("want challenge %q; got %v", "abc", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("want %v; got %v", tt.wantErr, err)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tokenMsg.Token != "jwt" {
+					t.Errorf("want %q; got %q", "jwt", tokenMsg.Token)
+				}
+			}
+		})
+	}
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	c := newTestClient(t, func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		rw.Write([]byte(`{"id":1,"title":"An old silent pond"}`))
+	})
+
+	s, etag, err := c.GetSnippetIfNoneMatch(context.Background(), 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "An old silent pond" || etag != `"v1"` {
+		t.Errorf("want the snippet and its ETag; got %q, %q", s.Title, etag)
+	}
+
+	s, etag, err = c.GetSnippetIfNoneMatch(context.Background(), 1, etag)
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("want %v; got %v", ErrNotModified, err)
+	}
+	if s != nil || etag != `"v1"` {
+		t.Errorf("want no snippet and the same ETag; got %v, %q", s, etag)
+	}
+
+	_, etag, err = c.GetSnippetIfNoneMatch(context.Background(), 1, `"v0"`)
+	if err != nil || etag != `"v1"` {
+		t.Errorf("want the snippet of the new ETag; got %q, %v", etag, err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       int
+		body       string
+		wantStatus string
+		wantCode   int
+	}{
+		{"Up", http.StatusOK, `{"status":"up","checked":"2026-01-02T15:04:05Z"}`, models.HealthUp, 0},
+		{"Down", http.StatusServiceUnavailable, `{"status":"down","checked":"2026-01-02T15:04:05Z","error":"connection refused"}`,
+			models.HealthDown, 0},
+		{"Proxy unavailable", http.StatusServiceUnavailable, `<html>Service Unavailable</html>`, "", http.StatusServiceUnavailable},
+		{"Not found", http.StatusNotFound, `{"message":"not found"}`, "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(rw http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/health" {
+					t.Errorf("want %q; got %q", "/health", r.URL.Path)
+				}
+				rw.Header().Set("Retry-After", "0")
+				rw.WriteHeader(tt.code)
+				rw.Write([]byte(tt.body))
+			})
+
+			h, err := c.Health(context.Background())
+			if tt.wantCode != 0 {
+				var apiErr *Error
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantCode {
+					t.Errorf("want *Error with %d; got %v", tt.wantCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Status != tt.wantStatus || h.Checked.IsZero() {
+				t.Errorf("want the status %q checked; got %q %v", tt.wantStatus, h.Status, h.Checked)
+			}
+		})
+	}
+}
+
+func TestPoolHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int
+		body    string
+		wantErr error
+	}{
+		{"Stats", http.StatusOK, `{"maxOpenConnections":25,"openConnections":3,"inUse":1,"idle":2,"waitDuration":15}`, nil},
+		{"No connection pool", http.StatusNotFound, `{"message":"the storage has no connection pool"}`, models.ErrNoRecord},
+		{"Not an administrator", http.StatusForbidden, `{"message":"Forbidden"}`, models.ErrForbiddenToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(rw http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/health/pool" || r.Header.Get("Authentication") != "token" {
+					t.Errorf("want the token on /health/pool; got %q on %q", r.Header.Get("Authentication"), r.URL.Path)
+				}
+				rw.WriteHeader(tt.code)
+				rw.Write([]byte(tt.body))
+			})
+
+			stats, err := c.PoolHealth(context.Background(), "token")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("want %v; got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.MaxOpenConnections != 25 || stats.InUse != 1 || stats.Idle != 2 || stats.WaitDuration != 15 {
+				t.Errorf("want the stats of the pool; got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestForgotPasswordDisabled(t *testing.T) {
+	c := newTestClient(t, func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNotImplemented)
+		rw.Write([]byte(`{"message":"Password reset by email is not enabled"}`))
+	})
+
+	err := c.ForgotPassword(context.Background(), "alice@example.com")
+	if !errors.Is(err, models.ErrPasswordResetDisabled) {
+		t.Errorf("want %v; got %v", models.ErrPasswordResetDisabled, err)
+	}
+}
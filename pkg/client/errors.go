@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ErrNotModified is returned by the conditional requests when the resource still has the ETag
+// of the request, the copy of the caller is current
+var ErrNotModified = errors.New("client: not modified")
+
+// Error is returned when the API responds with an error status code
+type Error struct {
+	// the method and the path of the request
+	Method string
+	Path   string
+	// the status code of the response
+	StatusCode int
+	// the message of the response body, empty when the body has none
+	Message string
+	// the models.Err* value matching the status code, nil when there is none
+	Err error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("client: %s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	if e.Message != "" {
+		return msg + ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap allows errors.Is(err, models.ErrNoRecord) and errors.As(err, &ve) with the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// the messages of the 400 responses that have their own errors
+var badRequestErrors = []struct {
+	re  *regexp.Regexp
+	err error
+}{
+	{regexp.MustCompile(`duplicate email`), models.ErrDuplicateEmail},
+	{regexp.MustCompile(`email domain not allowed`), models.ErrEmailDomainNotAllowed},
+	{regexp.MustCompile(`invalid credentials`), models.ErrInvalidCredentials},
+}
+
+// newError returns the *Error of the error response of the request
+func newError(r *request, resp *response) error {
+	e := &Error{
+		Method:     r.method,
+		Path:       r.path,
+		StatusCode: resp.StatusCode,
+	}
+	msg := &models.GenericMessage{}
+	if models.FromJSON(msg, bytes.NewReader(resp.Body)) == nil {
+		e.Message = msg.Message
+	}
+
+	if err, ok := r.errs[resp.StatusCode]; ok {
+		e.Err = err
+		return e
+	}
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		e.Err = models.ErrBadRequest
+		for _, b := range badRequestErrors {
+			if b.re.MatchString(e.Message) {
+				e.Err = b.err
+				break
+			}
+		}
+	case http.StatusUnauthorized:
+		e.Err = models.ErrUnauthorizedToken
+	case http.StatusForbidden:
+		e.Err = models.ErrForbiddenToken
+	case http.StatusNotFound:
+		e.Err = models.ErrNoRecord
+	case http.StatusUnprocessableEntity:
+		e.Err = validationError(resp.Body)
+	case http.StatusTooManyRequests:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		e.Err = &models.LoginLockedError{RetryAfter: time.Duration(seconds) * time.Second}
+	}
+	return e
+}
+
+// validationError returns a *models.ValidationMessagesError with the messages of the 422 response body,
+// or models.ErrValidation when the body has none
+func validationError(body []byte) error {
+	ve := &models.ValidationMessagesError{}
+	err := models.FromJSON(ve, bytes.NewReader(body))
+	if err != nil || len(ve.Messages) == 0 {
+		return models.ErrValidation
+	}
+	return ve
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// Home returns the string "Snippets API" of the root of the API
+func (c *Client) Home(ctx context.Context) (string, error) {
+	r := &request{method: http.MethodGet, path: "/"}
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", newError(r, resp)
+	}
+	return string(resp.Body), nil
+}
+
+// Ping returns the message "OK" while the API is running
+func (c *Client) Ping(ctx context.Context) (string, error) {
+	msg := &models.GenericMessage{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: "/ping"}, msg)
+	if err != nil {
+		return "", err
+	}
+	return msg.Message, nil
+}
+
+// Health returns the status of the database on its last health check, the status is models.HealthDown
+// when the database did not answer it, an error is only returned when the API did not report the status
+func (c *Client) Health(ctx context.Context) (*models.Health, error) {
+	r := &request{method: http.MethodGet, path: "/health"}
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, newError(r, resp)
+	}
+	h := &models.Health{}
+	err = models.FromJSON(h, bytes.NewReader(resp.Body))
+	if err != nil {
+		// the 503 response of a proxy in front of the API has no status
+		if resp.StatusCode != http.StatusOK {
+			return nil, newError(r, resp)
+		}
+		return nil, fmt.Errorf("client: %s %s: Deserialization: %v", r.method, r.path, err)
+	}
+	return h, nil
+}
+
+// PoolHealth returns the statistics of the connection pool of the database, the token is of an administrator.
+// ErrNoRecord is returned when the storage of the API has no connection pool.
+func (c *Client) PoolHealth(ctx context.Context, token string) (*models.PoolStats, error) {
+	stats := &models.PoolStats{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: "/health/pool", token: token}, stats)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetKeys returns the JSON Web Key Set published by the API to verify its tokens
+func (c *Client) GetKeys(ctx context.Context) (*models.JWKSet, error) {
+	jwks := &models.JWKSet{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: "/.well-known/jwks.json"}, jwks)
+	if err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+// GetAudit returns the page of the audit log events matching the filter, the token is of an administrator
+func (c *Client) GetAudit(ctx context.Context, token string, f *models.AuditFilter) (*models.AuditPage, error) {
+	page := &models.AuditPage{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: "/audit", query: f.Query(), token: token}, page)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
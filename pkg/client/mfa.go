@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// VerifyMFA completes the login of the challenge with a TOTP or recovery code and returns the
+// JSON Web Token (JWT) and the refresh token of the user
+func (c *Client) VerifyMFA(ctx context.Context, challenge, code string) (*models.TokenMessage, error) {
+	tokenMsg := &models.TokenMessage{}
+	err := c.call(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/login/mfa",
+		body:   &models.VerifyMFA{Challenge: challenge, Code: code},
+		errs: map[int]error{
+			http.StatusUnauthorized:        models.ErrInvalidMFACode,
+			http.StatusUnprocessableEntity: models.ErrInvalidMFACode,
+		},
+	}, tokenMsg)
+	if err != nil {
+		return nil, err
+	}
+	return tokenMsg, nil
+}
+
+// EnrollMFAChallenge starts the enrollment required to complete the login of the challenge
+func (c *Client) EnrollMFAChallenge(ctx context.Context, challenge string) (*models.MFAEnrollment, error) {
+	enrollment := &models.MFAEnrollment{}
+	err := c.call(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/login/mfa/enroll",
+		body:   &models.MFAChallengeEnrollment{Challenge: challenge},
+		errs: map[int]error{
+			http.StatusUnauthorized:        models.ErrInvalidToken,
+			http.StatusUnprocessableEntity: models.ErrInvalidToken,
+		},
+	}, enrollment)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+// GetMFA returns the two-factor authentication status of the user with the given id
+func (c *Client) GetMFA(ctx context.Context, token string, id int) (*models.MFAStatus, error) {
+	status := &models.MFAStatus{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: fmt.Sprintf("/users/%d/mfa", id), token: token}, status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// EnrollMFA starts the two-factor authentication enrollment of the user with the given id
+func (c *Client) EnrollMFA(ctx context.Context, token string, id int) (*models.MFAEnrollment, error) {
+	enrollment := &models.MFAEnrollment{}
+	err := c.call(ctx, &request{method: http.MethodPost, path: fmt.Sprintf("/users/%d/mfa", id), token: token}, enrollment)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+// ConfirmMFA enables the two-factor authentication of the user with the given id,
+// the returned recovery codes are only available on this call
+func (c *Client) ConfirmMFA(ctx context.Context, token string, id int, code string) ([]string, error) {
+	codes := &models.MFARecoveryCodes{}
+	err := c.call(ctx, &request{
+		method: http.MethodPut,
+		path:   fmt.Sprintf("/users/%d/mfa", id),
+		token:  token,
+		body:   &models.MFACode{Code: code},
+		errs: map[int]error{
+			http.StatusBadRequest:          models.ErrInvalidMFACode,
+			http.StatusUnprocessableEntity: models.ErrInvalidMFACode,
+		},
+	}, codes)
+	if err != nil {
+		return nil, err
+	}
+	return codes.RecoveryCodes, nil
+}
+
+// DisableMFA disables the two-factor authentication of the user with the given id,
+// the code is not required when an administrator disables other user
+func (c *Client) DisableMFA(ctx context.Context, token string, id int, code string) error {
+	return c.call(ctx, &request{
+		method: http.MethodDelete,
+		path:   fmt.Sprintf("/users/%d/mfa", id),
+		token:  token,
+		body:   &models.MFACode{Code: code},
+		errs: map[int]error{
+			http.StatusBadRequest:          models.ErrInvalidMFACode,
+			http.StatusUnprocessableEntity: models.ErrInvalidMFACode,
+		},
+	}, nil)
+}
+
+// SetRoleMFA defines if the users of the role type with roleID must use two-factor authentication
+func (c *Client) SetRoleMFA(ctx context.Context, token string, roleID int, required bool) error {
+	return c.call(ctx, &request{
+		method: http.MethodPut,
+		path:   fmt.Sprintf("/users/role-types/%d/mfa", roleID),
+		token:  token,
+		body:   &models.RoleMFA{MFARequired: required},
+	}, nil)
+}
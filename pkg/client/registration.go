@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// Register creates the account of a new user and returns the message describing how it
+// will be activated, models.ErrRegistrationClosed is wrapped when the registration is disabled
+func (c *Client) Register(ctx context.Context, ru *models.RegisterUser) (string, error) {
+	msg := &models.GenericMessage{}
+	err := c.call(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/register",
+		body:   ru,
+		errs:   map[int]error{http.StatusForbidden: models.ErrRegistrationClosed},
+	}, msg)
+	if err != nil {
+		return "", err
+	}
+	return msg.Message, nil
+}
+
+// VerifyEmail activates the registered user that received the verification token by email
+func (c *Client) VerifyEmail(ctx context.Context, token string) error {
+	return c.call(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/verify-email",
+		body:   &models.VerifyEmail{Token: token},
+		errs:   map[int]error{http.StatusBadRequest: models.ErrInvalidToken},
+	}, nil)
+}
+
+// ForgotPassword requests the API to send a password reset link to the email of the user,
+// models.ErrPasswordResetDisabled is wrapped when the password reset is not enabled
+func (c *Client) ForgotPassword(ctx context.Context, email string) error {
+	return c.call(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/forgot-password",
+		body:   &models.ForgotPassword{Email: email},
+		errs:   map[int]error{http.StatusNotImplemented: models.ErrPasswordResetDisabled},
+	}, nil)
+}
+
+// ResetPassword changes the password of the user that received the reset token by email
+func (c *Client) ResetPassword(ctx context.Context, token, newPassword string) error {
+	return c.call(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/reset-password",
+		body:   &models.ResetUserPassword{Token: token, NewPassword: newPassword},
+		errs:   map[int]error{http.StatusBadRequest: models.ErrInvalidToken},
+	}, nil)
+}
+
+// GetPendingUsers returns the registered users waiting for the approval of an administrator
+func (c *Client) GetPendingUsers(ctx context.Context, token string) ([]*models.User, error) {
+	users := []*models.User{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: "/users/pending", token: token}, &users)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ApproveUser sends the administrator decision on the pending registration of the user with the given id
+func (c *Client) ApproveUser(ctx context.Context, token string, id int, approved bool) error {
+	return c.call(ctx, &request{
+		method: http.MethodPut,
+		path:   fmt.Sprintf("/users/%d/approval", id),
+		token:  token,
+		body:   &models.UserApproval{Approved: approved},
+	}, nil)
+}
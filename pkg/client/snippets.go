@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// LatestSnippets returns the 10 most recently created snippets that have not expired
+func (c *Client) LatestSnippets(ctx context.Context) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: "/snippets"}, &snippets)
+	if err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// GetSnippet returns the snippet with the given id
+func (c *Client) GetSnippet(ctx context.Context, id int) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: fmt.Sprintf("/snippets/%d", id)}, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LatestSnippetsIfNoneMatch returns the latest snippets and their ETag, or ErrNotModified
+// when they did not change since the etag of a previous call
+func (c *Client) LatestSnippetsIfNoneMatch(ctx context.Context, etag string) ([]*models.Snippet, string, error) {
+	snippets := []*models.Snippet{}
+	etag, err := c.callIfNoneMatch(ctx, &request{method: http.MethodGet, path: "/snippets", etag: etag}, &snippets)
+	if err != nil {
+		return nil, etag, err
+	}
+	return snippets, etag, nil
+}
+
+// GetSnippetIfNoneMatch returns the snippet with the given id and its ETag, or ErrNotModified
+// when it did not change since the etag of a previous call
+func (c *Client) GetSnippetIfNoneMatch(ctx context.Context, id int, etag string) (*models.Snippet, string, error) {
+	s := &models.Snippet{}
+	etag, err := c.callIfNoneMatch(ctx, &request{method: http.MethodGet, path: fmt.Sprintf("/snippets/%d", id), etag: etag}, s)
+	if err != nil {
+		return nil, etag, err
+	}
+	return s, etag, nil
+}
+
+// CreateSnippet creates the snippet and returns it with its id
+func (c *Client) CreateSnippet(ctx context.Context, token string, sc *models.SnippetCreate) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	err := c.call(ctx, &request{method: http.MethodPost, path: "/snippets", token: token, body: sc}, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// Login verifies the email address and password of a user and returns the JSON Web Token (JWT)
+// and the refresh token of the user, a *models.MFAChallenge as the error when the user must
+// complete a two-factor authentication, or an error wrapping a *models.LoginLockedError when
+// the logins are blocked after too many failures.
+func (c *Client) Login(ctx context.Context, email, password string) (*models.TokenMessage, error) {
+	return c.login(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/login",
+		body:   &models.LoginUser{Username: email, Password: password},
+		errs: map[int]error{
+			http.StatusUnauthorized:        models.ErrInvalidCredentials,
+			http.StatusUnprocessableEntity: models.ErrInvalidCredentials,
+		},
+	})
+}
+
+// login executes the login request and returns the token message of the 200 response,
+// or the *models.MFAChallenge of the 202 response as the error
+func (c *Client) login(ctx context.Context, r *request) (*models.TokenMessage, error) {
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	// the users with two-factor authentication receive a challenge to complete the login
+	if resp.StatusCode == http.StatusAccepted {
+		challenge := &models.MFAChallenge{}
+		err = models.FromJSON(challenge, bytes.NewReader(resp.Body))
+		if err != nil {
+			return nil, fmt.Errorf("client: %s %s: Deserialization: %v", r.method, r.path, err)
+		}
+		return nil, challenge
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newError(r, resp)
+	}
+	tokenMsg := &models.TokenMessage{}
+	err = models.FromJSON(tokenMsg, bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, fmt.Errorf("client: %s %s: Deserialization: %v", r.method, r.path, err)
+	}
+	return tokenMsg, nil
+}
+
+// LoginOIDC exchanges the ID token of the OpenID Connect provider, with the nonce of its authorization
+// request, for the JWT and the refresh token of the user linked to it, a *models.MFAChallenge as
+// the error when the user must complete a two-factor authentication, models.ErrOIDCDisabled is
+// wrapped when the API has no provider
+func (c *Client) LoginOIDC(ctx context.Context, idToken, nonce string) (*models.TokenMessage, error) {
+	return c.login(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/login/oidc",
+		body:   &models.LoginOIDC{IDToken: idToken, Nonce: nonce},
+		errs: map[int]error{
+			http.StatusUnauthorized:        models.ErrInvalidCredentials,
+			http.StatusUnprocessableEntity: models.ErrInvalidCredentials,
+			http.StatusNotFound:            models.ErrOIDCDisabled,
+		},
+	})
+}
+
+// RefreshToken exchanges the refresh token for a new JWT and a new refresh token
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*models.TokenMessage, error) {
+	tokenMsg := &models.TokenMessage{}
+	err := c.call(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/token/refresh",
+		body:   &models.RefreshToken{RefreshToken: refreshToken},
+		errs: map[int]error{
+			http.StatusUnauthorized:        models.ErrInvalidToken,
+			http.StatusUnprocessableEntity: models.ErrInvalidToken,
+		},
+	}, tokenMsg)
+	if err != nil {
+		return nil, err
+	}
+	return tokenMsg, nil
+}
+
+// Logout revokes the token and the refresh token of the session of the user
+func (c *Client) Logout(ctx context.Context, token, refreshToken string) error {
+	return c.call(ctx, &request{
+		method: http.MethodPost,
+		path:   "/users/logout",
+		token:  token,
+		body:   &models.LogoutUser{RefreshToken: refreshToken},
+	}, nil)
+}
+
+// GetSessions returns the active sessions of the user with the given id
+func (c *Client) GetSessions(ctx context.Context, token string, id int) ([]*models.Session, error) {
+	sessions := []*models.Session{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: fmt.Sprintf("/users/%d/sessions", id), token: token}, &sessions)
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs out the session with sessionID of the user with the given id
+func (c *Client) RevokeSession(ctx context.Context, token string, id, sessionID int) error {
+	return c.call(ctx, &request{
+		method: http.MethodDelete,
+		path:   fmt.Sprintf("/users/%d/sessions/%d", id, sessionID),
+		token:  token,
+	}, nil)
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+)
+
+// GetUsers returns all the users
+func (c *Client) GetUsers(ctx context.Context, token string) ([]*models.User, error) {
+	users := []*models.User{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: "/users", token: token}, &users)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUser returns the user with the given id
+func (c *Client) GetUser(ctx context.Context, token string, id int) (*models.User, error) {
+	u := &models.User{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: fmt.Sprintf("/users/%d", id), token: token}, u)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// CreateUser creates the user, models.ErrDuplicateEmail is wrapped when the email is in use
+func (c *Client) CreateUser(ctx context.Context, token string, cu *models.CreateUser) error {
+	return c.call(ctx, &request{method: http.MethodPost, path: "/users", token: token, body: cu}, nil)
+}
+
+// ChangePassword changes the password of the user with the given id, models.ErrInvalidCredentials
+// is wrapped when the old password is wrong
+func (c *Client) ChangePassword(ctx context.Context, token string, id int, cp *models.ChangeUserPassword) error {
+	return c.call(ctx, &request{
+		method: http.MethodPut,
+		path:   fmt.Sprintf("/users/%d/change-password", id),
+		token:  token,
+		body:   cp,
+	}, nil)
+}
+
+// GetRoleTypes returns the role types that can be given to the users
+func (c *Client) GetRoleTypes(ctx context.Context, token string) ([]*models.RoleType, error) {
+	roles := []*models.RoleType{}
+	err := c.call(ctx, &request{method: http.MethodGet, path: "/users/role-types", token: token}, &roles)
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// UnlockUser removes the lockout and the failed logins of the user with the given id
+func (c *Client) UnlockUser(ctx context.Context, token string, id int) error {
+	return c.call(ctx, &request{method: http.MethodDelete, path: fmt.Sprintf("/users/%d/lockout", id), token: token}, nil)
+}
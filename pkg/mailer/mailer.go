@@ -0,0 +1,118 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer is implemented by the types able to deliver an email message to an address
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// MailData mail delivery configuration data from config file
+type MailData struct {
+	Driver   string // "smtp", "file" or "log"
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	File     string // file used by the "file" driver
+}
+
+// New returns the Mailer selected by the Driver field of the configuration data.
+// The "log" driver writes the messages to infoLog.
+func New(infoLog *log.Logger, md MailData) (Mailer, error) {
+	switch md.Driver {
+	case "smtp":
+		if md.Host == "" || md.From == "" {
+			return nil, fmt.Errorf("mailer: New: smtp driver requires host and from")
+		}
+		return NewSMTPMailer(md), nil
+	case "file":
+		return NewFileMailer(md.File, md.From)
+	case "log", "":
+		return NewLogMailer(infoLog, md.From), nil
+	default:
+		return nil, fmt.Errorf("mailer: New: invalid driver - %q", md.Driver)
+	}
+}
+
+// SMTPMailer delivers the messages to an SMTP server
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer creates a new SMTPMailer, PLAIN authentication is used when an username is provided
+func NewSMTPMailer(md MailData) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(md.Host, strconv.Itoa(md.Port)),
+		from: md.From,
+	}
+	if md.Username != "" {
+		m.auth = smtp.PlainAuth("", md.Username, md.Password, md.Host)
+	}
+	return m
+}
+
+// Send delivers the message to the SMTP server
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	err := smtp.SendMail(m.addr, m.auth, m.from, []string{to}, buildMessage(m.from, to, subject, body))
+	if err != nil {
+		return fmt.Errorf("SMTPMailer: Send: %v", err)
+	}
+	return nil
+}
+
+// LogMailer writes the messages to a logger instead of delivering them,
+// used for development and tests
+type LogMailer struct {
+	log  *log.Logger
+	from string
+}
+
+// NewLogMailer creates a new LogMailer that writes to the given logger
+func NewLogMailer(l *log.Logger, from string) *LogMailer {
+	return &LogMailer{log: l, from: from}
+}
+
+// NewFileMailer creates a new LogMailer that appends the messages to the given file
+func NewFileMailer(filename, from string) (*LogMailer, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("mailer: NewFileMailer: file not specified")
+	}
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: NewFileMailer: %v", err)
+	}
+	return NewLogMailer(log.New(f, "", log.Ldate|log.Ltime), from), nil
+}
+
+// Send writes the message to the logger
+func (m *LogMailer) Send(to, subject, body string) error {
+	return m.log.Output(2, string(buildMessage(m.from, to, subject, body)))
+}
+
+// buildMessage returns the RFC 822 formatted message
+func buildMessage(from, to, subject, body string) []byte {
+	var sb strings.Builder
+	sb.WriteString("From: " + from + "\r\n")
+	sb.WriteString("To: " + to + "\r\n")
+	sb.WriteString("Subject: " + subject + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(body)
+	sb.WriteString("\r\n")
+	return []byte(sb.String())
+}
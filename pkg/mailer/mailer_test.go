@@ -0,0 +1,227 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		md      MailData
+		wantErr bool
+	}{
+		{"SMTP", MailData{Driver: "smtp", Host: "mail.example.com", Port: 587, From: "snippets@example.com"}, false},
+		{"SMTP without host", MailData{Driver: "smtp", Port: 587, From: "snippets@example.com"}, true},
+		{"SMTP without from", MailData{Driver: "smtp", Host: "mail.example.com", Port: 587}, true},
+		{"File", MailData{Driver: "file", File: filepath.Join(t.TempDir(), "mail.log")}, false},
+		{"File without file", MailData{Driver: "file"}, true},
+		{"Log", MailData{Driver: "log"}, false},
+		{"Default", MailData{}, false},
+		{"Invalid", MailData{Driver: "pigeon"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(log.New(ioutil.Discard, "", 0), tt.md)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want an error; got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m == nil {
+				t.Error("want a mailer; got nil")
+			}
+		})
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	msg := string(buildMessage("snippets@example.com", "alice@example.com", "Hello", "Line 1\r\nLine 2"))
+
+	parts := strings.SplitN(msg, "\r\n\r\n", 2)
+	if len(parts) != 2 {
+		t.Fatalf("want the header separated from the body by an empty line; got %q", msg)
+	}
+	header, body := parts[0]+"\r\n", parts[1]
+	for _, want := range []string{
+		"From: snippets@example.com\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: Hello\r\n",
+		"Date: ",
+		"MIME-Version: 1.0\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("want the header %q; got %q", want, header)
+		}
+	}
+	if body != "Line 1\r\nLine 2\r\n" {
+		t.Errorf("want the body ended by CRLF; got %q", body)
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(log.New(&buf, "", 0), "snippets@example.com")
+	if err := m.Send("alice@example.com", "Hello", "The body"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"From: snippets@example.com", "To: alice@example.com", "Subject: Hello", "The body"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %q in the log; got %q", want, buf.String())
+		}
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "mail.log")
+	m, err := NewFileMailer(filename, "snippets@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send("alice@example.com", "First", "The first body"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send("bob@example.com", "Second", "The second body"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "The first body") || !strings.Contains(string(b), "The second body") {
+		t.Errorf("want both messages appended to the file; got %q", b)
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("want the file only readable by its owner; got %v", perm)
+	}
+}
+
+// smtpServer is a minimal SMTP server that accepts a single message, rcptCode is the reply to RCPT TO
+type smtpServer struct {
+	ln       net.Listener
+	rcptCode string
+	done     chan struct{}
+	// the MAIL and RCPT commands and the data received
+	from, rcpt, data string
+}
+
+func newSMTPServer(t *testing.T, rcptCode string) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln, rcptCode: rcptCode, done: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpServer) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			s.from = line
+			reply("250 OK")
+		case "RCPT":
+			s.rcpt = line
+			reply(s.rcptCode + " recipient")
+		case "DATA":
+			reply("354 send the data")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// mailData returns the configuration of a mailer of the server
+func (s *smtpServer) mailData(t *testing.T) MailData {
+	host, port, err := net.SplitHostPort(s.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return MailData{Driver: "smtp", Host: host, Port: p, From: "snippets@example.com"}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	s := newSMTPServer(t, "250")
+	m := NewSMTPMailer(s.mailData(t))
+	if err := m.Send("alice@example.com", "Hello", "The body"); err != nil {
+		t.Fatal(err)
+	}
+	<-s.done
+
+	if s.from != "MAIL FROM:<snippets@example.com>" {
+		t.Errorf("want the sender of the configuration; got %q", s.from)
+	}
+	if s.rcpt != "RCPT TO:<alice@example.com>" {
+		t.Errorf("want the recipient of the message; got %q", s.rcpt)
+	}
+	if !strings.Contains(s.data, "Subject: Hello\r\n") || !strings.Contains(s.data, "\r\n\r\nThe body\r\n") {
+		t.Errorf("want the message; got %q", s.data)
+	}
+}
+
+func TestSMTPMailerRejected(t *testing.T) {
+	s := newSMTPServer(t, "550")
+	m := NewSMTPMailer(s.mailData(t))
+	err := m.Send("unknown@example.com", "Hello", "The body")
+	if err == nil || !strings.HasPrefix(err.Error(), "SMTPMailer: Send:") {
+		t.Errorf("want the error of the server; got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidAPIKey error if an API key is unknown, expired or revoked
+	ErrInvalidAPIKey = errors.New("models: invalid API key")
+)
+
+const (
+	// APIKeyPrefix starts every API key so that keys can be told apart from JWT
+	APIKeyPrefix = "snk_"
+	// apiKeyDisplayLen number of characters of the key stored in plain-text to identify it
+	apiKeyDisplayLen = len(APIKeyPrefix) + 8
+)
+
+// APIKeys manages the long lived personal API keys of the users.
+// Only the hash of the keys is stored, the plain-text value is returned once on creation.
+type APIKeys interface {
+	// Insert creates a key for the user and returns it with its plain-text value
+	Insert(ctx context.Context, userID int, name string, scopes []string, expires *time.Time) (*APIKey, string, error)
+	GetAll(ctx context.Context, userID int) ([]*APIKey, error)
+	// Revoke revokes the key of the user, ErrNoRecord is returned when the key is unknown
+	Revoke(ctx context.Context, userID, id int) error
+	// Authenticate returns the valid key with the plain-text value and updates its last used time
+	Authenticate(ctx context.Context, key string) (*APIKey, error)
+}
+
+// NewAPIKey returns a new random API key and its display prefix
+func NewAPIKey() (key, prefix string, err error) {
+	token, err := NewRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	key = APIKeyPrefix + token
+	return key, key[:apiKeyDisplayLen], nil
+}
+
+// IsAPIKey returns true if the credential has the format of an API key
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// ScopeRoles returns the roles of the user allowed by the scopes of an API key
+func ScopeRoles(scopes, roles []string) []string {
+	allowed := []string{}
+	for _, s := range scopes {
+		for _, r := range roles {
+			if s == r {
+				allowed = append(allowed, r)
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+// APIKey defines the structure of a personal API key of an user
+// swagger:model
+type APIKey struct {
+	// the id for the key
+	ID int `json:"id"`
+	// the id of the user that owns the key
+	UserID int `json:"userId"`
+	// the name given to the key
+	Name string `json:"name"`
+	// the first characters of the key, used to identify it
+	Prefix string `json:"prefix"`
+	// the roles of the user granted to the key, self grants the operations on the own user
+	Scopes []string `json:"scopes"`
+	// the created dateTime for this key
+	Created time.Time `json:"created"`
+	// the expiration dateTime for this key, null when the key does not expire
+	Expires *time.Time `json:"expires"`
+	// the dateTime of the last request authenticated with this key, null when never used
+	LastUsed *time.Time `json:"lastUsed"`
+}
+
+// CreateAPIKey defines the structure to create a personal API key
+// swagger:model
+type CreateAPIKey struct {
+	// the name given to the key
+	//
+	// required: true
+	// max length: 255
+	Name string `json:"name" validate:"required,max=255"`
+	// the roles of the user granted to the key, self grants the operations on the own user
+	//
+	// required: true
+	// min items: 1
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,required,max=45"`
+	// number of days the key is valid, zero for a key that does not expire
+	//
+	// required: false
+	// minimum: 0
+	// maximum: 3650
+	ExpiresInDays int `json:"expiresInDays" validate:"min=0,max=3650"`
+}
+
+// NewAPIKeyMessage defines the structure returned on the creation of an API key,
+// the plain-text key is not available after it
+// swagger:model
+type NewAPIKeyMessage struct {
+	APIKey
+	// the plain-text key
+	Key string `json:"key"`
+}
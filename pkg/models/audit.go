@@ -0,0 +1,206 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// the actions recorded on the audit log
+const (
+	AuditLogin          = "login"
+	AuditLoginFailed    = "login.failed"
+	AuditUserCreate     = "user.create"
+	AuditUserUpdate     = "user.update"
+	AuditUserRoles      = "user.roles"
+	AuditPasswordChange = "password.change"
+	AuditPasswordReset  = "password.reset"
+	AuditSnippetCreate  = "snippet.create"
+	AuditMFAEnable      = "mfa.enable"
+	AuditMFADisable     = "mfa.disable"
+	AuditRoleMFA        = "role.mfa"
+	AuditAPIKeyCreate   = "apikey.create"
+	AuditAPIKeyRevoke   = "apikey.revoke"
+	AuditSessionRevoke  = "session.revoke"
+	AuditLogout         = "logout"
+)
+
+// the types of the targets of the audit events
+const (
+	AuditTargetUser    = "user"
+	AuditTargetRole    = "role"
+	AuditTargetSnippet = "snippet"
+	AuditTargetAPIKey  = "apikey"
+	AuditTargetSession = "session"
+)
+
+const (
+	// AuditDefaultPerPage is the number of events of a page when not given
+	AuditDefaultPerPage = 50
+	// AuditMaxPerPage is the maximum number of events of a page
+	AuditMaxPerPage = 200
+)
+
+// AuditEvent is an entry of the audit log
+// swagger:model
+type AuditEvent struct {
+	// the id of the event
+	ID int `json:"id"`
+
+	// the time of the event
+	Created time.Time `json:"created"`
+
+	// the action, like login, login.failed, user.create or snippet.create
+	Action string `json:"action"`
+
+	// the id of the user that did the action, 0 when not authenticated
+	ActorID int `json:"actorId"`
+
+	// the type of the target of the action: user, role, snippet, apikey or session
+	TargetType string `json:"targetType"`
+
+	// the id of the target of the action, 0 when unknown
+	TargetID int `json:"targetId"`
+
+	// the IP address of the client
+	IP string `json:"ip"`
+
+	// the ID of the request, returned on the X-Request-ID header of the response
+	RequestID string `json:"requestId"`
+
+	// more information about the action
+	Details string `json:"details,omitempty"`
+}
+
+// AuditFilter selects a page of the audit events, the zero values match any event
+type AuditFilter struct {
+	Action     string
+	ActorID    int
+	TargetType string
+	TargetID   int
+	From       time.Time
+	To         time.Time
+	// Page starts at 1, PerPage is between 1 and AuditMaxPerPage
+	Page    int
+	PerPage int
+}
+
+// auditDateLayout is the layout of the dates accepted on the from and to filters besides RFC 3339
+const auditDateLayout = "2006-01-02"
+
+// ParseAuditFilter returns the filter of the query parameters action, actor, targetType, target,
+// from, to, page and perPage. The dates are in RFC 3339 or 2006-01-02, a to date without time
+// includes the whole day. The page and perPage defaults are 1 and AuditDefaultPerPage.
+func ParseAuditFilter(q url.Values) (*AuditFilter, error) {
+	f := &AuditFilter{
+		Action:     q.Get("action"),
+		TargetType: q.Get("targetType"),
+		Page:       1,
+		PerPage:    AuditDefaultPerPage,
+	}
+	ints := []struct {
+		name string
+		min  int
+		max  int
+		v    *int
+	}{
+		{"actor", 0, -1, &f.ActorID},
+		{"target", 0, -1, &f.TargetID},
+		{"page", 1, -1, &f.Page},
+		{"perPage", 1, AuditMaxPerPage, &f.PerPage},
+	}
+	for _, p := range ints {
+		s := q.Get(p.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < p.min || (p.max > 0 && n > p.max) {
+			return nil, fmt.Errorf("invalid %s %q", p.name, s)
+		}
+		*p.v = n
+	}
+	for _, p := range []struct {
+		name string
+		v    *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		s := q.Get(p.name)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t, err = time.Parse(auditDateLayout, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", p.name, s)
+			}
+			if p.name == "to" {
+				t = t.AddDate(0, 0, 1)
+			}
+		}
+		*p.v = t
+	}
+	return f, nil
+}
+
+// Query returns the query parameters of the filter, the zero values are omitted
+func (f *AuditFilter) Query() url.Values {
+	q := url.Values{}
+	if f.Action != "" {
+		q.Set("action", f.Action)
+	}
+	if f.ActorID > 0 {
+		q.Set("actor", strconv.Itoa(f.ActorID))
+	}
+	if f.TargetType != "" {
+		q.Set("targetType", f.TargetType)
+	}
+	if f.TargetID > 0 {
+		q.Set("target", strconv.Itoa(f.TargetID))
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.UTC().Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.UTC().Format(time.RFC3339))
+	}
+	if f.Page > 1 {
+		q.Set("page", strconv.Itoa(f.Page))
+	}
+	if f.PerPage > 0 && f.PerPage != AuditDefaultPerPage {
+		q.Set("perPage", strconv.Itoa(f.PerPage))
+	}
+	return q
+}
+
+// AuditPage is a page of the audit events, the most recent first
+// swagger:model
+type AuditPage struct {
+	// the events of the page
+	Events []*AuditEvent `json:"events"`
+
+	// the number of the page, starting at 1
+	Page int `json:"page"`
+
+	// the maximum number of events of a page
+	PerPage int `json:"perPage"`
+
+	// the number of events matching the filter
+	Total int `json:"total"`
+}
+
+// AuditLog is the append-only log of the security relevant and content events,
+// the events are never updated nor deleted
+type AuditLog interface {
+	// Insert appends the event to the log
+	Insert(ctx context.Context, e *AuditEvent) error
+	// GetAll returns the page of the events matching the filter
+	GetAll(ctx context.Context, f *AuditFilter) (*AuditPage, error)
+}
+
+// APIAudit reads the audit log through the API, the first parameter is a valid token of an administrator
+type APIAudit interface {
+	GetAll(ctx context.Context, token string, f *AuditFilter) (*AuditPage, error)
+}
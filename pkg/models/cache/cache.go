@@ -0,0 +1,267 @@
+// Package cache keeps the snippets read from a storage in a Store, the LRU of the process or
+// an external cache shared by the instances. The cached snippets expire after the TTL of the
+// cache or when the snippet expires, whatever happens first, and they are removed when the
+// snippets are written through the decorators of this package.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store keeps the values of the keys until their TTL, the LRU or a client of an external
+// cache. The errors of a Store are logged and the snippets are read from the storage.
+type Store interface {
+	// Get returns the value of the key, false when it is not cached
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set keeps the value of the key until the ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the keys
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// the keys of the cached snippets
+const (
+	latestKey  = "snippets:latest"
+	snippetKey = "snippets:"
+)
+
+// Cache is a models.UnauthotizedSnippets that reads the snippets from the Store before the
+// next one, the concurrent misses of a key are read once from the next one
+type Cache struct {
+	next     models.UnauthotizedSnippets
+	store    Store
+	ttl      time.Duration
+	errorLog *log.Logger
+
+	mu     sync.Mutex
+	flying map[string]*flight
+	// incremented by each invalidation, the reads started before one are not cached
+	generation uint64
+	// true from an invalidation until the latest snippets read from the primary are cached,
+	// so the list of a lagging read replica without the new snippet is not kept up to the ttl
+	latestPrimary bool
+}
+
+// flight is a read of the next one shared by the concurrent misses of a key
+type flight struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// New returns the cache of the snippets of next kept on the store up to the ttl
+func New(next models.UnauthotizedSnippets, store Store, ttl time.Duration, errorLog *log.Logger) *Cache {
+	return &Cache{next: next, store: store, ttl: ttl, errorLog: errorLog, flying: map[string]*flight{}}
+}
+
+// Get returns the snippet of the id, the missing ones are not cached
+func (c *Cache) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	key := snippetKey + strconv.Itoa(id)
+	s := &models.Snippet{}
+	if c.cached(ctx, key, s) {
+		return s, nil
+	}
+	v, err := c.do(ctx, key, func() (interface{}, error) {
+		generation := c.current()
+		s, err := c.next.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		c.set(ctx, key, s, s.Expires, generation)
+		return s, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copySnippet(v.(*models.Snippet)), nil
+}
+
+// Latest returns the latest snippets, they are cached until the first one expires
+func (c *Cache) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+	if c.cached(ctx, latestKey, &snippets) {
+		return snippets, nil
+	}
+	v, err := c.do(ctx, latestKey, func() (interface{}, error) {
+		c.mu.Lock()
+		generation, primary := c.generation, c.latestPrimary
+		c.mu.Unlock()
+		readCtx := ctx
+		if primary {
+			readCtx = models.WithPrimary(ctx)
+		}
+		snippets, err := c.next.Latest(readCtx)
+		if err != nil {
+			return nil, err
+		}
+		expires := time.Now().Add(c.ttl)
+		for _, s := range snippets {
+			if s.Expires.Before(expires) {
+				expires = s.Expires
+			}
+		}
+		if c.set(ctx, latestKey, snippets, expires, generation) && primary {
+			c.mu.Lock()
+			if c.generation == generation {
+				c.latestPrimary = false
+			}
+			c.mu.Unlock()
+		}
+		return snippets, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	latest := v.([]*models.Snippet)
+	snippets = make([]*models.Snippet, 0, len(latest))
+	for _, s := range latest {
+		snippets = append(snippets, copySnippet(s))
+	}
+	return snippets, nil
+}
+
+// Invalidate removes the snippet of the id and the latest snippets from the cache, the next
+// latest snippets are read from the primary. It is called after a snippet is inserted, updated or deleted
+func (c *Cache) Invalidate(ctx context.Context, id int) {
+	c.mu.Lock()
+	c.generation++
+	c.latestPrimary = true
+	c.mu.Unlock()
+	err := c.store.Delete(ctx, snippetKey+strconv.Itoa(id), latestKey)
+	if err != nil {
+		c.errorLog.Printf("cache: Invalidate: snippet %d: %v\n", id, err)
+	}
+}
+
+// cached decodes the value of the key on v, false when it is not cached
+func (c *Cache) cached(ctx context.Context, key string, v interface{}) bool {
+	b, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		c.errorLog.Printf("cache: Get %s: %v\n", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		c.errorLog.Printf("cache: Get %s: %v\n", key, err)
+		return false
+	}
+	return true
+}
+
+// current returns the generation of the invalidations
+func (c *Cache) current() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// set keeps v on the key until the TTL of the cache or the expires, whatever happens first,
+// and returns true when it is kept. Nothing is kept when the cache was invalidated after the
+// read of v started on the generation.
+func (c *Cache) set(ctx context.Context, key string, v interface{}, expires time.Time, generation uint64) bool {
+	if c.current() != generation {
+		return false
+	}
+	ttl := time.Until(expires)
+	if ttl > c.ttl {
+		ttl = c.ttl
+	}
+	if ttl <= 0 {
+		return false
+	}
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = c.store.Set(ctx, key, b, ttl)
+	}
+	if err != nil {
+		c.errorLog.Printf("cache: Set %s: %v\n", key, err)
+		return false
+	}
+	return true
+}
+
+// do calls read once for the concurrent calls of the key and returns its result to all of them,
+// a call reads on its own when the shared read was cancelled by the context of another request
+func (c *Cache) do(ctx context.Context, key string, read func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if f, ok := c.flying[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		cancelled := errors.Is(f.err, context.Canceled) || errors.Is(f.err, context.DeadlineExceeded)
+		if cancelled && ctx.Err() == nil {
+			return read()
+		}
+		return f.value, f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flying[key] = f
+	c.mu.Unlock()
+
+	f.value, f.err = read()
+	c.mu.Lock()
+	delete(c.flying, key)
+	c.mu.Unlock()
+	close(f.done)
+	return f.value, f.err
+}
+
+// copySnippet returns a copy of the snippet shared by a flight, so the callers can change it
+func copySnippet(s *models.Snippet) *models.Snippet {
+	cp := *s
+	return &cp
+}
+
+// Snippets is the cache of the models.Snippets of the API, the inserts invalidate it
+type Snippets struct {
+	*Cache
+	snippets models.Snippets
+}
+
+// NewSnippets returns the cache of the snippets of the storage
+func NewSnippets(snippets models.Snippets, store Store, ttl time.Duration, errorLog *log.Logger) *Snippets {
+	return &Snippets{Cache: New(snippets, store, ttl, errorLog), snippets: snippets}
+}
+
+// Insert inserts the snippet on the storage and invalidates the cache
+func (m *Snippets) Insert(ctx context.Context, title, content, expires string) (int, error) {
+	id, err := m.snippets.Insert(ctx, title, content, expires)
+	if err != nil {
+		return id, err
+	}
+	m.Invalidate(ctx, id)
+	return id, nil
+}
+
+// APISnippets is the cache of the models.APISnippets of the web, the inserts invalidate it
+type APISnippets struct {
+	*Cache
+	snippets models.APISnippets
+}
+
+// NewAPISnippets returns the cache of the snippets of the API
+func NewAPISnippets(snippets models.APISnippets, store Store, ttl time.Duration, errorLog *log.Logger) *APISnippets {
+	return &APISnippets{Cache: New(snippets, store, ttl, errorLog), snippets: snippets}
+}
+
+// Insert inserts the snippet with the token of the user and invalidates the cache
+func (m *APISnippets) Insert(ctx context.Context, token, title, content, expires string) (int, error) {
+	id, err := m.snippets.Insert(ctx, token, title, content, expires)
+	if err != nil {
+		return id, err
+	}
+	m.Invalidate(ctx, id)
+	return id, nil
+}
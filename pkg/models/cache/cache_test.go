@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+	"io/ioutil"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubSnippets counts the reads of the snippets, the reads wait for release when it is set
+type stubSnippets struct {
+	snippets map[int]*models.Snippet
+	reads    int32
+	release  chan struct{}
+}
+
+func (m *stubSnippets) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	atomic.AddInt32(&m.reads, 1)
+	if m.release != nil {
+		<-m.release
+	}
+	s, ok := m.snippets[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (m *stubSnippets) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	atomic.AddInt32(&m.reads, 1)
+	snippets := []*models.Snippet{}
+	for _, s := range m.snippets {
+		cp := *s
+		snippets = append(snippets, &cp)
+	}
+	return snippets, nil
+}
+
+func (m *stubSnippets) Insert(ctx context.Context, title, content, expires string) (int, error) {
+	id := len(m.snippets) + 1
+	m.snippets[id] = &models.Snippet{ID: id, Title: title, Content: content, Expires: time.Now().Add(time.Hour)}
+	return id, nil
+}
+
+// mapStore is the stand-in of an external cache, it records the TTL of the keys
+type mapStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	err    error
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (s *mapStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok, s.err
+}
+
+func (s *mapStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key], s.ttls[key] = value, ttl
+	return s.err
+}
+
+func (s *mapStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.values, key)
+	}
+	return s.err
+}
+
+func newStub() *stubSnippets {
+	return &stubSnippets{snippets: map[int]*models.Snippet{
+		1: {ID: 1, Title: "An old silent pond", Expires: time.Now().Add(time.Hour)},
+		2: {ID: 2, Title: "Over the wintry forest", Expires: time.Now().Add(time.Minute)},
+	}}
+}
+
+var discard = log.New(ioutil.Discard, "", 0)
+
+func TestCacheGet(t *testing.T) {
+	stub := newStub()
+	store := newMapStore()
+	c := New(stub, store, 10*time.Minute, discard)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		s, err := c.Get(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Title != "An old silent pond" {
+			t.Errorf("want the snippet 1; got %q", s.Title)
+		}
+	}
+	if stub.reads != 1 {
+		t.Errorf("want one read of the storage; got %d", stub.reads)
+	}
+	if ttl := store.ttls["snippets:1"]; ttl != 10*time.Minute {
+		t.Errorf("want the TTL of the cache; got %v", ttl)
+	}
+
+	// the TTL is bounded by the expiry of the snippet
+	if _, err := c.Get(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := store.ttls["snippets:2"]; ttl > time.Minute || ttl <= 0 {
+		t.Errorf("want the TTL bounded by the expiry; got %v", ttl)
+	}
+
+	// the missing snippets are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(ctx, 3); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want ErrNoRecord; got %v", err)
+		}
+	}
+	if stub.reads != 4 {
+		t.Errorf("want the missing snippet read each time; got %d reads", stub.reads)
+	}
+
+	// the snippets are read from the storage while the store fails
+	store.err = errors.New("connection refused")
+	if _, err := c.Get(ctx, 1); err != nil {
+		t.Errorf("want the snippet of the storage; got %v", err)
+	}
+}
+
+func TestCacheLatestAndInsert(t *testing.T) {
+	stub := newStub()
+	store := newMapStore()
+	m := NewSnippets(stub, store, 10*time.Minute, discard)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.Latest(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stub.reads != 1 {
+		t.Errorf("want one read of the storage; got %d", stub.reads)
+	}
+	if ttl := store.ttls["snippets:latest"]; ttl > time.Minute || ttl <= 0 {
+		t.Errorf("want the TTL bounded by the first expiry; got %v", ttl)
+	}
+
+	id, err := m.Insert(ctx, "First autumn morning", "content", "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippets, err := m.Latest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 3 || stub.reads != 2 {
+		t.Errorf("want the latest snippets read again after the insert; got %d snippets and %d reads", len(snippets), stub.reads)
+	}
+	if s, err := m.Get(ctx, id); err != nil || s.ID != id {
+		t.Errorf("want the inserted snippet; got %v, %v", s, err)
+	}
+}
+
+// laggingSnippets reads the latest snippets from a replica that does not have the inserts,
+// unless the context requires the primary
+type laggingSnippets struct {
+	*stubSnippets
+	replica      []*models.Snippet
+	primaryReads int
+}
+
+func (m *laggingSnippets) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	if models.UsePrimary(ctx) {
+		m.primaryReads++
+		return m.stubSnippets.Latest(ctx)
+	}
+	return m.replica, nil
+}
+
+func TestCacheLatestLaggingReplica(t *testing.T) {
+	stub := newStub()
+	replica, err := stub.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lagging := &laggingSnippets{stubSnippets: stub, replica: replica}
+	m := NewSnippets(lagging, newMapStore(), 10*time.Minute, discard)
+	ctx := context.Background()
+
+	if _, err := m.Latest(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Insert(ctx, "First autumn morning", "content", "7"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first read after the insert is done on the primary and its list is cached
+	for i := 0; i < 2; i++ {
+		snippets, err := m.Latest(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snippets) != 3 {
+			t.Errorf("read %d: want the inserted snippet; got %d snippets", i+1, len(snippets))
+		}
+	}
+	if lagging.primaryReads != 1 {
+		t.Errorf("want one read of the primary; got %d", lagging.primaryReads)
+	}
+
+	// the reads go back to the replica once the list of the primary is cached
+	m.Cache.store.Delete(ctx, latestKey)
+	if _, err := m.Latest(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if lagging.primaryReads != 1 {
+		t.Errorf("want the replica read; got %d reads of the primary", lagging.primaryReads)
+	}
+}
+
+func TestCacheSingleFlight(t *testing.T) {
+	stub := newStub()
+	stub.release = make(chan struct{})
+	c := New(stub, NewLRU(10), time.Minute, discard)
+
+	var wg sync.WaitGroup
+	titles := make([]string, 10)
+	for i := range titles {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := c.Get(context.Background(), 1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			titles[i] = s.Title
+		}(i)
+	}
+	// wait until a read is started before releasing it
+	for atomic.LoadInt32(&stub.reads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(stub.release)
+	wg.Wait()
+
+	if stub.reads != 1 {
+		t.Errorf("want one read of the storage for the concurrent misses; got %d", stub.reads)
+	}
+	for _, title := range titles {
+		if title != "An old silent pond" {
+			t.Errorf("want the snippet 1 on every call; got %q", title)
+		}
+	}
+}
+
+func TestLRU(t *testing.T) {
+	c := NewLRU(2)
+	ctx := context.Background()
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("want the least recently used entry dropped")
+	}
+	if v, ok, _ := c.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Errorf("want the entry a; got %q", v)
+	}
+	if c.Len() != 2 {
+		t.Errorf("want 2 entries; got %d", c.Len())
+	}
+
+	c.Set(ctx, "d", []byte("4"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok, _ := c.Get(ctx, "d"); ok {
+		t.Error("want the expired entry missing")
+	}
+
+	c.Delete(ctx, "a", "c")
+	if c.Len() != 0 {
+		t.Errorf("want no entries; got %d", c.Len())
+	}
+}
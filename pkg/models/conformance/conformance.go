@@ -0,0 +1,566 @@
+// Package conformance has the tests shared by the storage backends of the models, each backend
+// runs them against its own database so that all of them behave the same.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unique returns a random suffix so that the tests can run on a database with previous data
+func unique(t *testing.T) string {
+	s, err := models.NewRandomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s[:12]
+}
+
+// missingID is the ID of a record that is never inserted by the tests
+const missingID = 1 << 30
+
+// Snippets tests the models.Snippets of a backend
+func Snippets(t *testing.T, m models.Snippets) {
+	ctx := context.Background()
+	title := "An old silent pond " + unique(t)
+
+	id, err := m.Insert(ctx, title, "A frog jumps into the pond", "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		s, err := m.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.ID != id || s.Title != title || s.Content != "A frog jumps into the pond" {
+			t.Errorf("want snippet %d %q; got %d %q %q", id, title, s.ID, s.Title, s.Content)
+		}
+		if d := time.Since(s.Created); d < -time.Minute || d > time.Minute {
+			t.Errorf("want created now; got %v", s.Created)
+		}
+		if d := s.Expires.Sub(s.Created); d != 7*24*time.Hour {
+			t.Errorf("want expires in %v; got %v", 7*24*time.Hour, d)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := m.Get(ctx, missingID)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired, err := m.Insert(ctx, "Over the wintry forest "+unique(t), "winds howl in rage", "0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Get(ctx, expired)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+		}
+		latest, err := m.Latest(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range latest {
+			if s.ID == expired {
+				t.Errorf("want expired snippet %d not in latest", expired)
+			}
+		}
+	})
+
+	t.Run("Latest", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			if _, err := m.Insert(ctx, "Latest "+unique(t), "content", "1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		latest, err := m.Latest(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(latest) != 10 {
+			t.Errorf("want 10 snippets; got %d", len(latest))
+		}
+		for i := 1; i < len(latest); i++ {
+			if latest[i].Created.After(latest[i-1].Created) {
+				t.Errorf("want most recent first; got %v after %v", latest[i].Created, latest[i-1].Created)
+			}
+		}
+	})
+}
+
+// Users tests the models.Users of a backend, the database must have at least one role type
+func Users(t *testing.T, m models.Users) {
+	ctx := context.Background()
+	email := "alice-" + unique(t) + "@example.com"
+	password := "pa55word-conformance"
+
+	roles, err := m.GetRoleTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) == 0 {
+		t.Fatal("want role types; got none")
+	}
+	role := roles[0]
+
+	if err := m.Insert(ctx, "Alice", email, password, []int{role.ID}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := m.GetByEmail(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := m.Get(ctx, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "Alice" || got.Email != email || !got.Active {
+			t.Errorf("want active user %q %q; got %q %q active %v", "Alice", email, got.Name, got.Email, got.Active)
+		}
+		if len(got.Roles) != 1 || got.Roles[0] != role.Role {
+			t.Errorf("want roles [%s]; got %v", role.Role, got.Roles)
+		}
+		all, err := m.GetAll(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsUser(all, u.ID) {
+			t.Errorf("want user %d in all users", u.ID)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		if _, err := m.Get(ctx, missingID); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+		}
+		if _, err := m.GetByEmail(ctx, "nobody-"+unique(t)+"@example.com"); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+		}
+	})
+
+	t.Run("Duplicate email", func(t *testing.T) {
+		err := m.Insert(ctx, "Alice again", email, password, nil)
+		if !errors.Is(err, models.ErrDuplicateEmail) {
+			t.Errorf("want %v; got %v", models.ErrDuplicateEmail, err)
+		}
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			email    string
+			password string
+			wantID   int
+			wantErr  error
+		}{
+			{"Valid", email, password, u.ID, nil},
+			{"Wrong password", email, "wrong-password", 0, models.ErrInvalidCredentials},
+			{"Unknown email", "nobody-" + unique(t) + "@example.com", password, 0, models.ErrInvalidCredentials},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				id, err := m.Authenticate(ctx, tt.email, tt.password)
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("want %v; got %v", tt.wantErr, err)
+				}
+				if id != tt.wantID {
+					t.Errorf("want %d; got %d", tt.wantID, id)
+				}
+			})
+		}
+	})
+
+	t.Run("ChangePassword", func(t *testing.T) {
+		err := m.ChangePassword(ctx, u.ID, "wrong-password", "new-pa55word")
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("want %v; got %v", models.ErrInvalidCredentials, err)
+		}
+		if err := m.ChangePassword(ctx, u.ID, password, "new-pa55word"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Authenticate(ctx, email, "new-pa55word"); err != nil {
+			t.Errorf("want login with the new password; got %v", err)
+		}
+		if err := m.ResetPassword(ctx, u.ID, password); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Authenticate(ctx, email, password); err != nil {
+			t.Errorf("want login with the reset password; got %v", err)
+		}
+	})
+
+	t.Run("Registration", func(t *testing.T) {
+		pendingEmail := "bob-" + unique(t) + "@example.com"
+		id, err := m.Register(ctx, "Bob", pendingEmail, password, []int{role.ID}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Authenticate(ctx, pendingEmail, password); !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("want %v before the approval; got %v", models.ErrInvalidCredentials, err)
+		}
+		pending, err := m.GetPending(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsUser(pending, id) {
+			t.Errorf("want user %d pending", id)
+		}
+		if err := m.Approve(ctx, id, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Approve(ctx, id, true); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v approving twice; got %v", models.ErrNoRecord, err)
+		}
+		if _, err := m.Authenticate(ctx, pendingEmail, password); err != nil {
+			t.Errorf("want login after the approval; got %v", err)
+		}
+
+		verifyEmail := "carol-" + unique(t) + "@example.com"
+		id, err = m.Register(ctx, "Carol", verifyEmail, password, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Activate(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Authenticate(ctx, verifyEmail, password); err != nil {
+			t.Errorf("want login after the activation; got %v", err)
+		}
+	})
+
+	t.Run("Roles", func(t *testing.T) {
+		ids := []int{}
+		for _, rt := range roles {
+			ids = append(ids, rt.ID)
+		}
+		// the roles the user already has are not granted twice
+		if err := m.AddRoles(ctx, u.ID, ids); err != nil {
+			t.Fatal(err)
+		}
+		got, err := m.GetRoles(ctx, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*got) != len(roles) {
+			t.Errorf("want %d roles; got %v", len(roles), *got)
+		}
+
+		if err := m.SetRoleMFARequired(ctx, role.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.SetRoleMFARequired(ctx, role.ID, role.MFARequired); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.SetRoleMFARequired(ctx, missingID, true); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+		}
+	})
+
+	t.Run("Identity", func(t *testing.T) {
+		issuer, subject := "https://idp.example.com", unique(t)
+		id, err := m.Provision(ctx, "Dave", "dave-"+unique(t)+"@example.com", []int{role.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.GetByIdentity(ctx, issuer, subject); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+		}
+		if err := m.LinkIdentity(ctx, id, issuer, subject); err != nil {
+			t.Fatal(err)
+		}
+		got, err := m.GetByIdentity(ctx, issuer, subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != id || !got.Active {
+			t.Errorf("want active user %d; got %d active %v", id, got.ID, got.Active)
+		}
+	})
+}
+
+// containsUser returns true when the user with the given id is on the list
+// UserTokens runs the tests of the one-time tokens of the users, tokens and users share the database
+func UserTokens(t *testing.T, tokens models.UserTokens, users models.Users) {
+	ctx := context.Background()
+	email := "carol-" + unique(t) + "@example.com"
+	password := "pa55word-conformance"
+
+	if err := users.Insert(ctx, "Carol", email, password, nil); err != nil {
+		t.Fatal(err)
+	}
+	u, err := users.GetByEmail(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Consume", func(t *testing.T) {
+		token, err := tokens.New(ctx, u.ID, models.TokenPurposeVerifyEmail, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tokens.Peek(ctx, token, models.TokenPurposeResetPassword); !errors.Is(err, models.ErrInvalidToken) {
+			t.Errorf("want %v for another purpose; got %v", models.ErrInvalidToken, err)
+		}
+		if id, err := tokens.Peek(ctx, token, models.TokenPurposeVerifyEmail); err != nil || id != u.ID {
+			t.Errorf("want %d; got %d, %v", u.ID, id, err)
+		}
+		if id, err := tokens.Consume(ctx, token, models.TokenPurposeVerifyEmail); err != nil || id != u.ID {
+			t.Errorf("want %d; got %d, %v", u.ID, id, err)
+		}
+		if _, err := tokens.Consume(ctx, token, models.TokenPurposeVerifyEmail); !errors.Is(err, models.ErrInvalidToken) {
+			t.Errorf("want %v on the second use; got %v", models.ErrInvalidToken, err)
+		}
+
+		// a new token invalidates the previous one of the purpose
+		first, err := tokens.New(ctx, u.ID, models.TokenPurposeVerifyEmail, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tokens.New(ctx, u.ID, models.TokenPurposeVerifyEmail, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tokens.Peek(ctx, first, models.TokenPurposeVerifyEmail); !errors.Is(err, models.ErrInvalidToken) {
+			t.Errorf("want %v for the previous token; got %v", models.ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("HasUnused", func(t *testing.T) {
+		id, err := users.Register(ctx, "Dave", "dave-"+unique(t)+"@example.com", password, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hasUnused := func(want bool) {
+			t.Helper()
+			got, err := tokens.HasUnused(ctx, id, models.TokenPurposeVerifyEmail)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("want %v; got %v", want, got)
+			}
+		}
+
+		hasUnused(false)
+		// an expired token is still unused
+		if _, err := tokens.New(ctx, id, models.TokenPurposeVerifyEmail, -time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		hasUnused(true)
+		if ok, err := tokens.HasUnused(ctx, id, models.TokenPurposeResetPassword); err != nil || ok {
+			t.Errorf("want no unused token of another purpose; got %v, %v", ok, err)
+		}
+		token, err := tokens.New(ctx, id, models.TokenPurposeVerifyEmail, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tokens.Consume(ctx, token, models.TokenPurposeVerifyEmail); err != nil {
+			t.Fatal(err)
+		}
+		hasUnused(false)
+	})
+
+	t.Run("ResetPasswordByToken", func(t *testing.T) {
+		if _, err := users.ResetPasswordByToken(ctx, "unknown-token", "new-pa55word"); !errors.Is(err, models.ErrInvalidToken) {
+			t.Errorf("want %v; got %v", models.ErrInvalidToken, err)
+		}
+		verify, err := tokens.New(ctx, u.ID, models.TokenPurposeVerifyEmail, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := users.ResetPasswordByToken(ctx, verify, "new-pa55word"); !errors.Is(err, models.ErrInvalidToken) {
+			t.Errorf("want %v for another purpose; got %v", models.ErrInvalidToken, err)
+		}
+
+		token, err := tokens.New(ctx, u.ID, models.TokenPurposeResetPassword, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := users.ResetPasswordByToken(ctx, token, "new-pa55word")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != u.ID {
+			t.Errorf("want %d; got %d", u.ID, id)
+		}
+		if _, err := users.Authenticate(ctx, email, "new-pa55word"); err != nil {
+			t.Errorf("want login with the new password; got %v", err)
+		}
+		if _, err := users.ResetPasswordByToken(ctx, token, "other-pa55word"); !errors.Is(err, models.ErrInvalidToken) {
+			t.Errorf("want %v on the second use; got %v", models.ErrInvalidToken, err)
+		}
+		if _, err := users.Authenticate(ctx, email, "new-pa55word"); err != nil {
+			t.Errorf("want the password unchanged by a used token; got %v", err)
+		}
+	})
+}
+
+// RefreshTokens runs the tests of the refresh tokens, the tokens and the users share the database
+func RefreshTokens(t *testing.T, m models.RefreshTokens, users models.Users) {
+	ctx := context.Background()
+	var ids []int
+	for _, name := range []string{"Frank", "Grace"} {
+		email := strings.ToLower(name) + "-" + unique(t) + "@example.com"
+		if err := users.Insert(ctx, name, email, "pa55word-conformance", nil); err != nil {
+			t.Fatal(err)
+		}
+		u, err := users.GetByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, u.ID)
+	}
+	frank, grace := ids[0], ids[1]
+
+	token, err := m.New(ctx, frank, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, id, _, err := m.Rotate(ctx, token, time.Hour)
+	if err != nil || id != frank {
+		t.Fatalf("want the token of %d rotated; got %d, %v", frank, id, err)
+	}
+
+	// the token of another user is not revoked
+	if err := m.Revoke(ctx, grace, rotated); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v for the token of another user; got %v", models.ErrNoRecord, err)
+	}
+	if err := m.Revoke(ctx, frank, "unknown-token"); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v for an unknown token; got %v", models.ErrNoRecord, err)
+	}
+	next, _, _, err := m.Rotate(ctx, rotated, time.Hour)
+	if err != nil {
+		t.Fatalf("want the token valid after the revoke of another user; got %v", err)
+	}
+
+	// a revoked token of the user revokes its family
+	if err := m.Revoke(ctx, frank, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := m.Rotate(ctx, next, time.Hour); !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("want %v for the family of a revoked token; got %v", models.ErrInvalidToken, err)
+	}
+}
+
+// MFA runs the tests of the two-factor authentication, the MFA and the users share the database
+func MFA(t *testing.T, m models.MFA, users models.Users) {
+	ctx := context.Background()
+	email := "erin-" + unique(t) + "@example.com"
+
+	if err := users.Insert(ctx, "Erin", email, "pa55word-conformance", nil); err != nil {
+		t.Fatal(err)
+	}
+	u, err := users.GetByEmail(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Get(ctx, u.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v before the enrollment; got %v", models.ErrNoRecord, err)
+	}
+	secret, err := models.NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Enroll(ctx, u.ID, secret); err != nil {
+		t.Fatal(err)
+	}
+	codes, err := models.NewRecoveryCodes(models.RecoveryCodesCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Enable(ctx, u.ID, codes); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Enabled || status.Secret != secret || status.RecoveryCodesLeft != len(codes) {
+		t.Errorf("want enabled with the secret and %d codes; got %v %v %d",
+			len(codes), status.Enabled, status.Secret == secret, status.RecoveryCodesLeft)
+	}
+
+	t.Run("UseStep", func(t *testing.T) {
+		step := models.TOTPStep(time.Now())
+		tests := []struct {
+			name string
+			step int64
+			want bool
+		}{
+			{"Current step", step, true},
+			{"Replayed step", step, false},
+			{"Earlier step", step - 1, false},
+			{"Next step", step + 1, true},
+			{"Replayed next step", step + 1, false},
+			{"Current step after the next one", step, false},
+		}
+		for _, tt := range tests {
+			ok, err := m.UseStep(ctx, u.ID, tt.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want {
+				t.Errorf("%s: want %v; got %v", tt.name, tt.want, ok)
+			}
+		}
+		if ok, err := m.UseStep(ctx, u.ID+1000000, step+2); err != nil || ok {
+			t.Errorf("want the step of an user without two-factor rejected; got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("UseRecoveryCode", func(t *testing.T) {
+		// the code typed in upper case and with spaces is the generated one
+		typed := " " + strings.ToUpper(strings.Replace(codes[0], "-", " - ", 1)) + " "
+		if ok, err := m.UseRecoveryCode(ctx, u.ID, typed); err != nil || !ok {
+			t.Errorf("want the typed code %q accepted; got %v, %v", typed, ok, err)
+		}
+		if ok, err := m.UseRecoveryCode(ctx, u.ID, codes[0]); err != nil || ok {
+			t.Errorf("want a used code rejected; got %v, %v", ok, err)
+		}
+		if ok, err := m.UseRecoveryCode(ctx, u.ID, "abcde-fghjk"); err != nil || ok {
+			t.Errorf("want an unknown code rejected; got %v, %v", ok, err)
+		}
+		if ok, err := m.UseRecoveryCode(ctx, u.ID, codes[1]); err != nil || !ok {
+			t.Errorf("want another code accepted; got %v, %v", ok, err)
+		}
+		status, err := m.Get(ctx, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.RecoveryCodesLeft != len(codes)-2 {
+			t.Errorf("want %d codes left; got %d", len(codes)-2, status.RecoveryCodesLeft)
+		}
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		if err := m.Disable(ctx, u.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Get(ctx, u.ID); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v after disabling; got %v", models.ErrNoRecord, err)
+		}
+		if ok, err := m.UseRecoveryCode(ctx, u.ID, codes[2]); err != nil || ok {
+			t.Errorf("want the codes removed; got %v, %v", ok, err)
+		}
+	})
+}
+
+func containsUser(users []*models.User, id int) bool {
+	for _, u := range users {
+		if u.ID == id {
+			return true
+		}
+	}
+	return false
+}
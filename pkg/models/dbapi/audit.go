@@ -0,0 +1,22 @@
+package dbapi
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+)
+
+// AuditModel define type which wraps a API middleware connection to the audit log
+type AuditModel struct {
+	Db API
+}
+
+func NewAuditModel(d *API) *AuditModel {
+	return &AuditModel{Db: *d}
+}
+
+// GetAll retrieves the page of the audit log events matching the filter
+func (m *AuditModel) GetAll(ctx context.Context, token string, f *models.AuditFilter) (*models.AuditPage, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.GetAudit(ctx, token, f)
+}
@@ -1,41 +1,59 @@
 package dbapi
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
+	"github.com/vgraveto/snippets/pkg/client"
 	"log"
-	"net/http"
+	"time"
 )
 
 type DBapi struct {
 	URL string // the URL where the middleware API is deployed
+	// the time limit of each attempt of a request, client.DefaultTimeout when zero
+	Timeout time.Duration
+	// the number of retries of the idempotent requests, zero disables the retries
+	Retries int
+	// the deadline of each operation of the models, including the retries, zero disables it
+	OperationTimeout time.Duration
 }
 
 type API struct {
 	Url string
+	// the client of the API shared by the models
+	Client *client.Client
+	// the deadline of each operation of the models, zero disables it
+	OperationTimeout time.Duration
+}
+
+// operation returns the context of an operation of a model, it is canceled with the context
+// of the request and after the OperationTimeout of the API
+func (db *API) operation(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.OperationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.OperationTimeout)
 }
 
 func DialDB(infoLog *log.Logger, dialData DBapi) (db *API, err error) {
 	if dialData.URL == "" {
 		return nil, fmt.Errorf("dbapi: DialDB: inalid URL - %q", dialData.URL)
 	}
-	resp, err := http.Get(dialData.URL)
+	c, err := client.New(client.Config{
+		URL:        dialData.URL,
+		Timeout:    dialData.Timeout,
+		MaxRetries: dialData.Retries,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("DialDB: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("DialDB: ReadAll: %v", err)
-		}
-		bodyString := string(bodyBytes)
-		return nil, fmt.Errorf("DialDB: Status: %s - %s", resp.Status, bodyString)
+	_, err = c.Home(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("DialDB: %v", err)
 	}
 	infoLog.Printf("DialDB: API database checked")
 	// valid URL and API running
-	return &API{Url: dialData.URL}, nil
+	return &API{Url: dialData.URL, Client: c, OperationTimeout: dialData.OperationTimeout}, nil
 }
 
 func CloseDB(infoLog *log.Logger, db *API) error {
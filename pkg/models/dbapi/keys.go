@@ -0,0 +1,22 @@
+package dbapi
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+)
+
+// KeyModel define type which wraps a API middleware connection to the token keys
+type KeyModel struct {
+	Db API
+}
+
+func NewKeyModel(d *API) *KeyModel {
+	return &KeyModel{Db: *d}
+}
+
+// Get returns the JSON Web Key Set published by the API to verify its tokens
+func (m *KeyModel) Get() (*models.JWKSet, error) {
+	ctx, cancel := m.Db.operation(context.Background())
+	defer cancel()
+	return m.Db.Client.GetKeys(ctx)
+}
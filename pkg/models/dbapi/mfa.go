@@ -0,0 +1,58 @@
+package dbapi
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+)
+
+// VerifyMFA completes the login of the challenge with a TOTP or recovery code and returns the
+// JSON Web Token (JWT) and the refresh token of the user
+func (m *UserModel) VerifyMFA(ctx context.Context, challenge, code string) (*models.TokenMessage, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.VerifyMFA(ctx, challenge, code)
+}
+
+// EnrollMFAChallenge starts the enrollment required to complete the login of the challenge
+func (m *UserModel) EnrollMFAChallenge(ctx context.Context, challenge string) (*models.MFAEnrollment, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.EnrollMFAChallenge(ctx, challenge)
+}
+
+// GetMFA retrieves the two-factor authentication status of the user with the given id
+func (m *UserModel) GetMFA(ctx context.Context, token string, id int) (*models.MFAStatus, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.GetMFA(ctx, token, id)
+}
+
+// EnrollMFA starts the two-factor authentication enrollment of the user with the given id
+func (m *UserModel) EnrollMFA(ctx context.Context, token string, id int) (*models.MFAEnrollment, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.EnrollMFA(ctx, token, id)
+}
+
+// ConfirmMFA enables the two-factor authentication of the user with the given id,
+// the returned recovery codes are only available on this call
+func (m *UserModel) ConfirmMFA(ctx context.Context, token string, id int, code string) ([]string, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.ConfirmMFA(ctx, token, id, code)
+}
+
+// DisableMFA disables the two-factor authentication of the user with the given id,
+// the code is not required when an administrator disables other user
+func (m *UserModel) DisableMFA(ctx context.Context, token string, id int, code string) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.DisableMFA(ctx, token, id, code)
+}
+
+// SetRoleMFA defines if the users of the role type with roleID must use two-factor authentication
+func (m *UserModel) SetRoleMFA(ctx context.Context, token string, roleID int, required bool) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.SetRoleMFA(ctx, token, roleID, required)
+}
@@ -1,143 +1,141 @@
 package dbapi
 
 import (
-	"bytes"
-	"fmt"
+	"context"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/client"
 	"github.com/vgraveto/snippets/pkg/models"
-	"io/ioutil"
-	"log"
-	"net/http"
+	"strconv"
+	"sync"
 )
 
+// the number of responses kept by the conditional cache of a SnippetModel
+const etagsSize = 100
+
+// the key of the latest snippets on the conditional cache, the snippets use their id
+const latestKey = "latest"
+
 // SnippetModel define type which wraps a API middleware connection to the database
 type SnippetModel struct {
 	Db API
+	// the last responses of the reads, revalidated with their ETag on each read
+	etags *etagCache
 }
 
 func NewSnippetModel(d *API) *SnippetModel {
-	return &SnippetModel{Db: *d}
+	return &SnippetModel{Db: *d, etags: &etagCache{entries: map[string]etagEntry{}}}
 }
 
 // Get will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-
-	// build the request URL
-	url := fmt.Sprintf("%s/snippets/%d", m.Db.Url, id)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	if m.etags == nil {
+		return m.Db.Client.GetSnippet(ctx, id)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			//			log.Printf("SnippetUnauthenticatedModel: Error body: %v\n",err)
-			return nil, err
-		}
-		bodyString := string(bodyBytes)
-		log.Printf("SnippetModel: Status %d (%s): %s\n", resp.StatusCode, resp.Status, bodyString)
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, models.ErrNoRecord
-		} else {
-			return nil, fmt.Errorf("SnippetModel: Get: StatusCode %d (%s): %s",
-				resp.StatusCode, resp.Status, bodyString)
-		}
+	key := strconv.Itoa(id)
+	etag, cached := m.etags.get(key)
+	s, etag, err := m.Db.Client.GetSnippetIfNoneMatch(ctx, id, etag)
+	if errors.Is(err, client.ErrNotModified) {
+		cp := *cached.(*models.Snippet)
+		return &cp, nil
 	}
-
-	// retrive the snippet data from response body
-	s := &models.Snippet{}
-	err = models.FromJSON(s, resp.Body)
 	if err != nil {
-		//		log.Printf("SnippetUnauthenticatedModel: Deserializing: %v\n",err)
+		if errors.Is(err, models.ErrNoRecord) {
+			m.etags.delete(key)
+		}
 		return nil, err
 	}
+	cp := *s
+	m.etags.set(key, etag, &cp)
 	return s, nil
 }
 
 // Latest will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-
-	// build the request URL
-	url := fmt.Sprintf("%s/snippets", m.Db.Url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	if m.etags == nil {
+		return m.Db.Client.LatestSnippets(ctx)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		bodyString := string(bodyBytes)
-		//log.Printf("SnippetModel: Status %d: %s\n", resp.StatusCode, bodyString)
-		return nil, fmt.Errorf("SnippetModel: Get: Status: %s: %s", resp.Status, bodyString)
+	etag, cached := m.etags.get(latestKey)
+	snippets, etag, err := m.Db.Client.LatestSnippetsIfNoneMatch(ctx, etag)
+	if errors.Is(err, client.ErrNotModified) {
+		return copySnippets(cached.([]*models.Snippet)), nil
 	}
-
-	// retrive the snippets data from response body
-	var snippets []*models.Snippet
-	err = models.FromJSON(&snippets, resp.Body)
 	if err != nil {
-		//		log.Printf("SnippetUnauthenticatedModel: Deserializing: %v\n",err)
 		return nil, err
 	}
+	m.etags.set(latestKey, etag, copySnippets(snippets))
 	return snippets, nil
 }
 
 // Insert will insert a new snippet into the database and return its id
-func (m *SnippetModel) Insert(token, title, content, expires string) (int, error) {
-	// build the request URL
-	url := fmt.Sprintf("%s/snippets", m.Db.Url)
-	// build de request body
-	body := models.SnippetCreate{
+func (m *SnippetModel) Insert(ctx context.Context, token, title, content, expires string) (int, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	s, err := m.Db.Client.CreateSnippet(ctx, token, &models.SnippetCreate{
 		Title:   title,
 		Content: content,
 		Expires: expires,
-	}
-	var bd bytes.Buffer
-	err := models.ToJSON(body, &bd)
-	if err != nil {
-		return -1, fmt.Errorf("SnippetModel: Insert: Serialization: %v", err)
-	}
-	req, err := http.NewRequest(http.MethodPost, url, &bd)
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("Authentication", token)
-	// execute the request and get the response
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	})
 	if err != nil {
 		return -1, err
 	}
-	defer resp.Body.Close()
+	return s.ID, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return -1, err
-		}
-		bodyString := string(bodyBytes)
-		log.Printf("SnippetModel: Status %d (%s): %s\n", resp.StatusCode, resp.Status, bodyString)
-		if resp.StatusCode == http.StatusBadRequest {
-			return -1, models.ErrBadRequest
-		} else if resp.StatusCode == http.StatusUnauthorized {
-			return -1, models.ErrUnauthorizedToken
-		} else if resp.StatusCode == http.StatusForbidden {
-			return -1, models.ErrForbiddenToken
-		} else if resp.StatusCode == http.StatusUnprocessableEntity {
-			return -1, models.ErrValidation
-		} else {
-			return -1, fmt.Errorf("SnippetModel: Insert: StatusCode %d (%s): %s",
-				resp.StatusCode, resp.Status, bodyString)
-		}
+// copySnippets returns a copy of the snippets, so the cached ones are never changed by the callers
+func copySnippets(snippets []*models.Snippet) []*models.Snippet {
+	cp := make([]*models.Snippet, 0, len(snippets))
+	for _, s := range snippets {
+		c := *s
+		cp = append(cp, &c)
 	}
+	return cp
+}
 
-	// retrieve the snippet data from response body
-	s := &models.Snippet{}
-	err = models.FromJSON(s, resp.Body)
-	if err != nil {
-		return -1, err
+// etagCache keeps the last response of the reads of the snippets with its ETag, the reads send it
+// on the If-None-Match header and the API answers with a 304 without body when it did not change
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+// etagEntry is a response and its ETag, the value is never changed once cached
+type etagEntry struct {
+	etag  string
+	value interface{}
+}
+
+// get returns the ETag and the value of the key, an empty ETag when it is not cached
+func (c *etagCache) get(key string) (string, interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[key]
+	return e.etag, e.value
+}
+
+// set keeps the value of the key with its ETag, an entry is dropped when the cache is full.
+// The responses without an ETag are not cached.
+func (c *etagCache) set(key, etag string, value interface{}) {
+	if etag == "" {
+		return
 	}
-	return s.ID, nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= etagsSize {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = etagEntry{etag: etag, value: value}
+}
+
+// delete removes the key, it is called when the snippet is no longer found
+func (c *etagCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
 }
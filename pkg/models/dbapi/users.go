@@ -1,12 +1,9 @@
 package dbapi
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"github.com/vgraveto/snippets/pkg/models"
-	"io/ioutil"
-	"net/http"
-	"regexp"
 )
 
 // UserModel define type which wraps a API middleware connection to the database
@@ -18,294 +15,182 @@ func NewUserModel(d *API) *UserModel {
 	return &UserModel{Db: *d}
 }
 
+// WithClient returns a copy of the model that forwards the IP address and the user agent
+// of the client to the API
+func (m *UserModel) WithClient(ip, userAgent string) models.APIUsers {
+	c := *m
+	c.Db.Client = m.Db.Client.WithClient(ip, userAgent)
+	return &c
+}
+
 // Authenticate method to verify whether a user exists with the provided email address and password.
-// This will return a JSON Web Token (JWT) for the relevant user if they do.
-func (m *UserModel) Authenticate(email, password string) (token string, err error) {
-	// build the request URL
-	urlRequest := fmt.Sprintf("%s/users/login", m.Db.Url)
-	// build de request body
-	body := models.LoginUser{
-		Username: email,
-		Password: password,
-	}
-	var bd bytes.Buffer
-	err = models.ToJSON(body, &bd)
-	if err != nil {
-		return "", fmt.Errorf("AuthenticateJWT: Serialization: %v", err)
-	}
-	resp, err := http.Post(urlRequest, "application/json; charset=utf-8", &bd)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+// This will return the JSON Web Token (JWT) and the refresh token for the relevant user if they do,
+// or a *models.MFAChallenge as the error when the user must complete a two-factor authentication,
+// or a *models.LoginLockedError when the logins are blocked after too many failures.
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (*models.TokenMessage, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.Login(ctx, email, password)
+}
+
+// AuthenticateOIDC exchanges the ID token of the OpenID Connect provider and the nonce of its
+// authorization request for the JSON Web Token (JWT) and the refresh token of the user linked to it
+func (m *UserModel) AuthenticateOIDC(ctx context.Context, idToken, nonce string) (*models.TokenMessage, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.LoginOIDC(ctx, idToken, nonce)
+}
+
+// RefreshToken exchanges the refresh token for a new JWT and a new refresh token
+func (m *UserModel) RefreshToken(ctx context.Context, refreshToken string) (*models.TokenMessage, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.RefreshToken(ctx, refreshToken)
+}
+
+// ForgotPassword requests the API to send a password reset link to the email of the user
+func (m *UserModel) ForgotPassword(ctx context.Context, email string) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.ForgotPassword(ctx, email)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("AuthenticateJWT: ReadAll: %v", err)
-		}
-		bodyString := string(bodyBytes)
-		//log.Printf("UserModel: Status %d (%s): %s\n", resp.StatusCode, resp.Status, bodyString)
-		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusUnprocessableEntity {
-			return "", models.ErrInvalidCredentials
-		} else {
-			return "", fmt.Errorf("AuthenticateJWT: StatusCode %d -(%s): %s",
-				resp.StatusCode, resp.Status, bodyString)
-		}
-	}
-	tokenMsg := &models.TokenMessage{}
-	err = models.FromJSON(tokenMsg, resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("AuthenticateJWT: Deserialization: %v", err)
-	}
-	return tokenMsg.Token, nil
+// ResetPasswordWithToken changes the password of the user that received the reset token by email
+func (m *UserModel) ResetPasswordWithToken(ctx context.Context, token, newPassword string) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.ResetPassword(ctx, token, newPassword)
 }
 
-var reDuplicatedEmail = regexp.MustCompile(`duplicate email`)
+// Register method used for the public registration of a new user.
+// Returns the API message describing how the new account will be activated.
+func (m *UserModel) Register(ctx context.Context, name, email, password string) (string, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.Register(ctx, &models.RegisterUser{
+		Name:     name,
+		Email:    email,
+		Password: password,
+	})
+}
+
+// VerifyEmail activates the registered user that received the verification token by email
+func (m *UserModel) VerifyEmail(ctx context.Context, token string) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.VerifyEmail(ctx, token)
+}
 
 // Insert method used to add a new record to the users table.
-func (m *UserModel) Insert(token, name, email, password string, roles []int) error {
-	// build the request URL
-	url := fmt.Sprintf("%s/users", m.Db.Url)
-	// build de request body
-	body := models.CreateUser{
+func (m *UserModel) Insert(ctx context.Context, token, name, email, password string, roles []int) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.CreateUser(ctx, token, &models.CreateUser{
 		Name:     name,
 		Email:    email,
 		Password: password,
 		Roles:    roles,
-	}
-	var bd bytes.Buffer
-	err := models.ToJSON(body, &bd)
-	if err != nil {
-		return fmt.Errorf("UserModel: Insert: Serialization: %v", err)
-	}
-	req, err := http.NewRequest(http.MethodPost, url, &bd)
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("Authentication", token)
-	// execute the request and get the response
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		bodyString := string(bodyBytes)
-		//log.Printf("UserModel: Status %d (%): %s\n",resp.StatusCode,resp.Status,bodyString)
-		if resp.StatusCode == http.StatusBadRequest {
-			duplicatedEmail := reDuplicatedEmail.FindAllString(bodyString, -1)
-			if duplicatedEmail == nil {
-				return models.ErrBadRequest
-			}
-			return models.ErrDuplicateEmail
-		} else if resp.StatusCode == http.StatusUnauthorized {
-			return models.ErrUnauthorizedToken
-		} else if resp.StatusCode == http.StatusForbidden {
-			return models.ErrForbiddenToken
-		} else if resp.StatusCode == http.StatusUnprocessableEntity {
-			return models.ErrValidation
-		} else {
-			return fmt.Errorf("UserModel: Insert: StatusCode %d (%s): %s",
-				resp.StatusCode, resp.Status, bodyString)
-		}
-	}
-	/*
-		// retrieve the message data from response body
-		msg := &models.GenericMessage{}
-		err = models.FromJSON(msg, resp.Body)
-		if err!=nil {
-			return err
-		}
-		log.Printf("UserModel: Insert: %q\n", msg.Message)
-	*/
-	return nil
+	})
 }
 
 // GetAll will return all the created users.
-func (m *UserModel) GetAll(token string) ([]*models.User, error) {
-	// build the request URL
-	url := fmt.Sprintf("%s/users", m.Db.Url)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("Authentication", token)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		bodyString := string(bodyBytes)
-		// log.Printf("UserModel: Status %d (%): %s\n", resp.StatusCode, resp.Status, bodyString)
-		if resp.StatusCode == http.StatusUnauthorized {
-			return nil, models.ErrUnauthorizedToken
-		} else if resp.StatusCode == http.StatusForbidden {
-			return nil, models.ErrForbiddenToken
-		} else {
-			return nil, fmt.Errorf("UserModel: GetAll: Status: %s: %s",
-				resp.Status, bodyString)
-		}
-	}
-
-	// retrive the snippets data from response body
-	var users []*models.User
-	err = models.FromJSON(&users, resp.Body)
-	if err != nil {
-		//		log.Printf("SnippetUnauthenticatedModel: Deserializing: %v\n",err)
-		return nil, err
-	}
-	return users, nil
+func (m *UserModel) GetAll(ctx context.Context, token string) ([]*models.User, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.GetUsers(ctx, token)
 }
 
 // Get method used to fetch details for a specific user based on their user ID.
-func (m *UserModel) Get(token string, id int) (*models.User, error) {
-	// build the request URL
-	url := fmt.Sprintf("%s/users/%d", m.Db.Url, id)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("Authentication", token)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		bodyString := string(bodyBytes)
-		// log.Printf("UserModel: Status %d (%): %s\n", resp.StatusCode, resp.Status, bodyString)
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, models.ErrNoRecord
-		} else if resp.StatusCode == http.StatusUnauthorized {
-			return nil, models.ErrUnauthorizedToken
-		} else if resp.StatusCode == http.StatusForbidden {
-			return nil, models.ErrForbiddenToken
-		} else {
-			return nil, fmt.Errorf("UserModel: Get: StatusCode %d (%s): %s",
-				resp.StatusCode, resp.Status, bodyString)
-		}
-	}
-
-	// retrieve the user data from response body
-	u := &models.User{}
-	err = models.FromJSON(u, resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return u, nil
+func (m *UserModel) Get(ctx context.Context, token string, id int) (*models.User, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.GetUser(ctx, token, id)
 }
 
-var reInvalidCredentials = regexp.MustCompile(`invalid credentials`)
-
 // ChangePassword given the user ID, the current and the new passwords
 // Verify current password to allow password change
-func (m *UserModel) ChangePassword(token string, id int, currentPassword, newPassword string) error {
-	// build the request URL
-	url := fmt.Sprintf("%s/users/%d/change-password", m.Db.Url, id)
-	// build de request body
-	body := models.ChangeUserPassword{
+func (m *UserModel) ChangePassword(ctx context.Context, token string, id int, currentPassword, newPassword string) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.ChangePassword(ctx, token, id, &models.ChangeUserPassword{
 		OldPassword: currentPassword,
 		NewPassword: newPassword,
-	}
-	var bd bytes.Buffer
-	err := models.ToJSON(body, &bd)
-	if err != nil {
-		return fmt.Errorf("UserModel: ChangePassword: Serialization: %v", err)
-	}
-	req, err := http.NewRequest(http.MethodPut, url, &bd)
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("Authentication", token)
-	// execute the request and get the response
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		bodyString := string(bodyBytes)
-		//log.Printf("UserModel: Status %d (%): %s\n",resp.StatusCode,resp.Status,bodyString)
-		if resp.StatusCode == http.StatusBadRequest {
-			invalidCredentials := reInvalidCredentials.FindAllString(bodyString, -1)
-			if invalidCredentials == nil {
-				return models.ErrBadRequest
-			}
-			return models.ErrInvalidCredentials
-		} else if resp.StatusCode == http.StatusUnauthorized {
-			return models.ErrUnauthorizedToken
-		} else if resp.StatusCode == http.StatusForbidden {
-			return models.ErrForbiddenToken
-		} else if resp.StatusCode == http.StatusUnprocessableEntity {
-			return models.ErrValidation
-		} else {
-			return fmt.Errorf("UserModel: ChangePassword: StatusCode %d (%s): %s",
-				resp.StatusCode, resp.Status, bodyString)
-		}
-	}
-	return nil
+	})
 }
 
 // GetRoleTypes retrieves the existing role types from the database
-func (m *UserModel) GetRoleTypes(token string) ([]*models.RoleType, error) {
-	// build the request URL
-	url := fmt.Sprintf("%s/users/role-types", m.Db.Url)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("Authentication", token)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+func (m *UserModel) GetRoleTypes(ctx context.Context, token string) ([]*models.RoleType, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.GetRoleTypes(ctx, token)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		bodyString := string(bodyBytes)
-		// log.Printf("UserModel: Status %d (%): %s\n", resp.StatusCode, resp.Status, bodyString)
-		if resp.StatusCode == http.StatusUnauthorized {
-			return nil, models.ErrUnauthorizedToken
-		} else if resp.StatusCode == http.StatusForbidden {
-			return nil, models.ErrForbiddenToken
-		} else {
-			return nil, fmt.Errorf("UserModel: GetRoleTypes: Status: %s: %s",
-				resp.Status, bodyString)
-		}
-	}
+// GetPending will return the registered users waiting for the approval of an administrator.
+func (m *UserModel) GetPending(ctx context.Context, token string) ([]*models.User, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.GetPendingUsers(ctx, token)
+}
 
-	// retrive the snippets data from response body
-	var roles []*models.RoleType
-	err = models.FromJSON(&roles, resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return roles, nil
+// Approve sends the administrator decision on the pending registration of the user with the given id
+func (m *UserModel) Approve(ctx context.Context, token string, id int, approved bool) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.ApproveUser(ctx, token, id, approved)
 }
 
 // GetRoles obtains the roles of the user with the given id
-func (m *UserModel) GetRoles(token string, id int) (*[]string, error) {
+func (m *UserModel) GetRoles(ctx context.Context, token string, id int) (*[]string, error) {
 	// TODO implement request
 	return nil, fmt.Errorf("UserModel: not implemented")
 }
+
+// Logout revokes the token and the refresh token of the user session
+func (m *UserModel) Logout(ctx context.Context, token, refreshToken string) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.Logout(ctx, token, refreshToken)
+}
+
+// GetAPIKeys retrieves the API keys of the user with the given id
+func (m *UserModel) GetAPIKeys(ctx context.Context, token string, id int) ([]*models.APIKey, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.GetAPIKeys(ctx, token, id)
+}
+
+// CreateAPIKey creates an API key for the user with the given id,
+// the returned message holds the only copy of the plain-text key
+func (m *UserModel) CreateAPIKey(ctx context.Context, token string, id int, ck *models.CreateAPIKey) (*models.NewAPIKeyMessage, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.CreateAPIKey(ctx, token, id, ck)
+}
+
+// RevokeAPIKey revokes the API key with keyID of the user with the given id
+func (m *UserModel) RevokeAPIKey(ctx context.Context, token string, id, keyID int) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.RevokeAPIKey(ctx, token, id, keyID)
+}
+
+// UnlockUser removes the lockout and the failed logins of the user with the given id
+func (m *UserModel) UnlockUser(ctx context.Context, token string, id int) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.UnlockUser(ctx, token, id)
+}
+
+// GetSessions retrieves the active sessions of the user with the given id
+func (m *UserModel) GetSessions(ctx context.Context, token string, id int) ([]*models.Session, error) {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.GetSessions(ctx, token, id)
+}
+
+// RevokeSession signs out the session with sessionID of the user with the given id
+func (m *UserModel) RevokeSession(ctx context.Context, token string, id, sessionID int) error {
+	ctx, cancel := m.Db.operation(ctx)
+	defer cancel()
+	return m.Db.Client.RevokeSession(ctx, token, id, sessionID)
+}
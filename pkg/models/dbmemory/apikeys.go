@@ -0,0 +1,117 @@
+package dbmemory
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"sort"
+	"time"
+)
+
+// apiKey is the row of the apiKeys table, the zero revoked time is a key not revoked
+type apiKey struct {
+	models.APIKey
+	hash    string
+	revoked time.Time
+}
+
+// copy returns a copy of the key that does not share the scopes and times with the table
+func (k *apiKey) copy() *models.APIKey {
+	c := k.APIKey
+	c.Scopes = append([]string{}, k.Scopes...)
+	if k.Expires != nil {
+		t := *k.Expires
+		c.Expires = &t
+	}
+	if k.LastUsed != nil {
+		t := *k.LastUsed
+		c.LastUsed = &t
+	}
+	return &c
+}
+
+// APIKeyModel type which wraps the in-memory tables.
+type APIKeyModel struct {
+	db *DB
+}
+
+// NewAPIKeyModel creates a new APIKeyModel
+func NewAPIKeyModel(d *DB) *APIKeyModel {
+	return &APIKeyModel{db: d}
+}
+
+// Insert creates a key for the user and returns it with its plain-text value, only its hash is stored
+func (m *APIKeyModel) Insert(ctx context.Context, userID int, name string, scopes []string, expires *time.Time) (*models.APIKey, string, error) {
+	key, prefix, err := models.NewAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	k := &apiKey{
+		APIKey: models.APIKey{
+			ID:      m.db.nextID("apiKeys"),
+			UserID:  userID,
+			Name:    name,
+			Prefix:  prefix,
+			Scopes:  append([]string{}, scopes...),
+			Created: now(),
+		},
+		hash: models.HashToken(key),
+	}
+	if expires != nil {
+		t := expires.UTC()
+		k.Expires = &t
+	}
+	m.db.apiKeys[k.ID] = k
+	return k.copy(), key, nil
+}
+
+// GetAll returns the keys of the user that are not revoked, the most recent first
+func (m *APIKeyModel) GetAll(ctx context.Context, userID int) ([]*models.APIKey, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	keys := []*models.APIKey{}
+	for _, k := range m.db.apiKeys {
+		if k.UserID == userID && k.revoked.IsZero() {
+			keys = append(keys, k.copy())
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID > keys[j].ID
+	})
+	return keys, nil
+}
+
+// Revoke revokes the key of the user, ErrNoRecord is returned when the key is unknown
+func (m *APIKeyModel) Revoke(ctx context.Context, userID, id int) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	k, ok := m.db.apiKeys[id]
+	if !ok || k.UserID != userID || !k.revoked.IsZero() {
+		return models.ErrNoRecord
+	}
+	k.revoked = now()
+	return nil
+}
+
+// Authenticate returns the valid key with the plain-text value and updates its last used time
+func (m *APIKeyModel) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	hash := models.HashToken(key)
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	t := now()
+	for _, k := range m.db.apiKeys {
+		if k.hash != hash {
+			continue
+		}
+		if !k.revoked.IsZero() || (k.Expires != nil && !k.Expires.After(t)) {
+			break
+		}
+		// the key is returned with its previous last used time as on the other databases
+		c := k.copy()
+		k.LastUsed = &t
+		return c, nil
+	}
+	return nil, models.ErrInvalidAPIKey
+}
@@ -0,0 +1,72 @@
+package dbmemory
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+)
+
+// AuditModel type which wraps the in-memory tables, the events are only appended.
+type AuditModel struct {
+	db *DB
+}
+
+// NewAuditModel creates a new AuditModel
+func NewAuditModel(d *DB) *AuditModel {
+	return &AuditModel{db: d}
+}
+
+// maxAuditDetailsLen is the size of the details column of the other databases
+const maxAuditDetailsLen = 1024
+
+// Insert appends the event to the log, the ID and the created time of the event are set by the log
+func (m *AuditModel) Insert(ctx context.Context, e *models.AuditEvent) error {
+	c := *e
+	if len(c.Details) > maxAuditDetailsLen {
+		c.Details = c.Details[:maxAuditDetailsLen]
+	}
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	c.ID = m.db.nextID("auditLog")
+	c.Created = now()
+	m.db.audit = append(m.db.audit, &c)
+	return nil
+}
+
+// matches returns true when the event matches the filter
+func matches(e *models.AuditEvent, f *models.AuditFilter) bool {
+	switch {
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case f.ActorID > 0 && e.ActorID != f.ActorID:
+		return false
+	case f.TargetType != "" && e.TargetType != f.TargetType:
+		return false
+	case f.TargetID > 0 && e.TargetID != f.TargetID:
+		return false
+	case !f.From.IsZero() && e.Created.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.Created.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// GetAll returns the page of the events matching the filter, the most recent first
+func (m *AuditModel) GetAll(ctx context.Context, f *models.AuditFilter) (*models.AuditPage, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	page := &models.AuditPage{Events: []*models.AuditEvent{}, Page: f.Page, PerPage: f.PerPage}
+	offset := (f.Page - 1) * f.PerPage
+	for i := len(m.db.audit) - 1; i >= 0; i-- {
+		e := m.db.audit[i]
+		if !matches(e, f) {
+			continue
+		}
+		if page.Total >= offset && len(page.Events) < f.PerPage {
+			c := *e
+			page.Events = append(page.Events, &c)
+		}
+		page.Total++
+	}
+	return page, nil
+}
@@ -0,0 +1,70 @@
+// Package dbmemory keeps the data of the models in memory, used for demos and integration tests.
+// The data is lost when the program ends.
+package dbmemory
+
+import (
+	"github.com/vgraveto/snippets/pkg/models"
+	"sync"
+	"time"
+)
+
+// DB holds the tables shared by the models, the models of a DB are safe for concurrent use
+type DB struct {
+	mu sync.RWMutex
+	// the last ID of each table
+	ids map[string]int
+
+	snippets   map[int]*models.Snippet
+	users      map[int]*user
+	emails     map[string]int // the ID of the user of each email
+	roleTypes  []*models.RoleType
+	identities map[identity]int // the ID of the user linked to each identity
+
+	userTokens    map[string]*userToken    // by token hash
+	refreshTokens map[string]*refreshToken // by token hash
+	denylist      map[string]time.Time     // the expiration of each token ID
+	sessions      map[int]*session
+	apiKeys       map[int]*apiKey
+	mfa           map[int]*mfa
+	challenges    map[string]*challenge // by challenge hash
+	audit         []*models.AuditEvent
+}
+
+// New returns an empty DB with the role types of the administrators and of the registered users
+func New() *DB {
+	db := &DB{
+		ids:           map[string]int{},
+		snippets:      map[int]*models.Snippet{},
+		users:         map[int]*user{},
+		emails:        map[string]int{},
+		identities:    map[identity]int{},
+		userTokens:    map[string]*userToken{},
+		refreshTokens: map[string]*refreshToken{},
+		denylist:      map[string]time.Time{},
+		sessions:      map[int]*session{},
+		apiKeys:       map[int]*apiKey{},
+		mfa:           map[int]*mfa{},
+		challenges:    map[string]*challenge{},
+	}
+	for _, rt := range []models.RoleType{
+		{Role: models.AministratorRole, Description: "Manages the users of the application"},
+		{Role: "user", Description: "Creates and reads snippets"},
+	} {
+		rt := rt
+		rt.ID = db.nextID("roleTypes")
+		rt.Created = now()
+		db.roleTypes = append(db.roleTypes, &rt)
+	}
+	return db
+}
+
+// nextID returns a new ID of the table, the caller holds the write lock
+func (db *DB) nextID(table string) int {
+	db.ids[table]++
+	return db.ids[table]
+}
+
+// now returns the current time on UTC as stored by the other databases
+func now() time.Time {
+	return time.Now().UTC()
+}
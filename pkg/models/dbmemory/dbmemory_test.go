@@ -0,0 +1,56 @@
+package dbmemory
+
+import (
+	"github.com/vgraveto/snippets/pkg/models"
+	"github.com/vgraveto/snippets/pkg/models/conformance"
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+func TestSnippetModel(t *testing.T) {
+	conformance.Snippets(t, NewSnippetModel(New()))
+}
+
+func TestUserModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conformance.Users(t, NewUserModel(New(), h, log.New(ioutil.Discard, "", 0)))
+}
+
+func TestUserTokenModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := New()
+	conformance.UserTokens(t, NewUserTokenModel(db), NewUserModel(db, h, log.New(ioutil.Discard, "", 0)))
+}
+
+func TestRefreshTokenModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := New()
+	conformance.RefreshTokens(t, NewRefreshTokenModel(db), NewUserModel(db, h, log.New(ioutil.Discard, "", 0)))
+}
+
+func TestMFAModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := New()
+	conformance.MFA(t, NewMFAModel(db), NewUserModel(db, h, log.New(ioutil.Discard, "", 0)))
+}
@@ -0,0 +1,137 @@
+package dbmemory
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"time"
+)
+
+// refreshToken is the row of the refreshTokens table, the zero revoked time is a token not revoked
+type refreshToken struct {
+	userID    int
+	sessionID int
+	family    string
+	expires   time.Time
+	revoked   time.Time
+}
+
+// RefreshTokenModel type which wraps the in-memory tables.
+type RefreshTokenModel struct {
+	db *DB
+}
+
+// NewRefreshTokenModel creates a new RefreshTokenModel
+func NewRefreshTokenModel(d *DB) *RefreshTokenModel {
+	return &RefreshTokenModel{db: d}
+}
+
+// New issues a refresh token for the session of the user starting a new family and returns its plain-text value
+func (m *RefreshTokenModel) New(ctx context.Context, userID, sessionID int, validTime time.Duration) (string, error) {
+	family, err := models.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	return m.insert(userID, sessionID, family, validTime)
+}
+
+// insert adds a refresh token of the family and returns its plain-text value. The caller holds the lock.
+func (m *RefreshTokenModel) insert(userID, sessionID int, family string, validTime time.Duration) (string, error) {
+	token, err := models.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+	m.db.refreshTokens[models.HashToken(token)] = &refreshToken{
+		userID:    userID,
+		sessionID: sessionID,
+		family:    family,
+		expires:   now().Add(validTime),
+	}
+	return token, nil
+}
+
+// revokeFamily revokes the tokens of the family and their session. The caller holds the lock.
+func (db *DB) revokeFamily(family string, sessionID int) {
+	t := now()
+	for _, rt := range db.refreshTokens {
+		if rt.family == family && rt.revoked.IsZero() {
+			rt.revoked = t
+		}
+	}
+	if s, ok := db.sessions[sessionID]; ok && s.revoked.IsZero() {
+		s.revoked = t
+	}
+}
+
+// Rotate revokes the refresh token and returns a new one of the same family, the user ID and the session ID.
+// The reuse of a revoked token revokes its family and its session, as the token may have been stolen.
+func (m *RefreshTokenModel) Rotate(ctx context.Context, token string, validTime time.Duration) (string, int, int, error) {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	rt, ok := m.db.refreshTokens[models.HashToken(token)]
+	if !ok {
+		return "", 0, 0, models.ErrInvalidToken
+	}
+	if !rt.revoked.IsZero() {
+		m.db.revokeFamily(rt.family, rt.sessionID)
+		return "", 0, 0, models.ErrInvalidToken
+	}
+	if !rt.expires.After(now()) {
+		return "", 0, 0, models.ErrInvalidToken
+	}
+
+	rt.revoked = now()
+	newToken, err := m.insert(rt.userID, rt.sessionID, rt.family, validTime)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return newToken, rt.userID, rt.sessionID, nil
+}
+
+// Revoke revokes the family of the refresh token of the user and its session,
+// ErrNoRecord is returned when the token is unknown or of another user
+func (m *RefreshTokenModel) Revoke(ctx context.Context, userID int, token string) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	rt, ok := m.db.refreshTokens[models.HashToken(token)]
+	if !ok || rt.userID != userID {
+		return models.ErrNoRecord
+	}
+	m.db.revokeFamily(rt.family, rt.sessionID)
+	return nil
+}
+
+// TokenDenylistModel type which wraps the in-memory tables.
+type TokenDenylistModel struct {
+	db *DB
+}
+
+// NewTokenDenylistModel creates a new TokenDenylistModel
+func NewTokenDenylistModel(d *DB) *TokenDenylistModel {
+	return &TokenDenylistModel{db: d}
+}
+
+// Add adds the ID of a revoked access token and removes the IDs of the expired ones
+func (m *TokenDenylistModel) Add(ctx context.Context, jti string, expires time.Time) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	t := now()
+	for id, exp := range m.db.denylist {
+		if exp.Before(t) {
+			delete(m.db.denylist, id)
+		}
+	}
+	if _, ok := m.db.denylist[jti]; !ok {
+		m.db.denylist[jti] = expires
+	}
+	return nil
+}
+
+// Contains returns true when the ID of the access token was revoked
+func (m *TokenDenylistModel) Contains(ctx context.Context, jti string) (bool, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	_, ok := m.db.denylist[jti]
+	return ok, nil
+}
@@ -0,0 +1,156 @@
+package dbmemory
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"time"
+)
+
+// mfa is the row of the userMFA table with the hashes of the recovery codes of the user
+type mfa struct {
+	secret   string
+	enabled  bool
+	lastStep int64
+	// the used codes are removed
+	recoveryCodes map[string]bool
+}
+
+// challenge is the row of the mfaChallenges table
+type challenge struct {
+	userID   int
+	expires  time.Time
+	attempts int
+}
+
+// MFAModel type which wraps the in-memory tables.
+type MFAModel struct {
+	db *DB
+}
+
+// NewMFAModel creates a new MFAModel
+func NewMFAModel(d *DB) *MFAModel {
+	return &MFAModel{db: d}
+}
+
+// Get returns the two-factor authentication of the user, ErrNoRecord when never enrolled
+func (m *MFAModel) Get(ctx context.Context, userID int) (*models.MFAStatus, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	u, ok := m.db.mfa[userID]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return &models.MFAStatus{
+		Secret:            u.secret,
+		LastStep:          u.lastStep,
+		Enabled:           u.enabled,
+		RecoveryCodesLeft: len(u.recoveryCodes),
+	}, nil
+}
+
+// Enroll stores a new secret for the user, the secret of an enabled two-factor authentication is kept
+func (m *MFAModel) Enroll(ctx context.Context, userID int, secret string) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	u, ok := m.db.mfa[userID]
+	if !ok {
+		m.db.mfa[userID] = &mfa{secret: secret, recoveryCodes: map[string]bool{}}
+		return nil
+	}
+	if !u.enabled {
+		u.secret = secret
+	}
+	return nil
+}
+
+// Enable confirms the enrollment and replaces the recovery codes of the user
+func (m *MFAModel) Enable(ctx context.Context, userID int, recoveryCodes []string) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	u, ok := m.db.mfa[userID]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	u.enabled = true
+	u.recoveryCodes = map[string]bool{}
+	for _, code := range recoveryCodes {
+		u.recoveryCodes[models.HashToken(models.NormalizeRecoveryCode(code))] = true
+	}
+	return nil
+}
+
+// Disable removes the two-factor authentication and the recovery codes of the user
+func (m *MFAModel) Disable(ctx context.Context, userID int) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	delete(m.db.mfa, userID)
+	return nil
+}
+
+// UseStep records the time step of a valid TOTP code, false when a code of the step, or a later one, was used
+func (m *MFAModel) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	u, ok := m.db.mfa[userID]
+	if !ok || u.lastStep >= step {
+		return false, nil
+	}
+	u.lastStep = step
+	return true, nil
+}
+
+// UseRecoveryCode marks the recovery code as used, false when the code is unknown or already used
+func (m *MFAModel) UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	u, ok := m.db.mfa[userID]
+	if !ok {
+		return false, nil
+	}
+	hash := models.HashToken(models.NormalizeRecoveryCode(code))
+	if !u.recoveryCodes[hash] {
+		return false, nil
+	}
+	delete(u.recoveryCodes, hash)
+	return true, nil
+}
+
+// NewChallenge creates a login challenge for the user and returns its plain-text value,
+// the expired challenges are removed
+func (m *MFAModel) NewChallenge(ctx context.Context, userID int, validTime time.Duration) (string, error) {
+	c, err := models.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	t := now()
+	for hash, ch := range m.db.challenges {
+		if !ch.expires.After(t) {
+			delete(m.db.challenges, hash)
+		}
+	}
+	m.db.challenges[models.HashToken(c)] = &challenge{userID: userID, expires: t.Add(validTime)}
+	return c, nil
+}
+
+// CheckChallenge counts an attempt and returns the user of the challenge,
+// ErrInvalidToken is returned when the challenge is unknown, expired or has no attempts left
+func (m *MFAModel) CheckChallenge(ctx context.Context, c string, maxAttempts int) (int, error) {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	ch, ok := m.db.challenges[models.HashToken(c)]
+	if !ok || !ch.expires.After(now()) || ch.attempts >= maxAttempts {
+		return 0, models.ErrInvalidToken
+	}
+	ch.attempts++
+	return ch.userID, nil
+}
+
+// DeleteChallenge removes the challenge after the login
+func (m *MFAModel) DeleteChallenge(ctx context.Context, c string) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	delete(m.db.challenges, models.HashToken(c))
+	return nil
+}
@@ -0,0 +1,111 @@
+package dbmemory
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"sort"
+	"time"
+)
+
+// session is the row of the userSessions table, the zero revoked time is an active session
+type session struct {
+	models.Session
+	revoked time.Time
+}
+
+// SessionModel type which wraps the in-memory tables.
+type SessionModel struct {
+	db *DB
+}
+
+// NewSessionModel creates a new SessionModel
+func NewSessionModel(d *DB) *SessionModel {
+	return &SessionModel{db: d}
+}
+
+// maxUserAgentLen is the size of the user_agent column of the other databases
+const maxUserAgentLen = 255
+
+// New starts a session of the user that expires after validTime and returns its ID
+func (m *SessionModel) New(ctx context.Context, userID int, userAgent, ip string, validTime time.Duration) (int, error) {
+	if len(userAgent) > maxUserAgentLen {
+		userAgent = userAgent[:maxUserAgentLen]
+	}
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	t := now()
+	s := &session{Session: models.Session{
+		ID:        m.db.nextID("sessions"),
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        ip,
+		Created:   t,
+		LastSeen:  t,
+		Expires:   t.Add(validTime),
+	}}
+	m.db.sessions[s.ID] = s
+	return s.ID, nil
+}
+
+// active returns true when the session is not revoked nor expired
+func (s *session) active(t time.Time) bool {
+	return s.revoked.IsZero() && s.Expires.After(t)
+}
+
+// Touch updates the last seen time and IP of the session and extends its expiration
+func (m *SessionModel) Touch(ctx context.Context, id int, ip string, validTime time.Duration) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	if s, ok := m.db.sessions[id]; ok && s.revoked.IsZero() {
+		t := now()
+		s.LastSeen = t
+		s.IP = ip
+		s.Expires = t.Add(validTime)
+	}
+	return nil
+}
+
+// GetAll returns the sessions of the user that are not revoked nor expired, the last seen first
+func (m *SessionModel) GetAll(ctx context.Context, userID int) ([]*models.Session, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	t := now()
+	sessions := []*models.Session{}
+	for _, s := range m.db.sessions {
+		if s.UserID == userID && s.active(t) {
+			c := s.Session
+			sessions = append(sessions, &c)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions, nil
+}
+
+// Revoke revokes the session of the user and its refresh tokens,
+// ErrNoRecord is returned when the session is unknown or already revoked
+func (m *SessionModel) Revoke(ctx context.Context, userID, id int) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	s, ok := m.db.sessions[id]
+	if !ok || s.UserID != userID || !s.revoked.IsZero() {
+		return models.ErrNoRecord
+	}
+	t := now()
+	s.revoked = t
+	for _, rt := range m.db.refreshTokens {
+		if rt.sessionID == id && rt.revoked.IsZero() {
+			rt.revoked = t
+		}
+	}
+	return nil
+}
+
+// Active returns true when the session is not revoked nor expired
+func (m *SessionModel) Active(ctx context.Context, id int) (bool, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	s, ok := m.db.sessions[id]
+	return ok && s.active(now()), nil
+}
@@ -0,0 +1,83 @@
+package dbmemory
+
+import (
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SnippetModel type which wraps the in-memory tables.
+type SnippetModel struct {
+	db *DB
+}
+
+// NewSnippetModel creates a new SnippetModel
+func NewSnippetModel(d *DB) *SnippetModel {
+	return &SnippetModel{db: d}
+}
+
+// Insert will insert a new snippet and return its id, expires is the number of days.
+func (m *SnippetModel) Insert(ctx context.Context, title, content, expires string) (int, error) {
+	days, err := strconv.Atoi(expires)
+	if err != nil {
+		return -1, fmt.Errorf("Insert: invalid expires %q: %v", expires, err)
+	}
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	// the titles are unique as on the snippets table
+	for _, s := range m.db.snippets {
+		if s.Title == title {
+			return -1, fmt.Errorf("Insert: duplicate snippet title %q", title)
+		}
+	}
+	created := now()
+	s := &models.Snippet{
+		ID:      m.db.nextID("snippets"),
+		Title:   title,
+		Content: content,
+		Created: created,
+		Expires: created.Add(time.Duration(days) * 24 * time.Hour),
+	}
+	m.db.snippets[s.ID] = s
+	return s.ID, nil
+}
+
+// Get will return a specific snippet based on its id.
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	s, ok := m.db.snippets[id]
+	if !ok || !s.Expires.After(now()) {
+		return nil, models.ErrNoRecord
+	}
+	c := *s
+	return &c, nil
+}
+
+// Latest will return the 10 most recently created snippets.
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	t := now()
+	snippets := []*models.Snippet{}
+	for _, s := range m.db.snippets {
+		if s.Expires.After(t) {
+			c := *s
+			snippets = append(snippets, &c)
+		}
+	}
+	// the IDs order the snippets created on the same instant
+	sort.Slice(snippets, func(i, j int) bool {
+		if !snippets[i].Created.Equal(snippets[j].Created) {
+			return snippets[i].Created.After(snippets[j].Created)
+		}
+		return snippets[i].ID > snippets[j].ID
+	})
+	if len(snippets) > 10 {
+		snippets = snippets[:10]
+	}
+	return snippets, nil
+}
@@ -0,0 +1,387 @@
+package dbmemory
+
+import (
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"log"
+	"time"
+)
+
+// user is the row of the users table with the IDs of its roles on the order they were granted
+type user struct {
+	id              int
+	name            string
+	email           string
+	hashedPassword  string
+	created         time.Time
+	active          bool
+	approvalPending bool
+	roles           []int
+}
+
+// identity is the subject of an OpenID Connect issuer
+type identity struct {
+	issuer  string
+	subject string
+}
+
+// UserModel type which wraps the in-memory tables and the hasher of the passwords.
+type UserModel struct {
+	db       *DB
+	hasher   models.PasswordHasher
+	errorLog *log.Logger
+}
+
+// NewUserModel creates a new UserModel, the new passwords are hashed by h and the failed
+// upgrades of the outdated hashes are logged to errorLog
+func NewUserModel(d *DB, h models.PasswordHasher, errorLog *log.Logger) *UserModel {
+	return &UserModel{db: d, hasher: h, errorLog: errorLog}
+}
+
+// Insert method used to add a new active user with its roles
+func (m *UserModel) Insert(ctx context.Context, name, email, password string, roles []int) error {
+	_, err := m.insert(name, email, password, roles, true, false)
+	return err
+}
+
+// Register method used to add a new inactive user that registered himself, the user waits for
+// the approval of an administrator when approvalPending is true. Returns the ID of the new user.
+func (m *UserModel) Register(ctx context.Context, name, email, password string, roles []int, approvalPending bool) (int, error) {
+	return m.insert(name, email, password, roles, false, approvalPending)
+}
+
+// insert adds the user and its roles and returns the ID of the new user, nothing is
+// added when the email is taken or a role is unknown
+func (m *UserModel) insert(name, email, password string, roles []int, active, approvalPending bool) (int, error) {
+	// hash outside of the lock, it is slow on purpose
+	hashedPassword, err := m.hasher.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	if _, ok := m.db.emails[email]; ok {
+		return 0, models.ErrDuplicateEmail
+	}
+	for _, role := range roles {
+		if m.db.roleType(role) == nil {
+			return 0, fmt.Errorf("Insert: unknown role %d", role)
+		}
+	}
+	u := &user{
+		id:              m.db.nextID("users"),
+		name:            name,
+		email:           email,
+		hashedPassword:  hashedPassword,
+		created:         now(),
+		active:          active,
+		approvalPending: approvalPending,
+		roles:           append([]int{}, roles...),
+	}
+	m.db.users[u.id] = u
+	m.db.emails[email] = u.id
+	return u.id, nil
+}
+
+// roleType returns the role type with the given id, nil when unknown. The caller holds the lock.
+func (db *DB) roleType(id int) *models.RoleType {
+	for _, rt := range db.roleTypes {
+		if rt.ID == id {
+			return rt
+		}
+	}
+	return nil
+}
+
+// toUser returns a copy of the user with the names of its roles. The caller holds the lock.
+func (db *DB) toUser(u *user) *models.User {
+	roles := []string{}
+	for _, id := range u.roles {
+		if rt := db.roleType(id); rt != nil {
+			roles = append(roles, rt.Role)
+		}
+	}
+	return &models.User{
+		ID:      u.id,
+		Name:    u.name,
+		Email:   u.email,
+		Roles:   roles,
+		Created: u.created,
+		Active:  u.active,
+	}
+}
+
+// Activate method used to activate an user after the email verification
+func (m *UserModel) Activate(ctx context.Context, id int) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	if u, ok := m.db.users[id]; ok {
+		u.active = true
+		u.approvalPending = false
+	}
+	return nil
+}
+
+// GetPending will return the registered users waiting for the approval of an administrator.
+func (m *UserModel) GetPending(ctx context.Context) ([]*models.User, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	users := []*models.User{}
+	for id := 1; id <= m.db.ids["users"]; id++ {
+		if u, ok := m.db.users[id]; ok && u.approvalPending {
+			users = append(users, m.db.toUser(u))
+		}
+	}
+	return users, nil
+}
+
+// Approve method used by an administrator to decide on a pending registration, the user is
+// activated when approved, otherwise the registration is rejected and the user remains inactive
+func (m *UserModel) Approve(ctx context.Context, id int, approved bool) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	u, ok := m.db.users[id]
+	if !ok || !u.approvalPending {
+		return models.ErrNoRecord
+	}
+	u.active = approved
+	u.approvalPending = false
+	return nil
+}
+
+// Authenticate method to verify whether an active user exists with the provided email address and
+// password. This will return the relevant user ID if they do. The hash of the password is upgraded
+// when it does not use the current algorithm and parameters of the hasher.
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	m.db.mu.RLock()
+	id, ok := m.db.emails[email]
+	var hashedPassword string
+	if ok {
+		u := m.db.users[id]
+		ok = u.active
+		hashedPassword = u.hashedPassword
+	}
+	m.db.mu.RUnlock()
+	if !ok {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := m.hasher.Verify(hashedPassword, password)
+	if err != nil {
+		return 0, err
+	}
+
+	// a failed upgrade does not block the login as it is retried on the next one
+	if m.hasher.NeedsRehash(hashedPassword) {
+		if err := m.rehash(id, hashedPassword, password); err != nil {
+			m.errorLog.Printf("UserModel: rehash of the password of the user %d: %v\n", id, err)
+		}
+	}
+	return id, nil
+}
+
+// rehash replaces the outdated hash of the password of the user, unless it was changed meanwhile
+func (m *UserModel) rehash(id int, oldHash, password string) error {
+	newHash, err := m.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	if u, ok := m.db.users[id]; ok && u.hashedPassword == oldHash {
+		u.hashedPassword = newHash
+	}
+	return nil
+}
+
+// GetAll will return all the created users, the most recent first.
+func (m *UserModel) GetAll(ctx context.Context) ([]*models.User, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	users := []*models.User{}
+	for id := m.db.ids["users"]; id > 0; id-- {
+		if u, ok := m.db.users[id]; ok {
+			users = append(users, m.db.toUser(u))
+		}
+	}
+	return users, nil
+}
+
+// Get method used to fetch details for a specific user based on their user ID.
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	u, ok := m.db.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return m.db.toUser(u), nil
+}
+
+// GetByEmail method used to fetch details for a specific user based on their email address.
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	id, ok := m.db.emails[email]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return m.db.toUser(m.db.users[id]), nil
+}
+
+// ChangePassword given the user ID, the current and the new passwords
+// Verify current password to allow password change
+func (m *UserModel) ChangePassword(ctx context.Context, id int, currentPassword, newPassword string) error {
+	m.db.mu.RLock()
+	u, ok := m.db.users[id]
+	var currentHashedPassword string
+	if ok {
+		currentHashedPassword = u.hashedPassword
+	}
+	m.db.mu.RUnlock()
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	err := m.hasher.Verify(currentHashedPassword, currentPassword)
+	if err != nil {
+		return err
+	}
+	return m.ResetPassword(ctx, id, newPassword)
+}
+
+// ResetPassword given the user ID and the new passwords
+// Only used for administrator purpose
+func (m *UserModel) ResetPassword(ctx context.Context, id int, newPassword string) error {
+	newHashedPassword, err := m.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	if u, ok := m.db.users[id]; ok {
+		u.hashedPassword = newHashedPassword
+	}
+	return nil
+}
+
+// ResetPasswordByToken uses the reset password token and replaces the password of its user,
+// the token is left unused when the password is not changed
+func (m *UserModel) ResetPasswordByToken(ctx context.Context, token, newPassword string) (int, error) {
+	newHashedPassword, err := m.hasher.Hash(newPassword)
+	if err != nil {
+		return 0, err
+	}
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	ut, err := m.db.validToken(token, models.TokenPurposeResetPassword)
+	if err != nil {
+		return 0, err
+	}
+	u, ok := m.db.users[ut.userID]
+	if !ok {
+		return 0, models.ErrInvalidToken
+	}
+	ut.used = now()
+	u.hashedPassword = newHashedPassword
+	return u.id, nil
+}
+
+// GetRoleTypes obtains the existing role types
+func (m *UserModel) GetRoleTypes(ctx context.Context) ([]*models.RoleType, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	roles := []*models.RoleType{}
+	for _, rt := range m.db.roleTypes {
+		c := *rt
+		roles = append(roles, &c)
+	}
+	return roles, nil
+}
+
+// GetRoles obtains the roles of the user with the given id
+func (m *UserModel) GetRoles(ctx context.Context, id int) (*[]string, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	userRoles := []string{}
+	if u, ok := m.db.users[id]; ok {
+		userRoles = m.db.toUser(u).Roles
+	}
+	return &userRoles, nil
+}
+
+// AddRoles grants the roles the user does not have yet
+func (m *UserModel) AddRoles(ctx context.Context, id int, roles []int) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	u, ok := m.db.users[id]
+	if !ok {
+		return fmt.Errorf("AddRoles: unknown user %d", id)
+	}
+	for _, role := range roles {
+		if m.db.roleType(role) == nil {
+			return fmt.Errorf("AddRoles: unknown role %d", role)
+		}
+		granted := false
+		for _, r := range u.roles {
+			if r == role {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			u.roles = append(u.roles, role)
+		}
+	}
+	return nil
+}
+
+// Provision inserts an active user with a random password, the user logs in with
+// an OpenID Connect provider or sets a password with the forgot password flow
+func (m *UserModel) Provision(ctx context.Context, name, email string, roles []int) (int, error) {
+	password, err := models.NewRandomToken()
+	if err != nil {
+		return 0, err
+	}
+	return m.insert(name, email, password, roles, true, false)
+}
+
+// GetByIdentity method used to fetch the user linked to the subject of an OpenID Connect issuer
+func (m *UserModel) GetByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	id, ok := m.db.identities[identity{issuer: issuer, subject: subject}]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return m.db.toUser(m.db.users[id]), nil
+}
+
+// LinkIdentity links the user to the subject of an OpenID Connect issuer
+func (m *UserModel) LinkIdentity(ctx context.Context, id int, issuer, subject string) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	if _, ok := m.db.users[id]; !ok {
+		return fmt.Errorf("LinkIdentity: unknown user %d", id)
+	}
+	key := identity{issuer: issuer, subject: subject}
+	if _, ok := m.db.identities[key]; ok {
+		return fmt.Errorf("LinkIdentity: identity %s %s already linked", issuer, subject)
+	}
+	m.db.identities[key] = id
+	return nil
+}
+
+// SetRoleMFARequired defines if the users of the role must use two-factor authentication
+func (m *UserModel) SetRoleMFARequired(ctx context.Context, id int, required bool) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	rt := m.db.roleType(id)
+	if rt == nil {
+		return models.ErrNoRecord
+	}
+	rt.MFARequired = required
+	return nil
+}
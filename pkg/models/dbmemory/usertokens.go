@@ -0,0 +1,98 @@
+package dbmemory
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"time"
+)
+
+// userToken is the row of the userTokens table, the zero used time is an unused token
+type userToken struct {
+	userID  int
+	purpose string
+	expires time.Time
+	used    time.Time
+}
+
+// UserTokenModel type which wraps the in-memory tables.
+type UserTokenModel struct {
+	db *DB
+}
+
+// NewUserTokenModel creates a new UserTokenModel
+func NewUserTokenModel(d *DB) *UserTokenModel {
+	return &UserTokenModel{db: d}
+}
+
+// New creates a token for the user with the given purpose and valid time and returns its plain-text value.
+// Any previous unused token of the same user and purpose is invalidated.
+func (m *UserTokenModel) New(ctx context.Context, userID int, purpose string, validTime time.Duration) (string, error) {
+	token, err := models.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	t := now()
+	for hash, ut := range m.db.userTokens {
+		if ut.userID == userID && ut.purpose == purpose && ut.used.IsZero() {
+			ut.used = t
+		}
+		// the used tokens are never valid again, the expired ones are kept for HasUnused
+		if !ut.used.IsZero() {
+			delete(m.db.userTokens, hash)
+		}
+	}
+	m.db.userTokens[models.HashToken(token)] = &userToken{
+		userID:  userID,
+		purpose: purpose,
+		expires: t.Add(validTime),
+	}
+	return token, nil
+}
+
+// validToken returns the unused and not expired token of the purpose. The caller holds the lock.
+func (db *DB) validToken(token, purpose string) (*userToken, error) {
+	ut, ok := db.userTokens[models.HashToken(token)]
+	if !ok || ut.purpose != purpose || !ut.used.IsZero() || !ut.expires.After(now()) {
+		return nil, models.ErrInvalidToken
+	}
+	return ut, nil
+}
+
+// Consume validates the token for the given purpose, marks it as used and returns the ID of its user
+func (m *UserTokenModel) Consume(ctx context.Context, token, purpose string) (int, error) {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+	ut, err := m.db.validToken(token, purpose)
+	if err != nil {
+		return 0, err
+	}
+	ut.used = now()
+	return ut.userID, nil
+}
+
+// Peek validates the token for the given purpose and returns the ID of its user without using it
+func (m *UserTokenModel) Peek(ctx context.Context, token, purpose string) (int, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	ut, err := m.db.validToken(token, purpose)
+	if err != nil {
+		return 0, err
+	}
+	return ut.userID, nil
+}
+
+// HasUnused returns true when the last token of the purpose created for the user was not used,
+// even if it expired
+func (m *UserTokenModel) HasUnused(ctx context.Context, userID int, purpose string) (bool, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+	for _, ut := range m.db.userTokens {
+		if ut.userID == userID && ut.purpose == purpose && ut.used.IsZero() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,155 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+	"strings"
+	"time"
+)
+
+// APIKeyModel type which wraps a sql.DB connection pool.
+type APIKeyModel struct {
+	db *DB
+}
+
+// NewAPIKeyModel creates a new APIKeyModel
+func NewAPIKeyModel(d *DB) *APIKeyModel {
+	return &APIKeyModel{db: d}
+}
+
+// Insert creates a key for the user and returns it with its plain-text value.
+// Only the key hash is stored on the apiKeys table.
+func (m *APIKeyModel) Insert(ctx context.Context, userID int, name string, scopes []string, expires *time.Time) (*models.APIKey, string, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	key, prefix, err := models.NewAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var exp sql.NullTime
+	if expires != nil {
+		exp = sql.NullTime{Time: expires.UTC(), Valid: true}
+	}
+	stmt := "INSERT INTO apiKeys (iduser, name, prefix, key_hash, scopes, created, expires)" +
+		" VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?)"
+	result, err := m.db.ExecContext(ctx, stmt, userID, name, prefix, models.HashToken(key), strings.Join(scopes, ","), exp)
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	k, err := m.get(ctx, int(id))
+	if err != nil {
+		return nil, "", err
+	}
+	return k, key, nil
+}
+
+// apiKeyColumns are the columns scanned by scanAPIKey
+const apiKeyColumns = "id, iduser, name, prefix, scopes, created, expires, last_used"
+
+// scanAPIKey scans a row with the apiKeyColumns
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	k := &models.APIKey{}
+	var scopes string
+	var expires, lastUsed sql.NullTime
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &scopes, &k.Created, &expires, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+	k.Scopes = []string{}
+	if scopes != "" {
+		k.Scopes = strings.Split(scopes, ",")
+	}
+	if expires.Valid {
+		k.Expires = &expires.Time
+	}
+	if lastUsed.Valid {
+		k.LastUsed = &lastUsed.Time
+	}
+	return k, nil
+}
+
+// get returns the key with the given ID
+func (m *APIKeyModel) get(ctx context.Context, id int) (*models.APIKey, error) {
+	stmt := "SELECT " + apiKeyColumns + " FROM apiKeys WHERE id = ?"
+	k, err := scanAPIKey(m.db.QueryRowContext(ctx, stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return k, nil
+}
+
+// GetAll returns the keys of the user that are not revoked
+func (m *APIKeyModel) GetAll(ctx context.Context, userID int) ([]*models.APIKey, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "SELECT " + apiKeyColumns + " FROM apiKeys WHERE iduser = ? AND revoked IS NULL ORDER BY created DESC"
+	rows, err := m.db.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke revokes the key of the user
+func (m *APIKeyModel) Revoke(ctx context.Context, userID, id int) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "UPDATE apiKeys SET revoked = UTC_TIMESTAMP() WHERE id = ? AND iduser = ? AND revoked IS NULL"
+	result, err := m.db.ExecContext(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Authenticate returns the valid key with the plain-text value and updates its last used time
+func (m *APIKeyModel) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "SELECT " + apiKeyColumns + " FROM apiKeys" +
+		" WHERE key_hash = ? AND revoked IS NULL AND (expires IS NULL OR expires > UTC_TIMESTAMP())"
+	k, err := scanAPIKey(m.db.QueryRowContext(ctx, stmt, models.HashToken(key)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	_, err = m.db.ExecContext(ctx, "UPDATE apiKeys SET last_used = UTC_TIMESTAMP() WHERE id = ?", k.ID)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
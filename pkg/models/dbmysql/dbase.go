@@ -1,12 +1,10 @@
 package dbmysql
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/go-sql-driver/mysql"
-	"io/ioutil"
 	"log"
 	"time"
 )
@@ -22,52 +20,86 @@ type DBdata struct {
 	Dbase             string
 	Username          string
 	Password          string
-	ServerCA          string
-	ClientCert        string
+	TLSMode           string // "disable", "preferred", "verify-ca" or "verify-full"
+	ServerName        string // the name verified by "verify-full", the host of the Server when empty
+	ServerCA          string // the CA of the server certificate, the system roots when empty
+	ClientCert        string // the client certificate, loaded again when the file changes
 	ClientKey         string
 	DbConnMaxLifetime time.Duration // number of seconds
+	QueryTimeout      time.Duration // deadline of the queries of each operation of the models, zero disables it
+	MaxOpenConns      int           // the open connections of the pool, zero is unlimited
+	MaxIdleConns      int           // the idle connections kept by the pool
+	ConnMaxIdleTime   time.Duration // the idle connections are closed after this time, zero keeps them
+	RetryAttempts     int           // the attempts of a read that fails with a transient error, one disables the retries
+	RetryBackoff      time.Duration // the wait before the second attempt, doubled on each one
+	Replicas          []string      // host:port of the read replicas, reached with the credentials and TLS of the Server
+	MaxReplicaLag     time.Duration // the replicas further behind the primary are not read
+	ReplicaCheck      time.Duration // the time between the checks of the lag of the replicas
 }
 
-func RefreshDBConnection(infoLog *log.Logger, caller string, db *sql.DB) (err error) {
-	if err = db.Ping(); err != nil {
-		return fmt.Errorf("RefreshDBConnection: RefreshDBConnection by %q: %v", caller, err)
-	}
-	infoLog.Printf("RefreshDBConnection: by %q\n", caller)
-	return nil
+// DB is the connection pool shared by the models
+type DB struct {
+	*sql.DB
+	// the deadline of the queries of each operation of the models, zero disables it
+	QueryTimeout time.Duration
+	// the attempts of the reads that fail with transient errors and the wait before the second one
+	RetryAttempts int
+	RetryBackoff  time.Duration
+	// the read replicas of the snippets, nil without replicas
+	replicas *replicaSet
 }
 
-func DialDB(infoLog *log.Logger, dialData DBdata, certsPath, keysPath string) (db *sql.DB, err error) {
-	infoLog.Println("DialDB: loading certificates")
+// operation returns the context of an operation of a model, it is canceled with the context
+// of the request and after the QueryTimeout of the pool
+func (db *DB) operation(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.QueryTimeout)
+}
 
-	rootCertPool := x509.NewCertPool()
-	pem, err := ioutil.ReadFile(certsPath + dialData.ServerCA)
+func DialDB(infoLog *log.Logger, dialData DBdata, certsPath, keysPath string) (*DB, error) {
+	infoLog.Println("DialDB: dialing mysql database")
+	db, err := open(infoLog, dialData, tlsConfigName, certsPath, keysPath)
 	if err != nil {
 		return nil, fmt.Errorf("DialDB: %v\n", err)
 	}
-	if ok := rootCertPool.AppendCertsFromPEM(pem); !ok {
-		return nil, fmt.Errorf("dbase: Failed to append server PEM.")
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("DialDB: error on Ping: %v\n", err)
 	}
-	clientCert := make([]tls.Certificate, 0, 1)
-	if len(dialData.ClientCert) == 0 || len(dialData.ClientKey) == 0 {
-		infoLog.Printf("dbase: No client certificate specified - only server side certificate will be used\n")
-		clientCert = nil
-	} else {
-		certs, err := tls.LoadX509KeyPair(certsPath+dialData.ClientCert, keysPath+dialData.ClientKey)
+	infoLog.Println("DialDB: connection to database is OK")
+
+	d := &DB{
+		DB:            db,
+		QueryTimeout:  dialData.QueryTimeout,
+		RetryAttempts: dialData.RetryAttempts,
+		RetryBackoff:  dialData.RetryBackoff,
+	}
+	if len(dialData.Replicas) > 0 {
+		d.replicas, err = dialReplicas(infoLog, dialData, certsPath, keysPath)
 		if err != nil {
-			return nil, fmt.Errorf("dbase: %v\n", err)
+			db.Close()
+			return nil, fmt.Errorf("DialDB: %v\n", err)
 		}
-		clientCert = append(clientCert, certs)
 	}
-	err = mysql.RegisterTLSConfig(tlsConfigName, &tls.Config{
-		RootCAs:            rootCertPool,
-		Certificates:       clientCert,
-		InsecureSkipVerify: true, // TODO lack of security accept any certificate and host name provided by the server
-	})
+	return d, nil
+}
+
+// open returns the connection pool of the Server of the data, the TLS configuration is
+// registered with the name. The server is not reached until the pool is used.
+func open(infoLog *log.Logger, dialData DBdata, name, certsPath, keysPath string) (*sql.DB, error) {
+	infoLog.Printf("DialDB: loading certificates of %s for the %s tlsMode\n", dialData.Server, dialData.TLSMode)
+	tlsConfig, tlsName, err := dialData.tlsConfig(infoLog, certsPath, keysPath)
 	if err != nil {
-		return nil, fmt.Errorf("DialDB: RegisterTLSConfig: %v\n", err)
+		return nil, err
+	}
+	if tlsConfig != nil {
+		tlsName = name
+		if err = mysql.RegisterTLSConfig(tlsName, tlsConfig); err != nil {
+			return nil, fmt.Errorf("RegisterTLSConfig: %v", err)
+		}
 	}
-
-	infoLog.Println("DialDB: dialing mysql database")
 
 	cfg := mysql.NewConfig()
 	cfg.Net = dialData.Protocol
@@ -75,22 +107,23 @@ func DialDB(infoLog *log.Logger, dialData DBdata, certsPath, keysPath string) (d
 	cfg.User = dialData.Username
 	cfg.Passwd = dialData.Password
 	cfg.DBName = dialData.Dbase
-	cfg.TLSConfig = tlsConfigName
+	cfg.TLSConfig = tlsName
 	cfg.ParseTime = true
-	db, err = sql.Open("mysql", cfg.FormatDSN())
+	db, err := sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
-		return nil, fmt.Errorf("DialDB: %v\n", err)
+		return nil, err
 	}
 	db.SetConnMaxLifetime(dialData.DbConnMaxLifetime) // imposed for correct work of reconnection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("DialDB: error on Ping: %v\n", err)
-	}
-
-	infoLog.Println("DialDB: connection to database is OK")
-	return db, err
+	db.SetMaxOpenConns(dialData.MaxOpenConns)
+	db.SetMaxIdleConns(dialData.MaxIdleConns)
+	db.SetConnMaxIdleTime(dialData.ConnMaxIdleTime)
+	return db, nil
 }
 
-func CloseDB(infoLog *log.Logger, db *sql.DB) error {
+func CloseDB(infoLog *log.Logger, db *DB) error {
+	if db.replicas != nil {
+		db.replicas.close(infoLog)
+	}
 	err := db.Close()
 	if err != nil {
 		return fmt.Errorf("CloseDB: CloseDB: %v", err)
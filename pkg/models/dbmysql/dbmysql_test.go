@@ -0,0 +1,106 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/vgraveto/snippets/pkg/models"
+	"github.com/vgraveto/snippets/pkg/models/conformance"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newTestDB returns the database of the SNIPPETS_TEST_MYSQL_DSN environment variable, the pending
+// migrations are applied. The test is skipped when it is not set.
+func newTestDB(t *testing.T) *DB {
+	dsn := os.Getenv("SNIPPETS_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("SNIPPETS_TEST_MYSQL_DSN not set")
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ParseTime = true
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := NewMigrator(&DB{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return &DB{DB: db}
+}
+
+// the baseline of the databases built with the former dump is never reverted and the following
+// migrations create their own tables, so their Down only drops what they created
+func TestMigrations(t *testing.T) {
+	if len(migrations[0].Down) != 0 {
+		t.Error("want the baseline migration irreversible")
+	}
+	for _, m := range migrations[1:] {
+		if len(m.Down) == 0 {
+			t.Errorf("want a Down on migration %d %s", m.Version, m.Name)
+		}
+		for _, s := range m.Up {
+			if strings.Contains(s, "IF NOT EXISTS") {
+				t.Errorf("want migration %d %s to create its tables; got %.40q", m.Version, m.Name, s)
+			}
+		}
+	}
+}
+
+func TestSnippetModel(t *testing.T) {
+	conformance.Snippets(t, NewSnippetModel(newTestDB(t)))
+}
+
+func TestUserModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conformance.Users(t, NewUserModel(newTestDB(t), h, log.New(ioutil.Discard, "", 0)))
+}
+
+func TestUserTokenModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	conformance.UserTokens(t, NewUserTokenModel(db), NewUserModel(db, h, log.New(ioutil.Discard, "", 0)))
+}
+
+func TestRefreshTokenModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	conformance.RefreshTokens(t, NewRefreshTokenModel(db), NewUserModel(db, h, log.New(ioutil.Discard, "", 0)))
+}
+
+func TestMFAModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	conformance.MFA(t, NewMFAModel(db), NewUserModel(db, h, log.New(ioutil.Discard, "", 0)))
+}
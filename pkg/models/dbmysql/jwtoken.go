@@ -0,0 +1,182 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"time"
+)
+
+// RefreshTokenModel type which wraps a sql.DB connection pool.
+type RefreshTokenModel struct {
+	db *DB
+}
+
+// NewRefreshTokenModel creates a new RefreshTokenModel
+func NewRefreshTokenModel(d *DB) *RefreshTokenModel {
+	return &RefreshTokenModel{db: d}
+}
+
+// New issues a refresh token for the session of the user starting a new family and returns its plain-text value
+func (m *RefreshTokenModel) New(ctx context.Context, userID, sessionID int, validTime time.Duration) (string, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	family, err := models.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+	return m.insert(ctx, m.db, userID, sessionID, family, validTime)
+}
+
+// execer is implemented by both sql.DB and sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// insert stores the hash of a new refresh token of the given family and returns its plain-text value
+func (m *RefreshTokenModel) insert(ctx context.Context, ex execer, userID, sessionID int, family string, validTime time.Duration) (string, error) {
+	token, err := models.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+	var session sql.NullInt64
+	if sessionID > 0 {
+		session = sql.NullInt64{Int64: int64(sessionID), Valid: true}
+	}
+	stmt := "INSERT INTO refreshTokens (iduser, idsession, family, token_hash, created, expires)" +
+		" VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))"
+	_, err = ex.ExecContext(ctx, stmt, userID, session, family, models.HashToken(token), int(validTime.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Rotate revokes the refresh token and returns a new one of the same family, the user ID and the session ID.
+// The reuse of an already revoked token revokes the whole family and its session as the token may have been stolen.
+func (m *RefreshTokenModel) Rotate(ctx context.Context, token string, validTime time.Duration) (string, int, int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	var id, idUser int
+	var idSession sql.NullInt64
+	var family string
+	var expired bool
+	var revoked sql.NullTime
+	stmt := "SELECT id, iduser, idsession, family, expires <= UTC_TIMESTAMP(), revoked FROM refreshTokens" +
+		" WHERE token_hash = ? FOR UPDATE"
+	err = tx.QueryRowContext(ctx, stmt, models.HashToken(token)).Scan(&id, &idUser, &idSession, &family, &expired, &revoked)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", 0, 0, models.ErrInvalidToken
+		}
+		return "", 0, 0, err
+	}
+
+	if revoked.Valid {
+		// token reuse detected - revoke every token of the family and its session
+		_, err = tx.ExecContext(ctx, "UPDATE refreshTokens SET revoked = UTC_TIMESTAMP() WHERE family = ? AND revoked IS NULL", family)
+		if err != nil {
+			tx.Rollback()
+			return "", 0, 0, err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE userSessions SET revoked = UTC_TIMESTAMP() WHERE id = ? AND revoked IS NULL", idSession)
+		if err != nil {
+			tx.Rollback()
+			return "", 0, 0, err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("Rotate: Commit: %v", err)
+		}
+		return "", 0, 0, models.ErrInvalidToken
+	}
+	if expired {
+		tx.Rollback()
+		return "", 0, 0, models.ErrInvalidToken
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE refreshTokens SET revoked = UTC_TIMESTAMP() WHERE id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return "", 0, 0, err
+	}
+	newToken, err := m.insert(ctx, tx, idUser, int(idSession.Int64), family, validTime)
+	if err != nil {
+		tx.Rollback()
+		return "", 0, 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("Rotate: Commit: %v", err)
+	}
+	return newToken, idUser, int(idSession.Int64), nil
+}
+
+// Revoke revokes the family of the refresh token of the user and its session,
+// ErrNoRecord is returned when the token is unknown or of another user
+func (m *RefreshTokenModel) Revoke(ctx context.Context, userID int, token string) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var exists bool
+	stmt := "SELECT EXISTS(SELECT true FROM refreshTokens WHERE token_hash = ? AND iduser = ?)"
+	err := m.db.QueryRowContext(ctx, stmt, models.HashToken(token), userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrNoRecord
+	}
+
+	stmt = "UPDATE userSessions SET revoked = UTC_TIMESTAMP() WHERE revoked IS NULL AND id =" +
+		" (SELECT idsession FROM refreshTokens WHERE token_hash = ?)"
+	_, err = m.db.ExecContext(ctx, stmt, models.HashToken(token))
+	if err != nil {
+		return err
+	}
+	stmt = "UPDATE refreshTokens SET revoked = UTC_TIMESTAMP() WHERE revoked IS NULL AND family =" +
+		" (SELECT family FROM (SELECT family FROM refreshTokens WHERE token_hash = ?) AS t)"
+	_, err = m.db.ExecContext(ctx, stmt, models.HashToken(token))
+	return err
+}
+
+// TokenDenylistModel type which wraps a sql.DB connection pool.
+type TokenDenylistModel struct {
+	db *DB
+}
+
+// NewTokenDenylistModel creates a new TokenDenylistModel
+func NewTokenDenylistModel(d *DB) *TokenDenylistModel {
+	return &TokenDenylistModel{db: d}
+}
+
+// Add inserts the token ID in the denylist until the token expires.
+// Entries of tokens already expired are removed as they are no longer needed.
+func (m *TokenDenylistModel) Add(ctx context.Context, jti string, expires time.Time) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	_, err := m.db.ExecContext(ctx, "DELETE FROM revokedTokens WHERE expires < UTC_TIMESTAMP()")
+	if err != nil {
+		return err
+	}
+	stmt := "INSERT IGNORE INTO revokedTokens (jti, expires) VALUES(?, ?)"
+	_, err = m.db.ExecContext(ctx, stmt, jti, expires.UTC())
+	return err
+}
+
+// Contains returns true if the token ID is in the denylist
+func (m *TokenDenylistModel) Contains(ctx context.Context, jti string) (bool, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var exists bool
+	stmt := "SELECT EXISTS(SELECT true FROM revokedTokens WHERE jti = ?)"
+	err := m.db.QueryRowContext(ctx, stmt, jti).Scan(&exists)
+	return exists, err
+}
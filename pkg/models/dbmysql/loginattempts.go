@@ -0,0 +1,80 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+	"time"
+)
+
+// LoginAttemptModel type which wraps a sql.DB connection pool.
+type LoginAttemptModel struct {
+	db *DB
+}
+
+// NewLoginAttemptModel creates a new LoginAttemptModel
+func NewLoginAttemptModel(d *DB) *LoginAttemptModel {
+	return &LoginAttemptModel{db: d}
+}
+
+// Get returns the failed logins of the key, ErrNoRecord when there are none
+func (m *LoginAttemptModel) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	a := &models.LoginAttempt{Key: key}
+	var lockedUntil sql.NullTime
+	stmt := "SELECT failures, last_failure, locked_until FROM loginAttempts WHERE attempt_key = ?"
+	err := m.db.QueryRowContext(ctx, stmt, key).Scan(&a.Failures, &a.LastFailure, &lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		a.LockedUntil = lockedUntil.Time
+	}
+	return a, nil
+}
+
+// AddFailure increments the failures of the key, restarting the count when the last failure
+// is older than resetAfter. The increment is made by the database so concurrent logins are all counted.
+func (m *LoginAttemptModel) AddFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*models.LoginAttempt, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	now = now.UTC()
+	stmt := "INSERT INTO loginAttempts (attempt_key, failures, last_failure) VALUES(?, 1, ?)" +
+		" ON DUPLICATE KEY UPDATE failures = IF(last_failure < ?, 1, failures + 1), last_failure = VALUES(last_failure)"
+	_, err := m.db.ExecContext(ctx, stmt, key, now, now.Add(-resetAfter))
+	if err != nil {
+		return nil, err
+	}
+	return m.Get(ctx, key)
+}
+
+// RemoveFailure decrements the failures of the key
+func (m *LoginAttemptModel) RemoveFailure(ctx context.Context, key string) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "UPDATE loginAttempts SET failures = failures - 1 WHERE attempt_key = ? AND failures > 0"
+	_, err := m.db.ExecContext(ctx, stmt, key)
+	return err
+}
+
+// Lock blocks the logins of the key until the given time
+func (m *LoginAttemptModel) Lock(ctx context.Context, key string, until time.Time) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "UPDATE loginAttempts SET locked_until = ? WHERE attempt_key = ?"
+	_, err := m.db.ExecContext(ctx, stmt, until.UTC(), key)
+	return err
+}
+
+// Delete removes the failed logins of the key
+func (m *LoginAttemptModel) Delete(ctx context.Context, key string) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	_, err := m.db.ExecContext(ctx, "DELETE FROM loginAttempts WHERE attempt_key = ?", key)
+	return err
+}
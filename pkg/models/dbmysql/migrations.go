@@ -0,0 +1,342 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models/migrate"
+	"time"
+)
+
+// migrations are the changes of the schema of MySQL, each statement is executed on its own as the
+// driver does not accept many statements on a query. The first one is the schema of the former
+// CreateSnippetsDatabase.sql dump, its tables are kept when they exist so that the databases built
+// with it are upgraded by the following ones, and it is never reverted. The role types of the
+// administrators and of the registered users are seeded.
+var migrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create tables",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS roleTypes (
+  id int NOT NULL AUTO_INCREMENT,
+  role varchar(45) COLLATE utf8mb4_unicode_ci NOT NULL,
+  description varchar(45) COLLATE utf8mb4_unicode_ci NOT NULL,
+  created datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY id_UNIQUE (id),
+  UNIQUE KEY name_UNIQUE (role)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			`CREATE TABLE IF NOT EXISTS snippets (
+  id int NOT NULL AUTO_INCREMENT,
+  title varchar(100) COLLATE utf8mb4_unicode_ci NOT NULL,
+  content text COLLATE utf8mb4_unicode_ci NOT NULL,
+  created datetime NOT NULL,
+  expires datetime NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY title_UNIQUE (title),
+  KEY idx_snippets_created (created)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			`CREATE TABLE IF NOT EXISTS users (
+  id int NOT NULL AUTO_INCREMENT,
+  name varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+  email varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+  hashed_password char(60) COLLATE utf8mb4_unicode_ci NOT NULL,
+  created datetime NOT NULL,
+  active tinyint(1) NOT NULL DEFAULT '1',
+  PRIMARY KEY (id),
+  UNIQUE KEY users_uc_email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			`CREATE TABLE IF NOT EXISTS userRolesDetails (
+  id int NOT NULL AUTO_INCREMENT,
+  iduser int NOT NULL,
+  idrole int NOT NULL,
+  created datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY id_UNIQUE (id),
+  KEY iduser_idx (iduser),
+  KEY idrole_idx (idrole),
+  CONSTRAINT idrole FOREIGN KEY (idrole) REFERENCES roleTypes (id),
+  CONSTRAINT iduser FOREIGN KEY (iduser) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "seed role types",
+		Up: []string{
+			"INSERT IGNORE INTO roleTypes (role, description) VALUES" +
+				" ('administrator', 'Manages the users of the application')," +
+				" ('user', 'Creates and reads snippets')",
+		},
+		Down: []string{
+			"DELETE FROM roleTypes WHERE role IN ('administrator', 'user')" +
+				" AND id NOT IN (SELECT idrole FROM userRolesDetails)",
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create user tokens",
+		Up: []string{
+			`CREATE TABLE userTokens (
+  id int NOT NULL AUTO_INCREMENT,
+  iduser int NOT NULL,
+  purpose varchar(45) NOT NULL,
+  token_hash char(64) NOT NULL,
+  created datetime NOT NULL,
+  expires datetime NOT NULL,
+  used datetime DEFAULT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY userTokens_uc_token_hash (token_hash),
+  KEY userTokens_iduser_idx (iduser),
+  CONSTRAINT userTokens_iduser FOREIGN KEY (iduser) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		},
+		Down: []string{"DROP TABLE userTokens"},
+	},
+	{
+		Version: 4,
+		Name:    "add users approval pending",
+		Up: []string{
+			"ALTER TABLE users ADD COLUMN approval_pending tinyint(1) NOT NULL DEFAULT '0' AFTER active",
+		},
+		Down: []string{"ALTER TABLE users DROP COLUMN approval_pending"},
+	},
+	{
+		Version: 5,
+		Name:    "create refresh tokens",
+		Up: []string{
+			`CREATE TABLE refreshTokens (
+  id int NOT NULL AUTO_INCREMENT,
+  iduser int NOT NULL,
+  family char(43) NOT NULL,
+  token_hash char(64) NOT NULL,
+  created datetime NOT NULL,
+  expires datetime NOT NULL,
+  revoked datetime DEFAULT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY refreshTokens_uc_token_hash (token_hash),
+  KEY refreshTokens_family_idx (family),
+  KEY refreshTokens_iduser_idx (iduser),
+  CONSTRAINT refreshTokens_iduser FOREIGN KEY (iduser) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			`CREATE TABLE revokedTokens (
+  jti varchar(64) NOT NULL,
+  expires datetime NOT NULL,
+  PRIMARY KEY (jti),
+  KEY revokedTokens_expires_idx (expires)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		},
+		Down: []string{
+			"DROP TABLE revokedTokens",
+			"DROP TABLE refreshTokens",
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create api keys",
+		Up: []string{
+			`CREATE TABLE apiKeys (
+  id int NOT NULL AUTO_INCREMENT,
+  iduser int NOT NULL,
+  name varchar(255) NOT NULL,
+  prefix varchar(12) NOT NULL,
+  key_hash char(64) NOT NULL,
+  scopes varchar(255) NOT NULL,
+  created datetime NOT NULL,
+  expires datetime DEFAULT NULL,
+  last_used datetime DEFAULT NULL,
+  revoked datetime DEFAULT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY apiKeys_uc_key_hash (key_hash),
+  KEY apiKeys_iduser_idx (iduser),
+  CONSTRAINT apiKeys_iduser FOREIGN KEY (iduser) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		},
+		Down: []string{"DROP TABLE apiKeys"},
+	},
+	{
+		Version: 7,
+		Name:    "create user identities",
+		Up: []string{
+			`CREATE TABLE userIdentities (
+  id int NOT NULL AUTO_INCREMENT,
+  iduser int NOT NULL,
+  issuer varchar(255) NOT NULL,
+  subject varchar(255) NOT NULL,
+  created datetime NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY userIdentities_uc_issuer_subject (issuer, subject),
+  KEY userIdentities_iduser_idx (iduser),
+  CONSTRAINT userIdentities_iduser FOREIGN KEY (iduser) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		},
+		Down: []string{"DROP TABLE userIdentities"},
+	},
+	{
+		Version: 8,
+		Name:    "create mfa",
+		Up: []string{
+			`CREATE TABLE userMFA (
+  iduser int NOT NULL,
+  secret varchar(64) NOT NULL,
+  enabled tinyint(1) NOT NULL DEFAULT '0',
+  last_step bigint NOT NULL DEFAULT '0',
+  created datetime NOT NULL,
+  PRIMARY KEY (iduser),
+  CONSTRAINT userMFA_iduser FOREIGN KEY (iduser) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			`CREATE TABLE mfaRecoveryCodes (
+  id int NOT NULL AUTO_INCREMENT,
+  iduser int NOT NULL,
+  code_hash char(64) NOT NULL,
+  used datetime DEFAULT NULL,
+  PRIMARY KEY (id),
+  KEY mfaRecoveryCodes_iduser_idx (iduser),
+  CONSTRAINT mfaRecoveryCodes_iduser FOREIGN KEY (iduser) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			`CREATE TABLE mfaChallenges (
+  id int NOT NULL AUTO_INCREMENT,
+  iduser int NOT NULL,
+  challenge_hash char(64) NOT NULL,
+  expires datetime NOT NULL,
+  attempts int NOT NULL DEFAULT '0',
+  PRIMARY KEY (id),
+  UNIQUE KEY mfaChallenges_uc_challenge_hash (challenge_hash),
+  KEY mfaChallenges_iduser_idx (iduser),
+  CONSTRAINT mfaChallenges_iduser FOREIGN KEY (iduser) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			"ALTER TABLE roleTypes ADD COLUMN mfa_required tinyint(1) NOT NULL DEFAULT '0' AFTER created",
+		},
+		Down: []string{
+			"ALTER TABLE roleTypes DROP COLUMN mfa_required",
+			"DROP TABLE mfaChallenges",
+			"DROP TABLE mfaRecoveryCodes",
+			"DROP TABLE userMFA",
+		},
+	},
+	{
+		Version: 9,
+		Name:    "create login attempts",
+		Up: []string{
+			`CREATE TABLE loginAttempts (
+  attempt_key varchar(320) NOT NULL,
+  failures int NOT NULL DEFAULT '0',
+  last_failure datetime NOT NULL,
+  locked_until datetime DEFAULT NULL,
+  PRIMARY KEY (attempt_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		},
+		Down: []string{"DROP TABLE loginAttempts"},
+	},
+	{
+		// the argon2id hashes are longer than the 60 characters of bcrypt, the Down fails while
+		// any of them is stored
+		Version: 10,
+		Name:    "widen users hashed password",
+		Up: []string{
+			"ALTER TABLE users MODIFY hashed_password varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL",
+		},
+		Down: []string{
+			"ALTER TABLE users MODIFY hashed_password char(60) COLLATE utf8mb4_unicode_ci NOT NULL",
+		},
+	},
+	{
+		Version: 11,
+		Name:    "create user sessions",
+		Up: []string{
+			`CREATE TABLE userSessions (
+  id int NOT NULL AUTO_INCREMENT,
+  iduser int NOT NULL,
+  user_agent varchar(255) NOT NULL,
+  ip varchar(45) NOT NULL,
+  created datetime NOT NULL,
+  last_seen datetime NOT NULL,
+  expires datetime NOT NULL,
+  revoked datetime DEFAULT NULL,
+  PRIMARY KEY (id),
+  KEY userSessions_iduser_idx (iduser),
+  CONSTRAINT userSessions_iduser FOREIGN KEY (iduser) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			"ALTER TABLE refreshTokens ADD COLUMN idsession int DEFAULT NULL," +
+				" ADD KEY refreshTokens_idsession_idx (idsession)," +
+				" ADD CONSTRAINT refreshTokens_idsession FOREIGN KEY (idsession) REFERENCES userSessions (id)",
+		},
+		Down: []string{
+			"ALTER TABLE refreshTokens DROP FOREIGN KEY refreshTokens_idsession",
+			"ALTER TABLE refreshTokens DROP KEY refreshTokens_idsession_idx, DROP COLUMN idsession",
+			"DROP TABLE userSessions",
+		},
+	},
+	{
+		Version: 12,
+		Name:    "create audit log",
+		Up: []string{
+			`CREATE TABLE auditLog (
+  id bigint NOT NULL AUTO_INCREMENT,
+  created datetime NOT NULL,
+  action varchar(32) NOT NULL,
+  idactor int DEFAULT NULL,
+  target_type varchar(16) NOT NULL,
+  idtarget int DEFAULT NULL,
+  ip varchar(45) NOT NULL,
+  request_id varchar(64) NOT NULL,
+  details varchar(1024) NOT NULL,
+  PRIMARY KEY (id),
+  KEY auditLog_created_idx (created),
+  KEY auditLog_action_idx (action),
+  KEY auditLog_idactor_idx (idactor),
+  KEY auditLog_target_idx (target_type, idtarget)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+			"CREATE TRIGGER auditLog_no_update BEFORE UPDATE ON auditLog FOR EACH ROW" +
+				" SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'auditLog is append-only'",
+			"CREATE TRIGGER auditLog_no_delete BEFORE DELETE ON auditLog FOR EACH ROW" +
+				" SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'auditLog is append-only'",
+		},
+		Down: []string{
+			"DROP TRIGGER auditLog_no_delete",
+			"DROP TRIGGER auditLog_no_update",
+			"DROP TABLE auditLog",
+		},
+	},
+}
+
+// dialect creates the schema_migrations table of MySQL
+var dialect = migrate.Dialect{
+	CreateTable: "CREATE TABLE IF NOT EXISTS schema_migrations (version int NOT NULL, name varchar(255) NOT NULL," +
+		" applied datetime NOT NULL, PRIMARY KEY (version)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci",
+	Placeholder: migrate.QuestionPlaceholder,
+	Lock:        lockMigrations,
+	Unlock:      unlockMigrations,
+}
+
+// migrationsLockTimeout is the time an instance waits for the migrations applied by another one
+const migrationsLockTimeout = 10 * time.Minute
+
+// the named lock of the migrations, the names are shared by the databases of the server
+const migrationsLock = "CONCAT(DATABASE(), '.schema_migrations')"
+
+// lockMigrations takes the named lock of the migrations of the database on the connection
+func lockMigrations(ctx context.Context, conn *sql.Conn) error {
+	var locked sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK("+migrationsLock+", ?)",
+		int(migrationsLockTimeout/time.Second)).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return errors.New("timeout waiting for the migrations of another instance")
+	}
+	return nil
+}
+
+// unlockMigrations releases the named lock of the migrations taken on the connection
+func unlockMigrations(ctx context.Context, conn *sql.Conn) error {
+	var released sql.NullInt64
+	return conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK("+migrationsLock+")").Scan(&released)
+}
+
+// NewMigrator returns the migrator of the schema of the database
+func NewMigrator(db *DB) (*migrate.Migrator, error) {
+	return migrate.New(db.DB, dialect, migrations)
+}
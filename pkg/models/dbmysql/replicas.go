@@ -0,0 +1,234 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replica is a read replica of the primary, it is read while its lag is within the MaxReplicaLag
+type replica struct {
+	addr string
+	db   *sql.DB
+
+	mu        sync.Mutex
+	available bool
+}
+
+// isAvailable returns true when the replica can be read
+func (r *replica) isAvailable() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.available
+}
+
+// setAvailable records the state of the replica and returns the previous one
+func (r *replica) setAvailable(available bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous := r.available
+	r.available = available
+	return previous
+}
+
+// replicaSet holds the read replicas and checks their lag in the background
+type replicaSet struct {
+	infoLog  *log.Logger
+	replicas []*replica
+	maxLag   time.Duration
+	timeout  time.Duration
+	next     uint32 // the replicas are read in turns
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// dialReplicas opens the pools of the Replicas of the data and starts the checks of their lag,
+// the replicas that do not answer are not read until they catch up with the primary
+func dialReplicas(infoLog *log.Logger, dialData DBdata, certsPath, keysPath string) (*replicaSet, error) {
+	if dialData.MaxReplicaLag <= 0 || dialData.ReplicaCheck <= 0 {
+		return nil, errors.New("maxReplicaLag and replicaCheckInterval must be positive with replicas")
+	}
+	s := &replicaSet{
+		infoLog: infoLog,
+		maxLag:  dialData.MaxReplicaLag,
+		timeout: dialData.ReplicaCheck,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for i, addr := range dialData.Replicas {
+		// the names of the certificates are verified against the host of each replica
+		data := dialData
+		data.Server = addr
+		data.ServerName = ""
+		db, err := open(infoLog, data, fmt.Sprintf("%s-replica%d", tlsConfigName, i), certsPath, keysPath)
+		if err != nil {
+			for _, r := range s.replicas {
+				r.db.Close()
+			}
+			return nil, fmt.Errorf("replica %s: %v", addr, err)
+		}
+		// the replicas start as available so the first check logs the ones that are not
+		s.replicas = append(s.replicas, &replica{addr: addr, db: db, available: true})
+	}
+	s.checkAll()
+	go s.run(dialData.ReplicaCheck)
+	return s, nil
+}
+
+// run checks the replicas on every interval until the set is closed
+func (s *replicaSet) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.checkAll()
+		}
+	}
+}
+
+// checkAll updates the state of the replicas from their lag, the changes are logged
+func (s *replicaSet) checkAll() {
+	for _, r := range s.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		lag, err := replicaLag(ctx, r.db)
+		cancel()
+		if err == nil && lag > s.maxLag {
+			err = fmt.Errorf("lag of %v is over %v", lag, s.maxLag)
+		}
+		previous := r.setAvailable(err == nil)
+		if err != nil && previous {
+			s.infoLog.Printf("dbmysql: replica %s is not read: %v\n", r.addr, err)
+		} else if err == nil && !previous {
+			s.infoLog.Printf("dbmysql: replica %s is read with a lag of %v\n", r.addr, lag)
+		}
+	}
+}
+
+// failed stops the reads of the replica after an error, until its next check
+func (s *replicaSet) failed(r *replica, err error) {
+	if r.setAvailable(false) {
+		s.infoLog.Printf("dbmysql: replica %s is not read: %v\n", r.addr, err)
+	}
+}
+
+// pick returns the next available replica in turns, nil when none is available
+func (s *replicaSet) pick() *replica {
+	n := uint32(len(s.replicas))
+	start := atomic.AddUint32(&s.next, 1)
+	for i := uint32(0); i < n; i++ {
+		r := s.replicas[(start+i)%n]
+		if r.isAvailable() {
+			return r
+		}
+	}
+	return nil
+}
+
+// close stops the checks and closes the pools of the replicas
+func (s *replicaSet) close(infoLog *log.Logger) {
+	close(s.stop)
+	<-s.done
+	for _, r := range s.replicas {
+		if err := r.db.Close(); err != nil {
+			infoLog.Printf("CloseDB: replica %s: %v\n", r.addr, err)
+		}
+	}
+}
+
+// replicaLag returns the seconds the replica is behind its primary, an error when it is
+// not replicating. The statement was renamed on MySQL 8.0.22, the old one is tried on errors.
+func replicaLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	lag, err := statusLag(ctx, db, "SHOW REPLICA STATUS")
+	if err != nil && ctx.Err() == nil {
+		lag, err = statusLag(ctx, db, "SHOW SLAVE STATUS")
+	}
+	return lag, err
+}
+
+// statusLag returns the lag of the row of the replication status statement
+func statusLag(ctx context.Context, db *sql.DB, stmt string) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, stmt)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("the server is not a replica")
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, c := range columns {
+		if c != "Seconds_Behind_Source" && c != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errors.New("the replication is stopped")
+		}
+		seconds, err := strconv.Atoi(string(values[i]))
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", c, err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, fmt.Errorf("%s: no Seconds_Behind column", stmt)
+}
+
+// replica returns the replica of a read, nil when it must be done on the primary
+func (db *DB) replica(ctx context.Context) *replica {
+	if db.replicas == nil || models.UsePrimary(ctx) {
+		return nil
+	}
+	return db.replicas.pick()
+}
+
+// queryReplicaContext executes a query that returns rows on a replica, the read is done on
+// the primary when no replica is available or when the replica fails
+func (db *DB) queryReplicaContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if r := db.replica(ctx); r != nil {
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err == nil || ctx.Err() != nil {
+			return rows, err
+		}
+		db.replicas.failed(r, err)
+	}
+	return db.QueryContext(ctx, query, args...)
+}
+
+// queryRowReplicaContext executes a query that returns at most one row on a replica, the read
+// is done on the primary when no replica is available or when the replica fails
+func (db *DB) queryRowReplicaContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if r := db.replica(ctx); r != nil {
+		row := r.db.QueryRowContext(ctx, query, args...)
+		err := row.Err()
+		if err == nil || ctx.Err() != nil {
+			return row
+		}
+		db.replicas.failed(r, err)
+	}
+	return db.QueryRowContext(ctx, query, args...)
+}
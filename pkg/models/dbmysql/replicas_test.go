@@ -0,0 +1,87 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vgraveto/snippets/pkg/models"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+// newTestReplicas returns a set of replicas on closed ports, the pools are not dialed until used
+func newTestReplicas(t *testing.T, addrs ...string) *replicaSet {
+	s := &replicaSet{infoLog: log.New(ioutil.Discard, "", 0), maxLag: time.Second, timeout: time.Second}
+	for _, addr := range addrs {
+		db, err := sql.Open("mysql", "user:password@tcp("+addr+")/snippets?timeout=1s")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		s.replicas = append(s.replicas, &replica{addr: addr, db: db, available: true})
+	}
+	return s
+}
+
+func TestReplicaPick(t *testing.T) {
+	s := newTestReplicas(t, "127.0.0.1:1", "127.0.0.1:2", "127.0.0.1:3")
+	db := &DB{replicas: s}
+	ctx := context.Background()
+
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		seen[db.replica(ctx).addr]++
+	}
+	for _, r := range s.replicas {
+		if seen[r.addr] != 2 {
+			t.Errorf("want the replicas read in turns; got %v", seen)
+		}
+	}
+
+	s.replicas[1].setAvailable(false)
+	for i := 0; i < 6; i++ {
+		if r := db.replica(ctx); r.addr == "127.0.0.1:2" {
+			t.Fatal("want the unavailable replica not read")
+		}
+	}
+
+	if r := db.replica(models.WithPrimary(ctx)); r != nil {
+		t.Errorf("want the primary for the reads after a write; got %s", r.addr)
+	}
+
+	s.replicas[0].setAvailable(false)
+	s.replicas[2].setAvailable(false)
+	if r := db.replica(ctx); r != nil {
+		t.Errorf("want the primary without available replicas; got %s", r.addr)
+	}
+
+	if r := (&DB{}).replica(ctx); r != nil {
+		t.Errorf("want the primary without replicas; got %s", r.addr)
+	}
+}
+
+func TestReplicaFailure(t *testing.T) {
+	s := newTestReplicas(t, "127.0.0.1:1")
+	primary, err := sql.Open("mysql", "user:password@tcp(127.0.0.1:2)/snippets?timeout=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	db := &DB{DB: primary, replicas: s}
+
+	// the failed replica is not read until its next check, the read goes to the primary
+	rows, err := db.queryReplicaContext(context.Background(), "SELECT 1")
+	if err == nil {
+		rows.Close()
+		t.Fatal("want the error of the primary")
+	}
+	if s.replicas[0].isAvailable() {
+		t.Error("want the failed replica unavailable")
+	}
+
+	s.checkAll()
+	if s.replicas[0].isAvailable() {
+		t.Error("want the replica that does not answer unavailable")
+	}
+}
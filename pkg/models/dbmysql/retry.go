@@ -0,0 +1,68 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"time"
+)
+
+// the codes of the MySQL errors of the transactions aborted by other transactions
+const (
+	errLockWaitTimeout = 1205
+	errLockDeadlock    = 1213
+)
+
+// transient returns true for the errors of a read that may succeed when tried again,
+// as the broken connections of a failover and the deadlocks
+func transient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errLockDeadlock || mysqlErr.Number == errLockWaitTimeout
+	}
+	return false
+}
+
+// retry calls the read until it does not fail with a transient error, up to RetryAttempts
+// times. The wait before each new attempt starts on RetryBackoff and is doubled each time.
+func (db *DB) retry(ctx context.Context, read func() error) error {
+	backoff := db.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := read()
+		if err == nil || attempt >= db.RetryAttempts || !transient(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// QueryContext executes a query that returns rows, the reads of the models are idempotent
+// so they are retried on the transient errors
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := db.retry(ctx, func() (err error) {
+		rows, err = db.DB.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// QueryRowContext executes a query that returns at most one row, retried on the transient errors
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	var row *sql.Row
+	db.retry(ctx, func() error {
+		row = db.DB.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	return row
+}
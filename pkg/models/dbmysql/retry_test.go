@@ -0,0 +1,59 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: errLockDeadlock, Message: "Deadlock found"}
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		wantErr  error
+	}{
+		{"success", []error{nil}, 1, nil},
+		{"bad connection", []error{driver.ErrBadConn, nil}, 2, nil},
+		{"invalid connection", []error{fmt.Errorf("query: %w", mysql.ErrInvalidConn), nil}, 2, nil},
+		{"deadlock", []error{deadlock, deadlock, nil}, 3, nil},
+		{"attempts exhausted", []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn}, 3, driver.ErrBadConn},
+		{"not transient", []error{&mysql.MySQLError{Number: 1146}}, 1, &mysql.MySQLError{Number: 1146}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &DB{RetryAttempts: 3, RetryBackoff: time.Millisecond}
+			attempts := 0
+			err := db.retry(context.Background(), func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			if attempts != tt.attempts {
+				t.Errorf("want %d attempts; got %d", tt.attempts, attempts)
+			}
+			if fmt.Sprint(err) != fmt.Sprint(tt.wantErr) {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		db := &DB{RetryAttempts: 3, RetryBackoff: time.Hour}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		attempts := 0
+		err := db.retry(ctx, func() error {
+			attempts++
+			return driver.ErrBadConn
+		})
+		if attempts != 1 || !errors.Is(err, driver.ErrBadConn) {
+			t.Errorf("want one attempt and ErrBadConn; got %d and %v", attempts, err)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package dbmysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"time"
+)
+
+// SessionModel type which wraps a sql.DB connection pool.
+type SessionModel struct {
+	db *DB
+}
+
+// NewSessionModel creates a new SessionModel
+func NewSessionModel(d *DB) *SessionModel {
+	return &SessionModel{db: d}
+}
+
+// maxUserAgentLen is the length of the user_agent column of the userSessions table
+const maxUserAgentLen = 255
+
+// New starts a session of the user that expires after validTime and returns its ID
+func (m *SessionModel) New(ctx context.Context, userID int, userAgent, ip string, validTime time.Duration) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	if len(userAgent) > maxUserAgentLen {
+		userAgent = userAgent[:maxUserAgentLen]
+	}
+	stmt := "INSERT INTO userSessions (iduser, user_agent, ip, created, last_seen, expires)" +
+		" VALUES(?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))"
+	result, err := m.db.ExecContext(ctx, stmt, userID, userAgent, ip, int(validTime.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Touch updates the last seen time and IP of the session and extends its expiration
+func (m *SessionModel) Touch(ctx context.Context, id int, ip string, validTime time.Duration) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "UPDATE userSessions SET last_seen = UTC_TIMESTAMP(), ip = ?," +
+		" expires = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND) WHERE id = ? AND revoked IS NULL"
+	_, err := m.db.ExecContext(ctx, stmt, ip, int(validTime.Seconds()), id)
+	return err
+}
+
+// GetAll returns the sessions of the user that are not revoked nor expired, the last seen first
+func (m *SessionModel) GetAll(ctx context.Context, userID int) ([]*models.Session, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "SELECT id, iduser, user_agent, ip, created, last_seen, expires FROM userSessions" +
+		" WHERE iduser = ? AND revoked IS NULL AND expires > UTC_TIMESTAMP() ORDER BY last_seen DESC"
+	rows, err := m.db.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		s := &models.Session{}
+		err = rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.Created, &s.LastSeen, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Revoke revokes the session of the user and its refresh tokens
+func (m *SessionModel) Revoke(ctx context.Context, userID, id int) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	stmt := "UPDATE userSessions SET revoked = UTC_TIMESTAMP() WHERE id = ? AND iduser = ? AND revoked IS NULL"
+	result, err := tx.ExecContext(ctx, stmt, id, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n == 0 {
+		tx.Rollback()
+		return models.ErrNoRecord
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE refreshTokens SET revoked = UTC_TIMESTAMP() WHERE idsession = ? AND revoked IS NULL", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Revoke: Commit: %v", err)
+	}
+	return nil
+}
+
+// Active returns true when the session is not revoked nor expired
+func (m *SessionModel) Active(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var active bool
+	stmt := "SELECT EXISTS(SELECT true FROM userSessions WHERE id = ? AND revoked IS NULL AND expires > UTC_TIMESTAMP())"
+	err := m.db.QueryRowContext(ctx, stmt, id).Scan(&active)
+	return active, err
+}
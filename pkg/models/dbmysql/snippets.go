@@ -1,6 +1,7 @@
 package dbmysql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/vgraveto/snippets/pkg/models"
@@ -8,29 +9,31 @@ import (
 
 // SnippetModel type which wraps a sql.DB connection pool.
 type SnippetModel struct {
-	db *sql.DB
+	db *DB
 }
 
 // NewSnippetModel creates a new SnippetModel
-func NewSnippetModel(d *sql.DB) *SnippetModel {
+func NewSnippetModel(d *DB) *SnippetModel {
 	return &SnippetModel{db: d}
 }
 
 // Insert will insert a new snippet into the database.and return its id
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
+func (m *SnippetModel) Insert(ctx context.Context, title, content, expires string) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
 	// Write the SQL statement we want to execute. I've split it over two lines
 	// for readability (which is why it's surrounded with backquotes instead
 	// of normal double quotes).
 	stmt := "INSERT INTO snippets (title, content, created, expires)" +
 		" VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))"
 
-	smt, err := m.db.Prepare(stmt)
+	smt, err := m.db.PrepareContext(ctx, stmt)
 	if err != nil {
 		return 0, err
 	}
 	defer smt.Close()
 
-	result, err := smt.Exec(title, content, expires)
+	result, err := smt.ExecContext(ctx, title, content, expires)
 	if err != nil {
 		return -1, err
 	}
@@ -47,7 +50,9 @@ func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
 }
 
 // Get will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
 	// Write the SQL statement we want to execute. Again, I've split it over two
 	//lines for readability.
 	stmt := "SELECT id, title, content, created, expires FROM snippets" +
@@ -56,7 +61,9 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	// SQL statement, passing in the untrusted id variable as the value for the
 	// placeholder parameter. This returns a pointer to a sql.Row object which
 	// holds the result from the database.
-	row := m.db.QueryRow(stmt, id)
+	// The read is done on a replica, it is done again on the primary when the
+	// snippet is not found as it may have been inserted after the lag of the replica.
+	row := m.db.queryRowReplicaContext(ctx, stmt, id)
 	// Initialize a pointer to a new zeroed Snippet struct.
 	s := &models.Snippet{}
 	// Use row.Scan() to copy the values from each field in sql.Row to the
@@ -65,6 +72,10 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	// and the number of arguments must be exactly the same as the number of
 	// columns returned by your statement.
 	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	if errors.Is(err, sql.ErrNoRows) && m.db.replicas != nil && !models.UsePrimary(ctx) {
+		row = m.db.QueryRowContext(ctx, stmt, id)
+		err = row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	}
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return a
 		// sql.ErrNoRows error. We use the errors.Is() function check for that
@@ -81,12 +92,15 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 }
 
 // Latest will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
 	// Write the SQL statement we want to execute.
 	stmt := "SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10"
 	// Use the Query() method on the connection pool to execute our
 	// SQL statement. This returns a sql.Rows resultset containing the result of // our query.
-	rows, err := m.db.Query(stmt)
+	// The latest snippets are read from a replica, they can miss the ones inserted during its lag.
+	rows, err := m.db.queryReplicaContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
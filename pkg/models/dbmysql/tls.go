@@ -0,0 +1,187 @@
+package dbmysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// the TLS modes of the connections to the server
+const (
+	// TLSDisable connects without TLS, for local servers only
+	TLSDisable = "disable"
+	// TLSPreferred uses TLS when the server supports it, the certificate of the server is not verified
+	TLSPreferred = "preferred"
+	// TLSVerifyCA requires TLS and a certificate of the server signed by the CA, the name is not verified
+	TLSVerifyCA = "verify-ca"
+	// TLSVerifyFull requires TLS and a certificate of the server signed by the CA for the ServerName
+	TLSVerifyFull = "verify-full"
+)
+
+// tlsConfig returns the TLS configuration of the verify modes or the name of the driver
+// configuration of the other modes. The ServerCA and the ClientCert are found on the
+// certsPath and the ClientKey on the keysPath, the system roots are used without a ServerCA.
+func (d DBdata) tlsConfig(infoLog *log.Logger, certsPath, keysPath string) (*tls.Config, string, error) {
+	switch d.TLSMode {
+	case TLSDisable:
+		return nil, "false", nil
+	case TLSPreferred:
+		return nil, "preferred", nil
+	case TLSVerifyCA, TLSVerifyFull:
+	default:
+		return nil, "", fmt.Errorf("tlsMode %q is not %q, %q, %q or %q",
+			d.TLSMode, TLSDisable, TLSPreferred, TLSVerifyCA, TLSVerifyFull)
+	}
+
+	var roots *x509.CertPool
+	if d.ServerCA == "" {
+		infoLog.Println("DialDB: no serverCA specified - the system roots verify the server certificate")
+	} else {
+		pem, err := ioutil.ReadFile(certsPath + d.ServerCA)
+		if err != nil {
+			return nil, "", fmt.Errorf("serverCA: %v", err)
+		}
+		roots = x509.NewCertPool()
+		if ok := roots.AppendCertsFromPEM(pem); !ok {
+			return nil, "", fmt.Errorf("serverCA: no PEM certificate found on %s", certsPath+d.ServerCA)
+		}
+	}
+	config := &tls.Config{RootCAs: roots}
+
+	switch {
+	case d.ClientCert == "" && d.ClientKey == "":
+		infoLog.Println("DialDB: no client certificate specified - only server side certificate will be used")
+	case d.ClientCert == "" || d.ClientKey == "":
+		return nil, "", errors.New("clientCert and clientKey must be both set or both empty")
+	default:
+		r, err := newCertReloader(infoLog, certsPath+d.ClientCert, keysPath+d.ClientKey)
+		if err != nil {
+			return nil, "", err
+		}
+		config.GetClientCertificate = r.GetClientCertificate
+	}
+
+	if d.TLSMode == TLSVerifyCA {
+		// the chain is verified without the name of the server by verifyChain
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyChain(roots)
+		return config, tlsConfigName, nil
+	}
+	config.ServerName = d.ServerName
+	if config.ServerName == "" {
+		if d.Protocol == "unix" {
+			return nil, "", errors.New("serverName is required by the verify-full tlsMode on the unix protocol")
+		}
+		host, _, err := net.SplitHostPort(d.Server)
+		if err != nil {
+			return nil, "", fmt.Errorf("server %q: %v - set the serverName", d.Server, err)
+		}
+		config.ServerName = host
+	}
+	return config, tlsConfigName, nil
+}
+
+// verifyChain returns the verification of the certificates of the server against the roots,
+// the system roots when nil, without verifying the name of the server
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("dbmysql: the server sent no certificate")
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("dbmysql: server certificate: %v", err)
+			}
+			certs = append(certs, cert)
+		}
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		if _, err := certs[0].Verify(opts); err != nil {
+			return fmt.Errorf("dbmysql: server certificate: %v", err)
+		}
+		return nil
+	}
+}
+
+// certReloader returns the client certificate of the connections, the files are loaded again
+// when they change so a rotated certificate is used by the new connections without a restart
+type certReloader struct {
+	infoLog  *log.Logger
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// newCertReloader returns the reloader of the certificate files, they must hold a valid key pair
+func newCertReloader(infoLog *log.Logger, certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{infoLog: infoLog, certFile: certFile, keyFile: keyFile}
+	modTime, err := r.lastChange()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// lastChange returns the latest modification time of the certificate and key files
+func (r *certReloader) lastChange() (time.Time, error) {
+	cert, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("clientCert: %v", err)
+	}
+	key, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("clientKey: %v", err)
+	}
+	if key.ModTime().After(cert.ModTime()) {
+		return key.ModTime(), nil
+	}
+	return cert.ModTime(), nil
+}
+
+// load reads the key pair of the files changed at modTime, it must be called with the lock held
+// or before the reloader is shared
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("clientCert %s / clientKey %s: %v", r.certFile, r.keyFile, err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// GetClientCertificate returns the client certificate, loaded again when the files changed.
+// The previous certificate is kept when the new files are not a valid key pair, as while
+// they are being replaced.
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modTime, err := r.lastChange()
+	if err == nil && !modTime.Equal(r.modTime) {
+		err = r.load(modTime)
+		if err == nil {
+			r.infoLog.Printf("dbmysql: reloaded the client certificate %s\n", r.certFile)
+		}
+	}
+	if err != nil {
+		r.infoLog.Printf("dbmysql: keeping the previous client certificate: %v\n", err)
+	}
+	return r.cert, nil
+}
@@ -0,0 +1,169 @@
+package dbmysql
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self signed certificate of the name and its key on the files
+func writeKeyPair(t *testing.T, certFile, keyFile, name string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestTLSConfig(t *testing.T) {
+	infoLog := log.New(ioutil.Discard, "", 0)
+	dir := t.TempDir() + "/"
+	writeKeyPair(t, dir+"ca.crt", dir+"ca.key", "db.local")
+
+	tests := []struct {
+		name       string
+		data       DBdata
+		tlsName    string
+		serverName string
+		wantErr    bool
+	}{
+		{"disable", DBdata{TLSMode: TLSDisable}, "false", "", false},
+		{"preferred", DBdata{TLSMode: TLSPreferred}, "preferred", "", false},
+		{"unknown mode", DBdata{TLSMode: "required"}, "", "", true},
+		{"host of the server", DBdata{TLSMode: TLSVerifyFull, Server: "db.local:3306", ServerCA: "ca.crt"}, tlsConfigName, "db.local", false},
+		{"server name", DBdata{TLSMode: TLSVerifyFull, Server: "10.0.0.1:3306", ServerName: "db.local"}, tlsConfigName, "db.local", false},
+		{"unix socket", DBdata{TLSMode: TLSVerifyFull, Protocol: "unix", Server: "/tmp/mysql.sock"}, "", "", true},
+		{"verify-ca", DBdata{TLSMode: TLSVerifyCA, Server: "10.0.0.1:3306", ServerCA: "ca.crt"}, tlsConfigName, "", false},
+		{"missing serverCA", DBdata{TLSMode: TLSVerifyFull, Server: "db.local:3306", ServerCA: "none.crt"}, "", "", true},
+		{"serverCA not PEM", DBdata{TLSMode: TLSVerifyFull, Server: "db.local:3306", ServerCA: "ca.key"}, "", "", true},
+		{"client key missing", DBdata{TLSMode: TLSVerifyFull, Server: "db.local:3306", ClientCert: "ca.crt"}, "", "", true},
+		{"client key pair", DBdata{TLSMode: TLSVerifyFull, Server: "db.local:3306", ClientCert: "ca.crt", ClientKey: "ca.key"}, tlsConfigName, "db.local", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, tlsName, err := tt.data.tlsConfig(infoLog, dir, dir)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tlsName != tt.tlsName {
+				t.Errorf("want %q; got %q", tt.tlsName, tlsName)
+			}
+			if config != nil && config.ServerName != tt.serverName {
+				t.Errorf("want server name %q; got %q", tt.serverName, config.ServerName)
+			}
+		})
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	dir := t.TempDir() + "/"
+	ca := writeKeyPair(t, dir+"ca.crt", dir+"ca.key", "db.local")
+	other := writeKeyPair(t, dir+"other.crt", dir+"other.key", "db.local")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	verify := verifyChain(roots)
+	if err := verify([][]byte{ca.Raw}, nil); err != nil {
+		t.Errorf("want the certificate of the CA accepted; got %v", err)
+	}
+	if err := verify([][]byte{other.Raw}, nil); err == nil {
+		t.Error("want a certificate of another CA rejected")
+	}
+	if err := verify(nil, nil); err == nil {
+		t.Error("want an error without certificates")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	infoLog := log.New(ioutil.Discard, "", 0)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	first := writeKeyPair(t, certFile, keyFile, "client")
+
+	r, err := newCertReloader(infoLog, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := func() *x509.Certificate {
+		cert, err := r.GetClientCertificate(&tls.CertificateRequestInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	if got := leaf(); got.SerialNumber.Cmp(first.SerialNumber) != 0 {
+		t.Fatal("want the first certificate")
+	}
+
+	// a rotated certificate is loaded
+	second := writeKeyPair(t, certFile, keyFile, "client")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if got := leaf(); got.SerialNumber.Cmp(second.SerialNumber) != 0 {
+		t.Fatal("want the rotated certificate")
+	}
+
+	// the previous certificate is kept while the key is invalid
+	ioutil.WriteFile(keyFile, []byte("partial"), 0600)
+	later = later.Add(time.Minute)
+	os.Chtimes(keyFile, later, later)
+	if got := leaf(); got.SerialNumber.Cmp(second.SerialNumber) != 0 {
+		t.Fatal("want the previous certificate kept")
+	}
+
+	if _, err := newCertReloader(infoLog, certFile, filepath.Join(dir, "none.key")); err == nil {
+		t.Error("want an error for a missing key file")
+	}
+}
@@ -0,0 +1,116 @@
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"time"
+)
+
+// UserTokenModel type which wraps a sql.DB connection pool.
+type UserTokenModel struct {
+	db *DB
+}
+
+// NewUserTokenModel creates a new UserTokenModel
+func NewUserTokenModel(d *DB) *UserTokenModel {
+	return &UserTokenModel{db: d}
+}
+
+// New creates a token for the user with the given purpose and valid time and returns its plain-text value.
+// Only the token hash is stored on the userTokens table.
+func (m *UserTokenModel) New(ctx context.Context, userID int, purpose string, validTime time.Duration) (string, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	token, err := models.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	// invalidate any previous token with the same purpose so that only the last one sent is valid
+	stmt := "UPDATE userTokens SET used = UTC_TIMESTAMP() WHERE iduser = ? AND purpose = ? AND used IS NULL"
+	_, err = tx.ExecContext(ctx, stmt, userID, purpose)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	stmt = "INSERT INTO userTokens (iduser, purpose, token_hash, created, expires)" +
+		" VALUES(?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))"
+	_, err = tx.ExecContext(ctx, stmt, userID, purpose, models.HashToken(token), int(validTime.Seconds()))
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", fmt.Errorf("New: Commit: %v", err)
+	}
+	return token, nil
+}
+
+// Consume validates the token for the given purpose, marks it as used and returns the ID of its user
+func (m *UserTokenModel) Consume(ctx context.Context, token, purpose string) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var id, idUser int
+	stmt := "SELECT id, iduser FROM userTokens" +
+		" WHERE token_hash = ? AND purpose = ? AND used IS NULL AND expires > UTC_TIMESTAMP() FOR UPDATE"
+	err = tx.QueryRowContext(ctx, stmt, models.HashToken(token), purpose).Scan(&id, &idUser)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidToken
+		}
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE userTokens SET used = UTC_TIMESTAMP() WHERE id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("Consume: Commit: %v", err)
+	}
+	return idUser, nil
+}
+
+// Peek validates the token for the given purpose and returns the ID of its user without using it
+func (m *UserTokenModel) Peek(ctx context.Context, token, purpose string) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var idUser int
+	stmt := "SELECT iduser FROM userTokens" +
+		" WHERE token_hash = ? AND purpose = ? AND used IS NULL AND expires > UTC_TIMESTAMP()"
+	err := m.db.QueryRowContext(ctx, stmt, models.HashToken(token), purpose).Scan(&idUser)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidToken
+		}
+		return 0, err
+	}
+	return idUser, nil
+}
+
+// HasUnused returns true when the last token of the purpose created for the user was not used,
+// even if it expired
+func (m *UserTokenModel) HasUnused(ctx context.Context, userID int, purpose string) (bool, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var unused bool
+	stmt := "SELECT EXISTS(SELECT 1 FROM userTokens WHERE iduser = ? AND purpose = ? AND used IS NULL)"
+	err := m.db.QueryRowContext(ctx, stmt, userID, purpose).Scan(&unused)
+	return unused, err
+}
@@ -0,0 +1,109 @@
+package dbpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"strings"
+)
+
+// AuditModel type which wraps a sql.DB connection pool.
+type AuditModel struct {
+	db *DB
+}
+
+// NewAuditModel creates a new AuditModel
+func NewAuditModel(d *DB) *AuditModel {
+	return &AuditModel{db: d}
+}
+
+// maxAuditDetailsLen is the length of the details column of the auditLog table
+const maxAuditDetailsLen = 1024
+
+// Insert appends the event to the auditLog table, the zero actor and target IDs are stored as NULL
+func (m *AuditModel) Insert(ctx context.Context, e *models.AuditEvent) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	details := e.Details
+	if len(details) > maxAuditDetailsLen {
+		details = details[:maxAuditDetailsLen]
+	}
+	var actor, target sql.NullInt64
+	if e.ActorID > 0 {
+		actor = sql.NullInt64{Int64: int64(e.ActorID), Valid: true}
+	}
+	if e.TargetID > 0 {
+		target = sql.NullInt64{Int64: int64(e.TargetID), Valid: true}
+	}
+	stmt := "INSERT INTO auditLog (created, action, idactor, target_type, idtarget, ip, request_id, details)" +
+		" VALUES(now(), $1, $2, $3, $4, $5, $6, $7)"
+	_, err := m.db.ExecContext(ctx, stmt, e.Action, actor, e.TargetType, target, e.IP, e.RequestID, details)
+	return err
+}
+
+// GetAll returns the page of the events matching the filter, the most recent first
+func (m *AuditModel) GetAll(ctx context.Context, f *models.AuditFilter) (*models.AuditPage, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var where []string
+	var args []interface{}
+	// arg adds the value to the args and returns its placeholder
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.Action != "" {
+		where = append(where, "action = "+arg(f.Action))
+	}
+	if f.ActorID > 0 {
+		where = append(where, "idactor = "+arg(f.ActorID))
+	}
+	if f.TargetType != "" {
+		where = append(where, "target_type = "+arg(f.TargetType))
+	}
+	if f.TargetID > 0 {
+		where = append(where, "idtarget = "+arg(f.TargetID))
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created >= "+arg(f.From.UTC()))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created < "+arg(f.To.UTC()))
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	page := &models.AuditPage{Events: []*models.AuditEvent{}, Page: f.Page, PerPage: f.PerPage}
+	err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM auditLog"+cond, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+	limit, offset := arg(f.PerPage), arg((f.Page-1)*f.PerPage)
+
+	stmt := "SELECT id, created, action, idactor, target_type, idtarget, ip, request_id, details FROM auditLog" +
+		cond + " ORDER BY id DESC LIMIT " + limit + " OFFSET " + offset
+	rows, err := m.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &models.AuditEvent{}
+		var actor, target sql.NullInt64
+		err = rows.Scan(&e.ID, &e.Created, &e.Action, &actor, &e.TargetType, &target, &e.IP, &e.RequestID, &e.Details)
+		if err != nil {
+			return nil, err
+		}
+		e.ActorID = int(actor.Int64)
+		e.TargetID = int(target.Int64)
+		page.Events = append(page.Events, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
@@ -0,0 +1,92 @@
+package dbpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/lib/pq"
+	"log"
+	"net/url"
+	"time"
+)
+
+// uniqueViolation is the code of the errors of the unique constraints
+const uniqueViolation = "23505"
+
+type DBdata struct {
+	Server            string // host:port of the server
+	Dbase             string
+	Username          string
+	Password          string
+	SSLMode           string        // sslmode of the connection, "disable", "require", "verify-ca" or "verify-full"
+	ServerCA          string        // the CA of the server certificate, used by "verify-ca" and "verify-full"
+	DbConnMaxLifetime time.Duration // number of seconds
+	QueryTimeout      time.Duration // deadline of the queries of each operation of the models, zero disables it
+	MaxOpenConns      int           // the open connections of the pool, zero is unlimited
+	MaxIdleConns      int           // the idle connections kept by the pool
+	ConnMaxIdleTime   time.Duration // the idle connections are closed after this time, zero keeps them
+}
+
+// DB is the connection pool shared by the models
+type DB struct {
+	*sql.DB
+	// the deadline of the queries of each operation of the models, zero disables it
+	QueryTimeout time.Duration
+}
+
+// operation returns the context of an operation of a model, it is canceled with the context
+// of the request and after the QueryTimeout of the pool
+func (db *DB) operation(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.QueryTimeout)
+}
+
+// DSN returns the connection URL of the server, the ServerCA is found on the certsPath
+func (d DBdata) DSN(certsPath string) string {
+	q := url.Values{}
+	q.Set("sslmode", d.SSLMode)
+	if d.ServerCA != "" {
+		q.Set("sslrootcert", certsPath+d.ServerCA)
+	}
+	// the dates are compared and returned on UTC as on the other databases
+	q.Set("timezone", "UTC")
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.Username, d.Password),
+		Host:     d.Server,
+		Path:     "/" + d.Dbase,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+func DialDB(infoLog *log.Logger, dialData DBdata, certsPath string) (*DB, error) {
+	infoLog.Println("DialDB: dialing postgres database")
+
+	db, err := sql.Open("postgres", dialData.DSN(certsPath))
+	if err != nil {
+		return nil, fmt.Errorf("DialDB: %v\n", err)
+	}
+	db.SetConnMaxLifetime(dialData.DbConnMaxLifetime)
+	db.SetMaxOpenConns(dialData.MaxOpenConns)
+	db.SetMaxIdleConns(dialData.MaxIdleConns)
+	db.SetConnMaxIdleTime(dialData.ConnMaxIdleTime)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("DialDB: error on Ping: %v\n", err)
+	}
+
+	infoLog.Println("DialDB: connection to database is OK")
+	return &DB{DB: db, QueryTimeout: dialData.QueryTimeout}, nil
+}
+
+func CloseDB(infoLog *log.Logger, db *DB) error {
+	err := db.Close()
+	if err != nil {
+		return fmt.Errorf("CloseDB: CloseDB: %v", err)
+	}
+	infoLog.Println("CloseDB: closed dbpostgres connection")
+	return err
+}
@@ -0,0 +1,527 @@
+package dbpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/vgraveto/snippets/pkg/models"
+	"log"
+)
+
+// UserModel type which wraps a sql.DB connection pool and the hasher of the passwords.
+type UserModel struct {
+	db       *DB
+	hasher   models.PasswordHasher
+	errorLog *log.Logger
+}
+
+// NewUserModel creates a new UserModel, the new passwords are hashed by h and the failed
+// upgrades of the outdated hashes are logged to errorLog
+func NewUserModel(d *DB, h models.PasswordHasher, errorLog *log.Logger) *UserModel {
+	return &UserModel{db: d, hasher: h, errorLog: errorLog}
+}
+
+// Insert method used to add a new record to the users table and its roles to useRoleDetails table
+func (m *UserModel) Insert(ctx context.Context, name, email, password string, roles []int) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	_, err := m.insert(ctx, name, email, password, roles, true, false)
+	return err
+}
+
+// Register method used to add a new inactive user that registered himself, the user waits for
+// the approval of an administrator when approvalPending is true. Returns the ID of the new user.
+func (m *UserModel) Register(ctx context.Context, name, email, password string, roles []int, approvalPending bool) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	return m.insert(ctx, name, email, password, roles, false, approvalPending)
+}
+
+// insert adds the user and its roles in a single transaction and returns the ID of the new user
+func (m *UserModel) insert(ctx context.Context, name, email, password string, roles []int, active, approvalPending bool) (int, error) {
+	// Create a hash of the plain-text password.
+	hashedPassword, err := m.hasher.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	// begin a new transaction to impose that user is only inserted if everything is runs ok
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	stmt := `INSERT INTO users (name, email, hashed_password, created, active, approval_pending)` +
+		` VALUES($1, $2, $3, now(), $4, $5) RETURNING id`
+	// the id of the new user is returned by the insert as there is no LastInsertId on postgres
+	var idUser int
+	err = tx.QueryRowContext(ctx, stmt, name, email, hashedPassword, active, approvalPending).Scan(&idUser)
+	if err != nil {
+		// the unique_violation of the users_uc_email key is an ErrDuplicateEmail
+		var pqError *pq.Error
+		if errors.As(err, &pqError) {
+			if pqError.Code == uniqueViolation && pqError.Constraint == "users_uc_email" {
+				tx.Rollback()
+				return 0, models.ErrDuplicateEmail
+			}
+		}
+		tx.Rollback()
+		return 0, err
+	}
+
+	ok := true
+	if roles != nil {
+		// insert the user roles in userRoledetails table
+		stmt := `INSERT INTO userRolesDetails (iduser, idrole, created) VALUES($1, $2, now())`
+		for _, role := range roles {
+			_, err = tx.ExecContext(ctx, stmt, idUser, role)
+			if err != nil {
+				ok = false
+				break
+			}
+		}
+	}
+	if !ok {
+		err1 := tx.Rollback()
+		if err1 != nil {
+			return 0, fmt.Errorf("Insert: Rollback: %v: %v", err1, err)
+		}
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("Insert: Commit: %v", err)
+	}
+	return idUser, nil
+}
+
+// Activate method used to activate an user after the email verification
+func (m *UserModel) Activate(ctx context.Context, id int) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "UPDATE users SET active = TRUE, approval_pending = FALSE WHERE id = $1"
+	_, err := m.db.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// GetPending will return the registered users waiting for the approval of an administrator.
+func (m *UserModel) GetPending(ctx context.Context) ([]*models.User, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "SELECT id, name, email, created, active FROM users WHERE approval_pending = TRUE ORDER BY id"
+	rows, err := m.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		u := &models.User{}
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	// check for any errors on rows
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Approve method used by an administrator to decide on a pending registration, the user is
+// activated when approved, otherwise the registration is rejected and the user remains inactive
+func (m *UserModel) Approve(ctx context.Context, id int, approved bool) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "UPDATE users SET active = $1, approval_pending = FALSE WHERE id = $2 AND approval_pending = TRUE"
+	result, err := m.db.ExecContext(ctx, stmt, approved, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Authenticate method to verify whether a user exists with the provided email address and password.
+// This will return the relevant user ID if they do. The hash of the password is upgraded when it does
+// not use the current algorithm and parameters of the hasher.
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	// Retrieve the id and hashed password associated with the given email. If no
+	// matching email exists, or the user is not active, we return the
+	// ErrInvalidCredentials error.
+	var id int
+	var hashedPassword string
+	stmt := "SELECT id, hashed_password FROM users WHERE email = $1 AND active = TRUE"
+	row := m.db.QueryRowContext(ctx, stmt, email)
+	err := row.Scan(&id, &hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	// Check whether the hashed password and plain-text password provided match.
+	// If they don't, we return the ErrInvalidCredentials error.
+	err = m.hasher.Verify(hashedPassword, password)
+	if err != nil {
+		return 0, err
+	}
+
+	// Otherwise, the password is correct. Upgrade an outdated hash, a failed upgrade
+	// does not block the login as it is retried on the next one.
+	if m.hasher.NeedsRehash(hashedPassword) {
+		if err := m.rehash(ctx, id, hashedPassword, password); err != nil {
+			m.errorLog.Printf("UserModel: rehash of the password of the user %d: %v\n", id, err)
+		}
+	}
+
+	// Return the user ID.
+	return id, nil
+}
+
+// rehash replaces the outdated hash of the password of the user, unless it was changed meanwhile
+func (m *UserModel) rehash(ctx context.Context, id int, oldHash, password string) error {
+	newHash, err := m.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	stmt := "UPDATE users SET hashed_password = $1 WHERE id = $2 AND hashed_password = $3"
+	_, err = m.db.ExecContext(ctx, stmt, newHash, id, oldHash)
+	return err
+}
+
+// GetAll will return all the created users.
+func (m *UserModel) GetAll(ctx context.Context) ([]*models.User, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "SELECT id, name, email, created, active FROM users ORDER BY id DESC"
+	rows, err := m.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		// Create a pointer to a new zeroed User struct.
+		u := &models.User{}
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active)
+		if err != nil {
+			return nil, err
+		}
+		// get user Roles
+		uRoles, err := m.GetRoles(ctx, u.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				// no roles defined for this user
+				u.Roles = nil
+			} else {
+				return nil, err
+			}
+		}
+		u.Roles = *uRoles
+
+		// Append it to the slice of snippets.
+		users = append(users, u)
+	}
+	// check for any errors on rows
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// If everything went OK then return the users slice.
+	return users, nil
+}
+
+// Get method used to fetch details for a specific user based on their user ID.
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	u := &models.User{}
+	stmt := `SELECT id, name, email, created, active FROM users WHERE id = $1`
+	err := m.db.QueryRowContext(ctx, stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	// get user roles
+	uRoles, err := m.GetRoles(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			// no roles defined for this user
+			u.Roles = nil
+		} else {
+			return nil, err
+		}
+	}
+	u.Roles = *uRoles
+
+	return u, nil
+}
+
+// GetByEmail method used to fetch details for a specific user based on their email address.
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var id int
+	stmt := `SELECT id FROM users WHERE email = $1`
+	err := m.db.QueryRowContext(ctx, stmt, email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return m.Get(ctx, id)
+}
+
+// ChangePassword given the user ID, the current and the new passwords
+// Verify current password to allow password change
+func (m *UserModel) ChangePassword(ctx context.Context, id int, currentPassword, newPassword string) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var currentHashedPassword string
+	row := m.db.QueryRowContext(ctx, "SELECT hashed_password FROM users WHERE id = $1", id)
+	err := row.Scan(&currentHashedPassword)
+	if err != nil {
+		return err
+	}
+
+	err = m.hasher.Verify(currentHashedPassword, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	newHashedPassword, err := m.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	stmt := "UPDATE users SET hashed_password = $1 WHERE id = $2"
+	_, err = m.db.ExecContext(ctx, stmt, newHashedPassword, id)
+	return err
+}
+
+// ResetPassword given the user ID and the new passwords
+// Only used for administrator purpose
+func (m *UserModel) ResetPassword(ctx context.Context, id int, newPassword string) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+
+	newHashedPassword, err := m.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	stmt := "UPDATE users SET hashed_password = $1 WHERE id = $2"
+	_, err = m.db.ExecContext(ctx, stmt, newHashedPassword, id)
+	return err
+}
+
+// ResetPasswordByToken uses the reset password token and replaces the password of its user in a single
+// transaction, the token is left unused when the password is not changed
+func (m *UserModel) ResetPasswordByToken(ctx context.Context, token, newPassword string) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+
+	newHashedPassword, err := m.hasher.Hash(newPassword)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var idToken, idUser int
+	stmt := "SELECT id, iduser FROM userTokens" +
+		" WHERE token_hash = $1 AND purpose = $2 AND used IS NULL AND expires > now() FOR UPDATE"
+	err = tx.QueryRowContext(ctx, stmt, models.HashToken(token), models.TokenPurposeResetPassword).Scan(&idToken, &idUser)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidToken
+		}
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE userTokens SET used = now() WHERE id = $1", idToken)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE users SET hashed_password = $1 WHERE id = $2", newHashedPassword, idUser)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("ResetPasswordByToken: Commit: %v", err)
+	}
+	return idUser, nil
+}
+
+// GetRoleTypes obtains the existing role types from the database
+func (m *UserModel) GetRoleTypes(ctx context.Context) ([]*models.RoleType, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+
+	roles := []*models.RoleType{}
+	stmt := "SELECT id, role, description, created, mfa_required FROM roleTypes"
+	rows, err := m.db.QueryContext(ctx, stmt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rt := &models.RoleType{}
+		err = rows.Scan(&rt.ID, &rt.Role, &rt.Description, &rt.Created, &rt.MFARequired)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, rt)
+	}
+	// check for any errors on rows
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// If everything went OK then return the roles slice.
+	return roles, nil
+}
+
+// GetRoles obtains the roles of the user with the given id
+func (m *UserModel) GetRoles(ctx context.Context, id int) (*[]string, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+
+	userRoles := []string{}
+	stmt := "SELECT role FROM roleTypes,userRolesDetails WHERE roleTypes.id=userRolesDetails.idrole AND userRolesDetails.iduser=$1;"
+	rows, err := m.db.QueryContext(ctx, stmt, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role string
+		err = rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+		userRoles = append(userRoles, role)
+	}
+	// check for any errors on rows
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// If everything went OK then return the userRoles slice.
+	return &userRoles, nil
+}
+
+// AddRoles grants the roles the user does not have yet
+func (m *UserModel) AddRoles(ctx context.Context, id int, roles []int) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "INSERT INTO userRolesDetails (iduser, idrole, created) SELECT $1::integer, $2::integer, now()" +
+		" WHERE NOT EXISTS (SELECT id FROM userRolesDetails WHERE iduser = $1 AND idrole = $2)"
+	for _, role := range roles {
+		_, err := m.db.ExecContext(ctx, stmt, id, role)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Provision inserts an active user with a random password, the user logs in with
+// an OpenID Connect provider or sets a password with the forgot password flow
+func (m *UserModel) Provision(ctx context.Context, name, email string, roles []int) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	password, err := models.NewRandomToken()
+	if err != nil {
+		return 0, err
+	}
+	return m.insert(ctx, name, email, password, roles, true, false)
+}
+
+// GetByIdentity method used to fetch the user linked to the subject of an OpenID Connect issuer
+func (m *UserModel) GetByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var id int
+	stmt := `SELECT iduser FROM userIdentities WHERE issuer = $1 AND subject = $2`
+	err := m.db.QueryRowContext(ctx, stmt, issuer, subject).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return m.Get(ctx, id)
+}
+
+// LinkIdentity links the user to the subject of an OpenID Connect issuer
+func (m *UserModel) LinkIdentity(ctx context.Context, id int, issuer, subject string) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := `INSERT INTO userIdentities (iduser, issuer, subject, created) VALUES($1, $2, $3, now())`
+	_, err := m.db.ExecContext(ctx, stmt, id, issuer, subject)
+	return err
+}
+
+// SetRoleMFARequired defines if the users of the role must use two-factor authentication
+func (m *UserModel) SetRoleMFARequired(ctx context.Context, id int, required bool) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	result, err := m.db.ExecContext(ctx, "UPDATE roleTypes SET mfa_required = $1 WHERE id = $2", required, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// no rows are affected when the value is not changed
+		var exists bool
+		err = m.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT id FROM roleTypes WHERE id = $1)", id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return models.ErrNoRecord
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package dbsqlite
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vgraveto/snippets/pkg/models"
+	"strings"
+)
+
+// AuditModel type which wraps a sql.DB connection pool.
+type AuditModel struct {
+	db *DB
+}
+
+// NewAuditModel creates a new AuditModel
+func NewAuditModel(d *DB) *AuditModel {
+	return &AuditModel{db: d}
+}
+
+// maxAuditDetailsLen is the length of the details column of the auditLog table
+const maxAuditDetailsLen = 1024
+
+// Insert appends the event to the auditLog table, the zero actor and target IDs are stored as NULL
+func (m *AuditModel) Insert(ctx context.Context, e *models.AuditEvent) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	details := e.Details
+	if len(details) > maxAuditDetailsLen {
+		details = details[:maxAuditDetailsLen]
+	}
+	var actor, target sql.NullInt64
+	if e.ActorID > 0 {
+		actor = sql.NullInt64{Int64: int64(e.ActorID), Valid: true}
+	}
+	if e.TargetID > 0 {
+		target = sql.NullInt64{Int64: int64(e.TargetID), Valid: true}
+	}
+	stmt := "INSERT INTO auditLog (created, action, idactor, target_type, idtarget, ip, request_id, details)" +
+		" VALUES(datetime('now'), ?, ?, ?, ?, ?, ?, ?)"
+	_, err := m.db.ExecContext(ctx, stmt, e.Action, actor, e.TargetType, target, e.IP, e.RequestID, details)
+	return err
+}
+
+// GetAll returns the page of the events matching the filter, the most recent first
+func (m *AuditModel) GetAll(ctx context.Context, f *models.AuditFilter) (*models.AuditPage, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	var where []string
+	var args []interface{}
+	if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.ActorID > 0 {
+		where = append(where, "idactor = ?")
+		args = append(args, f.ActorID)
+	}
+	if f.TargetType != "" {
+		where = append(where, "target_type = ?")
+		args = append(args, f.TargetType)
+	}
+	if f.TargetID > 0 {
+		where = append(where, "idtarget = ?")
+		args = append(args, f.TargetID)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created >= ?")
+		args = append(args, timestamp(f.From))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created < ?")
+		args = append(args, timestamp(f.To))
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	page := &models.AuditPage{Events: []*models.AuditEvent{}, Page: f.Page, PerPage: f.PerPage}
+	err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM auditLog"+cond, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := "SELECT id, created, action, idactor, target_type, idtarget, ip, request_id, details FROM auditLog" +
+		cond + " ORDER BY id DESC LIMIT ? OFFSET ?"
+	rows, err := m.db.QueryContext(ctx, stmt, append(args, f.PerPage, (f.Page-1)*f.PerPage)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &models.AuditEvent{}
+		var actor, target sql.NullInt64
+		err = rows.Scan(&e.ID, &e.Created, &e.Action, &actor, &e.TargetType, &target, &e.IP, &e.RequestID, &e.Details)
+		if err != nil {
+			return nil, err
+		}
+		e.ActorID = int(actor.Int64)
+		e.TargetID = int(target.Int64)
+		page.Events = append(page.Events, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
@@ -0,0 +1,68 @@
+package dbsqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"log"
+	"time"
+)
+
+type DBdata struct {
+	Path         string        // the database file, created when it does not exist
+	QueryTimeout time.Duration // deadline of the queries of each operation of the models, zero disables it
+}
+
+// DB is the connection pool shared by the models
+type DB struct {
+	*sql.DB
+	// the deadline of the queries of each operation of the models, zero disables it
+	QueryTimeout time.Duration
+}
+
+// operation returns the context of an operation of a model, it is canceled with the context
+// of the request and after the QueryTimeout of the pool
+func (db *DB) operation(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.QueryTimeout)
+}
+
+// timestampLayout is the layout of the dates returned by the datetime('now') of SQLite,
+// the dates given to the queries use it so that they are compared as text
+const timestampLayout = "2006-01-02 15:04:05"
+
+// timestamp returns the UTC date of t on the layout of the dates stored on the database
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+func DialDB(infoLog *log.Logger, dialData DBdata) (*DB, error) {
+	infoLog.Printf("DialDB: opening sqlite database %q\n", dialData.Path)
+
+	// the foreign keys are not enforced by default, the transactions take the write lock
+	// when they begin so that the reads of a transaction are not made stale by other writers
+	dsn := "file:" + dialData.Path + "?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate&_journal_mode=WAL"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("DialDB: %v\n", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("DialDB: error on Ping: %v\n", err)
+	}
+
+	infoLog.Println("DialDB: connection to database is OK")
+	return &DB{DB: db, QueryTimeout: dialData.QueryTimeout}, nil
+}
+
+func CloseDB(infoLog *log.Logger, db *DB) error {
+	err := db.Close()
+	if err != nil {
+		return fmt.Errorf("CloseDB: CloseDB: %v", err)
+	}
+	infoLog.Println("CloseDB: closed dbsqlite connection")
+	return err
+}
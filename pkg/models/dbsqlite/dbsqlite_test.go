@@ -0,0 +1,110 @@
+package dbsqlite
+
+import (
+	"context"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+	"github.com/vgraveto/snippets/pkg/models/conformance"
+	"github.com/vgraveto/snippets/pkg/models/migrate"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"testing"
+)
+
+// newTestDB returns a new database on a temporary file with the migrations applied, closed at the end of the test
+func newTestDB(t *testing.T) *DB {
+	db, err := DialDB(log.New(ioutil.Discard, "", 0), DBdata{Path: filepath.Join(t.TempDir(), "snippets.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewMigrator(newTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every migration is reverted and applied again
+	for range migrations {
+		if _, err := m.Down(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Down(ctx); !errors.Is(err, migrate.ErrNoMigration) {
+		t.Errorf("want %v; got %v", migrate.ErrNoMigration, err)
+	}
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(migrations) {
+		t.Errorf("want %d migrations applied; got %d", len(migrations), len(done))
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.Applied == nil {
+			t.Errorf("want migration %d applied", s.Version)
+		}
+	}
+}
+
+func TestSnippetModel(t *testing.T) {
+	conformance.Snippets(t, NewSnippetModel(newTestDB(t)))
+}
+
+func TestUserModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conformance.Users(t, NewUserModel(newTestDB(t), h, log.New(ioutil.Discard, "", 0)))
+}
+
+func TestUserTokenModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	conformance.UserTokens(t, NewUserTokenModel(db), NewUserModel(db, h, log.New(ioutil.Discard, "", 0)))
+}
+
+func TestRefreshTokenModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	conformance.RefreshTokens(t, NewRefreshTokenModel(db), NewUserModel(db, h, log.New(ioutil.Discard, "", 0)))
+}
+
+func TestMFAModel(t *testing.T) {
+	hd := models.DefaultHasherData()
+	hd.BcryptCost = 4
+	h, err := models.NewPasswordHasher(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	conformance.MFA(t, NewMFAModel(db), NewUserModel(db, h, log.New(ioutil.Discard, "", 0)))
+}
@@ -0,0 +1,217 @@
+package dbsqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/vgraveto/snippets/pkg/models"
+	"time"
+)
+
+// MFAModel type which wraps a sql.DB connection pool.
+type MFAModel struct {
+	db *DB
+}
+
+// NewMFAModel creates a new MFAModel
+func NewMFAModel(d *DB) *MFAModel {
+	return &MFAModel{db: d}
+}
+
+// Get returns the two-factor authentication of the user, ErrNoRecord when never enrolled
+func (m *MFAModel) Get(ctx context.Context, userID int) (*models.MFAStatus, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	s := &models.MFAStatus{}
+	stmt := "SELECT secret, enabled, last_step," +
+		" (SELECT COUNT(*) FROM mfaRecoveryCodes WHERE iduser = userMFA.iduser AND used IS NULL)" +
+		" FROM userMFA WHERE iduser = ?"
+	err := m.db.QueryRowContext(ctx, stmt, userID).Scan(&s.Secret, &s.Enabled, &s.LastStep, &s.RecoveryCodesLeft)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// Enroll stores a new secret for the user, not used until the enrollment is confirmed.
+// An enabled two-factor authentication is not changed.
+func (m *MFAModel) Enroll(ctx context.Context, userID int, secret string) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "INSERT INTO userMFA (iduser, secret, enabled, last_step, created) VALUES(?, ?, FALSE, 0, datetime('now'))" +
+		" ON CONFLICT (iduser) DO UPDATE SET secret = CASE WHEN enabled THEN secret ELSE excluded.secret END," +
+		" created = CASE WHEN enabled THEN created ELSE excluded.created END"
+	_, err := m.db.ExecContext(ctx, stmt, userID, secret)
+	return err
+}
+
+// Enable confirms the enrollment and replaces the recovery codes of the user
+func (m *MFAModel) Enable(ctx context.Context, userID int, recoveryCodes []string) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = m.enable(ctx, tx, userID, recoveryCodes)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return fmt.Errorf("Enable: Rollback: %v: %v", err1, err)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// enable runs the statements of Enable in the transaction
+func (m *MFAModel) enable(ctx context.Context, tx *sql.Tx, userID int, recoveryCodes []string) error {
+	result, err := tx.ExecContext(ctx, "UPDATE userMFA SET enabled = TRUE WHERE iduser = ?", userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// the row is not affected when already enabled
+		var exists bool
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT iduser FROM userMFA WHERE iduser = ?)", userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return models.ErrNoRecord
+		}
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM mfaRecoveryCodes WHERE iduser = ?", userID)
+	if err != nil {
+		return err
+	}
+	stmt := "INSERT INTO mfaRecoveryCodes (iduser, code_hash) VALUES(?, ?)"
+	for _, code := range recoveryCodes {
+		_, err = tx.ExecContext(ctx, stmt, userID, models.HashToken(models.NormalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Disable removes the two-factor authentication and the recovery codes of the user
+func (m *MFAModel) Disable(ctx context.Context, userID int) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM mfaRecoveryCodes WHERE iduser = ?", userID)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "DELETE FROM userMFA WHERE iduser = ?", userID)
+	}
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return fmt.Errorf("Disable: Rollback: %v: %v", err1, err)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseStep records the time step of a valid TOTP code, false when a code of the step, or a later one, was used
+func (m *MFAModel) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "UPDATE userMFA SET last_step = ? WHERE iduser = ? AND last_step < ?"
+	result, err := m.db.ExecContext(ctx, stmt, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// UseRecoveryCode marks the recovery code as used, false when the code is unknown or already used
+func (m *MFAModel) UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "UPDATE mfaRecoveryCodes SET used = datetime('now') WHERE id =" +
+		" (SELECT id FROM mfaRecoveryCodes WHERE iduser = ? AND code_hash = ? AND used IS NULL LIMIT 1)"
+	result, err := m.db.ExecContext(ctx, stmt, userID, models.HashToken(models.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// NewChallenge creates a login challenge for the user and returns its plain-text value,
+// only the hash of the challenge is stored
+func (m *MFAModel) NewChallenge(ctx context.Context, userID int, validTime time.Duration) (string, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	challenge, err := models.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+	// remove the expired challenges
+	_, err = m.db.ExecContext(ctx, "DELETE FROM mfaChallenges WHERE expires <= datetime('now')")
+	if err != nil {
+		return "", err
+	}
+	stmt := "INSERT INTO mfaChallenges (iduser, challenge_hash, expires, attempts) VALUES(?, ?, ?, 0)"
+	_, err = m.db.ExecContext(ctx, stmt, userID, models.HashToken(challenge), timestamp(time.Now().Add(validTime)))
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// CheckChallenge counts an attempt and returns the user of the challenge,
+// ErrInvalidToken is returned when the challenge is unknown, expired or has no attempts left
+func (m *MFAModel) CheckChallenge(ctx context.Context, challenge string, maxAttempts int) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	hash := models.HashToken(challenge)
+	stmt := "UPDATE mfaChallenges SET attempts = attempts + 1" +
+		" WHERE challenge_hash = ? AND expires > datetime('now') AND attempts < ?"
+	result, err := m.db.ExecContext(ctx, stmt, hash, maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, models.ErrInvalidToken
+	}
+	var userID int
+	err = m.db.QueryRowContext(ctx, "SELECT iduser FROM mfaChallenges WHERE challenge_hash = ?", hash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidToken
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// DeleteChallenge removes the challenge after the login
+func (m *MFAModel) DeleteChallenge(ctx context.Context, challenge string) error {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	_, err := m.db.ExecContext(ctx, "DELETE FROM mfaChallenges WHERE challenge_hash = ?", models.HashToken(challenge))
+	return err
+}
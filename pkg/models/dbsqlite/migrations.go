@@ -0,0 +1,225 @@
+package dbsqlite
+
+import (
+	"github.com/vgraveto/snippets/pkg/models/migrate"
+)
+
+// migrations are the changes of the schema on the types of SQLite, the same tables of the other
+// databases. The role types of the administrators and of the registered users are seeded.
+var migrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create tables",
+		Up:      []string{createTables},
+		Down: []string{
+			"DROP TABLE IF EXISTS auditLog",
+			"DROP TABLE IF EXISTS loginAttempts",
+			"DROP TABLE IF EXISTS mfaChallenges",
+			"DROP TABLE IF EXISTS mfaRecoveryCodes",
+			"DROP TABLE IF EXISTS userMFA",
+			"DROP TABLE IF EXISTS apiKeys",
+			"DROP TABLE IF EXISTS revokedTokens",
+			"DROP TABLE IF EXISTS refreshTokens",
+			"DROP TABLE IF EXISTS userSessions",
+			"DROP TABLE IF EXISTS userTokens",
+			"DROP TABLE IF EXISTS userIdentities",
+			"DROP TABLE IF EXISTS userRolesDetails",
+			"DROP TABLE IF EXISTS roleTypes",
+			"DROP TABLE IF EXISTS users",
+			"DROP TABLE IF EXISTS snippets",
+		},
+	},
+	{
+		Version: 2,
+		Name:    "seed role types",
+		Up: []string{`
+INSERT OR IGNORE INTO roleTypes (role, description) VALUES
+  ('administrator', 'Manages the users of the application'),
+  ('user', 'Creates and reads snippets')`},
+		Down: []string{`
+DELETE FROM roleTypes WHERE role IN ('administrator', 'user')
+  AND id NOT IN (SELECT idrole FROM userRolesDetails)`},
+	},
+}
+
+// dialect creates the schema_migrations table of SQLite
+var dialect = migrate.Dialect{
+	CreateTable: "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY," +
+		" name VARCHAR(255) NOT NULL, applied DATETIME NOT NULL)",
+	Placeholder: migrate.QuestionPlaceholder,
+}
+
+// NewMigrator returns the migrator of the schema of the database
+func NewMigrator(db *DB) (*migrate.Migrator, error) {
+	return migrate.New(db.DB, dialect, migrations)
+}
+
+// createTables creates the tables of the models, the tables that exist are kept so that the
+// databases created before the migrations are upgraded
+const createTables = `
+CREATE TABLE IF NOT EXISTS snippets (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  title VARCHAR(100) NOT NULL,
+  content TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  CONSTRAINT title_UNIQUE UNIQUE (title)
+);
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets (created);
+
+CREATE TABLE IF NOT EXISTS users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  hashed_password VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  approval_pending BOOLEAN NOT NULL DEFAULT FALSE,
+  CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS roleTypes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  role VARCHAR(45) NOT NULL,
+  description VARCHAR(45) NOT NULL,
+  created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  mfa_required BOOLEAN NOT NULL DEFAULT FALSE,
+  CONSTRAINT name_UNIQUE UNIQUE (role)
+);
+
+CREATE TABLE IF NOT EXISTS userRolesDetails (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iduser INTEGER NOT NULL REFERENCES users (id),
+  idrole INTEGER NOT NULL REFERENCES roleTypes (id),
+  created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS iduser_idx ON userRolesDetails (iduser);
+CREATE INDEX IF NOT EXISTS idrole_idx ON userRolesDetails (idrole);
+
+CREATE TABLE IF NOT EXISTS userIdentities (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iduser INTEGER NOT NULL REFERENCES users (id),
+  issuer VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL,
+  CONSTRAINT userIdentities_uc_issuer_subject UNIQUE (issuer, subject)
+);
+CREATE INDEX IF NOT EXISTS userIdentities_iduser_idx ON userIdentities (iduser);
+
+CREATE TABLE IF NOT EXISTS userTokens (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iduser INTEGER NOT NULL REFERENCES users (id),
+  purpose VARCHAR(45) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  used DATETIME DEFAULT NULL,
+  CONSTRAINT userTokens_uc_token_hash UNIQUE (token_hash)
+);
+CREATE INDEX IF NOT EXISTS userTokens_iduser_idx ON userTokens (iduser);
+
+CREATE TABLE IF NOT EXISTS userSessions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iduser INTEGER NOT NULL REFERENCES users (id),
+  user_agent VARCHAR(255) NOT NULL,
+  ip VARCHAR(45) NOT NULL,
+  created DATETIME NOT NULL,
+  last_seen DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  revoked DATETIME DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS userSessions_iduser_idx ON userSessions (iduser);
+
+CREATE TABLE IF NOT EXISTS refreshTokens (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iduser INTEGER NOT NULL REFERENCES users (id),
+  family CHAR(43) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  revoked DATETIME DEFAULT NULL,
+  idsession INTEGER DEFAULT NULL REFERENCES userSessions (id),
+  CONSTRAINT refreshTokens_uc_token_hash UNIQUE (token_hash)
+);
+CREATE INDEX IF NOT EXISTS refreshTokens_family_idx ON refreshTokens (family);
+CREATE INDEX IF NOT EXISTS refreshTokens_iduser_idx ON refreshTokens (iduser);
+CREATE INDEX IF NOT EXISTS refreshTokens_idsession_idx ON refreshTokens (idsession);
+
+CREATE TABLE IF NOT EXISTS revokedTokens (
+  jti VARCHAR(64) NOT NULL PRIMARY KEY,
+  expires DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS revokedTokens_expires_idx ON revokedTokens (expires);
+
+CREATE TABLE IF NOT EXISTS apiKeys (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iduser INTEGER NOT NULL REFERENCES users (id),
+  name VARCHAR(255) NOT NULL,
+  prefix VARCHAR(12) NOT NULL,
+  key_hash CHAR(64) NOT NULL,
+  scopes VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME DEFAULT NULL,
+  last_used DATETIME DEFAULT NULL,
+  revoked DATETIME DEFAULT NULL,
+  CONSTRAINT apiKeys_uc_key_hash UNIQUE (key_hash)
+);
+CREATE INDEX IF NOT EXISTS apiKeys_iduser_idx ON apiKeys (iduser);
+
+CREATE TABLE IF NOT EXISTS userMFA (
+  iduser INTEGER NOT NULL PRIMARY KEY REFERENCES users (id),
+  secret VARCHAR(64) NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  last_step BIGINT NOT NULL DEFAULT 0,
+  created DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS mfaRecoveryCodes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iduser INTEGER NOT NULL REFERENCES users (id),
+  code_hash CHAR(64) NOT NULL,
+  used DATETIME DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS mfaRecoveryCodes_iduser_idx ON mfaRecoveryCodes (iduser);
+
+CREATE TABLE IF NOT EXISTS mfaChallenges (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iduser INTEGER NOT NULL REFERENCES users (id),
+  challenge_hash CHAR(64) NOT NULL,
+  expires DATETIME NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  CONSTRAINT mfaChallenges_uc_challenge_hash UNIQUE (challenge_hash)
+);
+CREATE INDEX IF NOT EXISTS mfaChallenges_iduser_idx ON mfaChallenges (iduser);
+
+CREATE TABLE IF NOT EXISTS loginAttempts (
+  attempt_key VARCHAR(320) NOT NULL PRIMARY KEY,
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_failure DATETIME NOT NULL,
+  locked_until DATETIME DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS auditLog (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  created DATETIME NOT NULL,
+  action VARCHAR(32) NOT NULL,
+  idactor INTEGER DEFAULT NULL,
+  target_type VARCHAR(16) NOT NULL,
+  idtarget INTEGER DEFAULT NULL,
+  ip VARCHAR(45) NOT NULL,
+  request_id VARCHAR(64) NOT NULL,
+  details VARCHAR(1024) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS auditLog_created_idx ON auditLog (created);
+CREATE INDEX IF NOT EXISTS auditLog_action_idx ON auditLog (action);
+CREATE INDEX IF NOT EXISTS auditLog_idactor_idx ON auditLog (idactor);
+CREATE INDEX IF NOT EXISTS auditLog_target_idx ON auditLog (target_type, idtarget);
+CREATE TRIGGER IF NOT EXISTS auditLog_no_update BEFORE UPDATE ON auditLog
+BEGIN
+  SELECT RAISE(ABORT, 'auditLog is append-only');
+END;
+CREATE TRIGGER IF NOT EXISTS auditLog_no_delete BEFORE DELETE ON auditLog
+BEGIN
+  SELECT RAISE(ABORT, 'auditLog is append-only');
+END;
+`
@@ -0,0 +1,79 @@
+package dbsqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/vgraveto/snippets/pkg/models"
+)
+
+// SnippetModel type which wraps a sql.DB connection pool.
+type SnippetModel struct {
+	db *DB
+}
+
+// NewSnippetModel creates a new SnippetModel
+func NewSnippetModel(d *DB) *SnippetModel {
+	return &SnippetModel{db: d}
+}
+
+// Insert will insert a new snippet into the database.and return its id
+func (m *SnippetModel) Insert(ctx context.Context, title, content, expires string) (int, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "INSERT INTO snippets (title, content, created, expires)" +
+		" VALUES(?, ?, datetime('now'), datetime('now', '+' || ? || ' days'))"
+	result, err := m.db.ExecContext(ctx, stmt, title, content, expires)
+	if err != nil {
+		return -1, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	return int(id), nil
+}
+
+// Get will return a specific snippet based on its id.
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "SELECT id, title, content, created, expires FROM snippets" +
+		" WHERE expires > datetime('now') AND id = ?"
+	s := &models.Snippet{}
+	err := m.db.QueryRowContext(ctx, stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// Latest will return the 10 most recently created snippets.
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	ctx, cancel := m.db.operation(ctx)
+	defer cancel()
+	stmt := "SELECT id, title, content, created, expires FROM snippets WHERE expires > datetime('now') ORDER BY created DESC LIMIT 10"
+	rows, err := m.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	// check for any errors on rows
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
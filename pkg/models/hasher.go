@@ -0,0 +1,186 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	// HashBcrypt hashes the passwords with bcrypt
+	HashBcrypt = "bcrypt"
+	// HashArgon2id hashes the passwords with argon2id, encoded as $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+	HashArgon2id = "argon2id"
+)
+
+// ErrUnknownHash is returned when the stored hash of a password does not use a known algorithm
+var ErrUnknownHash = errors.New("models: unknown password hash")
+
+// PasswordHasher hashes and verifies the passwords of the users
+type PasswordHasher interface {
+	// Hash returns the encoded hash of the password with the algorithm and parameters of the hasher
+	Hash(password string) (string, error)
+	// Verify returns ErrInvalidCredentials when the password does not match the hash,
+	// the hashes of any known algorithm and parameters are verified
+	Verify(hash, password string) error
+	// NeedsRehash returns true when the hash does not use the algorithm and parameters of the hasher
+	NeedsRehash(hash string) bool
+}
+
+// HasherData holds the algorithm and parameters of the new password hashes
+type HasherData struct {
+	// Algorithm is HashBcrypt or HashArgon2id
+	Algorithm string
+	// BcryptCost is the bcrypt cost, between bcrypt.MinCost and bcrypt.MaxCost
+	BcryptCost int
+	// Argon2Time is the number of passes, Argon2Memory the memory in KiB and Argon2Threads the parallelism
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+	// Argon2SaltLength and Argon2KeyLength are in bytes
+	Argon2SaltLength uint32
+	Argon2KeyLength  uint32
+}
+
+// DefaultHasherData returns bcrypt with cost 12 and the argon2id parameters recommended by RFC 9106
+func DefaultHasherData() HasherData {
+	return HasherData{
+		Algorithm:        HashBcrypt,
+		BcryptCost:       12,
+		Argon2Time:       3,
+		Argon2Memory:     64 * 1024,
+		Argon2Threads:    2,
+		Argon2SaltLength: 16,
+		Argon2KeyLength:  32,
+	}
+}
+
+// passwordHasher hashes with the algorithm of the data and verifies bcrypt and argon2id hashes
+type passwordHasher struct {
+	data HasherData
+}
+
+// NewPasswordHasher returns a hasher with the algorithm and parameters of the data
+func NewPasswordHasher(d HasherData) (PasswordHasher, error) {
+	switch d.Algorithm {
+	case HashBcrypt:
+		if d.BcryptCost < bcrypt.MinCost || d.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("NewPasswordHasher: invalid bcrypt cost %d", d.BcryptCost)
+		}
+	case HashArgon2id:
+		if d.Argon2Time < 1 || d.Argon2Memory < 8*uint32(d.Argon2Threads) || d.Argon2Threads < 1 ||
+			d.Argon2SaltLength < 8 || d.Argon2KeyLength < 16 {
+			return nil, fmt.Errorf("NewPasswordHasher: invalid argon2id parameters")
+		}
+	default:
+		return nil, fmt.Errorf("NewPasswordHasher: unknown algorithm %q", d.Algorithm)
+	}
+	return &passwordHasher{data: d}, nil
+}
+
+// Hash returns the encoded hash of the password
+func (h *passwordHasher) Hash(password string) (string, error) {
+	if h.data.Algorithm == HashBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.data.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.data.Argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := argon2Params{
+		time:    h.data.Argon2Time,
+		memory:  h.data.Argon2Memory,
+		threads: h.data.Argon2Threads,
+		salt:    salt,
+	}
+	p.key = argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, h.data.Argon2KeyLength)
+	return p.encode(), nil
+}
+
+// Verify returns ErrInvalidCredentials when the password does not match the hash
+func (h *passwordHasher) Verify(hash, password string) error {
+	if strings.HasPrefix(hash, "$"+HashArgon2id+"$") {
+		p, err := decodeArgon2(hash)
+		if err != nil {
+			return err
+		}
+		key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+		if subtle.ConstantTimeCompare(key, p.key) != 1 {
+			return ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	return nil
+}
+
+// NeedsRehash returns true when the hash does not use the algorithm and parameters of the hasher
+func (h *passwordHasher) NeedsRehash(hash string) bool {
+	if h.data.Algorithm == HashBcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.data.BcryptCost
+	}
+	p, err := decodeArgon2(hash)
+	return err != nil || p.time != h.data.Argon2Time || p.memory != h.data.Argon2Memory ||
+		p.threads != h.data.Argon2Threads || uint32(len(p.salt)) != h.data.Argon2SaltLength ||
+		uint32(len(p.key)) != h.data.Argon2KeyLength
+}
+
+// argon2Params are the values of an encoded argon2id hash
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// encode returns the hash in the format of the reference implementation
+func (p *argon2Params) encode() string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", HashArgon2id, argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(p.salt), base64.RawStdEncoding.EncodeToString(p.key))
+}
+
+// decodeArgon2 parses an encoded argon2id hash
+func decodeArgon2(hash string) (*argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return nil, ErrUnknownHash
+	}
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("%w: argon2id version %q", ErrUnknownHash, parts[2])
+	}
+	p := &argon2Params{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads)
+	if err != nil {
+		return nil, fmt.Errorf("%w: argon2id parameters %q", ErrUnknownHash, parts[3])
+	}
+	p.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("%w: argon2id salt: %v", ErrUnknownHash, err)
+	}
+	p.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(p.key) == 0 {
+		return nil, fmt.Errorf("%w: argon2id key", ErrUnknownHash)
+	}
+	return p, nil
+}
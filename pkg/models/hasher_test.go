@@ -0,0 +1,187 @@
+package models
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+// testArgon2 returns argon2id parameters small enough for the tests
+func testArgon2() HasherData {
+	d := DefaultHasherData()
+	d.Algorithm = HashArgon2id
+	d.Argon2Time = 1
+	d.Argon2Memory = 64
+	d.Argon2Threads = 1
+	return d
+}
+
+func newHasher(t *testing.T, d HasherData) PasswordHasher {
+	t.Helper()
+	h, err := NewPasswordHasher(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	bcryptCost := func(cost int) HasherData {
+		d := DefaultHasherData()
+		d.BcryptCost = cost
+		return d
+	}
+	argon2 := func(change func(d *HasherData)) HasherData {
+		d := testArgon2()
+		change(&d)
+		return d
+	}
+	tests := []struct {
+		name string
+		data HasherData
+	}{
+		{"bcrypt cost below the minimum", bcryptCost(bcrypt.MinCost - 1)},
+		{"bcrypt cost above the maximum", bcryptCost(bcrypt.MaxCost + 1)},
+		{"argon2id without passes", argon2(func(d *HasherData) { d.Argon2Time = 0 })},
+		{"argon2id without threads", argon2(func(d *HasherData) { d.Argon2Threads = 0 })},
+		{"argon2id memory below 8 KiB per thread", argon2(func(d *HasherData) { d.Argon2Memory = 8*uint32(d.Argon2Threads) - 1 })},
+		{"argon2id short salt", argon2(func(d *HasherData) { d.Argon2SaltLength = 7 })},
+		{"argon2id short key", argon2(func(d *HasherData) { d.Argon2KeyLength = 15 })},
+		{"unknown algorithm", argon2(func(d *HasherData) { d.Algorithm = "scrypt" })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPasswordHasher(tt.data); err == nil {
+				t.Error("want an error; got nil")
+			}
+		})
+	}
+}
+
+func TestHasherBcrypt(t *testing.T) {
+	d := DefaultHasherData()
+	d.BcryptCost = bcrypt.MinCost
+	h := newHasher(t, d)
+
+	hash, err := h.Hash("pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost != bcrypt.MinCost {
+		t.Errorf("want a bcrypt hash of cost %d; got %q", bcrypt.MinCost, hash)
+	}
+	if err := h.Verify(hash, "pa$$word"); err != nil {
+		t.Errorf("want the password verified; got %v", err)
+	}
+	if err := h.Verify(hash, "password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("want ErrInvalidCredentials; got %v", err)
+	}
+	if h.NeedsRehash(hash) {
+		t.Error("want no rehash of a hash with the cost of the hasher")
+	}
+
+	// a new cost verifies the former hashes and upgrades them
+	d.BcryptCost = bcrypt.MinCost + 1
+	h2 := newHasher(t, d)
+	if err := h2.Verify(hash, "pa$$word"); err != nil {
+		t.Errorf("want the hash of the former cost verified; got %v", err)
+	}
+	if !h2.NeedsRehash(hash) {
+		t.Error("want a rehash of the hash of the former cost")
+	}
+
+	// the argon2id hashes are verified and replaced by bcrypt ones
+	argon2Hash, err := newHasher(t, testArgon2()).Hash("pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Verify(argon2Hash, "pa$$word"); err != nil {
+		t.Errorf("want the argon2id hash verified; got %v", err)
+	}
+	if !h.NeedsRehash(argon2Hash) {
+		t.Error("want a rehash of the argon2id hash")
+	}
+}
+
+func TestHasherArgon2id(t *testing.T) {
+	d := testArgon2()
+	h := newHasher(t, d)
+
+	hash, err := h.Hash("pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("want the encoding of the reference implementation; got %q", hash)
+	}
+	if err := h.Verify(hash, "pa$$word"); err != nil {
+		t.Errorf("want the password verified; got %v", err)
+	}
+	if err := h.Verify(hash, "password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("want ErrInvalidCredentials; got %v", err)
+	}
+	if h.NeedsRehash(hash) {
+		t.Error("want no rehash of a hash with the parameters of the hasher")
+	}
+	if again, err := h.Hash("pa$$word"); err != nil || again == hash {
+		t.Errorf("want a new salt on each hash; got %q, %v", again, err)
+	}
+
+	// the decoded hash is encoded back unchanged
+	p, err := decodeArgon2(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.time != d.Argon2Time || p.memory != d.Argon2Memory || p.threads != d.Argon2Threads ||
+		uint32(len(p.salt)) != d.Argon2SaltLength || uint32(len(p.key)) != d.Argon2KeyLength {
+		t.Errorf("want the parameters of the hasher; got %+v", p)
+	}
+	if got := p.encode(); got != hash {
+		t.Errorf("want %q; got %q", hash, got)
+	}
+
+	// each new parameter verifies the former hashes and upgrades them
+	changes := map[string]func(d *HasherData){
+		"time":    func(d *HasherData) { d.Argon2Time++ },
+		"memory":  func(d *HasherData) { d.Argon2Memory *= 2 },
+		"threads": func(d *HasherData) { d.Argon2Threads++ },
+		"salt":    func(d *HasherData) { d.Argon2SaltLength++ },
+		"key":     func(d *HasherData) { d.Argon2KeyLength++ },
+		"bcrypt":  func(d *HasherData) { d.Algorithm, d.BcryptCost = HashBcrypt, bcrypt.MinCost },
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			d2 := d
+			change(&d2)
+			h2 := newHasher(t, d2)
+			if err := h2.Verify(hash, "pa$$word"); err != nil {
+				t.Errorf("want the hash of the former parameters verified; got %v", err)
+			}
+			if !h2.NeedsRehash(hash) {
+				t.Error("want a rehash of the hash of the former parameters")
+			}
+		})
+	}
+}
+
+func TestHasherUnknownHash(t *testing.T) {
+	h := newHasher(t, testArgon2())
+	hashes := []string{
+		"",
+		"plain-text",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5aw",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5aw",
+		"$argon2id$v=19$m=64;t=1;p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5aw",
+		"$argon2id$v=19$m=64,t=1,p=1$not base64!$a2V5a2V5a2V5a2V5a2V5aw",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$",
+	}
+	for _, hash := range hashes {
+		if err := h.Verify(hash, "pa$$word"); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("%q: want ErrUnknownHash; got %v", hash, err)
+		}
+		if !h.NeedsRehash(hash) {
+			t.Errorf("%q: want a rehash of an unknown hash", hash)
+		}
+	}
+}
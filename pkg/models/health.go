@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// the status of the database returned by the health checks
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+// Health is the result of the last health check of the database
+type Health struct {
+	// HealthUp or HealthDown
+	Status string `json:"status"`
+	// the time of the last check
+	Checked time.Time `json:"checked"`
+	// the error of the last check when down
+	Error string `json:"error,omitempty"`
+}
+
+// PoolStats are the statistics of the connection pool of the database
+type PoolStats struct {
+	MaxOpenConnections int   `json:"maxOpenConnections"`
+	OpenConnections    int   `json:"openConnections"`
+	InUse              int   `json:"inUse"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"waitCount"`
+	// the total time waited for a connection, in milliseconds
+	WaitDuration      int64 `json:"waitDuration"`
+	MaxIdleClosed     int64 `json:"maxIdleClosed"`
+	MaxIdleTimeClosed int64 `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed int64 `json:"maxLifetimeClosed"`
+}
+
+// DBHealth checks the connection pool of a database periodically, it keeps the result of the
+// last check so the health requests do not reach the database
+type DBHealth struct {
+	db       *sql.DB
+	interval time.Duration
+	timeout  time.Duration
+	infoLog  *log.Logger
+	errorLog *log.Logger
+
+	mu     sync.RWMutex
+	health Health
+}
+
+// NewDBHealth returns the health checks of the pool done on every interval, each one is
+// aborted after the timeout, the interval when zero. The database is up until the first check.
+func NewDBHealth(db *sql.DB, interval, timeout time.Duration, infoLog, errorLog *log.Logger) *DBHealth {
+	if timeout <= 0 {
+		timeout = interval
+	}
+	return &DBHealth{
+		db:       db,
+		interval: interval,
+		timeout:  timeout,
+		infoLog:  infoLog,
+		errorLog: errorLog,
+		health:   Health{Status: HealthUp, Checked: time.Now().UTC()},
+	}
+}
+
+// Run checks the database on every interval until the context is cancelled
+func (h *DBHealth) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Check(ctx)
+		}
+	}
+}
+
+// Check pings the database and returns the result, the changes of the status are logged
+func (h *DBHealth) Check(ctx context.Context) Health {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	err := h.db.PingContext(ctx)
+
+	health := Health{Status: HealthUp, Checked: time.Now().UTC()}
+	if err != nil {
+		health.Status = HealthDown
+		health.Error = err.Error()
+	}
+
+	h.mu.Lock()
+	previous := h.health.Status
+	h.health = health
+	h.mu.Unlock()
+
+	if err != nil && previous == HealthUp {
+		h.errorLog.Printf("DBHealth: the database is down: %v\n", err)
+	} else if err == nil && previous == HealthDown {
+		h.infoLog.Println("DBHealth: the database is up again")
+	}
+	return health
+}
+
+// Health returns the result of the last check
+func (h *DBHealth) Health() Health {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.health
+}
+
+// Stats returns the statistics of the connection pool
+func (h *DBHealth) Stats() *PoolStats {
+	s := h.db.Stats()
+	return &PoolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
@@ -0,0 +1,311 @@
+package models
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"math/big"
+)
+
+const (
+	// TokenAlgHS256 signs the tokens with the shared signingKey
+	TokenAlgHS256 = "HS256"
+	// TokenAlgRS256 signs the tokens with a RSA private key
+	TokenAlgRS256 = "RS256"
+	// TokenAlgES256 signs the tokens with an ECDSA P-256 private key
+	TokenAlgES256 = "ES256"
+	// TokenAlgEdDSA signs the tokens with an Ed25519 private key
+	TokenAlgEdDSA = "EdDSA"
+)
+
+// SigningMethodEdDSA implements the EdDSA signing method with Ed25519 keys
+// as jwt-go does not provide it
+type SigningMethodEdDSA struct{}
+
+func init() {
+	jwt.RegisterSigningMethod(TokenAlgEdDSA, func() jwt.SigningMethod {
+		return &SigningMethodEdDSA{}
+	})
+}
+
+// Alg returns the name of the signing method
+func (m *SigningMethodEdDSA) Alg() string {
+	return TokenAlgEdDSA
+}
+
+// Sign signs the signingString with an ed25519.PrivateKey
+func (m *SigningMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	k, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(k, []byte(signingString))), nil
+}
+
+// Verify verifies the signature of the signingString with an ed25519.PublicKey
+func (m *SigningMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	k, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(k, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// JWK defines the structure of a public key in the JSON Web Key format (RFC 7517)
+// swagger:model
+type JWK struct {
+	// the key type - "RSA", "EC" or "OKP"
+	Kty string `json:"kty"`
+	// the intended use of the key - always "sig"
+	Use string `json:"use,omitempty"`
+	// the algorithm of the tokens signed with the key
+	Alg string `json:"alg,omitempty"`
+	// the key ID used on the "kid" header of the tokens
+	Kid string `json:"kid,omitempty"`
+	// the RSA modulus
+	N string `json:"n,omitempty"`
+	// the RSA public exponent
+	E string `json:"e,omitempty"`
+	// the curve of EC and OKP keys
+	Crv string `json:"crv,omitempty"`
+	// the x coordinate of EC keys or the public key of OKP keys
+	X string `json:"x,omitempty"`
+	// the y coordinate of EC keys
+	Y string `json:"y,omitempty"`
+}
+
+// JWKSet defines the structure of a JSON Web Key Set
+// swagger:model
+type JWKSet struct {
+	// the public keys that verify the tokens
+	Keys []JWK `json:"keys"`
+}
+
+// PublicKey returns the public key described by the JWK
+func (j *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("PublicKey: n: %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("PublicKey: e: %v", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("PublicKey: unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("PublicKey: x: %v", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("PublicKey: y: %v", err)
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("PublicKey: point not on curve")
+		}
+		return key, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("PublicKey: unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("PublicKey: x: %v", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("PublicKey: invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("PublicKey: unsupported key type %q", j.Kty)
+	}
+}
+
+// tokenKey is a public key able to verify the tokens signed with its alg
+type tokenKey struct {
+	alg string
+	key crypto.PublicKey
+	jwk JWK
+}
+
+// newTokenKey returns the tokenKey of the public key with the key ID set to its JWK thumbprint (RFC 7638)
+func newTokenKey(pub crypto.PublicKey) (*tokenKey, error) {
+	var tk tokenKey
+	var thumbprint []byte
+	var err error
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		tk.alg = TokenAlgRS256
+		tk.jwk = JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+		thumbprint, err = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{tk.jwk.E, tk.jwk.Kty, tk.jwk.N})
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("newTokenKey: unsupported curve %s", k.Curve.Params().Name)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		tk.alg = TokenAlgES256
+		tk.jwk = JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(padBytes(k.X.Bytes(), size)),
+			Y:   base64.RawURLEncoding.EncodeToString(padBytes(k.Y.Bytes(), size)),
+		}
+		thumbprint, err = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{tk.jwk.Crv, tk.jwk.Kty, tk.jwk.X, tk.jwk.Y})
+	case ed25519.PublicKey:
+		tk.alg = TokenAlgEdDSA
+		tk.jwk = JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}
+		thumbprint, err = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{tk.jwk.Crv, tk.jwk.Kty, tk.jwk.X})
+	default:
+		return nil, fmt.Errorf("newTokenKey: unsupported key type %T", pub)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("newTokenKey: %v", err)
+	}
+	sum := sha256.Sum256(thumbprint)
+	tk.key = pub
+	tk.jwk.Use = "sig"
+	tk.jwk.Alg = tk.alg
+	tk.jwk.Kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	return &tk, nil
+}
+
+// NewJWK returns the JWK of the public key with the key ID set to its JWK thumbprint (RFC 7638)
+func NewJWK(pub crypto.PublicKey) (*JWK, error) {
+	tk, err := newTokenKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &tk.jwk, nil
+}
+
+// padBytes left pads b with zeros up to size bytes
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	p := make([]byte, size)
+	copy(p[size-len(b):], b)
+	return p
+}
+
+// readPEM returns the first PEM block of the file
+func readPEM(filename string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", filename)
+	}
+	return block, nil
+}
+
+// LoadPrivateKey reads a PKCS#1, SEC 1 or PKCS#8 PEM encoded RSA, ECDSA or Ed25519 private key from the file
+func LoadPrivateKey(filename string) (crypto.Signer, error) {
+	block, err := readPEM(filename)
+	if err != nil {
+		return nil, fmt.Errorf("LoadPrivateKey: %v", err)
+	}
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("LoadPrivateKey: %s: unsupported PEM type %q", filename, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LoadPrivateKey: %s: %v", filename, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("LoadPrivateKey: %s: unsupported key type %T", filename, key)
+	}
+	return signer, nil
+}
+
+// LoadPublicKey reads a PEM encoded public key from the file,
+// the public key is extracted when the file holds a private key
+func LoadPublicKey(filename string) (crypto.PublicKey, error) {
+	block, err := readPEM(filename)
+	if err != nil {
+		return nil, fmt.Errorf("LoadPublicKey: %v", err)
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("LoadPublicKey: %s: %v", filename, err)
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("LoadPublicKey: %s: %v", filename, err)
+		}
+		return key, nil
+	default:
+		signer, err := LoadPrivateKey(filename)
+		if err != nil {
+			return nil, errors.New("LoadPublicKey: " + err.Error())
+		}
+		return signer.Public(), nil
+	}
+}
@@ -0,0 +1,391 @@
+package models
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestKey returns a new private key of the algorithm
+func newTestKey(t *testing.T, alg string) crypto.Signer {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case TokenAlgRS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case TokenAlgES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case TokenAlgEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// writePEM writes the PEM block of the bytes to the file of the dir and returns the name of the file
+func writePEM(t *testing.T, dir, name, blockType string, b []byte) string {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: b})
+	if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// writePrivateKey writes the PKCS#8 PEM file of the key and returns the name of the file
+func writePrivateKey(t *testing.T, dir, name string, key crypto.Signer) string {
+	t.Helper()
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, name, "PRIVATE KEY", b)
+}
+
+// writePublicKey writes the PKIX PEM file of the public key and returns the name of the file
+func writePublicKey(t *testing.T, dir, name string, pub crypto.PublicKey) string {
+	t.Helper()
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, name, "PUBLIC KEY", b)
+}
+
+// newTestTokenModel returns a model signing with a new key of the algorithm and verifying the tokens
+// of the previous keys, the dir holds the key files
+func newTestTokenModel(t *testing.T, dir, alg string, key crypto.Signer, previous ...crypto.PublicKey) *TokenModel {
+	t.Helper()
+	td := &TokenData{
+		TokenIssuerName: "snippets",
+		TokenAudience:   "snippets-api",
+		TokenValidTime:  time.Minute,
+		TokenAlgorithm:  alg,
+		TokenPrivateKey: writePrivateKey(t, dir, alg+".pem", key),
+	}
+	for i, pub := range previous {
+		td.TokenVerificationKeys = append(td.TokenVerificationKeys, writePublicKey(t, dir, alg+"-previous"+string(rune('0'+i))+".pem", pub))
+	}
+	m, err := NewTokenModelWithKeys(td, dir+string(filepath.Separator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// tamper changes the first character of the part of the token, the last one may only have padding bits
+func tamper(token string, part int) string {
+	parts := strings.Split(token, ".")
+	s := parts[part]
+	c := "A"
+	if s[0] == 'A' {
+		c = "B"
+	}
+	parts[part] = c + s[1:]
+	return strings.Join(parts, ".")
+}
+
+var testTokenUser = &User{ID: 7, Name: "Alice", Roles: []string{"user"}}
+
+func TestTokenModelWithKeys(t *testing.T) {
+	for _, alg := range []string{TokenAlgRS256, TokenAlgES256, TokenAlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			dir := t.TempDir()
+			key := newTestKey(t, alg)
+			m := newTestTokenModel(t, dir, alg, key)
+			jwk, err := NewJWK(key.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := m.CreateSessionToken(testTokenUser, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := m.ParseToken(&token)
+			if err != nil {
+				t.Fatalf("want the token verified; got %v", err)
+			}
+			if claims.User.ID != testTokenUser.ID || claims.SessionID != 3 {
+				t.Errorf("want the user %d and the session 3; got %d and %d", testTokenUser.ID, claims.User.ID, claims.SessionID)
+			}
+			parsed, _, err := new(jwt.Parser).ParseUnverified(token, &MyCustomClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["alg"] != alg || parsed.Header["kid"] != jwk.Kid {
+				t.Errorf("want the alg %s and the kid %s; got %v and %v", alg, jwk.Kid, parsed.Header["alg"], parsed.Header["kid"])
+			}
+
+			// the token of another key of the same algorithm has an unknown kid
+			other, err := newTestTokenModel(t, t.TempDir(), alg, newTestKey(t, alg)).CreateToken(testTokenUser)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the public key used as the HMAC secret must not verify a HS256 token
+			pubPEM, err := ioutil.ReadFile(filepath.Join(dir, writePublicKey(t, dir, "public.pem", key.Public())))
+			if err != nil {
+				t.Fatal(err)
+			}
+			confused, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(pubPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			withKid := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			withKid.Header["kid"] = jwk.Kid
+			confusedKid, err := withKid.SignedString(pubPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name  string
+				token string
+			}{
+				{"Tampered claims", tamper(token, 1)},
+				{"Tampered signature", tamper(token, 2)},
+				{"Unknown kid", other},
+				{"HS256 signed with the public key", confused},
+				{"HS256 signed with the public key and its kid", confusedKid},
+				{"Unsigned", unsigned},
+				{"Empty", ""},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					if _, err := m.ParseToken(&tt.token); err == nil {
+						t.Error("want the token rejected; got nil")
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestTokenModelRotation(t *testing.T) {
+	for _, alg := range []string{TokenAlgRS256, TokenAlgES256, TokenAlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			oldKey, newKey := newTestKey(t, alg), newTestKey(t, alg)
+			old := newTestTokenModel(t, t.TempDir(), alg, oldKey)
+			token, err := old.CreateToken(testTokenUser)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the new model signs with the new key and still verifies the tokens of the previous one
+			rotated := newTestTokenModel(t, t.TempDir(), alg, newKey, oldKey.Public())
+			if _, err := rotated.ParseToken(&token); err != nil {
+				t.Errorf("want the token of the previous key verified; got %v", err)
+			}
+			if n := len(rotated.JWKS().Keys); n != 2 {
+				t.Errorf("want the 2 keys published; got %d", n)
+			}
+			newToken, err := rotated.CreateToken(testTokenUser)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := old.ParseToken(&newToken); err == nil {
+				t.Error("want the token of the new key rejected by the previous model; got nil")
+			}
+
+			// a verification key of the signing key is published once
+			dup := newTestTokenModel(t, t.TempDir(), alg, newKey, newKey.Public())
+			if n := len(dup.JWKS().Keys); n != 1 {
+				t.Errorf("want the signing key published once; got %d", n)
+			}
+		})
+	}
+}
+
+func TestJWKSRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	keys := map[string]crypto.Signer{}
+	var previous []crypto.PublicKey
+	for _, alg := range []string{TokenAlgRS256, TokenAlgES256, TokenAlgEdDSA} {
+		keys[alg] = newTestKey(t, alg)
+		previous = append(previous, keys[alg].Public())
+	}
+	// the verification keys may be of other algorithms than the signing key
+	m := newTestTokenModel(t, dir, TokenAlgEdDSA, keys[TokenAlgEdDSA], previous...)
+
+	b, err := json.Marshal(m.JWKS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set JWKSet
+	if err = json.Unmarshal(b, &set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 3 {
+		t.Fatalf("want 3 keys; got %d", len(set.Keys))
+	}
+	for _, jwk := range set.Keys {
+		t.Run(jwk.Alg, func(t *testing.T) {
+			if jwk.Use != "sig" {
+				t.Errorf("want the use sig; got %q", jwk.Use)
+			}
+			pub, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := keys[jwk.Alg].Public().(interface{ Equal(crypto.PublicKey) bool })
+			if !want.Equal(pub) {
+				t.Errorf("want the public key of the %s key; got %v", jwk.Alg, pub)
+			}
+			again, err := NewJWK(pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *again != jwk {
+				t.Errorf("want %+v; got %+v", jwk, *again)
+			}
+		})
+	}
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// the example of RFC 7638 section 3.1
+	jwk := JWK{
+		Kty: "RSA",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjB" +
+			"ZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZ" +
+			"gnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIq" +
+			"bw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E: "AQAB",
+	}
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewJWK(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got.Kid != want {
+		t.Errorf("want %s; got %s", want, got.Kid)
+	}
+}
+
+func TestJWKPublicKeyInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+	}{
+		{"Unknown type", JWK{Kty: "oct"}},
+		{"EC curve", JWK{Kty: "EC", Crv: "P-384", X: "AA", Y: "AA"}},
+		{"EC point not on the curve", JWK{Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"}},
+		{"OKP curve", JWK{Kty: "OKP", Crv: "X25519", X: "AA"}},
+		{"OKP size", JWK{Kty: "OKP", Crv: "Ed25519", X: "AAAA"}},
+		{"Invalid base64", JWK{Kty: "RSA", N: "!", E: "AQAB"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.jwk.PublicKey(); err == nil {
+				t.Error("want an error; got nil")
+			}
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := newTestKey(t, TokenAlgRS256).(*rsa.PrivateKey)
+	ecKey := newTestKey(t, TokenAlgES256).(*ecdsa.PrivateKey)
+	edKey := newTestKey(t, TokenAlgEdDSA)
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		want    crypto.PublicKey
+		private bool // LoadPrivateKey reads the file
+	}{
+		{"PKCS#1 private key", writePEM(t, dir, "pkcs1.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), rsaKey.Public(), true},
+		{"SEC 1 private key", writePEM(t, dir, "sec1.pem", "EC PRIVATE KEY", sec1), ecKey.Public(), true},
+		{"PKCS#8 private key", writePrivateKey(t, dir, "pkcs8.pem", edKey), edKey.Public(), true},
+		{"PKIX public key", writePublicKey(t, dir, "pkix.pem", ecKey.Public()), ecKey.Public(), false},
+		{"PKCS#1 public key", writePEM(t, dir, "pkcs1-public.pem", "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)), rsaKey.Public(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.private {
+				signer, err := LoadPrivateKey(filepath.Join(dir, tt.file))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !signer.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(tt.want) {
+					t.Error("want the key of the file")
+				}
+			}
+			// the public key is also extracted from a private key
+			pub, err := LoadPublicKey(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want.(interface{ Equal(crypto.PublicKey) bool }).Equal(pub) {
+				t.Error("want the public key of the file")
+			}
+		})
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "text.pem"), []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := []struct {
+		name string
+		file string
+	}{
+		{"Missing file", "missing.pem"},
+		{"No PEM data", "text.pem"},
+		{"Unsupported PEM type", writePEM(t, dir, "cert.pem", "CERTIFICATE", []byte("x"))},
+		{"Invalid key", writePEM(t, dir, "invalid.pem", "PRIVATE KEY", []byte("x"))},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadPrivateKey(filepath.Join(dir, tt.file)); err == nil {
+				t.Error("want an error of LoadPrivateKey; got nil")
+			}
+			if _, err := LoadPublicKey(filepath.Join(dir, tt.file)); err == nil {
+				t.Error("want an error of LoadPublicKey; got nil")
+			}
+		})
+	}
+
+	// the unsupported curves are rejected when the model is created
+	td := &TokenData{TokenAlgorithm: TokenAlgES256, TokenPrivateKey: writePrivateKey(t, dir, "p384.pem", p384)}
+	if _, err := NewTokenModelWithKeys(td, dir+string(filepath.Separator)); err == nil {
+		t.Error("want the P-384 key rejected; got nil")
+	}
+	// and the keys of another algorithm
+	td = &TokenData{TokenAlgorithm: TokenAlgRS256, TokenPrivateKey: "sec1.pem"}
+	if _, err := NewTokenModelWithKeys(td, dir+string(filepath.Separator)); err == nil {
+		t.Error("want the EC key rejected for RS256; got nil")
+	}
+}
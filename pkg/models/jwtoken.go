@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -13,26 +14,140 @@ var (
 	ErrUnauthorizedToken = errors.New("models: unauthorized JWT used")
 	ErrForbiddenToken    = errors.New("models: forbidden JWT used")
 	ErrExpiredToken      = errors.New("models: expired JWT used")
+	ErrRevokedToken      = errors.New("models: revoked JWT used")
 )
 
 type Tokens interface {
 	CreateToken(*User) (string, error)
+	// CreateSessionToken creates a token of the user carrying the ID of its session
+	CreateSessionToken(user *User, sessionID int) (string, error)
 	VerifyToken(*string) error
+	// ParseToken verifies the token and returns its claims
+	ParseToken(*string) (*MyCustomClaims, error)
+	// JWKS returns the public keys that verify the tokens
+	JWKS() *JWKSet
+}
+
+// RefreshTokens manages the server side stored refresh tokens used to obtain new access tokens.
+// Refresh tokens are rotated on each use and belong to a family started at login, the reuse of
+// an already rotated token revokes the whole family.
+type RefreshTokens interface {
+	// New issues a refresh token for the session of the user starting a new family and returns its plain-text value
+	New(ctx context.Context, userID, sessionID int, validTime time.Duration) (string, error)
+	// Rotate revokes the refresh token and returns a new one of the same family, the user ID and the session ID
+	Rotate(ctx context.Context, token string, validTime time.Duration) (string, int, int, error)
+	// Revoke revokes the family of the refresh token of the user and its session,
+	// ErrNoRecord is returned when the token is unknown or of another user
+	Revoke(ctx context.Context, userID int, token string) error
+}
+
+// TokenDenylist holds the IDs (jti) of revoked access tokens until they expire
+type TokenDenylist interface {
+	Add(ctx context.Context, jti string, expires time.Time) error
+	Contains(ctx context.Context, jti string) (bool, error)
 }
 
 type TokenModel struct {
-	td *TokenData
+	td      *TokenData
+	method  jwt.SigningMethod
+	signKey interface{}
+	kid     string
+	keys    map[string]*tokenKey // verification keys by key ID
+	jwks    JWKSet
 }
 
+// NewTokenModel creates a TokenModel that signs the tokens with HS256 and the shared TokenSigningKey
 func NewTokenModel(d *TokenData) *TokenModel {
-	return &TokenModel{td: d}
+	return &TokenModel{
+		td:      d,
+		method:  jwt.SigningMethodHS256,
+		signKey: []byte(d.TokenSigningKey),
+		keys:    map[string]*tokenKey{},
+		jwks:    JWKSet{Keys: []JWK{}},
+	}
+}
+
+// NewTokenModelWithKeys creates a TokenModel that signs the tokens with the TokenAlgorithm.
+// The private and verification key files are read from keysPath, the key ID of each key
+// is its JWK thumbprint.
+func NewTokenModelWithKeys(d *TokenData, keysPath string) (*TokenModel, error) {
+	if d.TokenAlgorithm == "" || d.TokenAlgorithm == TokenAlgHS256 {
+		if d.TokenSigningKey == "" {
+			return nil, errors.New("NewTokenModelWithKeys: signing key not specified")
+		}
+		t := NewTokenModel(d)
+		err := t.addVerificationKeys(keysPath)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	switch d.TokenAlgorithm {
+	case TokenAlgRS256, TokenAlgES256, TokenAlgEdDSA:
+	default:
+		return nil, fmt.Errorf("NewTokenModelWithKeys: unsupported algorithm %q", d.TokenAlgorithm)
+	}
+	if d.TokenPrivateKey == "" {
+		return nil, errors.New("NewTokenModelWithKeys: private key not specified")
+	}
+	signer, err := LoadPrivateKey(keysPath + d.TokenPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("NewTokenModelWithKeys: %v", err)
+	}
+	tk, err := newTokenKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("NewTokenModelWithKeys: %s: %v", d.TokenPrivateKey, err)
+	}
+	if tk.alg != d.TokenAlgorithm {
+		return nil, fmt.Errorf("NewTokenModelWithKeys: %s: key type does not match algorithm %q", d.TokenPrivateKey, d.TokenAlgorithm)
+	}
+
+	t := &TokenModel{
+		td:      d,
+		method:  jwt.GetSigningMethod(d.TokenAlgorithm),
+		signKey: signer,
+		kid:     tk.jwk.Kid,
+		keys:    map[string]*tokenKey{tk.jwk.Kid: tk},
+		jwks:    JWKSet{Keys: []JWK{tk.jwk}},
+	}
+	err = t.addVerificationKeys(keysPath)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// addVerificationKeys loads the public keys that still verify the tokens signed before a key rotation
+func (t *TokenModel) addVerificationKeys(keysPath string) error {
+	for _, f := range t.td.TokenVerificationKeys {
+		pub, err := LoadPublicKey(keysPath + f)
+		if err != nil {
+			return fmt.Errorf("NewTokenModelWithKeys: %v", err)
+		}
+		tk, err := newTokenKey(pub)
+		if err != nil {
+			return fmt.Errorf("NewTokenModelWithKeys: %s: %v", f, err)
+		}
+		if _, ok := t.keys[tk.jwk.Kid]; ok {
+			continue
+		}
+		t.keys[tk.jwk.Kid] = tk
+		t.jwks.Keys = append(t.jwks.Keys, tk.jwk)
+	}
+	return nil
 }
 
 // api JWT token data from config file
 type TokenData struct {
-	TokenIssuerName string
-	TokenValidTime  time.Duration // number of hours
-	TokenSigningKey string
+	TokenIssuerName       string
+	TokenAudience         string        // the services that accept the tokens
+	TokenValidTime        time.Duration // access token valid time - number of minutes
+	TokenRefreshValidTime time.Duration // refresh token valid time - number of hours
+	TokenAlgorithm        string        // TokenAlgHS256 (default), TokenAlgRS256, TokenAlgES256 or TokenAlgEdDSA
+	TokenSigningKey       string        // shared key used by TokenAlgHS256
+	TokenPrivateKey       string        // private key file used by the other algorithms
+	TokenVerificationKeys []string      // public key files of previous keys accepted during a key rotation
 }
 
 // Define the token user structure
@@ -55,18 +170,28 @@ func (t *TokenUser) IsAdmin() bool {
 }
 
 type TokenMessage struct {
-	User  TokenUser `json:"user"`
-	Token string    `json:"token"`
+	User         TokenUser `json:"user"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	// the recovery codes of the two-factor authentication, only on the login that completes an enrollment
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
 }
 
 // Define the token claims structure
 type MyCustomClaims struct {
 	User *TokenUser `json:"user,omitempty"`
+	// SessionID is the session of the login that issued the token, 0 for the tokens without a session
+	SessionID int `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
 // CreateToken creates token with TokenUser data inside claims
 func (t *TokenModel) CreateToken(user *User) (string, error) {
+	return t.CreateSessionToken(user, 0)
+}
+
+// CreateSessionToken creates token with TokenUser data and the ID of the session inside claims
+func (t *TokenModel) CreateSessionToken(user *User, sessionID int) (string, error) {
 	if user == nil {
 		return "", errors.New("createToken: user not defined")
 	}
@@ -76,22 +201,35 @@ func (t *TokenModel) CreateToken(user *User) (string, error) {
 		Name:  user.Name,
 		Roles: user.Roles,
 	}
+	// the token ID allows the revocation of the token
+	jti, err := NewRandomToken()
+	if err != nil {
+		return "", fmt.Errorf("createToken: %v", err)
+	}
+
 	// Create the Claims
 	claims := MyCustomClaims{
 		tokenUser,
+		sessionID,
 		jwt.StandardClaims{
+			Id:        jti,
 			Subject:   "User JWT",
+			Audience:  t.td.TokenAudience,
 			IssuedAt:  time.Now().Unix(),
+			NotBefore: time.Now().Unix(),
 			ExpiresAt: time.Now().Add(time.Duration(t.td.TokenValidTime)).Unix(),
 			Issuer:    t.td.TokenIssuerName,
 		},
 	}
 
 	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(t.method, claims)
+	if t.kid != "" {
+		token.Header["kid"] = t.kid
+	}
 
 	// Sign token with key
-	tokenString, err := token.SignedString([]byte(t.td.TokenSigningKey))
+	tokenString, err := token.SignedString(t.signKey)
 	if err != nil {
 		return "", errors.New("createToken: failed to sign token")
 	}
@@ -101,32 +239,71 @@ func (t *TokenModel) CreateToken(user *User) (string, error) {
 
 // VerifyToken verifies if token is valid
 func (t *TokenModel) VerifyToken(tokenString *string) error {
+	_, err := t.ParseToken(tokenString)
+	if err != nil {
+		return fmt.Errorf("VerifyToken: %v", err)
+	}
+	return nil
+}
 
-	// If the token is empty...
+// ParseToken verifies the token and returns its claims
+func (t *TokenModel) ParseToken(tokenString *string) (*MyCustomClaims, error) {
 	if *tokenString == "" {
-		// If we get here, the required token is missing
-		return fmt.Errorf("VerifyToken: empty token")
+		return nil, fmt.Errorf("ParseToken: empty token")
 	}
 
-	// Now parse the token
-	token, err := jwt.Parse(*tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("VerifyToken: Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(t.td.TokenSigningKey), nil
-	})
+	claims := &MyCustomClaims{}
+	token, err := jwt.ParseWithClaims(*tokenString, claims, t.keyFunc)
 	if err != nil {
-		return fmt.Errorf("VerifyToken: Invalid Token: %v", err)
+		return nil, fmt.Errorf("ParseToken: Invalid Token: %v", err)
 	}
+	if !token.Valid {
+		return nil, fmt.Errorf("ParseToken: Invalid Token")
+	}
+	err = claims.verifyIssuerAudience(t.td.TokenIssuerName, t.td.TokenAudience)
+	if err != nil {
+		return nil, fmt.Errorf("ParseToken: %v", err)
+	}
+	return claims, nil
+}
 
-	// Check if token is valid
-	if _, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		// Everything worked. Token valid and claims ok!
-		return nil
+// verifyIssuerAudience verifies the iss and aud claims when the expected values are defined,
+// the exp, iat and nbf claims are verified by the jwt parser
+func (c *MyCustomClaims) verifyIssuerAudience(issuer, audience string) error {
+	if issuer != "" && !c.VerifyIssuer(issuer, true) {
+		return fmt.Errorf("Invalid issuer: %q", c.Issuer)
+	}
+	if audience != "" && !c.VerifyAudience(audience, true) {
+		return fmt.Errorf("Invalid audience: %q", c.Audience)
 	}
+	return nil
+}
+
+// keyFunc returns the key that verifies the token.
+// HMAC tokens are only accepted with TokenAlgHS256 while the other tokens need the
+// "kid" header of a known key with the same algorithm.
+func (t *TokenModel) keyFunc(token *jwt.Token) (interface{}, error) {
+	// Don't forget to validate the alg is what you expect:
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if t.method != jwt.SigningMethodHS256 || token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return t.signKey, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	k, ok := t.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key ID: %q", kid)
+	}
+	if token.Method.Alg() != k.alg {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return k.key, nil
+}
 
-	return fmt.Errorf("VerifyToken: Invalid Token")
+// JWKS returns the public keys that verify the tokens, the shared key of HS256 is never published
+func (t *TokenModel) JWKS() *JWKSet {
+	return &t.jwks
 }
 
 // GetClaimsFromToken verifies if token format is valid and extract claims data
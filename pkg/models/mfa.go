@@ -0,0 +1,246 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidMFACode error if a TOTP or recovery code is not valid
+	ErrInvalidMFACode = errors.New("models: invalid two-factor authentication code")
+	// ErrMFANotEnabled error if the user has no two-factor authentication enabled
+	ErrMFANotEnabled = errors.New("models: two-factor authentication not enabled")
+)
+
+const (
+	// TOTPPeriod time step of the TOTP codes (RFC 6238)
+	TOTPPeriod = 30 * time.Second
+	// TOTPDigits number of digits of the TOTP codes
+	TOTPDigits = 6
+	// totpSkew number of time steps accepted before and after the current one
+	totpSkew = 1
+	// RecoveryCodesCount number of recovery codes generated on the enrollment
+	RecoveryCodesCount = 10
+	// MFAChallengeValidTime valid time of the challenges returned by the login
+	MFAChallengeValidTime = 5 * time.Minute
+	// MFAChallengeMaxAttempts number of codes that can be tried with a challenge
+	MFAChallengeMaxAttempts = 5
+)
+
+// MFA manages the TOTP two-factor authentication of the users and the challenges of the login
+type MFA interface {
+	// Get returns the two-factor authentication of the user, ErrNoRecord when never enrolled
+	Get(ctx context.Context, userID int) (*MFAStatus, error)
+	// Enroll stores a new secret for the user, not used until the enrollment is confirmed
+	Enroll(ctx context.Context, userID int, secret string) error
+	// Enable confirms the enrollment and replaces the recovery codes of the user
+	Enable(ctx context.Context, userID int, recoveryCodes []string) error
+	// Disable removes the two-factor authentication and the recovery codes of the user
+	Disable(ctx context.Context, userID int) error
+	// UseStep records the time step of a valid TOTP code, false when a code of the step, or a later one, was used
+	UseStep(ctx context.Context, userID int, step int64) (bool, error)
+	// UseRecoveryCode marks the recovery code as used, false when the code is unknown or already used
+	UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error)
+	// NewChallenge creates a login challenge for the user and returns its plain-text value
+	NewChallenge(ctx context.Context, userID int, validTime time.Duration) (string, error)
+	// CheckChallenge counts an attempt and returns the user of the challenge,
+	// ErrInvalidToken is returned when the challenge is unknown, expired or has no attempts left
+	CheckChallenge(ctx context.Context, challenge string, maxAttempts int) (int, error)
+	// DeleteChallenge removes the challenge after the login
+	DeleteChallenge(ctx context.Context, challenge string) error
+}
+
+// MFAStatus defines the structure of the two-factor authentication of an user
+// swagger:model
+type MFAStatus struct {
+	// the shared secret of the TOTP codes
+	Secret string `json:"-"`
+	// the last time step used to login, used to reject replayed codes
+	LastStep int64 `json:"-"`
+	// true when the user must enter a code to login
+	Enabled bool `json:"enabled"`
+	// true when one of the roles of the user requires two-factor authentication
+	Required bool `json:"required"`
+	// the number of recovery codes not used
+	RecoveryCodesLeft int `json:"recoveryCodesLeft"`
+}
+
+// NewTOTPSecret returns a new random base32 encoded TOTP secret with 160 bits
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// TOTPStep returns the time step of t
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode returns the TOTP code of the secret for the time step (RFC 6238 with HMAC-SHA1)
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("TOTPCode: invalid secret: %v", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation (RFC 4226)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+// ValidateTOTP returns the time step of the code when it is valid for the secret at time t
+func ValidateTOTP(secret, code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	current := TOTPStep(t)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		c, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(c), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI returns the otpauth URI of the secret that is shown as a QR code to the authenticator apps
+func TOTPURI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(TOTPDigits))
+	v.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// recoveryCodeAlphabet characters of the recovery codes, without the ones easily mistaken
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// NewRecoveryCodes returns n new random recovery codes with the format xxxxx-xxxxx
+func NewRecoveryCodes(n int) ([]string, error) {
+	// the bytes over the largest multiple of the alphabet length are discarded to avoid a modulo bias
+	max := 256 - 256%len(recoveryCodeAlphabet)
+	codes := make([]string, n)
+	b := make([]byte, 1)
+	for i := range codes {
+		var sb strings.Builder
+		for sb.Len() < 11 {
+			if sb.Len() == 5 {
+				sb.WriteByte('-')
+				continue
+			}
+			if _, err := rand.Read(b); err != nil {
+				return nil, err
+			}
+			if int(b[0]) >= max {
+				continue
+			}
+			sb.WriteByte(recoveryCodeAlphabet[int(b[0])%len(recoveryCodeAlphabet)])
+		}
+		codes[i] = sb.String()
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode returns the recovery code as it is hashed, ignoring case and spaces
+func NormalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.Join(strings.Fields(code), ""))
+}
+
+// MFAChallenge defines the structure returned by the login of the users with two-factor
+// authentication, the tokens are only returned after the verification of a code
+// swagger:model
+type MFAChallenge struct {
+	// the challenge sent with the code to complete the login
+	Challenge string `json:"challenge"`
+	// true when the user must enroll, required by one of the user roles, before the login
+	EnrollmentRequired bool `json:"enrollmentRequired"`
+}
+
+// Error allows the clients of the API to return the challenge as the error of the login
+func (c *MFAChallenge) Error() string {
+	return "models: two-factor authentication required"
+}
+
+// VerifyMFA defines the structure to complete the login with a TOTP or recovery code
+// swagger:model
+type VerifyMFA struct {
+	// the challenge returned by the login
+	//
+	// required: true
+	Challenge string `json:"challenge" validate:"required,max=255"`
+	// the TOTP code of the authenticator app or a recovery code
+	//
+	// required: true
+	Code string `json:"code" validate:"required,max=45"`
+}
+
+// MFAChallengeEnrollment defines the structure to enroll on the login when two-factor authentication is required
+// swagger:model
+type MFAChallengeEnrollment struct {
+	// the challenge returned by the login
+	//
+	// required: true
+	Challenge string `json:"challenge" validate:"required,max=255"`
+}
+
+// MFAEnrollment defines the structure with the secret of a new enrollment
+// swagger:model
+type MFAEnrollment struct {
+	// the base32 encoded shared secret
+	Secret string `json:"secret"`
+	// the otpauth URI of the secret, shown as a QR code to the authenticator apps
+	URI string `json:"uri"`
+}
+
+// MFACode defines the structure with a TOTP code to confirm or disable two-factor authentication
+// swagger:model
+type MFACode struct {
+	// the TOTP code of the authenticator app, or a recovery code to disable, not required when an administrator disables other user
+	//
+	// required: false
+	Code string `json:"code" validate:"max=45"`
+}
+
+// MFARecoveryCodes defines the structure with the recovery codes of an user, only returned on the enrollment
+// swagger:model
+type MFARecoveryCodes struct {
+	// the single use codes accepted when the authenticator app is not available
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// RoleMFA defines the structure to require two-factor authentication to the users of a role
+// swagger:model
+type RoleMFA struct {
+	// true when the users of the role must use two-factor authentication
+	MFARequired bool `json:"mfaRequired"`
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the base32 of the SHA-1 seed "12345678901234567890" of the test vectors of RFC 6238
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// the test vectors of RFC 6238 appendix B, the codes of 6 digits are the last ones of the 8 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		step := TOTPStep(time.Unix(tt.unix, 0))
+		got, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%d: want %s; got %s", tt.unix, tt.want, got)
+		}
+	}
+
+	// the secrets are accepted in lower case and with padding
+	if got, err := TOTPCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq====", 1); err != nil || got != "287082" {
+		t.Errorf("want 287082; got %s, %v", got, err)
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("want an error for an invalid secret; got nil")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+	code := func(step int64) string {
+		c, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// the codes of one step before and after the current one are accepted for the clock skew
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"Current step", code(current), current, true},
+		{"Previous step", code(current - 1), current - 1, true},
+		{"Next step", code(current + 1), current + 1, true},
+		{"Two steps before", code(current - 2), 0, false},
+		{"Two steps after", code(current + 2), 0, false},
+		{"Spaces", " " + code(current) + " ", current, true},
+		{"Short", code(current)[:5], 0, false},
+		{"Long", code(current) + "0", 0, false},
+		{"Empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfc6238Secret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("want %d %v; got %d %v", tt.wantStep, tt.wantOK, step, ok)
+			}
+		})
+	}
+
+	if _, ok := ValidateTOTP("not base32!", code(current), now); ok {
+		t.Error("want the code of an invalid secret rejected")
+	}
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("want the 32 characters of 160 bits; got %q", secret)
+	}
+	c, err := TOTPCode(secret, TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ValidateTOTP(secret, c, time.Now()); !ok {
+		t.Error("want the code of a new secret accepted")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(RecoveryCodesCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodesCount {
+		t.Fatalf("want %d codes; got %d", RecoveryCodesCount, len(codes))
+	}
+	format := regexp.MustCompile(`^[` + recoveryCodeAlphabet + `]{5}-[` + recoveryCodeAlphabet + `]{5}$`)
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if !format.MatchString(c) {
+			t.Errorf("want the format xxxxx-xxxxx; got %q", c)
+		}
+		if seen[c] {
+			t.Errorf("want unique codes; got %q twice", c)
+		}
+		seen[c] = true
+		if NormalizeRecoveryCode(c) != c {
+			t.Errorf("want the code unchanged by the normalization; got %q", NormalizeRecoveryCode(c))
+		}
+	}
+
+	// the codes typed with other case and spaces are hashed as the generated ones
+	tests := []struct {
+		typed string
+		want  string
+	}{
+		{"abcde-fghjk", "abcde-fghjk"},
+		{"ABCDE-FGHJK", "abcde-fghjk"},
+		{"  abcde-fghjk\n", "abcde-fghjk"},
+		{"abcde - fghjk", "abcde-fghjk"},
+		{"Ab cDe-fG hjK", "abcde-fghjk"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRecoveryCode(tt.typed); got != tt.want {
+			t.Errorf("%q: want %q; got %q", tt.typed, tt.want, got)
+		}
+		if HashToken(NormalizeRecoveryCode(tt.typed)) != HashToken(tt.want) {
+			t.Errorf("%q: want the hash of %q", tt.typed, tt.want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package migrate applies the numbered migrations of the schema of a database and records the
+// applied ones on the schema_migrations table. The migrations are compiled into the binary by
+// each storage backend.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoMigration is returned by Down when no migration is applied
+var ErrNoMigration = errors.New("migrate: no migration applied")
+
+// ErrIrreversible is returned by Down when the last applied migration has no Down statements
+var ErrIrreversible = errors.New("migrate: migration is irreversible")
+
+// Migration is a numbered change of the schema, each statement of Down reverts the ones of Up.
+// A migration without Down statements is never reverted, like the baseline of a database that
+// holds tables created before the migrations.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// Dialect holds the SQL of the schema_migrations table that differs between the databases
+type Dialect struct {
+	// CreateTable creates the schema_migrations table when it does not exist
+	CreateTable string
+	// Placeholder returns the placeholder of the parameter n of a query, starting on 1
+	Placeholder func(n int) string
+	// Lock waits for the lock of the migrations of the database and takes it on the connection,
+	// so the instances started together apply each migration once. Nil when the database has no
+	// lock shared by the instances, like SQLite.
+	Lock func(ctx context.Context, conn *sql.Conn) error
+	// Unlock releases the lock taken by Lock on the connection
+	Unlock func(ctx context.Context, conn *sql.Conn) error
+}
+
+// QuestionPlaceholder is the placeholder of MySQL and SQLite
+func QuestionPlaceholder(n int) string {
+	return "?"
+}
+
+// DollarPlaceholder is the placeholder of PostgreSQL
+func DollarPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// Status is a migration and the time it was applied, nil when it is pending
+type Status struct {
+	Migration
+	Applied *time.Time
+}
+
+// Migrator applies the migrations of a database
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New returns the migrator of the database, the versions of the migrations must be positive and ascending
+func New(db *sql.DB, d Dialect, migrations []Migration) (*Migrator, error) {
+	last := 0
+	for _, m := range migrations {
+		if m.Version <= last {
+			return nil, fmt.Errorf("migrate: version %d of %q is not after version %d", m.Version, m.Name, last)
+		}
+		last = m.Version
+	}
+	return &Migrator{db: db, dialect: d, migrations: migrations}, nil
+}
+
+// applied returns the versions of the applied migrations and the time each one was applied
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	if _, err := m.db.ExecContext(ctx, m.dialect.CreateTable); err != nil {
+		return nil, fmt.Errorf("migrate: creating schema_migrations: %v", err)
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var applied time.Time
+		if err = rows.Scan(&version, &applied); err != nil {
+			return nil, err
+		}
+		versions[version] = applied
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// Status returns all the migrations on the order of their versions
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := []Status{}
+	for _, mg := range m.migrations {
+		s := Status{Migration: mg}
+		if t, ok := versions[mg.Version]; ok {
+			s.Applied = &t
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// lock takes the lock of the migrations and returns the function that releases it
+func (m *Migrator) lock(ctx context.Context) (func() error, error) {
+	if m.dialect.Lock == nil {
+		return func() error { return nil }, nil
+	}
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: lock: %v", err)
+	}
+	if err = m.dialect.Lock(ctx, conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("migrate: lock: %v", err)
+	}
+	return func() error {
+		err := m.dialect.Unlock(context.Background(), conn)
+		conn.Close()
+		if err != nil {
+			return fmt.Errorf("migrate: unlock: %v", err)
+		}
+		return nil
+	}, nil
+}
+
+// Up applies the pending migrations on the order of their versions and returns them,
+// it stops on the first migration that fails. The migrations applied by another instance
+// while Up waits for the lock are not applied again.
+func (m *Migrator) Up(ctx context.Context) (done []Migration, err error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if uerr := unlock(); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done = []Migration{}
+	stmt := fmt.Sprintf("INSERT INTO schema_migrations (version, name, applied) VALUES(%s, %s, %s)",
+		m.dialect.Placeholder(1), m.dialect.Placeholder(2), m.dialect.Placeholder(3))
+	for _, mg := range m.migrations {
+		if _, ok := versions[mg.Version]; ok {
+			continue
+		}
+		err = m.run(ctx, mg.Up, stmt, mg.Version, mg.Name, time.Now().UTC().Truncate(time.Second))
+		if err != nil {
+			return done, fmt.Errorf("migrate: up %d %s: %v", mg.Version, mg.Name, err)
+		}
+		done = append(done, mg)
+	}
+	return done, nil
+}
+
+// Down reverts the last applied migration and returns it, ErrNoMigration when none is applied
+// and ErrIrreversible when it has no Down statements
+func (m *Migrator) Down(ctx context.Context) (_ *Migration, err error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if uerr := unlock(); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if _, ok := versions[mg.Version]; !ok {
+			continue
+		}
+		if len(mg.Down) == 0 {
+			return nil, fmt.Errorf("migrate: down %d %s: %w", mg.Version, mg.Name, ErrIrreversible)
+		}
+		stmt := "DELETE FROM schema_migrations WHERE version = " + m.dialect.Placeholder(1)
+		err = m.run(ctx, mg.Down, stmt, mg.Version)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: down %d %s: %v", mg.Version, mg.Name, err)
+		}
+		return &mg, nil
+	}
+	return nil, ErrNoMigration
+}
+
+// run executes the statements and the record of the migration in a single transaction, the
+// statements that change the tables of MySQL are committed even when the transaction fails
+func (m *Migrator) run(ctx context.Context, statements []string, record string, args ...interface{}) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, s := range statements {
+		if _, err = tx.ExecContext(ctx, s); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
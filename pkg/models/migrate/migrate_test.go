@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	_ "github.com/mattn/go-sqlite3"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDownIrreversible(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	d := Dialect{
+		CreateTable: "CREATE TABLE IF NOT EXISTS schema_migrations (version integer NOT NULL PRIMARY KEY," +
+			" name text NOT NULL, applied datetime NOT NULL)",
+		Placeholder: QuestionPlaceholder,
+	}
+	m, err := New(db, d, []Migration{
+		{Version: 1, Name: "baseline", Up: []string{"CREATE TABLE IF NOT EXISTS kept (id integer)"}},
+		{Version: 2, Name: "added", Up: []string{"CREATE TABLE added (id integer)"}, Down: []string{"DROP TABLE added"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if mg, err := m.Down(ctx); err != nil || mg.Version != 2 {
+		t.Fatalf("want migration 2 reverted; got %v, %v", mg, err)
+	}
+	if _, err := m.Down(ctx); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("want %v; got %v", ErrIrreversible, err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status[0].Applied == nil {
+		t.Error("want the baseline still applied")
+	}
+	if _, err := db.ExecContext(ctx, "SELECT id FROM kept"); err != nil {
+		t.Errorf("want the baseline table kept; got %v", err)
+	}
+}
+
+func TestUpLocked(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the lock shared by the instances is a semaphore of the test
+	sem := make(chan struct{}, 1)
+	var unlocked int32
+	d := Dialect{
+		CreateTable: "CREATE TABLE IF NOT EXISTS schema_migrations (version integer NOT NULL PRIMARY KEY," +
+			" name text NOT NULL, applied datetime NOT NULL)",
+		Placeholder: QuestionPlaceholder,
+		Lock: func(ctx context.Context, conn *sql.Conn) error {
+			select {
+			case sem <- struct{}{}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+		Unlock: func(ctx context.Context, conn *sql.Conn) error {
+			atomic.AddInt32(&unlocked, 1)
+			<-sem
+			return nil
+		},
+	}
+	migrations := []Migration{
+		{Version: 1, Name: "first", Up: []string{"CREATE TABLE first (id integer)"}},
+		{Version: 2, Name: "second", Up: []string{"CREATE TABLE second (id integer)"}},
+	}
+
+	// the instances started together apply each migration once
+	const instances = 4
+	applied := make(chan int, instances)
+	errs := make(chan error, instances)
+	var wg sync.WaitGroup
+	for i := 0; i < instances; i++ {
+		m, err := New(db, d, migrations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done, err := m.Up(ctx)
+			if err != nil {
+				errs <- err
+				return
+			}
+			applied <- len(done)
+		}()
+	}
+	wg.Wait()
+	close(applied)
+	close(errs)
+	for err := range errs {
+		t.Errorf("want the migrations applied once; got %v", err)
+	}
+	total := 0
+	for n := range applied {
+		total += n
+	}
+	if total != len(migrations) {
+		t.Errorf("want %d migrations applied; got %d", len(migrations), total)
+	}
+	if unlocked != instances {
+		t.Errorf("want the lock released by the %d instances; got %d", instances, unlocked)
+	}
+}
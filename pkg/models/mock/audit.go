@@ -0,0 +1,30 @@
+package mock
+
+import (
+	"context"
+	"github.com/vgraveto/snippets/pkg/models"
+	"time"
+)
+
+type AuditModel struct{}
+
+var mockAuditEvent = &models.AuditEvent{
+	ID:         1,
+	Created:    time.Now(),
+	Action:     models.AuditUserCreate,
+	ActorID:    1,
+	TargetType: models.AuditTargetUser,
+	TargetID:   2,
+	IP:         "192.0.2.10",
+	RequestID:  "4f1c9a7e2b6d8c30",
+	Details:    `user "bob@example.com" with roles [2]`,
+}
+
+func (m *AuditModel) GetAll(ctx context.Context, token string, f *models.AuditFilter) (*models.AuditPage, error) {
+	page := &models.AuditPage{Events: []*models.AuditEvent{}, Page: f.Page, PerPage: f.PerPage}
+	if f.Action == "" || f.Action == mockAuditEvent.Action {
+		page.Events = append(page.Events, mockAuditEvent)
+		page.Total = 1
+	}
+	return page, nil
+}
@@ -0,0 +1,174 @@
+package mock
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/vgraveto/snippets/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// OIDCUser is the identity asserted by the OIDCServer on every authorization request
+type OIDCUser struct {
+	Subject string
+	Email   string
+	Name    string
+	Groups  []string
+	// the email_verified claim is not sent when true
+	UnverifiedEmail bool
+}
+
+// oidcCode the data of an authorization request bound to its code
+type oidcCode struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// OIDCServer is a local OpenID Connect provider implementing the authorization code flow with PKCE.
+// The authorization requests are approved without user interaction as the User of the server.
+type OIDCServer struct {
+	*httptest.Server
+	ClientID string
+	User     OIDCUser
+
+	key   *rsa.PrivateKey
+	jwk   *models.JWK
+	mu    sync.Mutex
+	codes map[string]oidcCode
+}
+
+// NewOIDCServer starts a new OIDCServer for the client, Close must be called when done
+func NewOIDCServer(clientID string, user OIDCUser) (*OIDCServer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	jwk, err := models.NewJWK(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	s := &OIDCServer{ClientID: clientID, User: user, key: key, jwk: jwk, codes: map[string]oidcCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.configuration)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/jwks", s.jwks)
+	s.Server = httptest.NewServer(mux)
+	return s, nil
+}
+
+func (s *OIDCServer) configuration(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	models.ToJSON(map[string]interface{}{
+		"issuer":                           s.URL,
+		"authorization_endpoint":           s.URL + "/authorize",
+		"token_endpoint":                   s.URL + "/token",
+		"jwks_uri":                         s.URL + "/jwks",
+		"response_types_supported":         []string{"code"},
+		"code_challenge_methods_supported": []string{"S256"},
+	}, rw)
+}
+
+func (s *OIDCServer) jwks(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	models.ToJSON(&models.JWKSet{Keys: []models.JWK{*s.jwk}}, rw)
+}
+
+// authorize approves the request and redirects back to the client with the authorization code
+func (s *OIDCServer) authorize(rw http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != s.ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(rw, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	code, err := models.NewRandomToken()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.codes[code] = oidcCode{
+		redirectURI:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+	}
+	s.mu.Unlock()
+
+	v := url.Values{}
+	v.Set("code", code)
+	v.Set("state", q.Get("state"))
+	http.Redirect(rw, r, q.Get("redirect_uri")+"?"+v.Encode(), http.StatusFound)
+}
+
+// token exchanges a code, once, for an ID token when the PKCE code verifier matches its challenge
+func (s *OIDCServer) token(rw http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(rw, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	c, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("client_id") != s.ClientID ||
+		r.PostForm.Get("redirect_uri") != c.redirectURI ||
+		models.PKCEChallenge(r.PostForm.Get("code_verifier")) != c.codeChallenge {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusBadRequest)
+		models.ToJSON(map[string]string{"error": "invalid_grant"}, rw)
+		return
+	}
+
+	idToken, err := s.IDToken(c.nonce)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	models.ToJSON(map[string]interface{}{
+		"access_token": "mockAccessToken",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	}, rw)
+}
+
+// IDToken returns an ID token of the User signed by the server
+func (s *OIDCServer) IDToken(nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            s.URL,
+		"sub":            s.User.Subject,
+		"aud":            s.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"email":          s.User.Email,
+		"email_verified": true,
+		"name":           s.User.Name,
+		"groups":         s.User.Groups,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if s.User.UnverifiedEmail {
+		delete(claims, "email_verified")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.jwk.Kid
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("OIDCServer: IDToken: %v", err)
+	}
+	return signed, nil
+}
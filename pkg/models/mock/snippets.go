@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"context"
 	"github.com/vgraveto/snippets/pkg/models"
 	"time"
 )
@@ -15,11 +16,11 @@ var mockSnippet = &models.Snippet{
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(token, title, content, expires string) (int, error) {
+func (m *SnippetModel) Insert(ctx context.Context, token, title, content, expires string) (int, error) {
 	return 2, nil
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
@@ -28,6 +29,6 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
@@ -1,7 +1,11 @@
 package mock
 
 import (
+	"context"
+	"encoding/base64"
 	"github.com/vgraveto/snippets/pkg/models"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,25 +17,111 @@ var mockUser = &models.User{
 	Active:  true,
 }
 
-type UserModel struct{}
+type UserModel struct {
+	// the number of calls of RefreshToken
+	Refreshes int32
+}
 
-func (m *UserModel) Authenticate(email, password string) (string, error) {
-	tD := models.TokenData{
-		TokenIssuerName: "Test Application",
-		TokenValidTime:  1 * time.Hour,
-		TokenSigningKey: "testKey",
-	}
+// TokenData holds the values used to sign the mock tokens
+var TokenData = models.TokenData{
+	TokenIssuerName: "Test Application",
+	TokenAudience:   "snippets",
+	TokenSigningKey: "testKey",
+}
+
+// tokenMessage returns a token message for the mockUser with a token valid for the given time
+func tokenMessage(validTime time.Duration) *models.TokenMessage {
+	tD := TokenData
+	tD.TokenValidTime = validTime
 	tM := models.NewTokenModel(&tD)
 	token, _ := tM.CreateToken(mockUser)
+	return &models.TokenMessage{
+		User: models.TokenUser{
+			ID:    mockUser.ID,
+			Name:  mockUser.Name,
+			Roles: mockUser.Roles,
+		},
+		Token:        token,
+		RefreshToken: "validRefreshToken",
+	}
+}
+
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (*models.TokenMessage, error) {
 	switch email {
 	case "alice@example.com":
-		return token, nil
+		return tokenMessage(1 * time.Hour), nil
+	case "mfa@example.com":
+		return nil, &models.MFAChallenge{Challenge: MFAChallenge}
+	case "enroll@example.com":
+		return nil, &models.MFAChallenge{Challenge: MFAChallenge, EnrollmentRequired: true}
+	case "locked@example.com":
+		return nil, &models.LoginLockedError{RetryAfter: 90 * time.Second}
+	case "expired@example.com":
+		// the token is already expired and must be refreshed on the next request
+		return tokenMessage(-1 * time.Hour), nil
+	case "tampered@example.com":
+		// the token payload is changed after signing
+		tm := tokenMessage(1 * time.Hour)
+		parts := strings.Split(tm.Token, ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"user":{"id":1,"name":"Alice","roles":["administrator"]}}`))
+		tm.Token = strings.Join(parts, ".")
+		return tm, nil
+	default:
+		return nil, models.ErrInvalidCredentials
+	}
+}
+
+func (m *UserModel) RefreshToken(ctx context.Context, refreshToken string) (*models.TokenMessage, error) {
+	atomic.AddInt32(&m.Refreshes, 1)
+	switch refreshToken {
+	case "validRefreshToken":
+		return tokenMessage(1 * time.Hour), nil
+	default:
+		return nil, models.ErrInvalidToken
+	}
+}
+
+func (m *UserModel) ForgotPassword(ctx context.Context, email string) error {
+	if email == "reset-disabled@example.com" {
+		return models.ErrPasswordResetDisabled
+	}
+	return nil
+}
+
+func (m *UserModel) ResetPasswordWithToken(ctx context.Context, token, newPassword string) error {
+	switch token {
+	case "validToken":
+		// the API checks the name and email of the owner of the token
+		if strings.Contains(strings.ToLower(newPassword), "alice") {
+			return &models.ValidationMessagesError{
+				Messages: []string{"newPassword: The password cannot contain your name or email"},
+			}
+		}
+		return nil
+	default:
+		return models.ErrInvalidToken
+	}
+}
+
+func (m *UserModel) Register(ctx context.Context, name, email, password string) (string, error) {
+	switch email {
+	case "bob@blocked.com":
+		return "", models.ErrEmailDomainNotAllowed
+	default:
+		return "Registration done, please follow the link sent to your email to activate the account", nil
+	}
+}
+
+func (m *UserModel) VerifyEmail(ctx context.Context, token string) error {
+	switch token {
+	case "validToken":
+		return nil
 	default:
-		return "", models.ErrInvalidCredentials
+		return models.ErrInvalidToken
 	}
 }
 
-func (m *UserModel) Insert(token, name, email, password string, roles []int) error {
+func (m *UserModel) Insert(ctx context.Context, token, name, email, password string, roles []int) error {
 	switch email {
 	case "dupe@example.com":
 		return models.ErrDuplicateEmail
@@ -40,7 +130,7 @@ func (m *UserModel) Insert(token, name, email, password string, roles []int) err
 	}
 }
 
-func (m *UserModel) Get(token string, id int) (*models.User, error) {
+func (m *UserModel) Get(ctx context.Context, token string, id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return mockUser, nil
@@ -49,18 +139,167 @@ func (m *UserModel) Get(token string, id int) (*models.User, error) {
 	}
 }
 
-func (m *UserModel) GetAll(string) ([]*models.User, error) {
+func (m *UserModel) GetAll(context.Context, string) ([]*models.User, error) {
 	return nil, nil
 }
 
-func (m *UserModel) ChangePassword(string, int, string, string) error {
+func (m *UserModel) ChangePassword(context.Context, string, int, string, string) error {
 	return nil
 }
 
-func (m *UserModel) GetRoleTypes(string) ([]*models.RoleType, error) {
+func (m *UserModel) GetRoleTypes(context.Context, string) ([]*models.RoleType, error) {
 	return nil, nil
 }
 
-func (m *UserModel) GetRoles(string, int) (*[]string, error) {
+func (m *UserModel) GetRoles(context.Context, string, int) (*[]string, error) {
 	return nil, nil
 }
+
+func (m *UserModel) GetPending(context.Context, string) ([]*models.User, error) {
+	return []*models.User{}, nil
+}
+
+func (m *UserModel) Approve(ctx context.Context, token string, id int, approved bool) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) Logout(ctx context.Context, token, refreshToken string) error {
+	return nil
+}
+
+var mockAPIKey = &models.APIKey{
+	ID:      1,
+	UserID:  1,
+	Name:    "CI pipeline",
+	Prefix:  models.APIKeyPrefix + "AbCdEfGh",
+	Scopes:  []string{"user"},
+	Created: time.Now(),
+}
+
+func (m *UserModel) GetAPIKeys(ctx context.Context, token string, id int) ([]*models.APIKey, error) {
+	return []*models.APIKey{mockAPIKey}, nil
+}
+
+func (m *UserModel) CreateAPIKey(ctx context.Context, token string, id int, ck *models.CreateAPIKey) (*models.NewAPIKeyMessage, error) {
+	for _, s := range ck.Scopes {
+		if s != "user" {
+			return nil, models.ErrBadRequest
+		}
+	}
+	k := *mockAPIKey
+	k.ID = 2
+	k.Name = ck.Name
+	k.Scopes = ck.Scopes
+	return &models.NewAPIKeyMessage{APIKey: k, Key: mockAPIKey.Prefix + "IjKlMnOpQrStUvWxYz"}, nil
+}
+
+func (m *UserModel) RevokeAPIKey(ctx context.Context, token string, id, keyID int) error {
+	switch keyID {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) AuthenticateOIDC(ctx context.Context, idToken, nonce string) (*models.TokenMessage, error) {
+	if idToken == "" || nonce == "" {
+		return nil, models.ErrInvalidCredentials
+	}
+	return tokenMessage(1 * time.Hour), nil
+}
+
+// MFAChallenge, MFASecret and MFACode are the values accepted by the two-factor authentication
+const (
+	MFAChallenge = "validChallenge"
+	MFASecret    = "JBSWY3DPEHPK3PXP"
+	MFACode      = "123456"
+)
+
+var mockRecoveryCodes = []string{"abcde-fghij", "klmno-pqrst"}
+
+func (m *UserModel) VerifyMFA(ctx context.Context, challenge, code string) (*models.TokenMessage, error) {
+	if challenge != MFAChallenge || code != MFACode {
+		return nil, models.ErrInvalidMFACode
+	}
+	return tokenMessage(1 * time.Hour), nil
+}
+
+func (m *UserModel) EnrollMFAChallenge(ctx context.Context, challenge string) (*models.MFAEnrollment, error) {
+	if challenge != MFAChallenge {
+		return nil, models.ErrInvalidToken
+	}
+	return &models.MFAEnrollment{
+		Secret: MFASecret,
+		URI:    models.TOTPURI("Snippets", mockUser.Email, MFASecret),
+	}, nil
+}
+
+func (m *UserModel) GetMFA(ctx context.Context, token string, id int) (*models.MFAStatus, error) {
+	return &models.MFAStatus{}, nil
+}
+
+func (m *UserModel) EnrollMFA(ctx context.Context, token string, id int) (*models.MFAEnrollment, error) {
+	return m.EnrollMFAChallenge(ctx, MFAChallenge)
+}
+
+func (m *UserModel) ConfirmMFA(ctx context.Context, token string, id int, code string) ([]string, error) {
+	if code != MFACode {
+		return nil, models.ErrInvalidMFACode
+	}
+	return mockRecoveryCodes, nil
+}
+
+func (m *UserModel) DisableMFA(ctx context.Context, token string, id int, code string) error {
+	if code != MFACode {
+		return models.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (m *UserModel) SetRoleMFA(ctx context.Context, token string, roleID int, required bool) error {
+	switch roleID {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) UnlockUser(ctx context.Context, token string, id int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+var mockSessions = []*models.Session{
+	{ID: 1, UserID: 1, UserAgent: "Mozilla/5.0 (X11; Linux x86_64) Firefox/115.0", IP: "192.0.2.10",
+		Created: time.Now(), LastSeen: time.Now(), Expires: time.Now().Add(24 * time.Hour), Current: true},
+	{ID: 2, UserID: 1, UserAgent: "curl/8.4.0", IP: "198.51.100.7",
+		Created: time.Now(), LastSeen: time.Now(), Expires: time.Now().Add(24 * time.Hour)},
+}
+
+func (m *UserModel) GetSessions(ctx context.Context, token string, id int) ([]*models.Session, error) {
+	return mockSessions, nil
+}
+
+func (m *UserModel) RevokeSession(ctx context.Context, token string, id, sessionID int) error {
+	switch sessionID {
+	case 1, 2:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) WithClient(ip, userAgent string) models.APIUsers {
+	return m
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"context"
 	"errors"
+	"strings"
 )
 
 var (
@@ -17,6 +19,21 @@ var (
 	ErrValidation = errors.New("models: validation error")
 )
 
+// primaryKey is the key of the context of the reads that must be done on the primary database
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads are done on the primary database by the storages
+// with read replicas, so a request reads the rows it has just written
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsePrimary returns true when the reads of the context must be done on the primary database
+func UsePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
 // GenericMessage is a generic message returned by a server
 type GenericMessage struct {
 	Message string `json:"message"`
@@ -26,3 +43,12 @@ type GenericMessage struct {
 type ValidationMessagesError struct {
 	Messages []string `json:"messages"`
 }
+
+func (e *ValidationMessagesError) Error() string {
+	return ErrValidation.Error() + ": " + strings.Join(e.Messages, "; ")
+}
+
+// Is allows errors.Is(err, ErrValidation)
+func (e *ValidationMessagesError) Is(target error) bool {
+	return target == ErrValidation
+}
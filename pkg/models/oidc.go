@@ -0,0 +1,308 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrOIDCDisabled error if the login with the OpenID Connect provider is not enabled
+	ErrOIDCDisabled = errors.New("models: OIDC login disabled")
+)
+
+// oidcHTTPTimeout timeout of the requests to the OpenID Connect provider
+const oidcHTTPTimeout = 10 * time.Second
+
+// OIDCData OpenID Connect provider data from config file
+type OIDCData struct {
+	Issuer       string   // issuer URL of the provider, the discovery document is read from it
+	ClientID     string   // client registered on the provider, the expected aud claim of the ID tokens
+	ClientSecret string   // secret of confidential clients, empty for public clients that only use PKCE
+	RedirectURL  string   // callback URL registered on the provider
+	Scopes       []string // scopes requested on the authorization, "openid" is always requested
+	GroupsClaim  string   // claim of the ID token with the groups of the user
+
+	// used by the API to map the identities to local users
+	AutoProvision bool              // insert the users unknown to the API
+	GroupRoles    map[string]string // role granted to the members of each group
+}
+
+// OIDCIdentity the user identity asserted by a verified ID token
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool // false when the provider does not send the email_verified claim
+	Name          string
+	Groups        []string
+	Expires       time.Time // the exp claim of the ID token
+}
+
+// oidcConfiguration the values of the provider discovery document used by the OIDCProvider
+type oidcConfiguration struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider implements the authorization code flow with PKCE (RFC 7636) of an OpenID Connect
+// provider. The discovery document is read on the first use and the ID tokens are verified
+// with the keys published on the provider jwks_uri.
+type OIDCProvider struct {
+	od       *OIDCData
+	client   *http.Client
+	mu       sync.Mutex
+	config   *oidcConfiguration
+	verifier *VerifierModel
+}
+
+// NewOIDCProvider creates a new OIDCProvider
+func NewOIDCProvider(d *OIDCData) *OIDCProvider {
+	p := &OIDCProvider{od: d, client: &http.Client{Timeout: oidcHTTPTimeout}}
+	p.verifier = NewVerifierModel(&VerifierData{FetchKeys: p.fetchKeys})
+	return p
+}
+
+// NewPKCEVerifier returns a new random PKCE code verifier
+func NewPKCEVerifier() (string, error) {
+	return NewRandomToken()
+}
+
+// PKCEChallenge returns the S256 code challenge of the PKCE code verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// discover returns the provider configuration, it is read again after a failure
+func (p *OIDCProvider) discover() (*oidcConfiguration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.config != nil {
+		return p.config, nil
+	}
+
+	issuer := strings.TrimSuffix(p.od.Issuer, "/")
+	resp, err := p.client.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("OIDCProvider: discover: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDCProvider: discover: Status: %s", resp.Status)
+	}
+	c := &oidcConfiguration{}
+	err = FromJSON(c, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("OIDCProvider: discover: Deserialization: %v", err)
+	}
+	if strings.TrimSuffix(c.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDCProvider: discover: issuer %q does not match %q", c.Issuer, p.od.Issuer)
+	}
+	if c.AuthorizationEndpoint == "" || c.TokenEndpoint == "" || c.JWKSURI == "" {
+		return nil, errors.New("OIDCProvider: discover: incomplete provider configuration")
+	}
+	p.config = c
+	return c, nil
+}
+
+// fetchKeys returns the keys published by the provider to verify the ID tokens
+func (p *OIDCProvider) fetchKeys() (*JWKSet, error) {
+	c, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Get(c.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDCProvider: fetchKeys: Status: %s", resp.Status)
+	}
+	jwks := &JWKSet{}
+	err = FromJSON(jwks, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("OIDCProvider: fetchKeys: Deserialization: %v", err)
+	}
+	return jwks, nil
+}
+
+// AuthCodeURL returns the URL of the provider authorization endpoint the user agent is redirected to
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	c, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	scopes := []string{"openid"}
+	for _, s := range p.od.Scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.od.ClientID)
+	v.Set("redirect_uri", p.od.RedirectURL)
+	v.Set("scope", strings.Join(scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", PKCEChallenge(codeVerifier))
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(c.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return c.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchange exchanges the authorization code on the provider token endpoint and returns the ID token,
+// the request is canceled with ctx
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	c, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", p.od.RedirectURL)
+	v.Set("client_id", p.od.ClientID)
+	v.Set("code_verifier", codeVerifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenEndpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.od.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.od.ClientID), url.QueryEscape(p.od.ClientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("OIDCProvider: Exchange: Status: %s: %s", resp.Status, string(bodyBytes))
+	}
+	tr := &struct {
+		IDToken string `json:"id_token"`
+	}{}
+	err = FromJSON(tr, resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("OIDCProvider: Exchange: Deserialization: %v", err)
+	}
+	if tr.IDToken == "" {
+		return "", errors.New("OIDCProvider: Exchange: no id_token in response")
+	}
+	return tr.IDToken, nil
+}
+
+// VerifyIDToken verifies the signature and the exp, nbf, iss, aud and nonce claims of the ID token
+// and returns the identity it asserts. The nonce of the authorization request is required.
+func (p *OIDCProvider) VerifyIDToken(idToken, nonce string) (*OIDCIdentity, error) {
+	if idToken == "" {
+		return nil, errors.New("VerifyIDToken: empty token")
+	}
+	if nonce == "" {
+		return nil, errors.New("VerifyIDToken: empty nonce")
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(idToken, claims, p.verifier.keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("VerifyIDToken: Invalid Token: %v", err)
+	}
+	if !token.Valid {
+		return nil, errors.New("VerifyIDToken: Invalid Token")
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(p.od.Issuer, "/") {
+		return nil, fmt.Errorf("VerifyIDToken: Invalid issuer: %q", iss)
+	}
+	if !containsClaim(claims["aud"], p.od.ClientID) {
+		return nil, fmt.Errorf("VerifyIDToken: Invalid audience: %v", claims["aud"])
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("VerifyIDToken: no exp claim")
+	}
+	if n, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(n), []byte(nonce)) != 1 {
+		return nil, errors.New("VerifyIDToken: Invalid nonce")
+	}
+
+	// the emails are only trusted when the provider asserts they are verified
+	id := &OIDCIdentity{Expires: time.Unix(int64(exp), 0)}
+	id.Subject, _ = claims["sub"].(string)
+	if id.Subject == "" {
+		return nil, errors.New("VerifyIDToken: no sub claim")
+	}
+	id.Email, _ = claims["email"].(string)
+	id.Name, _ = claims["name"].(string)
+	if v, ok := claims["email_verified"].(bool); ok {
+		id.EmailVerified = v
+	}
+	if p.od.GroupsClaim != "" {
+		id.Groups = claimStrings(claims[p.od.GroupsClaim])
+	}
+	return id, nil
+}
+
+// containsClaim returns true if the claim, a string or an array of strings, contains value
+func containsClaim(claim interface{}, value string) bool {
+	for _, s := range claimStrings(claim) {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// claimStrings returns the values of a claim that is a string or an array of strings
+func claimStrings(claim interface{}) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []interface{}:
+		values := []string{}
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// LoginOIDC defines the structure to login with an ID token issued by the OpenID Connect provider
+// swagger:model
+type LoginOIDC struct {
+	// the ID token received by the client on the authorization code flow
+	//
+	// required: true
+	IDToken string `json:"idToken" validate:"required"`
+
+	// the nonce of the authorization request of the ID token, each one is accepted once
+	//
+	// required: true
+	Nonce string `json:"nonce" validate:"required"`
+}
@@ -0,0 +1,217 @@
+package models
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicyData holds the rules of the passwords of the users
+type PasswordPolicyData struct {
+	// length in characters, MaxLength 0 is unlimited
+	MinLength int
+	MaxLength int
+	// number of classes - lowercase letters, uppercase letters, digits and symbols - that must be used
+	MinClasses int
+	// reject the passwords containing the name or the email of the user
+	DisallowPersonal bool
+	// breached passwords list, a file or a directory of range files, see NewBreachedPasswords
+	BreachedFile string
+}
+
+// PasswordPolicy checks the new passwords of the users
+type PasswordPolicy struct {
+	data     PasswordPolicyData
+	breached BreachedPasswords
+}
+
+// NewPasswordPolicy returns the policy of the data, loading the breached passwords list when configured
+func NewPasswordPolicy(d PasswordPolicyData) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{data: d}
+	if d.BreachedFile != "" {
+		b, err := NewBreachedPasswords(d.BreachedFile)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = b
+	}
+	return p, nil
+}
+
+// Check returns the messages of the rules the password of the user with the name and email does not
+// follow, none when it is valid, the error is only returned when the breached list can not be read
+func (p *PasswordPolicy) Check(password, name, email string) ([]string, error) {
+	var msgs []string
+	length := utf8.RuneCountInString(password)
+	if length < p.data.MinLength {
+		msgs = append(msgs, fmt.Sprintf("This field is too short (minimum is %d characters)", p.data.MinLength))
+	}
+	if p.data.MaxLength > 0 && length > p.data.MaxLength {
+		msgs = append(msgs, fmt.Sprintf("This field is too long (maximum is %d characters)", p.data.MaxLength))
+	}
+	if passwordClasses(password) < p.data.MinClasses {
+		msgs = append(msgs, fmt.Sprintf("Use at least %d of lowercase letters, uppercase letters, digits and symbols",
+			p.data.MinClasses))
+	}
+	if p.data.DisallowPersonal && containsPersonal(password, name, email) {
+		msgs = append(msgs, "The password cannot contain your name or email")
+	}
+	if p.breached != nil {
+		breached, err := p.breached.Breached(password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			msgs = append(msgs, "This password is on a list of breached passwords, please choose another one")
+		}
+	}
+	return msgs, nil
+}
+
+// passwordClasses returns the number of character classes used by the password
+func passwordClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower = 1
+		case unicode.IsUpper(c):
+			upper = 1
+		case unicode.IsDigit(c):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// personalMinLength is the length of the shortest part of the name or email checked on the passwords
+const personalMinLength = 3
+
+// containsPersonal returns true when the password contains a part of the name or of the email local part
+func containsPersonal(password, name, email string) bool {
+	password = strings.ToLower(password)
+	local := email
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		local = email[:i]
+	}
+	parts := []string{name, local}
+	isSeparator := func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}
+	parts = append(parts, strings.FieldsFunc(name, isSeparator)...)
+	parts = append(parts, strings.FieldsFunc(local, isSeparator)...)
+	for _, part := range parts {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if utf8.RuneCountInString(part) >= personalMinLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// BreachedPasswords checks the passwords against a list of breached ones
+type BreachedPasswords interface {
+	Breached(password string) (bool, error)
+}
+
+// NewBreachedPasswords returns the breached passwords list of the path, with the SHA-1 hashes of the passwords
+// in uppercase hexadecimal, optionally followed by ":count" as on the Pwned Passwords downloads.
+// When the path is a directory it holds a range file for each 5 characters prefix of the hashes, named by the
+// prefix, with the remaining 35 characters on each line - the k-anonymity format of the range API - and only
+// the range of the password is read on each check. Otherwise the path is a single file of full hashes loaded
+// into memory, suited for small lists.
+func NewBreachedPasswords(path string) (BreachedPasswords, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("NewBreachedPasswords: %v", err)
+	}
+	if fi.IsDir() {
+		return &breachedRanges{dir: path}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("NewBreachedPasswords: %v", err)
+	}
+	defer f.Close()
+	hashes := map[string]struct{}{}
+	err = scanHashes(f, func(hash string) bool {
+		if len(hash) == sha1.Size*2 {
+			hashes[hash] = struct{}{}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("NewBreachedPasswords: %s: %v", path, err)
+	}
+	return breachedHashes(hashes), nil
+}
+
+// passwordHash returns the SHA-1 hash of the password in uppercase hexadecimal
+func passwordHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// scanHashes calls fn with the uppercase hash of each line of the file until it returns true
+func scanHashes(f *os.File, fn func(hash string) bool) error {
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+		if line != "" && fn(strings.ToUpper(line)) {
+			return nil
+		}
+	}
+	return s.Err()
+}
+
+// breachedHashes is a list of full hashes kept in memory
+type breachedHashes map[string]struct{}
+
+// Breached returns true when the password is on the list
+func (b breachedHashes) Breached(password string) (bool, error) {
+	_, ok := b[passwordHash(password)]
+	return ok, nil
+}
+
+// breachedRanges is a directory of range files named by the prefix of the hashes
+type breachedRanges struct {
+	dir string
+}
+
+// Breached returns true when the password is on the range file of its hash prefix
+func (b *breachedRanges) Breached(password string) (bool, error) {
+	hash := passwordHash(password)
+	prefix, suffix := hash[:5], hash[5:]
+	f, err := os.Open(filepath.Join(b.dir, prefix))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(b.dir, prefix+".txt"))
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Breached: %v", err)
+	}
+	defer f.Close()
+	found := false
+	err = scanHashes(f, func(hash string) bool {
+		found = hash == suffix
+		return found
+	})
+	if err != nil {
+		return false, fmt.Errorf("Breached: %s: %v", prefix, err)
+	}
+	return found, nil
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	p, err := NewPasswordPolicy(PasswordPolicyData{MinLength: 10, MaxLength: 20, MinClasses: 3, DisallowPersonal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		user     string
+		email    string
+		wantMsgs []string
+	}{
+		{"Valid", "Tr0ub4dor&3x", "Alice Smith", "alice.smith@example.com", nil},
+		{"Too short", "Sh0rt!", "Alice", "alice@example.com", []string{"too short (minimum is 10"}},
+		{"Too long", "Correct-Horse-Battery-Staple-1", "Alice", "alice@example.com", []string{"too long (maximum is 20"}},
+		{"Length in characters", "Pässwörd-éé1", "Alice", "alice@example.com", nil},
+		{"Two classes", "lowercase123", "Alice", "alice@example.com", []string{"Use at least 3"}},
+		{"Symbols count as a class", "lowercase-!?1", "Alice", "alice@example.com", nil},
+		{"Name", "My-Alice-Pass1", "Alice", "bob@example.com", []string{"name or email"}},
+		{"Name of any case", "my-ALICE-pass1", "alice", "bob@example.com", []string{"name or email"}},
+		{"Part of the name", "Smith-Pass-123", "Alice Smith", "bob@example.com", []string{"name or email"}},
+		{"Email local part", "X-bob.jones-1", "Alice", "bob.jones@example.com", []string{"name or email"}},
+		{"Part of the email", "Jones-Pass-123", "Alice", "bob.jones@example.com", []string{"name or email"}},
+		{"Email domain", "Example-Pass-1", "Alice", "bob@example.com", nil},
+		{"Short name", "Al-Password-1", "Al", "al@example.com", nil},
+		{"Three letters name", "Bob-Password-1", "Bob", "robert@example.com", []string{"name or email"}},
+		{"Many rules", "alice", "Alice", "alice@example.com", []string{"too short", "Use at least 3", "name or email"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := p.Check(tt.password, tt.user, tt.email)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msgs) != len(tt.wantMsgs) {
+				t.Fatalf("want %d messages; got %q", len(tt.wantMsgs), msgs)
+			}
+			for i, want := range tt.wantMsgs {
+				if !strings.Contains(msgs[i], want) {
+					t.Errorf("want %q; got %q", want, msgs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyPersonalAllowed(t *testing.T) {
+	p, err := NewPasswordPolicy(PasswordPolicyData{MinLength: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := p.Check("alice-password", "Alice", "alice@example.com")
+	if err != nil || len(msgs) != 0 {
+		t.Errorf("want the name allowed; got %q, %v", msgs, err)
+	}
+}
+
+func TestPasswordClasses(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcDEF", 2},
+		{"abcDEF123", 3},
+		{"abcDEF123!", 4},
+		{"ÀÉÎ", 1},
+		{"   ", 1},
+	}
+	for _, tt := range tests {
+		if got := passwordClasses(tt.password); got != tt.want {
+			t.Errorf("%q: want %d; got %d", tt.password, tt.want, got)
+		}
+	}
+}
+
+// writeFile writes the lines to the file of the dir and returns its path
+func writeFile(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	// the hashes of the passwords on both formats, "password" is only on the list
+	// with the lowercase hash and "letmein" with the count of the Pwned Passwords downloads
+	hash := passwordHash("123456")
+	lower := strings.ToLower(passwordHash("password"))
+	counted := passwordHash("letmein") + ":42"
+
+	file := writeFile(t, t.TempDir(), "breached.txt", hash, lower, counted, "", "not a hash", "  "+passwordHash("qwerty")+"  ")
+	ranges := t.TempDir()
+	writeFile(t, ranges, hash[:5], hash[5:]+":10", strings.Repeat("0", 35)+":1")
+	writeFile(t, ranges, strings.ToUpper(lower[:5])+".txt", lower[5:])
+	writeFile(t, ranges, counted[:5], counted[5:])
+	writeFile(t, ranges, passwordHash("qwerty")[:5], "  "+passwordHash("qwerty")[5:]+"  ")
+
+	for _, path := range []string{file, ranges} {
+		b, err := NewBreachedPasswords(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			password string
+			want     bool
+		}{
+			{"123456", true},
+			{"password", true},
+			{"letmein", true},
+			{"qwerty", true},
+			{"Tr0ub4dor&3x", false},
+		}
+		for _, tt := range tests {
+			t.Run(filepath.Base(path)+"/"+tt.password, func(t *testing.T) {
+				got, err := b.Breached(tt.password)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Errorf("want %v; got %v", tt.want, got)
+				}
+			})
+		}
+	}
+}
+
+func TestBreachedPasswordsPolicy(t *testing.T) {
+	file := writeFile(t, t.TempDir(), "breached.txt", passwordHash("Password-123"))
+	p, err := NewPasswordPolicy(PasswordPolicyData{MinLength: 10, BreachedFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := p.Check("Password-123", "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "breached") {
+		t.Errorf("want the breached password rejected; got %q", msgs)
+	}
+}
+
+func TestBreachedPasswordsErrors(t *testing.T) {
+	dir := t.TempDir()
+	// a line longer than the scanner buffer is a malformed file
+	long := writeFile(t, dir, "long.txt", strings.Repeat("A", 70*1024))
+
+	if _, err := NewBreachedPasswords(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("want an error for a missing file; got nil")
+	}
+	if _, err := NewBreachedPasswords(long); err == nil {
+		t.Error("want an error for a malformed file; got nil")
+	}
+	if _, err := NewPasswordPolicy(PasswordPolicyData{BreachedFile: long}); err == nil {
+		t.Error("want the policy of a malformed file rejected; got nil")
+	}
+
+	// the passwords without a range file are not breached
+	ranges := t.TempDir()
+	b, err := NewBreachedPasswords(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if breached, err := b.Breached("123456"); err != nil || breached {
+		t.Errorf("want a password without range file not breached; got %v, %v", breached, err)
+	}
+
+	// a range file that can not be read fails the check
+	prefix := passwordHash("123456")[:5]
+	if err = os.Mkdir(filepath.Join(ranges, prefix), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.Breached("123456"); err == nil {
+		t.Error("want an error for an unreadable range file; got nil")
+	}
+	writeFile(t, ranges, passwordHash("letmein")[:5], strings.Repeat("A", 70*1024))
+	if _, err = b.Breached("letmein"); err == nil {
+		t.Error("want an error for a malformed range file; got nil")
+	}
+	p := &PasswordPolicy{breached: b}
+	if _, err = p.Check("123456", "Alice", "alice@example.com"); err == nil {
+		t.Error("want the error of the breached list returned by Check; got nil")
+	}
+}
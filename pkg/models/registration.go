@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrRegistrationClosed error if a user tries to register when the public registration is not enabled
+	ErrRegistrationClosed = errors.New("models: registration closed")
+	// ErrEmailDomainNotAllowed error if a user tries to register with an email domain that is not allowed
+	ErrEmailDomainNotAllowed = errors.New("models: email domain not allowed")
+)
+
+const (
+	// RegistrationClosed only administrators can create new users
+	RegistrationClosed = "closed"
+	// RegistrationVerify new users are activated after the verification of their email
+	RegistrationVerify = "verify"
+	// RegistrationApproval new users are activated after the approval of an administrator
+	RegistrationApproval = "approval"
+
+	// TokenPurposeVerifyEmail identifies the tokens sent to new users to verify their email
+	TokenPurposeVerifyEmail = "verify-email"
+)
+
+// RegistrationData public registration configuration data from config file
+type RegistrationData struct {
+	Mode                 string        // RegistrationClosed, RegistrationVerify or RegistrationApproval
+	AllowedDomains       []string      // when empty any email domain is allowed
+	DefaultRole          string        // role assigned to the registered users
+	VerifyEmailURL       string        // web page URL that receives the verification token
+	VerifyTokenValidTime time.Duration // number of minutes
+}
+
+// EmailDomainAllowed returns true if the domain of the email is one of the allowed domains
+// or if no domains are specified
+func EmailDomainAllowed(email string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[i+1:])
+	for _, d := range domains {
+		if domain == strings.ToLower(strings.TrimPrefix(d, "@")) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterUser defines the structure for the public registration of an user
+// swagger:model
+type RegisterUser struct {
+	// the name for this user
+	//
+	// required: true
+	// max length: 255
+	Name string `json:"name" validate:"required,max=255"`
+	// the email for this user
+	//
+	// required: true
+	// max length: 255
+	Email string `json:"email" validate:"required,email,max=255"`
+	// the password for this user
+	//
+	// it must also follow the password policy of the API
+	//
+	// required: true
+	// max length: 255
+	Password string `json:"password" validate:"required,max=255"`
+}
+
+// VerifyEmail defines the structure to verify the email of a registered user
+// swagger:model
+type VerifyEmail struct {
+	// the verification token received by email
+	//
+	// required: true
+	// max length: 255
+	Token string `json:"token" validate:"required,max=255"`
+}
+
+// UserApproval defines the structure for the administrator decision on a pending registration
+// swagger:model
+type UserApproval struct {
+	// true to activate the user, false to reject the registration
+	//
+	// required: true
+	Approved bool `json:"approved"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Session is a login of a user on a device, it lasts while its refresh tokens are rotated
+// swagger:model
+type Session struct {
+	// the id of the session
+	ID int `json:"id"`
+
+	// the id of the user of the session
+	UserID int `json:"userId"`
+
+	// the user agent of the client that logged in
+	UserAgent string `json:"userAgent"`
+
+	// the IP address of the client on the last use of the session
+	IP string `json:"ip"`
+
+	// the time of the login
+	Created time.Time `json:"created"`
+
+	// the time of the last login or token refresh of the session
+	LastSeen time.Time `json:"lastSeen"`
+
+	// the time the session expires if it is not used
+	Expires time.Time `json:"expires"`
+
+	// true for the session of the JWT used on the request
+	Current bool `json:"current"`
+}
+
+// Sessions manages the logins of the users, the access tokens carry the ID of their session
+// so that a revoked session stops being accepted before its tokens expire.
+type Sessions interface {
+	// New starts a session of the user that expires after validTime and returns its ID
+	New(ctx context.Context, userID int, userAgent, ip string, validTime time.Duration) (int, error)
+	// Touch updates the last seen time and IP of the session and extends its expiration
+	Touch(ctx context.Context, id int, ip string, validTime time.Duration) error
+	// GetAll returns the sessions of the user that are not revoked nor expired
+	GetAll(ctx context.Context, userID int) ([]*Session, error)
+	// Revoke revokes the session of the user and its refresh tokens,
+	// ErrNoRecord is returned when the session is unknown
+	Revoke(ctx context.Context, userID, id int) error
+	// Active returns true when the session is not revoked nor expired
+	Active(ctx context.Context, id int) (bool, error)
+}
@@ -1,20 +1,23 @@
 package models
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type UnauthotizedSnippets interface {
-	Get(int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Get(context.Context, int) (*Snippet, error)
+	Latest(context.Context) ([]*Snippet, error)
 }
 
 type Snippets interface {
 	UnauthotizedSnippets
-	Insert(string, string, string) (int, error)
+	Insert(context.Context, string, string, string) (int, error)
 }
 
 type APISnippets interface {
 	UnauthotizedSnippets
-	Insert(string, string, string, string) (int, error)
+	Insert(context.Context, string, string, string, string) (int, error)
 }
 
 // Snippet defines the structure for an API snippet
@@ -0,0 +1,378 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLoginLocked is matched by the LoginLockedError returned while the logins are blocked
+var ErrLoginLocked = errors.New("models: too many failed logins")
+
+// LoginLockedError is returned while the logins of an account or address are blocked
+type LoginLockedError struct {
+	// the time to wait before the next login is accepted
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrLoginLocked, e.RetryAfter)
+}
+
+// Is allows errors.Is(err, ErrLoginLocked)
+func (e *LoginLockedError) Is(target error) bool {
+	return target == ErrLoginLocked
+}
+
+// RetryAfterSeconds returns the value of the Retry-After header, rounded up to the next second
+func (e *LoginLockedError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// LoginAttempt holds the failed logins of an account or address
+type LoginAttempt struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// LoginAttempts stores the failed logins
+type LoginAttempts interface {
+	// Get returns the failed logins of the key, ErrNoRecord when there are none
+	Get(ctx context.Context, key string) (*LoginAttempt, error)
+	// AddFailure increments the failures of the key, restarting the count when
+	// the last failure is older than resetAfter, and returns the updated record
+	AddFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*LoginAttempt, error)
+	// RemoveFailure decrements the failures of the key, used to give back a reserved attempt
+	RemoveFailure(ctx context.Context, key string) error
+	// Lock blocks the logins of the key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Delete removes the failed logins of the key
+	Delete(ctx context.Context, key string) error
+}
+
+// ThrottleData holds the limits of the failed logins
+type ThrottleData struct {
+	// failures of an account accepted before the backoff starts
+	FreeAttempts int
+	// failures of an account that lock it for LockoutTime
+	LockoutAttempts int
+	// failures of an address, higher than the account ones as an address can be shared
+	IPFreeAttempts    int
+	IPLockoutAttempts int
+	// delay after the first failure beyond the free ones, doubled on each failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// time the logins are blocked after the lockout attempts
+	LockoutTime time.Duration
+	// failures older than this are forgotten
+	ResetAfter time.Duration
+}
+
+// LoginThrottle applies exponential backoff and temporary lockout to the failed logins
+// of the accounts and of the addresses of the clients. A login reserves its attempt before
+// the password is verified, then it calls Fail or Succeed, or Release when it was not verified.
+type LoginThrottle struct {
+	store LoginAttempts
+	data  ThrottleData
+	now   func() time.Time
+}
+
+// NewLoginThrottle returns a throttle keeping the failed logins on the store
+func NewLoginThrottle(store LoginAttempts, d ThrottleData) *LoginThrottle {
+	return &LoginThrottle{store: store, data: d, now: time.Now}
+}
+
+// AccountKey returns the key of the failed logins of the email
+func AccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPKey returns the key of the failed logins of the address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ClientIP returns the address of the client of the request, the X-Forwarded-For header is only
+// used when the request comes from one of the trusted proxies, and returns an empty string when
+// a trusted proxy does not send it so the proxy itself is not throttled
+func ClientIP(r *http.Request, trustedProxies []string) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !contains(trustedProxies, ip) {
+		return ip
+	}
+	// the rightmost address not added by a trusted proxy is the client
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if addr != "" && !contains(trustedProxies, addr) {
+			return addr
+		}
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a *LoginLockedError when the logins of one of the keys are blocked, empty keys are ignored
+func (t *LoginThrottle) Check(ctx context.Context, keys ...string) error {
+	now := t.now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		a, err := t.store.Get(ctx, key)
+		if errors.Is(err, ErrNoRecord) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if wait := a.LockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Reserve counts the login as a failure of the keys before the password is verified, and returns
+// a *LoginLockedError when the logins of one of the keys are blocked. The count is incremented by
+// the store so the parallel logins beyond the lockout attempts are refused, after a lockout only
+// one login is accepted until the next one.
+func (t *LoginThrottle) Reserve(ctx context.Context, keys ...string) error {
+	err := t.Check(ctx, keys...)
+	if err != nil {
+		return err
+	}
+	now := t.now()
+	for i, key := range keys {
+		if key == "" {
+			continue
+		}
+		a, err := t.store.AddFailure(ctx, key, now, t.data.ResetAfter)
+		if err != nil {
+			t.Release(ctx, keys[:i]...)
+			return err
+		}
+		_, lockout := t.limits(key)
+		afterLockout := a.Failures == lockout+1 && !a.LockedUntil.IsZero() && !a.LockedUntil.After(now)
+		if lockout <= 0 || a.Failures <= lockout || afterLockout {
+			continue
+		}
+		// another login holds the last attempt, its failure sets the lockout
+		if err := t.Release(ctx, keys[:i+1]...); err != nil {
+			return err
+		}
+		return &LoginLockedError{RetryAfter: t.data.LockoutTime}
+	}
+	return nil
+}
+
+// Fail applies the backoff of the failures reserved on the keys and returns a *LoginLockedError
+// when the next login must wait
+func (t *LoginThrottle) Fail(ctx context.Context, keys ...string) error {
+	now := t.now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		a, err := t.store.Get(ctx, key)
+		if errors.Is(err, ErrNoRecord) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		free, lockout := t.limits(key)
+		// the attempt accepted after a lockout keeps the count on the lockout attempts
+		if lockout > 0 && a.Failures > lockout {
+			if err := t.store.RemoveFailure(ctx, key); err != nil {
+				return err
+			}
+		}
+		wait := t.delay(a.Failures, free, lockout)
+		if wait <= 0 {
+			continue
+		}
+		err = t.store.Lock(ctx, key, now.Add(wait))
+		if err != nil {
+			return err
+		}
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// limits returns the free and lockout attempts of the key, the account keys start with "account:"
+// and the others use the IP limits
+func (t *LoginThrottle) limits(key string) (free, lockout int) {
+	if strings.HasPrefix(key, "ip:") {
+		return t.data.IPFreeAttempts, t.data.IPLockoutAttempts
+	}
+	return t.data.FreeAttempts, t.data.LockoutAttempts
+}
+
+// delay returns the time the logins are blocked after the given failures
+func (t *LoginThrottle) delay(failures, free, lockout int) time.Duration {
+	if lockout > 0 && failures >= lockout {
+		return t.data.LockoutTime
+	}
+	if failures <= free {
+		return 0
+	}
+	delay := t.data.BaseDelay
+	for i := free + 1; i < failures && delay < t.data.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.data.MaxDelay {
+		delay = t.data.MaxDelay
+	}
+	return delay
+}
+
+// Succeed forgets the failed logins of the account keys after a valid login, the attempt
+// reserved on the other keys is given back
+func (t *LoginThrottle) Succeed(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		var err error
+		if strings.HasPrefix(key, "account:") {
+			err = t.store.Delete(ctx, key)
+		} else {
+			err = t.store.RemoveFailure(ctx, key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release gives back the attempt reserved on the keys by a login that did not verify the password
+func (t *LoginThrottle) Release(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := t.store.RemoveFailure(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unlock removes the lockout and the failed logins of the key
+func (t *LoginThrottle) Unlock(ctx context.Context, key string) error {
+	return t.store.Delete(ctx, key)
+}
+
+// memoryPruneSize is the number of keys that triggers the removal of the expired ones
+const memoryPruneSize = 10000
+
+// MemoryLoginAttempts keeps the failed logins in memory, they are lost on restart
+// and not shared between instances
+type MemoryLoginAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]LoginAttempt
+}
+
+// NewMemoryLoginAttempts returns an empty in-memory store
+func NewMemoryLoginAttempts() *MemoryLoginAttempts {
+	return &MemoryLoginAttempts{attempts: map[string]LoginAttempt{}}
+}
+
+// Get returns the failed logins of the key, ErrNoRecord when there are none
+func (m *MemoryLoginAttempts) Get(ctx context.Context, key string) (*LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.attempts[key]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+	return &a, nil
+}
+
+// AddFailure increments the failures of the key, restarting the count when the last failure is older than resetAfter
+func (m *MemoryLoginAttempts) AddFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.attempts) >= memoryPruneSize {
+		m.prune(now, resetAfter)
+	}
+	a, ok := m.attempts[key]
+	if !ok || now.Sub(a.LastFailure) > resetAfter {
+		a = LoginAttempt{Key: key, LockedUntil: a.LockedUntil}
+	}
+	a.Failures++
+	a.LastFailure = now
+	m.attempts[key] = a
+	return &a, nil
+}
+
+// prune removes the keys with old failures and no lockout
+func (m *MemoryLoginAttempts) prune(now time.Time, resetAfter time.Duration) {
+	for key, a := range m.attempts {
+		if now.Sub(a.LastFailure) > resetAfter && now.After(a.LockedUntil) {
+			delete(m.attempts, key)
+		}
+	}
+}
+
+// RemoveFailure decrements the failures of the key
+func (m *MemoryLoginAttempts) RemoveFailure(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a, ok := m.attempts[key]; ok && a.Failures > 0 {
+		a.Failures--
+		m.attempts[key] = a
+	}
+	return nil
+}
+
+// Lock blocks the logins of the key until the given time
+func (m *MemoryLoginAttempts) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.attempts[key]
+	a.Key = key
+	a.LockedUntil = until
+	m.attempts[key] = a
+	return nil
+}
+
+// Delete removes the failed logins of the key
+func (m *MemoryLoginAttempts) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, key)
+	return nil
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestThrottle returns a throttle of the memory store with a clock moved by the tests
+func newTestThrottle() (*LoginThrottle, *MemoryLoginAttempts, *time.Time) {
+	store := NewMemoryLoginAttempts()
+	t := NewLoginThrottle(store, ThrottleData{
+		FreeAttempts:      1,
+		LockoutAttempts:   3,
+		IPFreeAttempts:    10,
+		IPLockoutAttempts: 20,
+		BaseDelay:         time.Second,
+		MaxDelay:          time.Minute,
+		LockoutTime:       time.Hour,
+		ResetAfter:        24 * time.Hour,
+	})
+	now := time.Now()
+	t.now = func() time.Time { return now }
+	return t, store, &now
+}
+
+// failures returns the failures of the key on the store
+func failures(t *testing.T, store *MemoryLoginAttempts, key string) int {
+	t.Helper()
+	a, err := store.Get(context.Background(), key)
+	if errors.Is(err, ErrNoRecord) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.Failures
+}
+
+func TestLoginThrottleReserveParallel(t *testing.T) {
+	throttle, _, _ := newTestThrottle()
+	key := AccountKey("alice@example.com")
+
+	// the parallel logins are all counted before any of them fails
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := throttle.Reserve(context.Background(), key)
+			if err != nil && !errors.Is(err, ErrLoginLocked) {
+				t.Error(err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if reserved != 3 {
+		t.Errorf("want the lockout attempts reserved; got %d", reserved)
+	}
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	throttle, store, now := newTestThrottle()
+	ctx := context.Background()
+	key := AccountKey("alice@example.com")
+
+	// the backoff after the free attempt and the lockout after the third failure
+	wantWaits := []time.Duration{0, time.Second, time.Hour}
+	for i, want := range wantWaits {
+		if err := throttle.Reserve(ctx, key); err != nil {
+			t.Fatalf("attempt %d: want it reserved; got %v", i+1, err)
+		}
+		var locked *LoginLockedError
+		err := throttle.Fail(ctx, key)
+		switch {
+		case want == 0 && err != nil:
+			t.Errorf("attempt %d: want no wait; got %v", i+1, err)
+		case want > 0 && (!errors.As(err, &locked) || locked.RetryAfter != want):
+			t.Errorf("attempt %d: want a wait of %v; got %v", i+1, want, err)
+		}
+		*now = now.Add(2 * time.Second)
+	}
+	if err := throttle.Reserve(ctx, key); !errors.Is(err, ErrLoginLocked) {
+		t.Errorf("want the login refused while locked; got %v", err)
+	}
+
+	// a single login is accepted after the lockout and its failure locks the account again
+	*now = now.Add(time.Hour)
+	if err := throttle.Reserve(ctx, key); err != nil {
+		t.Fatalf("want a login after the lockout; got %v", err)
+	}
+	if err := throttle.Reserve(ctx, key); !errors.Is(err, ErrLoginLocked) {
+		t.Errorf("want a second login refused; got %v", err)
+	}
+	var locked *LoginLockedError
+	if err := throttle.Fail(ctx, key); !errors.As(err, &locked) || locked.RetryAfter != time.Hour {
+		t.Errorf("want the lockout again; got %v", err)
+	}
+	if n := failures(t, store, key); n != 3 {
+		t.Errorf("want the failures kept on the lockout attempts; got %d", n)
+	}
+}
+
+func TestLoginThrottleSucceed(t *testing.T) {
+	throttle, store, _ := newTestThrottle()
+	ctx := context.Background()
+	account, ip := AccountKey("alice@example.com"), IPKey("192.0.2.1")
+
+	if err := throttle.Reserve(ctx, account, ip); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Fail(ctx, account, ip); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Reserve(ctx, account, ip, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Succeed(ctx, account, ip, ""); err != nil {
+		t.Fatal(err)
+	}
+	if n := failures(t, store, account); n != 0 {
+		t.Errorf("want the failures of the account forgotten; got %d", n)
+	}
+	if n := failures(t, store, ip); n != 1 {
+		t.Errorf("want the failure of the address kept and the reserved attempt given back; got %d", n)
+	}
+
+	// a login that did not verify the password gives back its attempt
+	if err := throttle.Reserve(ctx, account, ip); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Release(ctx, account, ip); err != nil {
+		t.Fatal(err)
+	}
+	if n := failures(t, store, account); n != 0 {
+		t.Errorf("want no failure of the account; got %d", n)
+	}
+	if n := failures(t, store, ip); n != 1 {
+		t.Errorf("want the failure of the address; got %d", n)
+	}
+}
@@ -1,38 +1,98 @@
 package models
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
 
 type UnauthotizedUsers interface {
-	Authenticate(string, string) (int, error)
+	Authenticate(context.Context, string, string) (int, error)
 }
 
 type Users interface {
 	UnauthotizedUsers
-	Insert(string, string, string, []int) error
-	Get(int) (*User, error)
-	GetAll() ([]*User, error)
-	ChangePassword(int, string, string) error
-	ResetPassword(int, string) error
-	GetRoleTypes() ([]*RoleType, error)
-	GetRoles(int) (*[]string, error)
+	Insert(context.Context, string, string, string, []int) error
+	// Register inserts an inactive user, waiting for approval when the bool parameter is true, and returns its ID
+	Register(context.Context, string, string, string, []int, bool) (int, error)
+	Activate(context.Context, int) error
+	GetPending(context.Context) ([]*User, error)
+	// Approve activates the pending user when the bool parameter is true, otherwise rejects the registration
+	Approve(context.Context, int, bool) error
+	Get(context.Context, int) (*User, error)
+	GetByEmail(context.Context, string) (*User, error)
+	GetAll(context.Context) ([]*User, error)
+	ChangePassword(context.Context, int, string, string) error
+	ResetPassword(context.Context, int, string) error
+	// ResetPasswordByToken uses the reset password token and replaces the password of its user in a single
+	// transaction, returns the ID of the user and ErrInvalidToken when the token is not valid
+	ResetPasswordByToken(ctx context.Context, token, newPassword string) (int, error)
+	GetRoleTypes(context.Context) ([]*RoleType, error)
+	GetRoles(context.Context, int) (*[]string, error)
+	// AddRoles grants the roles the user does not have yet
+	AddRoles(context.Context, int, []int) error
+	// Provision inserts an active user without an usable password and returns its ID,
+	// used for the users of an OpenID Connect provider
+	Provision(context.Context, string, string, []int) (int, error)
+	// GetByIdentity returns the user linked to the subject of an OpenID Connect issuer
+	GetByIdentity(ctx context.Context, issuer, subject string) (*User, error)
+	// LinkIdentity links the user to the subject of an OpenID Connect issuer
+	LinkIdentity(ctx context.Context, id int, issuer, subject string) error
+	// SetRoleMFARequired defines if the users of the role must use two-factor authentication
+	SetRoleMFARequired(ctx context.Context, id int, required bool) error
 }
 
 type APIUnauthotizedUsers interface {
-	Authenticate(email, password string) (token string, err error)
+	// Authenticate returns a *MFAChallenge as the error when the login requires two-factor authentication
+	// and a *LoginLockedError when the logins are blocked after too many failures
+	Authenticate(ctx context.Context, email, password string) (*TokenMessage, error)
+	// RefreshToken exchanges a refresh token for a new token message with rotated tokens
+	RefreshToken(ctx context.Context, refreshToken string) (*TokenMessage, error)
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPasswordWithToken(ctx context.Context, token, newPassword string) error
+	// Register returns the API message describing how the new account will be activated
+	Register(ctx context.Context, name, email, password string) (message string, err error)
+	VerifyEmail(ctx context.Context, token string) error
+	// AuthenticateOIDC exchanges an ID token of the OpenID Connect provider and the nonce of its
+	// authorization request for a token message
+	AuthenticateOIDC(ctx context.Context, idToken, nonce string) (*TokenMessage, error)
+	// VerifyMFA completes the login of the challenge with a TOTP or recovery code
+	VerifyMFA(ctx context.Context, challenge, code string) (*TokenMessage, error)
+	// EnrollMFAChallenge starts the enrollment required to complete the login of the challenge
+	EnrollMFAChallenge(ctx context.Context, challenge string) (*MFAEnrollment, error)
 }
 
 type APIUsers interface {
 	APIUnauthotizedUsers
 	// the first string parameter is a valid token for the API
-	Insert(string, string, string, string, []int) error
-	Get(string, int) (*User, error)
-	GetAll(string) ([]*User, error)
-	ChangePassword(string, int, string, string) error
-	GetRoleTypes(string) ([]*RoleType, error)
-	GetRoles(string, int) (*[]string, error)
+	Insert(context.Context, string, string, string, string, []int) error
+	Get(context.Context, string, int) (*User, error)
+	GetAll(context.Context, string) ([]*User, error)
+	ChangePassword(context.Context, string, int, string, string) error
+	GetRoleTypes(context.Context, string) ([]*RoleType, error)
+	GetRoles(context.Context, string, int) (*[]string, error)
+	GetPending(context.Context, string) ([]*User, error)
+	Approve(context.Context, string, int, bool) error
+	// Logout revokes the token and the refresh token
+	Logout(ctx context.Context, token, refreshToken string) error
+	// GetAPIKeys, CreateAPIKey and RevokeAPIKey manage the personal API keys of the user
+	GetAPIKeys(ctx context.Context, token string, id int) ([]*APIKey, error)
+	CreateAPIKey(ctx context.Context, token string, id int, ck *CreateAPIKey) (*NewAPIKeyMessage, error)
+	RevokeAPIKey(ctx context.Context, token string, id, keyID int) error
+	// GetMFA, EnrollMFA, ConfirmMFA and DisableMFA manage the two-factor authentication of the user
+	GetMFA(ctx context.Context, token string, id int) (*MFAStatus, error)
+	EnrollMFA(ctx context.Context, token string, id int) (*MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, token string, id int, code string) ([]string, error)
+	DisableMFA(ctx context.Context, token string, id int, code string) error
+	// SetRoleMFA defines if the users of the role must use two-factor authentication
+	SetRoleMFA(ctx context.Context, token string, roleID int, required bool) error
+	// UnlockUser removes the lockout of the logins of the user
+	UnlockUser(ctx context.Context, token string, id int) error
+	// GetSessions and RevokeSession list and sign out the sessions of the user
+	GetSessions(ctx context.Context, token string, id int) ([]*Session, error)
+	RevokeSession(ctx context.Context, token string, id, sessionID int) error
+	// WithClient returns the users forwarding the IP address and the user agent of the client to the API
+	WithClient(ip, userAgent string) APIUsers
 }
 
 const (
@@ -106,6 +166,11 @@ type RoleType struct {
 	//
 	// required: false
 	Created time.Time `json:"created"`
+
+	// true when the users of the role must use two-factor authentication
+	//
+	// required: false
+	MFARequired bool `json:"mfaRequired"`
 }
 
 // UserRoleDetail defines the structure for roles of an user
@@ -166,9 +231,8 @@ type LoginUser struct {
 	// the username for this user
 	//
 	// required: true
-	// max length: 60
-	// min length: 10
-	Password string `json:"password" validate:"required,min=10,max=60"`
+	// max length: 255
+	Password string `json:"password" validate:"required,max=255"`
 }
 
 // CreateUser defines the structure for creating an user
@@ -187,10 +251,11 @@ type CreateUser struct {
 	Email string `json:"email" validate:"required,email"`
 	// the username for this user
 	//
+	// it must also follow the password policy of the API
+	//
 	// required: true
-	// max length: 60
-	// min length: 10
-	Password string `json:"password" validate:"required,min=10,max=60"`
+	// max length: 255
+	Password string `json:"password" validate:"required,max=255"`
 	// the int slice of role ID's for this user
 	//
 	// required: false
@@ -203,13 +268,60 @@ type ChangeUserPassword struct {
 	// the old password for this user
 	//
 	// required: true
-	// max length: 60
-	// min length: 10
-	OldPassword string `json:"oldPassword" validate:"required,min=10,max=60"`
+	// max length: 255
+	OldPassword string `json:"oldPassword" validate:"required,max=255"`
 	// the new password for this user
 	//
+	// it must also follow the password policy of the API
+	//
 	// required: true
-	// max length: 60
-	// min length: 10
-	NewPassword string `json:"newPassword" validate:"required,min=10,max=60"`
+	// max length: 255
+	NewPassword string `json:"newPassword" validate:"required,max=255"`
+}
+
+// ForgotPassword defines the structure to request a password reset email
+// swagger:model
+type ForgotPassword struct {
+	// the email of the user that forgot the password
+	//
+	// required: true
+	// max length: 255
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// ResetUserPassword defines the structure to reset a password with the token sent by email
+// swagger:model
+type ResetUserPassword struct {
+	// the reset token received by email
+	//
+	// required: true
+	// max length: 255
+	Token string `json:"token" validate:"required,max=255"`
+	// the new password for this user
+	//
+	// it must also follow the password policy of the API
+	//
+	// required: true
+	// max length: 255
+	NewPassword string `json:"newPassword" validate:"required,max=255"`
+}
+
+// RefreshToken defines the structure to obtain a new access token with a refresh token
+// swagger:model
+type RefreshToken struct {
+	// the refresh token received on login or on the last refresh
+	//
+	// required: true
+	// max length: 255
+	RefreshToken string `json:"refreshToken" validate:"required,max=255"`
+}
+
+// LogoutUser defines the structure to logout an user
+// swagger:model
+type LogoutUser struct {
+	// the refresh token to revoke
+	//
+	// required: false
+	// max length: 255
+	RefreshToken string `json:"refreshToken" validate:"max=255"`
 }
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidToken error if a single use token is unknown, expired or was already used
+	ErrInvalidToken = errors.New("models: invalid or expired token")
+	// ErrPasswordResetDisabled error if the password reset by email is not configured
+	ErrPasswordResetDisabled = errors.New("models: password reset disabled")
+)
+
+const (
+	// TokenPurposeResetPassword identifies the tokens sent to users that forgot their password
+	TokenPurposeResetPassword = "reset-password"
+)
+
+// UserTokens manages the single use and time limited tokens that are sent to the users by email.
+// Only the hash of the tokens is stored, the plain-text value is returned once on creation.
+type UserTokens interface {
+	// New creates a token for the user with the given purpose and valid time and returns its plain-text value.
+	// Any previous unused token of the same user and purpose is invalidated.
+	New(ctx context.Context, userID int, purpose string, validTime time.Duration) (string, error)
+	// Consume validates the token for the given purpose, marks it as used and returns the ID of its user
+	Consume(ctx context.Context, token, purpose string) (int, error)
+	// Peek validates the token for the given purpose and returns the ID of its user without using it
+	Peek(ctx context.Context, token, purpose string) (int, error)
+	// HasUnused returns true when the last token of the purpose created for the user was not used,
+	// even if it expired
+	HasUnused(ctx context.Context, userID int, purpose string) (bool, error)
+}
+
+// NewRandomToken returns a new URL safe random token with 256 bits of entropy
+func NewRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash of the token, the value that is stored in the database
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
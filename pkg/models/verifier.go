@@ -0,0 +1,154 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"sync"
+	"time"
+)
+
+// jwksMinRefreshTime minimum time between the fetches of the keys triggered by unknown key IDs
+const jwksMinRefreshTime = time.Minute
+
+// TokenVerifier is implemented by the clients of the API able to verify the tokens locally
+type TokenVerifier interface {
+	// Verify verifies the token and returns its claims, ErrExpiredToken is returned
+	// when the only problem of the token is its expiration
+	Verify(tokenString string) (*MyCustomClaims, error)
+}
+
+// VerifierData token verification data from config file
+type VerifierData struct {
+	Issuer     string                  // expected iss claim
+	Audience   string                  // expected aud claim
+	SigningKey string                  // shared key of the HS256 tokens
+	FetchKeys  func() (*JWKSet, error) // returns the keys published by the API, used when SigningKey is empty
+}
+
+// VerifierModel verifies the tokens with the shared key or with the keys published by the API.
+// The keys are fetched again when a token signed by an unknown key is received.
+type VerifierModel struct {
+	vd      *VerifierData
+	mu      sync.RWMutex
+	keys    map[string]*tokenKey
+	fetched time.Time
+}
+
+// NewVerifierModel creates a new VerifierModel
+func NewVerifierModel(d *VerifierData) *VerifierModel {
+	return &VerifierModel{vd: d, keys: map[string]*tokenKey{}}
+}
+
+// Verify verifies the signature and the exp, nbf, iss and aud claims of the token and returns its claims
+func (v *VerifierModel) Verify(tokenString string) (*MyCustomClaims, error) {
+	if tokenString == "" {
+		return nil, fmt.Errorf("Verify: empty token")
+	}
+
+	claims := &MyCustomClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, v.keyFunc)
+	if err != nil {
+		// the signature is verified even when the token is expired
+		var ve *jwt.ValidationError
+		if errors.As(err, &ve) && ve.Errors == jwt.ValidationErrorExpired {
+			return nil, ErrExpiredToken
+		}
+		return nil, fmt.Errorf("Verify: Invalid Token: %v", err)
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("Verify: Invalid Token")
+	}
+	err = claims.verifyIssuerAudience(v.vd.Issuer, v.vd.Audience)
+	if err != nil {
+		return nil, fmt.Errorf("Verify: %v", err)
+	}
+	if claims.User == nil {
+		return nil, fmt.Errorf("Verify: no user in token claims")
+	}
+	return claims, nil
+}
+
+// keyFunc returns the key that verifies the token
+func (v *VerifierModel) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if v.vd.SigningKey == "" || token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(v.vd.SigningKey), nil
+	}
+	if v.vd.SigningKey != "" {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	k, err := v.key(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != k.alg {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return k.key, nil
+}
+
+// key returns the key with the key ID, the keys are fetched when the key is unknown
+func (v *VerifierModel) key(kid string) (*tokenKey, error) {
+	v.mu.RLock()
+	k, ok := v.keys[kid]
+	v.mu.RUnlock()
+	if ok {
+		return k, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	if time.Since(v.fetched) < jwksMinRefreshTime {
+		return nil, fmt.Errorf("Unknown key ID: %q", kid)
+	}
+	err := v.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	k, ok = v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key ID: %q", kid)
+	}
+	return k, nil
+}
+
+// fetchKeys replaces the known keys with the keys published by the API, must be called with the lock held
+func (v *VerifierModel) fetchKeys() error {
+	v.fetched = time.Now()
+	if v.vd.FetchKeys == nil {
+		return errors.New("fetchKeys: no keys available")
+	}
+	jwks, err := v.vd.FetchKeys()
+	if err != nil {
+		return fmt.Errorf("fetchKeys: %v", err)
+	}
+	keys := map[string]*tokenKey{}
+	for _, j := range jwks.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		pub, err := j.PublicKey()
+		if err != nil {
+			return fmt.Errorf("fetchKeys: %q: %v", j.Kid, err)
+		}
+		tk, err := newTokenKey(pub)
+		if err != nil {
+			return fmt.Errorf("fetchKeys: %q: %v", j.Kid, err)
+		}
+		if j.Alg != "" && j.Alg != tk.alg {
+			return fmt.Errorf("fetchKeys: %q: unexpected algorithm %q", j.Kid, j.Alg)
+		}
+		// keep the key ID published by the issuer
+		keys[j.Kid] = tk
+	}
+	v.keys = keys
+	return nil
+}